@@ -40,7 +40,7 @@ func setupTestDatabase(t *testing.T) (*Database, func()) {
 		IsActive:  true,
 		CreatedAt: time.Now(),
 	}
-	err = db.SaveSeason(season)
+	err = db.SaveSeason(season, "admin")
 	if err != nil {
 		db.Close()
 		os.Remove(tmpfile.Name())
@@ -56,7 +56,7 @@ func setupTestDatabase(t *testing.T) (*Database, func()) {
 		IsDefault:     true,
 		CreatedAt:     time.Now(),
 	}
-	err = db.SaveTrack(track)
+	err = db.SaveTrack(track, "admin")
 	if err != nil {
 		db.Close()
 		os.Remove(tmpfile.Name())
@@ -88,7 +88,7 @@ func createTestRegistration(t *testing.T, db *Database, seasonID string) *Regist
 		RegisteredAt:        time.Now(),
 	}
 
-	err := db.SaveRegistration(reg)
+	err := db.SaveRegistration(reg, "admin")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -352,12 +352,12 @@ func TestAPIScanHandlerDatabaseLockContention(t *testing.T) {
 
 			// Measure request duration
 			start := time.Now()
-			
+
 			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				session.Save(r, w)
 				apiScanHandler(w, r)
 			})
-			
+
 			handler.ServeHTTP(rr, req)
 			duration := time.Since(start)
 
@@ -422,7 +422,7 @@ func TestAPIScanHandlerSlowQueries(t *testing.T) {
 	t.Log("Creating test data...")
 	for i := 0; i < 100; i++ {
 		reg := createTestRegistration(t, db, activeSeason.ID)
-		
+
 		// Add some scan records for each registration
 		for j := 0; j < 5; j++ {
 			// Use raw SQL to bypass the debounce logic
@@ -462,12 +462,12 @@ func TestAPIScanHandlerSlowQueries(t *testing.T) {
 
 	// Measure request duration
 	start := time.Now()
-	
+
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		session.Save(r, w)
 		apiScanHandler(w, r)
 	})
-	
+
 	// Run with timeout
 	done := make(chan bool)
 	go func() {
@@ -479,7 +479,7 @@ func TestAPIScanHandlerSlowQueries(t *testing.T) {
 	case <-done:
 		duration := time.Since(start)
 		t.Logf("Request completed in %v", duration)
-		
+
 		// Check if it took too long (potential hang)
 		if duration > 2*time.Second {
 			t.Errorf("Request took too long: %v", duration)
@@ -498,4 +498,255 @@ func TestAPIScanHandlerSlowQueries(t *testing.T) {
 	if !result.Success {
 		t.Errorf("Expected success, got error: %s", result.Message)
 	}
-}
\ No newline at end of file
+}
+
+func TestAPIScansBatchHandler(t *testing.T) {
+	// Save original database and restore after tests
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	// Setup test database
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	// Get the active season
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := createTestRegistration(t, db, activeSeason.ID)
+
+	// Initialize test session store
+	store = sessions.NewCookieStore([]byte("test-secret"))
+
+	// Scans queued offline, sent out of order. The test track is 1 mile,
+	// so scans less than 5 minutes apart are too fast.
+	base := time.Now().Add(-time.Hour)
+	scans := []BatchScanItem{
+		{IdempotencyKey: "lap-2", Code: reg.ID, ScannedAt: base.Add(12 * time.Minute)},
+		{IdempotencyKey: "lap-1", Code: reg.ID, ScannedAt: base},
+		{IdempotencyKey: "lap-2-double", Code: reg.ID, ScannedAt: base.Add(13 * time.Minute)},
+		{IdempotencyKey: "stranger", Code: uuid.New().String(), ScannedAt: base},
+		{IdempotencyKey: "", Code: reg.ID, ScannedAt: base},
+	}
+
+	sendBatch := func(items []BatchScanItem) []BatchScanItemResult {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{"scans": items})
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/batch", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		session, _ := store.Get(req, "run-club-session")
+		session.Values["username"] = "testuser"
+		session.Values["role"] = RoleScanner
+		rr := httptest.NewRecorder()
+		apiScansBatchHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Results []BatchScanItemResult `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(response.Results) != len(items) {
+			t.Fatalf("Expected %d results, got %d", len(items), len(response.Results))
+		}
+		return response.Results
+	}
+
+	results := sendBatch(scans)
+	expected := []string{BatchScanRecorded, BatchScanRecorded, BatchScanRejectedTooFast, BatchScanUnknownRunner, BatchScanInvalid}
+	for i, want := range expected {
+		if results[i].Status != want {
+			t.Errorf("Scan %d (%s): expected status %s, got %s (%s)", i, scans[i].IdempotencyKey, want, results[i].Status, results[i].Message)
+		}
+	}
+
+	// Lap time uses the client timestamps, not the time the batch arrived
+	if results[0].LapTime == nil || *results[0].LapTime < 11.9 || *results[0].LapTime > 12.1 {
+		t.Errorf("Expected 12 minute lap time, got %v", results[0].LapTime)
+	}
+
+	// Replaying the queue is safe
+	results = sendBatch(scans[:2])
+	for i, result := range results {
+		if result.Status != BatchScanDuplicate {
+			t.Errorf("Replayed scan %d: expected status %s, got %s", i, BatchScanDuplicate, result.Status)
+		}
+	}
+
+	// A lap queued in another zone is checked against a live scan by the
+	// time it happened, not by how its zone's clock reads
+	if _, _, err := db.RecordScan(reg.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, offset := time.Now().Zone()
+	elsewhere := time.FixedZone("Elsewhere", offset+10*60*60)
+	results = sendBatch([]BatchScanItem{
+		{IdempotencyKey: "lap-3", Code: reg.ID, ScannedAt: time.Now().Add(-6 * time.Minute).In(elsewhere)},
+	})
+	if results[0].Status != BatchScanRecorded {
+		t.Errorf("Expected the lap before the live scan to be recorded, got %s (%s)", results[0].Status, results[0].Message)
+	}
+
+	recorded, err := db.GetScansByRegistrationID(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 4 {
+		t.Errorf("Expected 4 recorded scans, got %d", len(recorded))
+	}
+}
+
+func TestAPIScanUndoHandler(t *testing.T) {
+	// Save original database and restore after tests
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	// Setup test database
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	// Get the active season
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := createTestRegistration(t, db, activeSeason.ID)
+
+	// Initialize test session store
+	store = sessions.NewCookieStore([]byte("test-secret"))
+
+	undo := func(scanID, username string) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(map[string]string{"scanId": scanID})
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/undo", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		session, _ := store.Get(req, "run-club-session")
+		session.Values["username"] = username
+		session.Values["role"] = RoleScanner
+		rr := httptest.NewRecorder()
+		apiScanUndoHandler(rr, req)
+		return rr
+	}
+
+	recent, _, err := db.RecordScanAt(reg.ID, nil, time.Now(), ScanOptions{RecordedBy: "scanner1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _, err := db.RecordScanAt(reg.ID, nil, time.Now().Add(-time.Hour), ScanOptions{RecordedBy: "scanner1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another scanner can't undo someone else's scan
+	if rr := undo(recent.ID, "scanner2"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's scan, got %d", rr.Code)
+	}
+
+	// Scans outside the undo window need an admin
+	if rr := undo(old.ID, "scanner1"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an old scan, got %d", rr.Code)
+	}
+
+	if rr := undo(recent.ID, "scanner1"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := undo(recent.ID, "scanner1"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 when undoing twice, got %d", rr.Code)
+	}
+
+	// The undone scan stays in the history but no longer counts
+	scans, err := db.GetScansByRegistrationID(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 1 || scans[0].ID != old.ID {
+		t.Errorf("Expected only the old scan to count, got %d scans", len(scans))
+	}
+	history, err := db.GetScanHistory(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].VoidedAt == nil || history[0].VoidedBy != "scanner1" {
+		t.Errorf("Expected the undone scan to be voided in the history, got %+v", history)
+	}
+}
+
+func TestManualCheckIn(t *testing.T) {
+	// Save original database and restore after tests
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	// Setup test database
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	// Get the active season
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := createTestRegistration(t, db, activeSeason.ID)
+
+	// Initialize test session store
+	store = sessions.NewCookieStore([]byte("test-secret"))
+
+	// Search by part of the runner's name
+	req := httptest.NewRequest(http.MethodGet, "/api/runners/search?q=runn", nil)
+	rr := httptest.NewRecorder()
+	apiRunnerSearchHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var matches []RunnerSearchResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &matches); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != reg.ID {
+		t.Fatalf("Expected search to find %s, got %+v", reg.ID, matches)
+	}
+	if strings.Contains(rr.Body.String(), reg.ParentEmail) {
+		t.Errorf("Search results should not include parent contact details")
+	}
+
+	// Record the run as a manual check-in
+	bodyBytes, _ := json.Marshal(map[string]string{"code": matches[0].ID, "source": ScanSourceManual})
+	req = httptest.NewRequest(http.MethodPost, "/api/scan", bytes.NewBuffer(bodyBytes))
+	session, _ := store.Get(req, "run-club-session")
+	session.Values["username"] = "testuser"
+	session.Values["role"] = RoleScanner
+	rr = httptest.NewRecorder()
+	apiScanHandler(rr, req)
+
+	var result ScanResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !result.Success || result.ScanRecord.Source != ScanSourceManual {
+		t.Fatalf("Expected a successful manual scan, got %+v", result)
+	}
+
+	// A badge scan later in the day
+	_, _, err = db.RecordScanAt(reg.ID, nil, time.Now().Add(10*time.Minute), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetSeasonStatistics(activeSeason.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ManualRuns != 1 || stats.ManualRunPercent != 50 {
+		t.Errorf("Expected 1 manual run (50%%), got %d (%.0f%%)", stats.ManualRuns, stats.ManualRunPercent)
+	}
+	if len(stats.ManualCheckInRunners) != 1 || stats.ManualCheckInRunners[0].RunCount != 1 {
+		t.Errorf("Expected one runner with a manual check-in, got %+v", stats.ManualCheckInRunners)
+	}
+}
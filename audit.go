@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audited entity types
+const (
+	AuditEntityRegistration = "registration"
+	AuditEntitySeason       = "season"
+	AuditEntityTrack        = "track"
+	AuditEntityScan         = "scan"
+	AuditEntityQuestion     = "question"
+	AuditEntityWaiver       = "waiver"
+	AuditEntityPayment      = "payment"
+)
+
+// Audited actions
+const (
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionWithdraw  = "withdraw"
+	AuditActionReinstate = "reinstate"
+	AuditActionVoid      = "void"
+	AuditActionDelete    = "delete"
+	AuditActionMerge     = "merge"
+	AuditActionRequest   = "request"
+)
+
+// auditActorPublic is the actor recorded for changes made through the public registration form
+const auditActorPublic = "public"
+
+// auditActorParent is the actor recorded for changes a parent makes through their edit link
+const auditActorParent = "parent"
+
+// auditSearchLimit caps how many entries the audit page shows at once
+const auditSearchLimit = 200
+
+// AuditChange is one field's value before and after a change
+type AuditChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// AuditEntry is one change to a registration, season, track or scan
+type AuditEntry struct {
+	ID             int64         `json:"id"`
+	EntityType     string        `json:"entityType"`
+	EntityID       string        `json:"entityId"`
+	RegistrationID string        `json:"registrationId,omitempty"` // The runner the change belongs to, if any
+	Action         string        `json:"action"`
+	Actor          string        `json:"actor"`
+	Changes        []AuditChange `json:"changes"`
+	CreatedAt      time.Time     `json:"createdAt"`
+
+	RunnerName string `json:"runnerName,omitempty"` // Populated when listing entries
+}
+
+// auditDiff lists the fields that differ between two values of the same
+// struct type, named by their JSON keys. Pass nil for old to list every field
+// a new record was created with. The ID, nested records and lists are skipped.
+func auditDiff(old, new interface{}) []AuditChange {
+	newValue := reflect.Indirect(reflect.ValueOf(new))
+	oldValue := reflect.Zero(newValue.Type())
+	if old != nil && !reflect.ValueOf(old).IsNil() {
+		oldValue = reflect.Indirect(reflect.ValueOf(old))
+	}
+
+	var changes []AuditChange
+	for i := 0; i < newValue.NumField(); i++ {
+		field := newValue.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "id" || !isAuditedKind(field.Type) {
+			continue
+		}
+
+		oldText := auditValue(oldValue.Field(i))
+		newText := auditValue(newValue.Field(i))
+		if oldText != newText {
+			changes = append(changes, AuditChange{Field: name, Old: oldText, New: newText})
+		}
+	}
+	return changes
+}
+
+// isAuditedKind reports whether a field holds a plain value rather than a nested record or list
+func isAuditedKind(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		return false
+	}
+	return true
+}
+
+// auditValue formats a field value for the audit log. Nil pointers and zero
+// times are empty, and times are shown in the club's timezone.
+func auditValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return auditTime(t)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// auditTime formats a time for the audit log in the club's timezone
+func auditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(clubLocation()).Format("2006-01-02 15:04:05")
+}
+
+// auditHandler shows the audit log for the whole club, newest first, with search
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	searchQuery := strings.TrimSpace(r.URL.Query().Get("search"))
+	entityType := r.URL.Query().Get("type")
+
+	entries, err := database.SearchAuditLog(searchQuery, entityType, auditSearchLimit)
+	if err != nil {
+		log.Printf("Error searching audit log: %v", err)
+		http.Error(w, "Failed to retrieve audit log", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "audit", PageData{
+		Title:           "Run Club - Audit Log",
+		User:            username,
+		Role:            role,
+		SearchQuery:     searchQuery,
+		AuditEntityType: entityType,
+		AuditEntries:    entries,
+	})
+}
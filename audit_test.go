@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditDiff(t *testing.T) {
+	seasonID := "season-1"
+	old := &Registration{ID: "reg-1", SeasonID: &seasonID, FirstName: "Jane", Allergies: "Peanuts", RegisterForSpring: true}
+	updated := *old
+	updated.Allergies = ""
+	updated.Grade = "4"
+	updated.Season = &Season{Name: "Not compared"}
+
+	changes := auditDiff(old, &updated)
+	want := map[string]AuditChange{
+		"grade":     {Field: "grade", Old: "", New: "4"},
+		"allergies": {Field: "allergies", Old: "Peanuts", New: ""},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for _, c := range changes {
+		if want[c.Field] != c {
+			t.Errorf("Unexpected change %+v", c)
+		}
+	}
+
+	t.Run("Create Lists Set Fields", func(t *testing.T) {
+		changes := auditDiff((*Registration)(nil), old)
+		fields := map[string]string{}
+		for _, c := range changes {
+			fields[c.Field] = c.New
+		}
+		if fields["seasonId"] != seasonID || fields["firstName"] != "Jane" || fields["registerForSpring"] != "true" {
+			t.Errorf("Expected the new registration's values, got %+v", changes)
+		}
+		if _, ok := fields["id"]; ok {
+			t.Error("Expected the ID to be left out of the changes")
+		}
+		if _, ok := fields["lastName"]; ok {
+			t.Error("Expected empty fields to be left out of the changes")
+		}
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := newTestRegistration(season.ID, "Jane", "Doe", "parent@example.com")
+	if err := db.SaveRegistration(reg, auditActorPublic); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, _, err := db.GetRegistration(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited.MedicalInfo = "Asthma inhaler"
+	if err := db.UpdateRegistration(edited, "coach"); err != nil {
+		t.Fatal(err)
+	}
+
+	scan, _, err := db.RecordScanAt(reg.ID, nil, time.Now(), ScanOptions{RecordedBy: "scanner1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.VoidScan(scan.ID, "coach", "Double scan"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Runner Timeline", func(t *testing.T) {
+		entries, err := db.GetRegistrationAuditLog(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Newest first, without the scan being recorded
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Actor+" "+e.Action+" "+e.EntityType)
+		}
+		want := []string{"coach void scan", "coach update registration", "public create registration"}
+		if len(got) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Entry %d: expected %q, got %q", i, want[i], got[i])
+			}
+		}
+
+		update := entries[1]
+		if len(update.Changes) != 1 || update.Changes[0] != (AuditChange{Field: "medicalInfo", Old: "", New: "Asthma inhaler"}) {
+			t.Errorf("Expected only the medical info change, got %+v", update.Changes)
+		}
+		if update.RunnerName != "Jane Doe" {
+			t.Errorf("Expected runner name Jane Doe, got %q", update.RunnerName)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		entries, err := db.SearchAuditLog("Asthma", "", auditSearchLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("Expected 1 entry mentioning Asthma, got %d", len(entries))
+		}
+
+		entries, err = db.SearchAuditLog("Jane", AuditEntityScan, auditSearchLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("Expected the scan's create and void entries for Jane, got %d", len(entries))
+		}
+
+		entries, err = db.SearchAuditLog("", AuditEntitySeason, auditSearchLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Action != AuditActionCreate {
+			t.Errorf("Expected the test season's creation, got %+v", entries)
+		}
+	})
+
+	t.Run("Append Only", func(t *testing.T) {
+		if _, err := db.db.Exec("DELETE FROM audit_log"); err == nil {
+			t.Error("Expected deleting audit log entries to fail")
+		}
+		if _, err := db.db.Exec("UPDATE audit_log SET actor = 'someone else'"); err == nil {
+			t.Error("Expected changing audit log entries to fail")
+		}
+	})
+}
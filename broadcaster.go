@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// scanSubscriberBuffer is how many events a slow client can fall behind before events are dropped for it
+const scanSubscriberBuffer = 32
+
+// scanStreamKeepAlive is how often an idle stream sends a comment so proxies don't close it
+const scanStreamKeepAlive = 15 * time.Second
+
+// ScanEvent is a recorded scan published to live feed subscribers
+type ScanEvent struct {
+	ScanID         string    `json:"scanId"`
+	RegistrationID string    `json:"registrationId"`
+	SeasonID       string    `json:"seasonId"`
+	RunnerName     string    `json:"runnerName"`
+	Grade          string    `json:"grade"`
+	Teacher        string    `json:"teacher"`
+	TrackName      string    `json:"trackName,omitempty"`
+	TrackMiles     float64   `json:"trackMiles,omitempty"`
+	LapTime        *float64  `json:"lapTime,omitempty"` // Minutes since the runner's previous scan
+	Pace           *float64  `json:"pace,omitempty"`    // Minutes per mile
+	Source         string    `json:"source,omitempty"`
+	ScannedAt      time.Time `json:"scannedAt"`
+
+	// Used to build public display names, not sent to the coaches' feed
+	FirstName            string `json:"-"`
+	LastName             string `json:"-"`
+	OptOutWebsiteDisplay bool   `json:"-"`
+}
+
+// newScanEvent builds the live feed event for a successful scan result
+func newScanEvent(result ScanResult) ScanEvent {
+	scan := result.ScanRecord
+	reg := result.Registration
+	event := ScanEvent{
+		ScanID:         scan.ID,
+		RegistrationID: scan.RegistrationID,
+		SeasonID:       scan.SeasonID,
+		RunnerName:     fmt.Sprintf("%s %s", reg.FirstName, reg.LastName),
+		Grade:          reg.Grade,
+		Teacher:        reg.Teacher,
+		LapTime:        result.LapTime,
+		Pace:           result.Pace,
+		Source:         scan.Source,
+		ScannedAt:      scan.ScannedAt,
+
+		FirstName:            reg.FirstName,
+		LastName:             reg.LastName,
+		OptOutWebsiteDisplay: reg.OptOutWebsiteDisplay,
+	}
+	if scan.Track != nil {
+		event.TrackName = scan.Track.Name
+		event.TrackMiles = scan.Track.DistanceMiles
+	}
+	return event
+}
+
+// ScanBroadcaster fans recorded scans out to live feed subscribers. Publishing
+// never blocks: a subscriber whose buffer is full misses the event.
+type ScanBroadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan ScanEvent]struct{}
+}
+
+// NewScanBroadcaster creates a broadcaster with no subscribers
+func NewScanBroadcaster() *ScanBroadcaster {
+	return &ScanBroadcaster{
+		subscribers: make(map[chan ScanEvent]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber. The returned function must be called
+// to unsubscribe, after which the channel is closed.
+func (b *ScanBroadcaster) Subscribe() (<-chan ScanEvent, func()) {
+	ch := make(chan ScanEvent, scanSubscriberBuffer)
+
+	b.mutex.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, ch)
+			b.mutex.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends an event to every subscriber without waiting on slow ones
+func (b *ScanBroadcaster) Publish(event ScanEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Live scan feed subscriber is falling behind, dropped scan %s", event.ScanID)
+		}
+	}
+}
+
+// SubscriberCount returns the number of connected subscribers
+func (b *ScanBroadcaster) SubscriberCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers)
+}
+
+// scanBroadcaster publishes every successful scan to the live feed
+var scanBroadcaster = NewScanBroadcaster()
+
+// publishScan sends a successful scan result to live feed subscribers
+func publishScan(result ScanResult) {
+	if !result.Success || result.ScanRecord == nil || result.Registration == nil {
+		return
+	}
+	scanBroadcaster.Publish(newScanEvent(result))
+}
+
+// scanStreamOptions customizes what a scan event stream sends
+type scanStreamOptions struct {
+	// EventName is the SSE event type; defaults to "scan"
+	EventName string
+	// Transform converts an event to the payload to send, or skips it by returning false.
+	// Defaults to sending the event as is.
+	Transform func(ScanEvent) (interface{}, bool)
+	// StillAllowed is called every CheckInterval; the stream ends once it returns false
+	StillAllowed  func() bool
+	CheckInterval time.Duration
+}
+
+// writeScanEvents streams events from ch as Server-Sent Events until the
+// client disconnects or the channel is closed
+func writeScanEvents(w http.ResponseWriter, r *http.Request, ch <-chan ScanEvent, opts scanStreamOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if opts.EventName == "" {
+		opts.EventName = "scan"
+	}
+	if opts.Transform == nil {
+		opts.Transform = func(event ScanEvent) (interface{}, bool) { return event, true }
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(scanStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	var check <-chan time.Time
+	if opts.StillAllowed != nil && opts.CheckInterval > 0 {
+		checkTicker := time.NewTicker(opts.CheckInterval)
+		defer checkTicker.Stop()
+		check = checkTicker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-check:
+			if !opts.StillAllowed() {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			payload, send := opts.Transform(event)
+			if !send {
+				continue
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("Error encoding scan event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ScanID, opts.EventName, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// apiScansStreamHandler streams every successful scan as Server-Sent Events
+func apiScansStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The feed shows runners' names, so it ends once the session is revoked
+	// or the user is disabled
+	session, _ := store.Get(r, "run-club-session")
+
+	ch, unsubscribe := scanBroadcaster.Subscribe()
+	defer unsubscribe()
+
+	writeScanEvents(w, r, ch, scanStreamOptions{
+		CheckInterval: scanStreamKeepAlive,
+		StillAllowed:  func() bool { return sessionStillActive(session) },
+	})
+}
+
+// liveFeedHandler shows scans from every scanner as they happen
+func liveFeedHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	activeSeason, _, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+	}
+
+	renderTemplate(w, "live_feed", PageData{
+		Title:        "Run Club - Live Scan Feed",
+		User:         username,
+		Role:         role,
+		ActiveSeason: activeSeason,
+	})
+}
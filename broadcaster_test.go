@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanBroadcasterSlowSubscriber(t *testing.T) {
+	b := NewScanBroadcaster()
+	slow, unsubscribeSlow := b.Subscribe()
+	fast, unsubscribeFast := b.Subscribe()
+	defer unsubscribeFast()
+
+	// Publishing more events than the buffer holds must not block on the slow subscriber
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < scanSubscriberBuffer+10; i++ {
+			b.Publish(ScanEvent{ScanID: "scan"})
+			<-fast
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	if len(slow) != scanSubscriberBuffer {
+		t.Errorf("Expected slow subscriber to have %d buffered events, got %d", scanSubscriberBuffer, len(slow))
+	}
+
+	unsubscribeSlow()
+	unsubscribeSlow() // safe to call twice
+	if b.SubscriberCount() != 1 {
+		t.Errorf("Expected 1 subscriber after unsubscribing, got %d", b.SubscriberCount())
+	}
+}
+
+func TestAPIScansStreamHandler(t *testing.T) {
+	server := httptest.NewServer(loggingMiddleware(apiScansStreamHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	// Wait for the handler to subscribe before publishing
+	deadline := time.Now().Add(2 * time.Second)
+	for scanBroadcaster.SubscriberCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Stream never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lapTime := 9.5
+	publishScan(ScanResult{
+		Success:      true,
+		Registration: &Registration{FirstName: "Test", LastName: "Runner", Grade: "3"},
+		ScanRecord: &ScanRecord{
+			ID:        "scan-1",
+			ScannedAt: time.Now(),
+			Track:     &Track{Name: "Test Track", DistanceMiles: 1.0},
+		},
+		LapTime: &lapTime,
+	})
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended before a scan event: %v", err)
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event ScanEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		if event.RunnerName != "Test Runner" || event.TrackName != "Test Track" || event.LapTime == nil || *event.LapTime != lapTime {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+		return
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// schoolGrades are the grades runners can register in, in order
+var schoolGrades = []string{"K", "1", "2", "3", "4", "5"}
+
+// errSeasonFull is returned when a season has reached its maximum registrations
+var errSeasonFull = errRegistrationInput("Registration is full for this season")
+
+// gradeLabel names a grade for pages, e.g. "Kindergarten" or "3rd Grade"
+func gradeLabel(grade string) string {
+	switch grade {
+	case "K":
+		return "Kindergarten"
+	case "1":
+		return "1st Grade"
+	case "2":
+		return "2nd Grade"
+	case "3":
+		return "3rd Grade"
+	}
+	return grade + "th Grade"
+}
+
+// checkRegistrationWindow returns an error if registration for the season
+// hasn't opened yet or has already closed
+func checkRegistrationWindow(season *Season) error {
+	if season.IsRegistrationClosed() {
+		return registrationClosedError(season)
+	}
+	if !season.IsRegistrationOpen() {
+		return errRegistrationInput("Registration is not yet open for this season")
+	}
+	return nil
+}
+
+// registrationClosedError says when a season's registration closed
+func registrationClosedError(season *Season) error {
+	return errRegistrationInput(fmt.Sprintf("Registration closed on %s",
+		season.RegistrationEndsAt.In(clubLocation()).Format("January 2, 2006 at 3:04 PM")))
+}
+
+// GradeSpots is how many runners a grade has against its capacity
+type GradeSpots struct {
+	Grade      string
+	Label      string
+	Capacity   int
+	Registered int
+}
+
+// Remaining returns how many more runners the grade can take
+func (g GradeSpots) Remaining() int {
+	if g.Registered >= g.Capacity {
+		return 0
+	}
+	return g.Capacity - g.Registered
+}
+
+// SeasonCapacity is how many runners a season has, overall and by grade.
+// Withdrawn runners don't take up a spot.
+type SeasonCapacity struct {
+	Season  *Season
+	Total   int
+	ByGrade map[string]int
+}
+
+// IsFull reports whether the season has reached its maximum registrations
+func (c *SeasonCapacity) IsFull() bool {
+	return c.Season.IsRegistrationFull(c.Total)
+}
+
+// Remaining returns how many more runners the season can take, or 0 if it has no maximum
+func (c *SeasonCapacity) Remaining() int {
+	if c.Season.MaxRegistrations <= 0 || c.Total >= c.Season.MaxRegistrations {
+		return 0
+	}
+	return c.Season.MaxRegistrations - c.Total
+}
+
+// IsGradeFull reports whether a grade has reached its capacity
+func (c *SeasonCapacity) IsGradeFull(grade string) bool {
+	return c.Season.IsGradeFull(grade, c.ByGrade[grade])
+}
+
+// Check returns an error if the season can't take another runner in the grade
+func (c *SeasonCapacity) Check(grade string) error {
+	if c.IsFull() {
+		return errSeasonFull
+	}
+	if c.IsGradeFull(grade) {
+		return errRegistrationInput(fmt.Sprintf("Registration is full for %s", gradeLabel(grade)))
+	}
+	return nil
+}
+
+// Add counts a runner registered after the capacity was loaded
+func (c *SeasonCapacity) Add(grade string) {
+	c.Total++
+	c.ByGrade[grade]++
+}
+
+// GradeSpots lists the grades with a capacity limit, in grade order
+func (c *SeasonCapacity) GradeSpots() []GradeSpots {
+	var spots []GradeSpots
+	for grade, capacity := range c.Season.GradeCapacities {
+		spots = append(spots, GradeSpots{
+			Grade:      grade,
+			Label:      gradeLabel(grade),
+			Capacity:   capacity,
+			Registered: c.ByGrade[grade],
+		})
+	}
+
+	order := make(map[string]int)
+	for i, grade := range schoolGrades {
+		order[grade] = i
+	}
+	sort.Slice(spots, func(i, j int) bool {
+		return order[spots[i].Grade] < order[spots[j].Grade]
+	})
+	return spots
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSeasonCapacity(t *testing.T) {
+	season := &Season{MaxRegistrations: 10, GradeCapacities: map[string]int{"3": 4, "K": 2}}
+	capacity := &SeasonCapacity{Season: season, Total: 6, ByGrade: map[string]int{"K": 2, "3": 1, "5": 3}}
+
+	if err := capacity.Check("3"); err != nil {
+		t.Errorf("Expected room in grade 3, got %v", err)
+	}
+	if err := capacity.Check("K"); err == nil || !strings.Contains(err.Error(), "Kindergarten") {
+		t.Errorf("Expected kindergarten to be full, got %v", err)
+	}
+	if err := capacity.Check("5"); err != nil {
+		t.Errorf("Expected grades without a limit to have room, got %v", err)
+	}
+
+	spots := capacity.GradeSpots()
+	if len(spots) != 2 || spots[0].Grade != "K" || spots[1].Grade != "3" {
+		t.Fatalf("Expected limited grades in grade order, got %+v", spots)
+	}
+	if spots[0].Remaining() != 0 || spots[1].Remaining() != 3 {
+		t.Errorf("Expected 0 and 3 spots left, got %d and %d", spots[0].Remaining(), spots[1].Remaining())
+	}
+	if capacity.Remaining() != 4 {
+		t.Errorf("Expected 4 spots left in the season, got %d", capacity.Remaining())
+	}
+
+	capacity.Total = 10
+	if err := capacity.Check("5"); !errors.Is(err, errSeasonFull) {
+		t.Errorf("Expected errSeasonFull, got %v", err)
+	}
+}
+
+func TestCheckRegistrationWindow(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		season  Season
+		wantErr string
+	}{
+		{"No Dates", Season{}, ""},
+		{"Open", Season{RegistrationStartsAt: &past, RegistrationEndsAt: &future}, ""},
+		{"Not Yet Open", Season{RegistrationStartsAt: &future}, "not yet open"},
+		{"Closed", Season{RegistrationEndsAt: &past}, "Registration closed on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRegistrationWindow(&tt.season)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected registration to be open, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProcessCsvFileEnforcesLimits(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	header := "FirstName,LastName,Grade,Teacher,Gender,ParentContactNumber,BackupContactNumber,ParentEmail,DismissalMethod,Allergies,MedicalInfo\n"
+	row := func(first, grade string) string {
+		return first + ",Doe," + grade + ",Smith,Female,555-555-1234,,parent@example.com,Car Pickup,,\n"
+	}
+
+	t.Run("Grade Capacity", func(t *testing.T) {
+		season := &Season{ID: uuid.New().String(), Name: "Limited", CreatedAt: time.Now(), GradeCapacities: map[string]int{"K": 1}}
+		if err := db.SaveSeason(season, "admin"); err != nil {
+			t.Fatal(err)
+		}
+
+		imp, err := parseCSVImport(header + row("Anna", "K") + row("Ben", "K") + row("Cara", "1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processCsvImport(imp, season, "admin", true); err != errCSVImportHasErrors {
+			t.Fatalf("Expected the import to be refused, got %v", err)
+		}
+		if imp.Count(csvRowReady) != 2 || imp.Count(csvRowError) != 1 {
+			t.Fatalf("Expected 2 ready and 1 error, got %+v", imp.Rows)
+		}
+		if row := imp.Rows[1]; row.Line != 3 || row.Status != csvRowError || !strings.Contains(row.Message, "Kindergarten") {
+			t.Errorf("Expected line 3 to be rejected for kindergarten, got %+v", row)
+		}
+		regs, err := db.GetAllRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(regs) != 0 {
+			t.Errorf("Expected nothing to be imported, got %d registrations", len(regs))
+		}
+
+		saved, _, err := db.GetSeason(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.GradeCapacities["K"] != 1 || len(saved.GradeCapacities) != 1 {
+			t.Errorf("Expected the grade limits to be saved, got %v", saved.GradeCapacities)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		closed := time.Now().Add(-time.Hour)
+		season := &Season{ID: uuid.New().String(), Name: "Closed", CreatedAt: time.Now(), RegistrationEndsAt: &closed}
+		if err := db.SaveSeason(season, "admin"); err != nil {
+			t.Fatal(err)
+		}
+
+		imp, err := parseCSVImport(header + row("Anna", "K"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processCsvImport(imp, season, "admin", true); err != errCSVImportHasErrors {
+			t.Errorf("Expected the import to be rejected, got %v", err)
+		}
+		if len(imp.Rows) != 1 || !strings.Contains(imp.Rows[0].Message, "Registration closed") {
+			t.Errorf("Expected Anna's row to say registration has closed, got %+v", imp.Rows)
+		}
+	})
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CSVField is a registration detail a bulk import can read from a CSV column
+type CSVField struct {
+	Name     string   // The field's name on the registration form
+	Label    string   // What the mapping step calls it
+	Required bool     // Every row needs a value
+	Match    bool     // Finds the registration a row updates, when there's no ID column
+	Bool     bool     // A yes/no answer
+	Choices  []string // The values allowed, if it's one of a list
+	Headers  []string // Column headers it's matched to automatically, normalized
+}
+
+// csvGrades are the grades a runner can be in
+var csvGrades = []string{"K", "1", "2", "3", "4", "5"}
+
+// csvFields are the registration details a bulk import can read, in the
+// template's column order. The ID is only read by update imports.
+var csvFields = []*CSVField{
+	{Name: "id", Label: "Registration ID", Headers: []string{"id", "registrationid"}},
+	{Name: "firstName", Label: "First Name", Required: true, Match: true, Headers: []string{"firstname", "runnerfirstname", "studentfirstname", "childfirstname", "first"}},
+	{Name: "lastName", Label: "Last Name", Required: true, Match: true, Headers: []string{"lastname", "runnerlastname", "studentlastname", "childlastname", "last"}},
+	{Name: "grade", Label: "Grade", Required: true, Match: true, Choices: csvGrades, Headers: []string{"grade", "gradelevel"}},
+	{Name: "teacher", Label: "Teacher", Headers: []string{"teacher", "homeroom", "homeroomteacher"}},
+	{Name: "gender", Label: "Gender", Choices: []string{"Male", "Female", "Other", "Prefer not to say"}, Headers: []string{"gender", "sex"}},
+	{Name: "tshirtSize", Label: "T-Shirt Size", Choices: []string{"YXS", "YS", "YM", "YL", "XS", "S", "M", "L", "XL", "2XL", "3XL", "4XL"}, Headers: []string{"tshirtsize", "tshirt", "shirtsize", "shirt"}},
+	{Name: "parentFirstName", Label: "Parent First Name", Headers: []string{"parentfirstname", "guardianfirstname"}},
+	{Name: "parentLastName", Label: "Parent Last Name", Headers: []string{"parentlastname", "guardianlastname"}},
+	{Name: "parentContactNumber", Label: "Parent Phone", Required: true, Headers: []string{"parentcontactnumber", "parentphone", "guardianphone", "contactnumber", "parentcontact", "phone"}},
+	{Name: "backupContactNumber", Label: "Backup Phone", Headers: []string{"backupcontactnumber", "backupphone", "backupcontact", "emergencycontactnumber", "emergencyphone"}},
+	{Name: "parentEmail", Label: "Parent Email", Headers: []string{"parentemail", "guardianemail", "email"}},
+	{Name: "dismissalMethod", Label: "Dismissal Method", Choices: []string{"Walking Unescorted", "Walking Escorted", "Car Pickup", "Clayton Crew"}, Headers: []string{"dismissalmethod", "dismissal"}},
+	{Name: "allergies", Label: "Allergies", Headers: []string{"allergies"}},
+	{Name: "medicalInfo", Label: "Medical Info", Headers: []string{"medicalinfo", "medicalinformation", "medical"}},
+	{Name: "registerForSpring", Label: "Register for Spring", Bool: true, Headers: []string{"registerforspring", "spring"}},
+	{Name: "optOutWebsiteDisplay", Label: "Opt Out of Website Display", Bool: true, Headers: []string{"optoutwebsitedisplay", "optoutwebsite"}},
+	{Name: "optOutPhotoSharing", Label: "Opt Out of Photo Sharing", Bool: true, Headers: []string{"optoutphotosharing", "optoutphotos"}},
+}
+
+// What happens to a row of a bulk import
+const (
+	csvRowReady     = "ready"     // The runner will be, or was, registered
+	csvRowUpdate    = "update"    // The runner's registration will be, or was, updated
+	csvRowUnchanged = "unchanged" // The runner's registration already matches the row
+	csvRowSkipped   = "skipped"   // The runner is already registered
+	csvRowError     = "error"     // The row has to be fixed before anything can be imported
+)
+
+// CSVImport is an uploaded CSV file of runners to register, which column
+// each registration detail is read from, and what happens to each row
+type CSVImport struct {
+	Data     string         // The file, carried from the preview to the import
+	Headers  []string       // The file's header row
+	Mapping  map[string]int // The column each field is read from, by field name, or -1
+	Rows     []*CSVImportRow
+	Update   bool // Rows matching a registration update it rather than being skipped
+	Imported bool // Whether the rows were saved, rather than previewed
+
+	records [][]string
+	lines   []int // Each record's line in the file
+}
+
+// CSVImportRow is one runner in a bulk import
+type CSVImportRow struct {
+	Line         int // The row's line in the file, counting the header as line 1
+	Registration *Registration
+	Status       string       // csvRowReady, csvRowUpdate, csvRowUnchanged, csvRowSkipped or csvRowError
+	Message      string       // Why the row was skipped or can't be imported
+	Changes      []*CSVChange // What an update changes
+}
+
+// CSVChange is a detail an update import changes on a registration
+type CSVChange struct {
+	Label string
+	Old   string
+	New   string
+}
+
+// Fields returns the registration details a column can be mapped to
+func (imp *CSVImport) Fields() []*CSVField {
+	return csvFields
+}
+
+// Count returns how many rows have the given status
+func (imp *CSVImport) Count(status string) int {
+	count := 0
+	for _, row := range imp.Rows {
+		if row.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// CanImport reports whether every row checked out and there's something to save
+func (imp *CSVImport) CanImport() bool {
+	return imp.Count(csvRowError) == 0 && imp.Count(csvRowReady)+imp.Count(csvRowUpdate) > 0
+}
+
+// errCSVImportHasErrors is returned when an import is committed with rows that need fixing
+var errCSVImportHasErrors = errRegistrationInput("Nothing was imported. Fix the rows with errors and upload the file again.")
+
+// parseCSVImport reads an uploaded CSV file and matches its columns to
+// registration details by their headers
+func parseCSVImport(data string) (*CSVImport, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var imp *CSVImport
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errRegistrationInput(fmt.Sprintf("Error reading CSV file: %v", err))
+		}
+		if imp == nil {
+			imp = &CSVImport{Data: data, Headers: record}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		imp.records = append(imp.records, record)
+		imp.lines = append(imp.lines, line)
+	}
+	if imp == nil {
+		return nil, errRegistrationInput("The CSV file is empty")
+	}
+
+	imp.Mapping = guessCSVMapping(imp.Headers)
+	return imp, nil
+}
+
+// guessCSVMapping matches each registration detail to the first column whose
+// header is one of its usual names, ignoring case, spaces and punctuation
+func guessCSVMapping(headers []string) map[string]int {
+	mapping := make(map[string]int)
+	used := make(map[int]bool)
+	for _, f := range csvFields {
+		mapping[f.Name] = -1
+		for i, header := range headers {
+			if used[i] {
+				continue
+			}
+			if slices.Contains(f.Headers, normalizeName(header)) {
+				mapping[f.Name] = i
+				used[i] = true
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// csvMappingFromForm reads the column chosen for each registration detail on the mapping step
+func csvMappingFromForm(r *http.Request) map[string]int {
+	mapping := make(map[string]int)
+	for _, f := range csvFields {
+		mapping[f.Name] = -1
+		if col, err := strconv.Atoi(r.FormValue("column." + f.Name)); err == nil {
+			mapping[f.Name] = col
+		}
+	}
+	return mapping
+}
+
+// checkMapping makes sure every required detail has a column, and that no
+// column is read as two details. An update import only needs the columns
+// that find the registration each row is for; new runners in it are
+// checked row by row.
+func (imp *CSVImport) checkMapping() error {
+	for _, f := range csvFields {
+		if col := imp.Mapping[f.Name]; col < 0 || col >= len(imp.Headers) {
+			imp.Mapping[f.Name] = -1
+		}
+	}
+
+	var missing []string
+	usedBy := make(map[int]string)
+	for _, f := range csvFields {
+		col := imp.Mapping[f.Name]
+		if col < 0 {
+			if (!imp.Update && f.Required) || (imp.Update && f.Match && imp.Mapping["id"] < 0) {
+				missing = append(missing, f.Label)
+			}
+			continue
+		}
+		if other, ok := usedBy[col]; ok {
+			return errRegistrationInput(fmt.Sprintf("The %q column can't be both %s and %s", imp.Headers[col], other, f.Label))
+		}
+		usedBy[col] = f.Label
+	}
+	if len(missing) > 0 {
+		return errRegistrationInput("Choose a column for " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// csvChoice returns the allowed value that matches value, ignoring case
+func csvChoice(value string, choices []string) (string, bool) {
+	for _, choice := range choices {
+		if strings.EqualFold(value, choice) {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// parseCSVBool reads a yes/no answer; a blank cell is no
+func parseCSVBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "t", "1", "x":
+		return true, true
+	case "no", "n", "false", "f", "0", "":
+		return false, true
+	}
+	return false, false
+}
+
+// formatPhoneNumber rewrites a ten digit phone number written any way, like
+// "(555) 123-4567", as 555-123-4567. Anything else is left alone.
+func formatPhoneNumber(phone string) string {
+	digits := normalizePhone(phone)
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	if len(digits) != 10 {
+		return phone
+	}
+	return digits[:3] + "-" + digits[3:6] + "-" + digits[6:]
+}
+
+// cell returns the value in a row for a registration detail, or "" if its column isn't in the file
+func (imp *CSVImport) cell(record []string, name string) string {
+	if col := imp.Mapping[name]; col >= 0 && col < len(record) {
+		return strings.TrimSpace(record[col])
+	}
+	return ""
+}
+
+// csvRegistration reads a registration from one row of a bulk import. With
+// a base registration, the row updates it: blank cells keep the base's
+// values. The registration is returned even when the row is invalid, so the
+// preview can say whose row it was.
+func (imp *CSVImport) csvRegistration(record []string, base *Registration) (*Registration, error) {
+	form := url.Values{}
+	if base != nil {
+		for _, c := range auditDiff(nil, base) {
+			form.Set(c.Field, c.New)
+		}
+	}
+
+	var problems []string
+	var missing []string
+	for _, f := range csvFields {
+		value := imp.cell(record, f.Name)
+		switch {
+		case base != nil && strings.EqualFold(value, form.Get(f.Name)):
+			// A roster in capitals doesn't rewrite every name
+			continue
+		case value == "":
+			if f.Required && base == nil {
+				missing = append(missing, f.Label)
+			}
+			continue
+		case f.Bool:
+			b, ok := parseCSVBool(value)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s should be yes or no, not %q", f.Label, value))
+			}
+			value = strconv.FormatBool(b)
+		case f.Choices != nil:
+			choice, ok := csvChoice(value, f.Choices)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("Invalid %s %q", strings.ToLower(f.Label), value))
+			}
+			value = choice
+		}
+		form.Set(f.Name, value)
+	}
+	form.Set("parentContactNumber", formatPhoneNumber(form.Get("parentContactNumber")))
+	form.Set("backupContactNumber", formatPhoneNumber(form.Get("backupContactNumber")))
+
+	reg := registrationFromForm(&http.Request{Form: form})
+	if base != nil {
+		updated := *base
+		applyRegistrationEdit(&updated, reg) // Validated below
+		reg = &updated
+	}
+	if len(missing) > 0 {
+		problems = append([]string{"Missing " + strings.Join(missing, ", ")}, problems...)
+	}
+	if len(problems) > 0 {
+		return reg, errRegistrationInput(strings.Join(problems, "; "))
+	}
+	return reg, validateRegistration(reg)
+}
+
+// csvMatch finds the registration a row of an update import is for: the
+// one with the row's ID, or else the only one in the season with the row's
+// name and grade. It returns nil if the row is a new runner.
+func (imp *CSVImport) csvMatch(record []string, existing []*Registration) (*Registration, error) {
+	if id := imp.cell(record, "id"); id != "" {
+		for _, reg := range existing {
+			if reg.ID == id {
+				return reg, nil
+			}
+		}
+		return nil, errRegistrationInput(fmt.Sprintf("No registration with ID %s in this season", id))
+	}
+
+	first := normalizeName(imp.cell(record, "firstName"))
+	last := normalizeName(imp.cell(record, "lastName"))
+	grade, _ := csvChoice(imp.cell(record, "grade"), csvGrades)
+	var match *Registration
+	for _, reg := range existing {
+		if normalizeName(reg.FirstName) != first || normalizeName(reg.LastName) != last || reg.Grade != grade {
+			continue
+		}
+		if match != nil {
+			return nil, errRegistrationInput(fmt.Sprintf("More than one %s %s is registered in grade %s; add an ID column to say which", reg.FirstName, reg.LastName, grade))
+		}
+		match = reg
+	}
+	return match, nil
+}
+
+// csvChanges lists the details an update import changes on a registration
+func csvChanges(old, updated *Registration) []*CSVChange {
+	var changes []*CSVChange
+	for _, c := range auditDiff(old, updated) {
+		for _, f := range csvFields {
+			if f.Name == c.Field {
+				changes = append(changes, &CSVChange{Label: f.Label, Old: c.Old, New: c.New})
+			}
+		}
+	}
+	return changes
+}
+
+// processCsvImport checks every row of a bulk import against the season:
+// each has to be a valid registration that fits under the season's limits,
+// and runners already registered are skipped. An update import changes the
+// registrations its rows match instead of skipping them, except those of
+// withdrawn runners, which are left alone. Nothing is saved unless commit
+// is set, and then only if every row checked out, in which
+// case all of them are saved together. actor is recorded in the audit log
+// as having made the changes.
+func processCsvImport(imp *CSVImport, season *Season, actor string, commit bool) error {
+	if err := imp.checkMapping(); err != nil {
+		return err
+	}
+
+	// Runners can't be added once registration has closed, or past the
+	// season's limits. Closing doesn't stop corrections to runners already
+	// registered.
+	closed := season.IsRegistrationClosed()
+	capacity, err := database.GetSeasonCapacity(season)
+	if err != nil {
+		return fmt.Errorf("failed to check registration capacity: %w", err)
+	}
+
+	// Rows for children already in the season, or earlier in the file, are skipped
+	existing, err := database.GetAllRegistrations(season.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check existing registrations: %w", err)
+	}
+	registered := existing
+	if imp.Update {
+		// Rows for withdrawn runners match them too, rather than signing
+		// them up again
+		withdrawn, err := database.GetWithdrawnRegistrations(season.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check withdrawn registrations: %w", err)
+		}
+		registered = append(append([]*Registration{}, existing...), withdrawn...)
+	}
+
+	now := time.Now()
+	imp.Rows = nil
+	var regs, updates []*Registration
+	updatedBy := make(map[string]int) // The line updating each registration
+	for i, record := range imp.records {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := &CSVImportRow{Line: imp.lines[i], Status: csvRowReady}
+		imp.Rows = append(imp.Rows, row)
+
+		var match *Registration
+		if imp.Update {
+			match, err = imp.csvMatch(record, registered)
+			if err != nil {
+				row.Status = csvRowError
+				row.Message = err.Error()
+				continue
+			}
+		}
+
+		if match != nil && match.WithdrawnAt != nil {
+			row.Registration = match
+			row.Status = csvRowSkipped
+			row.Message = fmt.Sprintf("Skipped %s %s, who has withdrawn; reinstate them to update their registration", match.FirstName, match.LastName)
+			continue
+		}
+		if match != nil {
+			updated, err := imp.csvRegistration(record, match)
+			row.Registration = updated
+			if line, ok := updatedBy[match.ID]; ok && err == nil {
+				err = errRegistrationInput(fmt.Sprintf("Line %d already updates %s %s", line, match.FirstName, match.LastName))
+			}
+			if err == nil && updated.Grade != match.Grade {
+				err = capacity.Check(updated.Grade)
+			}
+			if err != nil {
+				row.Status = csvRowError
+				row.Message = err.Error()
+				continue
+			}
+			updatedBy[match.ID] = row.Line
+
+			row.Changes = csvChanges(match, updated)
+			if len(row.Changes) == 0 {
+				row.Status = csvRowUnchanged
+				continue
+			}
+			row.Status = csvRowUpdate
+			if updated.Grade != match.Grade {
+				capacity.Add(updated.Grade)
+			}
+			updates = append(updates, updated)
+			continue
+		}
+
+		reg, err := imp.csvRegistration(record, nil)
+		reg.ID = uuid.New().String()
+		reg.SeasonID = &season.ID
+		reg.RegisteredAt = now
+		reg.Season = season
+		row.Registration = reg
+
+		if err == nil {
+			if dup := findDuplicate(reg, existing); dup != nil {
+				row.Status = csvRowSkipped
+				row.Message = fmt.Sprintf("Skipped %s %s, who is already registered in grade %s", dup.FirstName, dup.LastName, dup.Grade)
+				continue
+			}
+			if closed {
+				err = registrationClosedError(season)
+			} else {
+				err = capacity.Check(reg.Grade)
+			}
+		}
+		if err != nil {
+			row.Status = csvRowError
+			row.Message = err.Error()
+			continue
+		}
+
+		capacity.Add(reg.Grade)
+		existing = append(existing, reg)
+		regs = append(regs, reg)
+	}
+
+	if !commit {
+		return nil
+	}
+	if imp.Count(csvRowError) > 0 {
+		return errCSVImportHasErrors
+	}
+	if err := database.ImportRegistrations(regs, updates, actor); err != nil {
+		return err
+	}
+	imp.Imported = true
+	return nil
+}
+
+// csvUploadData returns the CSV file to import: a newly uploaded file, whose
+// columns are matched by their headers, or the file carried over from the
+// preview with the columns the admin chose
+func csvUploadData(r *http.Request) (*CSVImport, error) {
+	var imp *CSVImport
+	file, header, err := r.FormFile("csv-file")
+	if err == nil {
+		defer file.Close()
+		if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			return nil, errRegistrationInput("Uploaded file is not a CSV")
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, errRegistrationInput("Error reading uploaded file")
+		}
+		if imp, err = parseCSVImport(string(data)); err != nil {
+			return nil, err
+		}
+	} else {
+		data := r.FormValue("csv-data")
+		if data == "" {
+			return nil, errRegistrationInput("Error retrieving file from form")
+		}
+		if imp, err = parseCSVImport(data); err != nil {
+			return nil, err
+		}
+		imp.Mapping = csvMappingFromForm(r)
+	}
+
+	imp.Update = r.FormValue("mode") == "update"
+	return imp, nil
+}
+
+// csvUploadHandler registers runners from a CSV file, or with the update
+// mode, also updates the registrations its rows match. An upload is
+// previewed first: the admin can fix which column each detail comes from
+// and see what would happen to every row, then import them all at once.
+func csvUploadHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	// Get active season
+	activeSeason, hasActiveSeason, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+	}
+
+	// Create base page data
+	data := PageData{
+		Title: "Run Club - Bulk Register",
+		User:  username,
+		Role:  role,
+	}
+
+	// Add active season data if available
+	if hasActiveSeason {
+		data.ActiveSeason = activeSeason
+	}
+
+	// For GET requests, just show the form
+	if r.Method == http.MethodGet {
+		renderTemplate(w, "csv_upload", data)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Check if there's an active season
+	if !hasActiveSeason {
+		data.Message = "Cannot register runners without an active season"
+		renderTemplate(w, "csv_upload", data)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // Max 10MB
+		data.Message = "Error parsing form"
+		renderTemplate(w, "csv_upload", data)
+		return
+	}
+
+	imp, err := csvUploadData(r)
+	if err != nil {
+		data.Message = err.Error()
+		renderTemplate(w, "csv_upload", data)
+		return
+	}
+	data.CSVImport = imp
+
+	err = processCsvImport(imp, activeSeason, username, r.FormValue("action") == "import")
+	var inputErr errRegistrationInput
+	switch {
+	case errors.As(err, &inputErr):
+		data.Message = err.Error()
+	case err != nil:
+		log.Printf("Error importing CSV file: %v", err)
+		data.Message = "Failed to register runners. Nothing was imported."
+	case imp.Imported:
+		data.Success = true
+		data.Message = fmt.Sprintf("Registered %d runners", imp.Count(csvRowReady))
+		if imp.Update {
+			data.Message += fmt.Sprintf(", updated %d", imp.Count(csvRowUpdate))
+		}
+		if skipped := imp.Count(csvRowSkipped); skipped > 0 {
+			data.Message += fmt.Sprintf(", and skipped %d already registered", skipped)
+		}
+	case imp.Update:
+		data.Success = imp.CanImport()
+		data.Message = fmt.Sprintf("Preview: %d new runners, %d to update, %d unchanged, %d already registered, %d with errors. Nothing has been saved yet.",
+			imp.Count(csvRowReady), imp.Count(csvRowUpdate), imp.Count(csvRowUnchanged), imp.Count(csvRowSkipped), imp.Count(csvRowError))
+	default:
+		data.Success = imp.CanImport()
+		data.Message = fmt.Sprintf("Preview: %d ready to register, %d already registered, %d with errors. Nothing has been saved yet.",
+			imp.Count(csvRowReady), imp.Count(csvRowSkipped), imp.Count(csvRowError))
+	}
+
+	renderTemplate(w, "csv_upload", data)
+}
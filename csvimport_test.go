@@ -0,0 +1,295 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuessCSVMapping(t *testing.T) {
+	mapping := guessCSVMapping([]string{"Parent Phone", "Student Last Name", "first name", "Email", "T-Shirt", "Notes", "Grade"})
+	for field, want := range map[string]int{
+		"parentContactNumber": 0,
+		"lastName":            1,
+		"firstName":           2,
+		"parentEmail":         3,
+		"tshirtSize":          4,
+		"grade":               6,
+		"teacher":             -1,
+		"registerForSpring":   -1,
+	} {
+		if mapping[field] != want {
+			t.Errorf("Expected %s in column %d, got %d", field, want, mapping[field])
+		}
+	}
+
+	imp := &CSVImport{Headers: []string{"First", "Last", "Grade"}, Mapping: guessCSVMapping([]string{"First", "Last", "Grade"})}
+	if err := imp.checkMapping(); err == nil || !strings.Contains(err.Error(), "Parent Phone") {
+		t.Errorf("Expected the missing phone column to be reported, got %v", err)
+	}
+	imp.Mapping["parentContactNumber"] = 0
+	if err := imp.checkMapping(); err == nil || !strings.Contains(err.Error(), "can't be both") {
+		t.Errorf("Expected a column used twice to be rejected, got %v", err)
+	}
+}
+
+func TestProcessCsvImport(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "Grade,Last Name,First Name,Phone,Parent Email,Parent First Name,T-Shirt Size,Spring,Opt Out Photos\n" +
+		"k,Doe,Anna,(555) 555-1234,pat@example.com,Pat,ym,yes,\n" +
+		"3,Doe,Ben,555.555.1234,PAT@example.com,Pat,YL,no,x\n" +
+		"\n" +
+		"2,Lee,Cara,555-555-9876,lee@example.com,Sam,,,\n"
+
+	imp, err := parseCSVImport(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := processCsvImport(imp, season, "admin", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.Rows) != 3 || !imp.CanImport() || imp.Imported {
+		t.Fatalf("Expected 3 rows ready to import, got %+v", imp.Rows)
+	}
+	if imp.Rows[2].Line != 5 {
+		t.Errorf("Expected Cara's row on line 5, got %d", imp.Rows[2].Line)
+	}
+	anna := imp.Rows[0].Registration
+	if anna.Grade != "K" || anna.TshirtSize != "YM" || anna.ParentContactNumber != "555-555-1234" || !anna.RegisterForSpring || anna.OptOutPhotoSharing || anna.ParentFirstName != "Pat" {
+		t.Errorf("Unexpected registration for Anna: %+v", anna)
+	}
+	if ben := imp.Rows[1].Registration; ben.RegisterForSpring || !ben.OptOutPhotoSharing {
+		t.Errorf("Unexpected registration for Ben: %+v", ben)
+	}
+
+	// A preview doesn't save anything
+	regs, err := db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regs) != 0 {
+		t.Fatalf("Expected the preview to save nothing, got %d registrations", len(regs))
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		bad, err := parseCSVImport(csv + "6,Roe,Dev,555-555-0000,,,Huge,maybe,\n" + ",Roe,,555-555-0000,,,,,\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processCsvImport(bad, season, "admin", true); err != errCSVImportHasErrors {
+			t.Fatalf("Expected the import to be refused, got %v", err)
+		}
+		if bad.Count(csvRowError) != 2 {
+			t.Fatalf("Expected 2 rows with errors, got %+v", bad.Rows)
+		}
+		for _, want := range []string{"Invalid grade", "Invalid t-shirt size", "yes or no"} {
+			if !strings.Contains(bad.Rows[3].Message, want) {
+				t.Errorf("Expected %q in %q", want, bad.Rows[3].Message)
+			}
+		}
+		if !strings.Contains(bad.Rows[4].Message, "Missing First Name, Grade") {
+			t.Errorf("Expected the missing fields to be reported, got %q", bad.Rows[4].Message)
+		}
+
+		regs, err := db.GetAllRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(regs) != 0 {
+			t.Errorf("Expected nothing to be imported, got %d registrations", len(regs))
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		if err := processCsvImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		if !imp.Imported {
+			t.Error("Expected the import to be saved")
+		}
+
+		regs, err := db.GetAllRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(regs) != 3 {
+			t.Fatalf("Expected 3 registrations, got %d", len(regs))
+		}
+		byName := make(map[string]*Registration)
+		for _, reg := range regs {
+			byName[reg.FirstName] = reg
+		}
+		if byName["Anna"].HouseholdID == "" || byName["Anna"].HouseholdID != byName["Ben"].HouseholdID {
+			t.Errorf("Expected Anna and Ben to share a household, got %q and %q", byName["Anna"].HouseholdID, byName["Ben"].HouseholdID)
+		}
+		if byName["Cara"].HouseholdID == byName["Anna"].HouseholdID {
+			t.Error("Expected Cara in a household of her own")
+		}
+	})
+}
+
+func TestProcessCsvImportUpdates(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	anna := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+	ben := newTestRegistration(season.ID, "Ben", "Doe", "pat@example.com")
+	for _, reg := range []*Registration{anna, ben} {
+		reg.Teacher = "Smith"
+		reg.ParentContactNumber = "555-555-1234"
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A corrected roster only needs the columns that find each runner
+	roster := "ID,Teacher\n" + anna.ID + ",Jones\n"
+	imp, err := parseCSVImport(roster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := processCsvImport(imp, season, "admin", false); err == nil {
+		t.Error("Expected an import without names to need an update import")
+	}
+	imp.Update = true
+	if err := processCsvImport(imp, season, "admin", false); err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "ID,First Name,Last Name,Grade,Teacher,Parent Contact\n" +
+		anna.ID + ",,,,Jones,\n" +
+		",ben,DOE,3,Smith,\n" +
+		",Cara,Lee,2,Brown,(555) 555-0000\n"
+	imp, err = parseCSVImport(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp.Update = true
+	if err := processCsvImport(imp, season, "admin", false); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{csvRowUpdate, csvRowUnchanged, csvRowReady} {
+		if imp.Rows[i].Status != want {
+			t.Errorf("Line %d: expected %s, got %s (%s)", imp.Rows[i].Line, want, imp.Rows[i].Status, imp.Rows[i].Message)
+		}
+	}
+	changes := imp.Rows[0].Changes
+	if len(changes) != 1 || changes[0].Label != "Teacher" || changes[0].Old != "Smith" || changes[0].New != "Jones" {
+		t.Errorf("Expected Anna's teacher to change from Smith to Jones, got %+v", changes)
+	}
+	if saved, _, _ := db.GetRegistration(anna.ID); saved.Teacher != "Smith" {
+		t.Error("Expected the preview to change nothing")
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		bad, err := parseCSVImport(csv + "missing,,,,Jones,\n" + anna.ID + ",,,,Brown,\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bad.Update = true
+		if err := processCsvImport(bad, season, "admin", true); err != errCSVImportHasErrors {
+			t.Fatalf("Expected the import to be refused, got %v", err)
+		}
+		if !strings.Contains(bad.Rows[3].Message, "No registration with ID missing") {
+			t.Errorf("Expected the unknown ID to be reported, got %q", bad.Rows[3].Message)
+		}
+		if !strings.Contains(bad.Rows[4].Message, "Line 2 already updates Anna Doe") {
+			t.Errorf("Expected the second update to Anna to be reported, got %q", bad.Rows[4].Message)
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		if err := processCsvImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		regs, err := db.GetAllRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(regs) != 3 {
+			t.Fatalf("Expected Cara to be added, got %d registrations", len(regs))
+		}
+		saved, _, err := db.GetRegistration(anna.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Teacher != "Jones" || saved.ParentContactNumber != "555-555-1234" || saved.ParentEmail != "pat@example.com" {
+			t.Errorf("Expected only Anna's teacher to change, got %+v", saved)
+		}
+
+		entries, err := db.GetRegistrationAuditLog(anna.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 || entries[0].Action != AuditActionUpdate || entries[0].Changes[0].Field != "teacher" {
+			t.Errorf("Expected the update in Anna's history, got %+v", entries)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		// Corrected rosters often arrive after registration has closed
+		closed := time.Now().Add(-time.Hour)
+		closedSeason := *season
+		closedSeason.RegistrationEndsAt = &closed
+
+		imp, err := parseCSVImport("ID,First Name,Last Name,Grade,Teacher,Parent Contact\n" +
+			ben.ID + ",,,,Brown,\n" +
+			",Dev,Roe,4,Brown,555-555-0000\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		imp.Update = true
+		if err := processCsvImport(imp, &closedSeason, "admin", false); err != nil {
+			t.Fatal(err)
+		}
+		if imp.Rows[0].Status != csvRowUpdate {
+			t.Errorf("Expected Ben's teacher to be corrected, got %+v", imp.Rows[0])
+		}
+		if imp.Rows[1].Status != csvRowError || !strings.Contains(imp.Rows[1].Message, "Registration closed") {
+			t.Errorf("Expected Dev not to be added, got %+v", imp.Rows[1])
+		}
+	})
+
+	t.Run("Withdrawn", func(t *testing.T) {
+		if err := db.WithdrawRegistration(ben.ID, "admin", "Moved away"); err != nil {
+			t.Fatal(err)
+		}
+		imp, err := parseCSVImport("ID,First Name,Last Name,Grade,Teacher,Parent Contact\n" +
+			",Ben,Doe,3,Brown,555-555-1234\n" +
+			ben.ID + ",,,,Brown,\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		imp.Update = true
+		if err := processCsvImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range imp.Rows {
+			if row.Status != csvRowSkipped || !strings.Contains(row.Message, "who has withdrawn") {
+				t.Errorf("Line %d: expected Ben to be left withdrawn, got %s (%s)", row.Line, row.Status, row.Message)
+			}
+		}
+		if regs, _ := db.GetAllRegistrations(season.ID); len(regs) != 2 {
+			t.Errorf("Expected Ben not to be registered again, got %d registrations", len(regs))
+		}
+	})
+}
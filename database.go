@@ -2,9 +2,13 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -125,8 +129,34 @@ func (db *Database) initSchema() error {
 	return nil
 }
 
+// seasonColumns are the season columns read by scanSeason
+const seasonColumns = `id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations, keep_badges,
+	registration_ends_at, grade_capacities, fee_cents, sibling_discount_cents`
+
+// scanSeason scans a row selected with seasonColumns
+func scanSeason(row interface{ Scan(...interface{}) error }) (*Season, error) {
+	season := &Season{}
+	var registrationToken, gradeCapacities sql.NullString
+	err := row.Scan(&season.ID, &season.Name, &season.IsActive, &season.CreatedAt, &registrationToken, &season.SpringRegistrationEnabled, &season.RegistrationStartsAt, &season.MaxRegistrations, &season.KeepBadges,
+		&season.RegistrationEndsAt, &gradeCapacities, &season.FeeCents, &season.SiblingDiscountCents)
+	if err != nil {
+		return nil, err
+	}
+
+	if registrationToken.Valid {
+		season.RegistrationToken = registrationToken.String
+	}
+	if gradeCapacities.Valid && gradeCapacities.String != "" {
+		if err := json.Unmarshal([]byte(gradeCapacities.String), &season.GradeCapacities); err != nil {
+			return nil, fmt.Errorf("failed to decode grade capacities: %w", err)
+		}
+	}
+
+	return season, nil
+}
+
 // SaveSeason saves a season to the database
-func (db *Database) SaveSeason(season *Season) error {
+func (db *Database) SaveSeason(season *Season, actor string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
@@ -154,16 +184,49 @@ func (db *Database) SaveSeason(season *Season) error {
 		season.RegistrationToken = uuid.New().String()
 	}
 
+	var gradeCapacities interface{}
+	if len(season.GradeCapacities) > 0 {
+		encoded, err := json.Marshal(season.GradeCapacities)
+		if err != nil {
+			return fmt.Errorf("failed to encode grade capacities: %w", err)
+		}
+		gradeCapacities = string(encoded)
+	}
+
 	// Insert the new season
 	_, err = tx.Exec(
-		`INSERT INTO seasons (id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		season.ID, season.Name, season.IsActive, season.CreatedAt, season.RegistrationToken, season.SpringRegistrationEnabled, season.RegistrationStartsAt, season.MaxRegistrations,
+		`INSERT INTO seasons (id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations, keep_badges,
+			registration_ends_at, grade_capacities, fee_cents, sibling_discount_cents) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		season.ID, season.Name, season.IsActive, season.CreatedAt, season.RegistrationToken, season.SpringRegistrationEnabled, season.RegistrationStartsAt, season.MaxRegistrations, season.KeepBadges,
+		season.RegistrationEndsAt, gradeCapacities, season.FeeCents, season.SiblingDiscountCents,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save season: %w", err)
 	}
 
+	// Start the season with the club's usual milestone thresholds
+	for _, miles := range defaultMilestoneMiles {
+		_, err = tx.Exec(
+			"INSERT INTO milestones (id, season_id, miles, name, created_at) VALUES (?, ?, ?, ?, ?)",
+			uuid.New().String(), season.ID, miles, defaultMilestoneName(miles), season.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save default milestones: %w", err)
+		}
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntitySeason,
+		EntityID:   season.ID,
+		Action:     AuditActionCreate,
+		Actor:      actor,
+		Changes:    auditDiff(nil, season),
+	})
+	if err != nil {
+		return err
+	}
+
 	// Commit the transaction
 	err = tx.Commit()
 	if err != nil {
@@ -178,13 +241,7 @@ func (db *Database) GetSeason(id string) (*Season, bool, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	season := &Season{}
-	var registrationToken sql.NullString
-	err := db.db.QueryRow(
-		`SELECT id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations FROM seasons WHERE id = ?`,
-		id,
-	).Scan(&season.ID, &season.Name, &season.IsActive, &season.CreatedAt, &registrationToken, &season.SpringRegistrationEnabled, &season.RegistrationStartsAt, &season.MaxRegistrations)
-
+	season, err := scanSeason(db.db.QueryRow("SELECT "+seasonColumns+" FROM seasons WHERE id = ?", id))
 	if err == sql.ErrNoRows {
 		return nil, false, nil
 	}
@@ -193,10 +250,6 @@ func (db *Database) GetSeason(id string) (*Season, bool, error) {
 		return nil, false, fmt.Errorf("failed to get season: %w", err)
 	}
 
-	if registrationToken.Valid {
-		season.RegistrationToken = registrationToken.String
-	}
-
 	return season, true, nil
 }
 
@@ -205,13 +258,7 @@ func (db *Database) GetSeasonByRegistrationToken(token string) (*Season, bool, e
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	season := &Season{}
-	var registrationToken sql.NullString
-	err := db.db.QueryRow(
-		`SELECT id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations FROM seasons WHERE registration_token = ?`,
-		token,
-	).Scan(&season.ID, &season.Name, &season.IsActive, &season.CreatedAt, &registrationToken, &season.SpringRegistrationEnabled, &season.RegistrationStartsAt, &season.MaxRegistrations)
-
+	season, err := scanSeason(db.db.QueryRow("SELECT "+seasonColumns+" FROM seasons WHERE registration_token = ?", token))
 	if err == sql.ErrNoRows {
 		return nil, false, nil
 	}
@@ -220,10 +267,6 @@ func (db *Database) GetSeasonByRegistrationToken(token string) (*Season, bool, e
 		return nil, false, fmt.Errorf("failed to get season by token: %w", err)
 	}
 
-	if registrationToken.Valid {
-		season.RegistrationToken = registrationToken.String
-	}
-
 	return season, true, nil
 }
 
@@ -232,12 +275,7 @@ func (db *Database) GetActiveSeason() (*Season, bool, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	season := &Season{}
-	var registrationToken sql.NullString
-	err := db.db.QueryRow(
-		`SELECT id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations FROM seasons WHERE is_active = 1`,
-	).Scan(&season.ID, &season.Name, &season.IsActive, &season.CreatedAt, &registrationToken, &season.SpringRegistrationEnabled, &season.RegistrationStartsAt, &season.MaxRegistrations)
-
+	season, err := scanSeason(db.db.QueryRow("SELECT " + seasonColumns + " FROM seasons WHERE is_active = 1"))
 	if err == sql.ErrNoRows {
 		return nil, false, nil
 	}
@@ -246,15 +284,11 @@ func (db *Database) GetActiveSeason() (*Season, bool, error) {
 		return nil, false, fmt.Errorf("failed to get active season: %w", err)
 	}
 
-	if registrationToken.Valid {
-		season.RegistrationToken = registrationToken.String
-	}
-
 	return season, true, nil
 }
 
 // SetActiveSeason sets a season as active and deactivates all others
-func (db *Database) SetActiveSeason(seasonID string) error {
+func (db *Database) SetActiveSeason(seasonID, actor string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
@@ -287,7 +321,19 @@ func (db *Database) SetActiveSeason(seasonID string) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("season not found: %s", seasonID)
+		err = fmt.Errorf("season not found: %s", seasonID)
+		return err
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntitySeason,
+		EntityID:   seasonID,
+		Action:     AuditActionUpdate,
+		Actor:      actor,
+		Changes:    []AuditChange{{Field: "isActive", Old: "false", New: "true"}},
+	})
+	if err != nil {
+		return err
 	}
 
 	// Commit the transaction
@@ -299,8 +345,9 @@ func (db *Database) SetActiveSeason(seasonID string) error {
 	return nil
 }
 
-// CopySpringRegistrations copies registrations from one season to another for those who opted for spring
-func (db *Database) CopySpringRegistrations(fromSeasonID, toSeasonID string) error {
+// CopySpringRegistrations copies registrations from one season to another for
+// those who opted for spring. actor is recorded in the audit log.
+func (db *Database) CopySpringRegistrations(fromSeasonID, toSeasonID, actor string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
@@ -308,9 +355,10 @@ func (db *Database) CopySpringRegistrations(fromSeasonID, toSeasonID string) err
 	rows, err := db.db.Query(
 		`SELECT first_name, last_name, grade, teacher, gender, tshirt_size,
 			parent_first_name, parent_last_name, parent_contact_number, backup_contact_number, 
-			parent_email, dismissal_method, allergies, medical_info, opt_out_website_display, opt_out_photo_sharing
+			parent_email, dismissal_method, allergies, medical_info, opt_out_website_display, opt_out_photo_sharing,
+			COALESCE(runner_id, id), COALESCE(household_id, ''), COALESCE(external_id, '')
 		FROM registrations 
-		WHERE season_id = ? AND register_for_spring = 1`,
+		WHERE season_id = ? AND register_for_spring = 1 AND withdrawn_at IS NULL`,
 		fromSeasonID,
 	)
 	if err != nil {
@@ -336,11 +384,13 @@ func (db *Database) CopySpringRegistrations(fromSeasonID, toSeasonID string) err
 		var parentFirstName, parentLastName, parentContactNumber, backupContactNumber string
 		var parentEmail, dismissalMethod, allergies, medicalInfo string
 		var optOutWebsiteDisplay, optOutPhotoSharing sql.NullBool
+		var runnerID, householdID, externalID string
 
 		err := rows.Scan(
 			&firstName, &lastName, &grade, &teacher, &gender, &tshirtSize,
 			&parentFirstName, &parentLastName, &parentContactNumber, &backupContactNumber,
 			&parentEmail, &dismissalMethod, &allergies, &medicalInfo, &optOutWebsiteDisplay, &optOutPhotoSharing,
+			&runnerID, &householdID, &externalID,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to scan registration row: %w", err)
@@ -363,28 +413,59 @@ func (db *Database) CopySpringRegistrations(fromSeasonID, toSeasonID string) err
 		}
 
 		// Create new registration for the target season
-		newID := uuid.New().String()
-		optOut1 := false
-		if optOutWebsiteDisplay.Valid {
-			optOut1 = optOutWebsiteDisplay.Bool
+		reg := &Registration{
+			ID:                   uuid.New().String(),
+			SeasonID:             &toSeasonID,
+			RunnerID:             runnerID,
+			HouseholdID:          householdID, // Siblings stay together in the new season
+			ExternalID:           externalID,
+			FirstName:            firstName,
+			LastName:             lastName,
+			Grade:                grade,
+			Gender:               gender,
+			TshirtSize:           tshirtSize,
+			ParentFirstName:      parentFirstName,
+			ParentLastName:       parentLastName,
+			ParentContactNumber:  parentContactNumber,
+			BackupContactNumber:  backupContactNumber,
+			ParentEmail:          parentEmail,
+			DismissalMethod:      dismissalMethod,
+			Allergies:            allergies,
+			MedicalInfo:          medicalInfo,
+			OptOutWebsiteDisplay: optOutWebsiteDisplay.Valid && optOutWebsiteDisplay.Bool,
+			OptOutPhotoSharing:   optOutPhotoSharing.Valid && optOutPhotoSharing.Bool,
+			RegisteredAt:         time.Now(),
+			EditToken:            uuid.New().String(),
 		}
-		optOut2 := false
-		if optOutPhotoSharing.Valid {
-			optOut2 = optOutPhotoSharing.Bool
+		if err = linkHousehold(tx, reg); err != nil {
+			return fmt.Errorf("failed to link household: %w", err)
 		}
 		_, err = tx.Exec(
 			`INSERT INTO registrations (
 				id, season_id, first_name, last_name, grade, teacher, gender, tshirt_size,
 				parent_first_name, parent_last_name, parent_contact_number, backup_contact_number, 
-				parent_email, dismissal_method, allergies, medical_info, register_for_spring, opt_out_website_display, opt_out_photo_sharing, registered_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?)`,
-			newID, toSeasonID, firstName, lastName, grade, "", gender, tshirtSize,
-			parentFirstName, parentLastName, parentContactNumber, backupContactNumber,
-			parentEmail, dismissalMethod, allergies, medicalInfo, optOut1, optOut2, time.Now(),
+				parent_email, dismissal_method, allergies, medical_info, register_for_spring, opt_out_website_display, opt_out_photo_sharing, registered_at,
+				runner_id, edit_token, household_id, external_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?)`,
+			reg.ID, toSeasonID, reg.FirstName, reg.LastName, reg.Grade, reg.Teacher, reg.Gender, reg.TshirtSize,
+			reg.ParentFirstName, reg.ParentLastName, reg.ParentContactNumber, reg.BackupContactNumber,
+			reg.ParentEmail, reg.DismissalMethod, reg.Allergies, reg.MedicalInfo, reg.OptOutWebsiteDisplay, reg.OptOutPhotoSharing, reg.RegisteredAt,
+			reg.RunnerID, reg.EditToken, reg.HouseholdID, reg.ExternalID,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert copied registration: %w", err)
 		}
+		err = writeAudit(tx, &AuditEntry{
+			EntityType:     AuditEntityRegistration,
+			EntityID:       reg.ID,
+			RegistrationID: reg.ID,
+			Action:         AuditActionCreate,
+			Actor:          actor,
+			Changes:        auditDiff(nil, reg),
+		})
+		if err != nil {
+			return err
+		}
 		count++
 	}
 
@@ -403,11 +484,7 @@ func (db *Database) GetAllSeasons() ([]*Season, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	rows, err := db.db.Query(
-		`SELECT id, name, is_active, created_at, registration_token, spring_registration_enabled, registration_starts_at, max_registrations 
-		FROM seasons
-		ORDER BY created_at DESC`,
-	)
+	rows, err := db.db.Query("SELECT " + seasonColumns + " FROM seasons ORDER BY created_at DESC")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query seasons: %w", err)
 	}
@@ -415,17 +492,10 @@ func (db *Database) GetAllSeasons() ([]*Season, error) {
 
 	var seasons []*Season
 	for rows.Next() {
-		season := &Season{}
-		var registrationToken sql.NullString
-		err := rows.Scan(
-			&season.ID, &season.Name, &season.IsActive, &season.CreatedAt, &registrationToken, &season.SpringRegistrationEnabled, &season.RegistrationStartsAt, &season.MaxRegistrations,
-		)
+		season, err := scanSeason(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan season row: %w", err)
 		}
-		if registrationToken.Valid {
-			season.RegistrationToken = registrationToken.String
-		}
 		seasons = append(seasons, season)
 	}
 
@@ -436,27 +506,82 @@ func (db *Database) GetAllSeasons() ([]*Season, error) {
 	return seasons, nil
 }
 
-// SaveRegistration saves a registration to the database
-func (db *Database) SaveRegistration(reg *Registration) error {
+// SaveRegistration saves a registration to the database, linking it to the
+// runner it belongs to. actor is recorded in the audit log.
+func (db *Database) SaveRegistration(reg *Registration, actor string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	_, err := db.db.Exec(
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = insertRegistration(tx, reg, actor)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertRegistration links a new registration to its runner and household,
+// saves it and records it in the audit log as part of a transaction
+func insertRegistration(tx *sql.Tx, reg *Registration, actor string) error {
+	err := linkRunner(tx, reg)
+	if err != nil {
+		return fmt.Errorf("failed to link runner: %w", err)
+	}
+	if err := linkHousehold(tx, reg); err != nil {
+		return fmt.Errorf("failed to link household: %w", err)
+	}
+
+	if reg.EditToken == "" {
+		reg.EditToken = uuid.New().String()
+	}
+
+	_, err = tx.Exec(
 		`INSERT INTO registrations (
 			id, season_id, first_name, last_name, grade, teacher, gender, tshirt_size,
 			parent_first_name, parent_last_name, parent_contact_number, backup_contact_number, parent_email, 
-			dismissal_method, allergies, medical_info, register_for_spring, opt_out_website_display, opt_out_photo_sharing, registered_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			dismissal_method, allergies, medical_info, register_for_spring, opt_out_website_display, opt_out_photo_sharing, registered_at,
+			runner_id, edit_token, waiver_version, waiver_signed_name, waiver_signed_at, waiver_signed_ip, fee_status, household_id, external_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		reg.ID, reg.SeasonID, reg.FirstName, reg.LastName, reg.Grade, reg.Teacher, reg.Gender, reg.TshirtSize,
 		reg.ParentFirstName, reg.ParentLastName, reg.ParentContactNumber, reg.BackupContactNumber, reg.ParentEmail,
 		reg.DismissalMethod, reg.Allergies, reg.MedicalInfo, reg.RegisterForSpring, reg.OptOutWebsiteDisplay, reg.OptOutPhotoSharing, reg.RegisteredAt,
+		reg.RunnerID, reg.EditToken, reg.WaiverVersion, reg.WaiverSignedName, reg.WaiverSignedAt, reg.WaiverSignedIP, reg.FeeStatus, reg.HouseholdID, reg.ExternalID,
 	)
-
 	if err != nil {
 		return fmt.Errorf("failed to save registration: %w", err)
 	}
 
-	return nil
+	if err := saveAnswers(tx, reg); err != nil {
+		return err
+	}
+
+	return writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       reg.ID,
+		RegistrationID: reg.ID,
+		Action:         AuditActionCreate,
+		Actor:          actor,
+		Changes:        auditDiff(nil, reg),
+	})
+}
+
+// rowQuerier is a *sql.DB or *sql.Tx, for lookups that are also needed inside a transaction
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // GetRegistration retrieves a registration by ID
@@ -464,6 +589,24 @@ func (db *Database) GetRegistration(id string) (*Registration, bool, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
+	return getRegistration(db.db, id)
+}
+
+// GetRegistrationByEditToken retrieves the registration a parent's edit link belongs to
+func (db *Database) GetRegistrationByEditToken(token string) (*Registration, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return getRegistrationBy(db.db, "r.edit_token", token)
+}
+
+// getRegistration retrieves a registration and its season by ID
+func getRegistration(q rowQuerier, id string) (*Registration, bool, error) {
+	return getRegistrationBy(q, "r.id", id)
+}
+
+// getRegistrationBy retrieves a registration and its season by a unique column
+func getRegistrationBy(q rowQuerier, column, value string) (*Registration, bool, error) {
 	reg := &Registration{}
 	var seasonID sql.NullString
 	var genderNull sql.NullString
@@ -474,20 +617,27 @@ func (db *Database) GetRegistration(id string) (*Registration, bool, error) {
 	var parentLastNameNull sql.NullString
 	var optOutWebsiteDisplayNull sql.NullBool
 	var optOutPhotoSharingNull sql.NullBool
+	var withdrawnAt, waiverSignedAt sql.NullTime
 
 	// Query registration with season data
 	var tshirtSizeNull sql.NullString
-	err := db.db.QueryRow(
+	err := q.QueryRow(
 		`SELECT
 			r.id, r.season_id, r.first_name, r.last_name, r.grade, r.teacher, r.gender, r.tshirt_size,
 			r.parent_first_name, r.parent_last_name, r.parent_contact_number, r.backup_contact_number, r.parent_email, 
-			r.dismissal_method, r.allergies, r.medical_info, r.register_for_spring, r.opt_out_website_display, r.opt_out_photo_sharing, r.registered_at
-		FROM registrations r WHERE r.id = ?`,
-		id,
+			r.dismissal_method, r.allergies, r.medical_info, r.register_for_spring, r.opt_out_website_display, r.opt_out_photo_sharing, r.registered_at,
+			COALESCE(r.runner_id, ''), r.withdrawn_at, COALESCE(r.withdrawn_by, ''), COALESCE(r.withdraw_reason, ''),
+			COALESCE(r.edit_token, ''), COALESCE(r.waiver_version, 0), COALESCE(r.waiver_signed_name, ''), r.waiver_signed_at,
+			COALESCE(r.waiver_signed_ip, ''), r.fee_status, COALESCE(r.household_id, ''), COALESCE(r.external_id, '')
+		FROM registrations r WHERE `+column+` = ?`,
+		value,
 	).Scan(
 		&reg.ID, &seasonID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &genderNull, &tshirtSizeNull,
 		&parentFirstNameNull, &parentLastNameNull, &reg.ParentContactNumber, &reg.BackupContactNumber, &reg.ParentEmail,
 		&dismissalMethodNull, &allergiesNull, &medicalInfoNull, &reg.RegisterForSpring, &optOutWebsiteDisplayNull, &optOutPhotoSharingNull, &reg.RegisteredAt,
+		&reg.RunnerID, &withdrawnAt, &reg.WithdrawnBy, &reg.WithdrawReason,
+		&reg.EditToken, &reg.WaiverVersion, &reg.WaiverSignedName, &waiverSignedAt,
+		&reg.WaiverSignedIP, &reg.FeeStatus, &reg.HouseholdID, &reg.ExternalID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -549,6 +699,12 @@ func (db *Database) GetRegistration(id string) (*Registration, bool, error) {
 	} else {
 		reg.OptOutPhotoSharing = false
 	}
+	if withdrawnAt.Valid {
+		reg.WithdrawnAt = &withdrawnAt.Time
+	}
+	if waiverSignedAt.Valid {
+		reg.WaiverSignedAt = &waiverSignedAt.Time
+	}
 
 	// Set season ID if not null
 	if seasonID.Valid {
@@ -557,7 +713,7 @@ func (db *Database) GetRegistration(id string) (*Registration, bool, error) {
 		// Fetch the associated season
 		season := &Season{}
 		var seasonRegToken sql.NullString
-		err = db.db.QueryRow(
+		err = q.QueryRow(
 			`SELECT id, name, is_active, created_at, registration_token
 			FROM seasons WHERE id = ?`,
 			reg.SeasonID,
@@ -578,7 +734,8 @@ func (db *Database) GetRegistration(id string) (*Registration, bool, error) {
 	return reg, true, nil
 }
 
-// GetAllRegistrations returns all registrations, optionally filtered by season
+// GetAllRegistrations returns all registrations that haven't been withdrawn,
+// optionally filtered by season
 func (db *Database) GetAllRegistrations(seasonID string) ([]*Registration, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
@@ -586,15 +743,19 @@ func (db *Database) GetAllRegistrations(seasonID string) ([]*Registration, error
 	query := `SELECT
 		r.id, r.season_id, r.first_name, r.last_name, r.grade, r.teacher, r.gender, r.tshirt_size,
 		r.parent_first_name, r.parent_last_name, r.parent_contact_number, r.backup_contact_number, r.parent_email, r.registered_at,
+		COALESCE(r.runner_id, ''), COALESCE(r.edit_token, ''),
+		COALESCE(r.waiver_version, 0), COALESCE(r.waiver_signed_name, ''), r.waiver_signed_at, r.fee_status,
+		COALESCE(r.household_id, ''), COALESCE(r.external_id, ''),
 		s.id, s.name, s.is_active, s.created_at
 	FROM registrations r
-	INNER JOIN seasons s ON r.season_id = s.id`
+	INNER JOIN seasons s ON r.season_id = s.id
+	WHERE r.withdrawn_at IS NULL`
 
 	args := []interface{}{}
 
 	// Add season filter if provided
 	if seasonID != "" {
-		query += " WHERE r.season_id = ?"
+		query += " AND r.season_id = ?"
 		args = append(args, seasonID)
 	}
 
@@ -615,15 +776,22 @@ func (db *Database) GetAllRegistrations(seasonID string) ([]*Registration, error
 		var seasonCreatedAtNull sql.NullTime
 		var genderNull, tshirtSizeNull sql.NullString
 		var parentFirstNameNull, parentLastNameNull sql.NullString
+		var waiverSignedAt sql.NullTime
 
 		err := rows.Scan(
 			&reg.ID, &reg.SeasonID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &genderNull, &tshirtSizeNull,
 			&parentFirstNameNull, &parentLastNameNull, &reg.ParentContactNumber, &reg.BackupContactNumber, &reg.ParentEmail, &reg.RegisteredAt,
+			&reg.RunnerID, &reg.EditToken,
+			&reg.WaiverVersion, &reg.WaiverSignedName, &waiverSignedAt, &reg.FeeStatus,
+			&reg.HouseholdID, &reg.ExternalID,
 			&seasonIDNull, &seasonNameNull, &seasonIsActiveNull, &seasonCreatedAtNull,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan registration row: %w", err)
 		}
+		if waiverSignedAt.Valid {
+			reg.WaiverSignedAt = &waiverSignedAt.Time
+		}
 
 		// Handle NULL values
 		if genderNull.Valid {
@@ -666,8 +834,9 @@ func (db *Database) GetAllRegistrations(seasonID string) ([]*Registration, error
 	return regs, nil
 }
 
-// GetFilteredRegistrations returns registrations with filtering, pagination, and search functionality
-func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page, perPage int) ([]*Registration, int, error) {
+// GetFilteredRegistrations returns registrations with filtering, pagination, and search functionality.
+// If answer is set, only runners whose answers to that question match it are returned.
+func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, answer *AnswerFilter, page, perPage int) ([]*Registration, int, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
@@ -678,22 +847,22 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 	query := `SELECT
 		r.id, r.season_id, r.first_name, r.last_name, r.grade, r.teacher, r.gender, r.tshirt_size,
 		r.parent_first_name, r.parent_last_name, r.parent_contact_number, r.backup_contact_number, r.parent_email, 
-		r.dismissal_method, r.allergies, r.medical_info, r.registered_at,
-		s.id, s.name, s.is_active, s.created_at
+		r.dismissal_method, r.allergies, r.medical_info, r.registered_at, COALESCE(r.runner_id, ''),
+		s.id, s.name, s.is_active, s.created_at, s.keep_badges
 	FROM registrations r
 	INNER JOIN seasons s ON r.season_id = s.id`
 
 	// Count query for pagination
 	countQuery := `SELECT COUNT(*) FROM registrations r`
 
-	// Build where clause
-	whereClause := ""
+	// Build where clause, leaving out withdrawn runners
+	whereClause := " WHERE r.withdrawn_at IS NULL"
 	args := []interface{}{}
 	countArgs := []interface{}{}
 
 	// Add season filter if provided
 	if seasonID != "" {
-		whereClause = " WHERE r.season_id = ?"
+		whereClause += " AND r.season_id = ?"
 		args = append(args, seasonID)
 		countArgs = append(countArgs, seasonID)
 	}
@@ -701,12 +870,7 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 	// Add search filter if provided
 	if searchQuery != "" {
 		searchTerm := "%" + searchQuery + "%"
-		if whereClause == "" {
-			whereClause = " WHERE"
-		} else {
-			whereClause += " AND"
-		}
-		whereClause += ` (
+		whereClause += ` AND (
 			r.first_name LIKE ? OR
 			r.last_name LIKE ? OR
 			r.grade LIKE ? OR
@@ -717,6 +881,23 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 		countArgs = append(countArgs, searchTerm, searchTerm, searchTerm, searchTerm, searchTerm)
 	}
 
+	// Add custom question answer filter if provided
+	if answer != nil {
+		answered := "SELECT 1 FROM registration_answers a WHERE a.registration_id = r.id AND a.question_id = ?"
+		args = append(args, answer.QuestionID)
+		countArgs = append(countArgs, answer.QuestionID)
+		switch answer.Value {
+		case "":
+			whereClause += " AND NOT EXISTS (" + answered + ")"
+		case answerAny:
+			whereClause += " AND EXISTS (" + answered + ")"
+		default:
+			whereClause += " AND EXISTS (" + answered + " AND a.value = ?)"
+			args = append(args, answer.Value)
+			countArgs = append(countArgs, answer.Value)
+		}
+	}
+
 	// Complete queries
 	query += whereClause + " ORDER BY r.registered_at DESC LIMIT ? OFFSET ?"
 	countQuery += whereClause
@@ -745,14 +926,15 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 		var seasonIDNull, seasonNameNull sql.NullString
 		var seasonIsActiveNull sql.NullBool
 		var seasonCreatedAtNull sql.NullTime
+		var seasonKeepBadgesNull sql.NullBool
 		var genderNull, tshirtSizeNull, dismissalMethodNull, allergiesNull, medicalInfoNull sql.NullString
 		var parentFirstNameNull, parentLastNameNull sql.NullString
 
 		err := rows.Scan(
 			&reg.ID, &reg.SeasonID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &genderNull, &tshirtSizeNull,
 			&parentFirstNameNull, &parentLastNameNull, &reg.ParentContactNumber, &reg.BackupContactNumber, &reg.ParentEmail,
-			&dismissalMethodNull, &allergiesNull, &medicalInfoNull, &reg.RegisteredAt,
-			&seasonIDNull, &seasonNameNull, &seasonIsActiveNull, &seasonCreatedAtNull,
+			&dismissalMethodNull, &allergiesNull, &medicalInfoNull, &reg.RegisteredAt, &reg.RunnerID,
+			&seasonIDNull, &seasonNameNull, &seasonIsActiveNull, &seasonCreatedAtNull, &seasonKeepBadgesNull,
 		)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan registration row: %w", err)
@@ -804,6 +986,7 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 			season.Name = seasonNameNull.String
 			season.IsActive = seasonIsActiveNull.Bool
 			season.CreatedAt = seasonCreatedAtNull.Time
+			season.KeepBadges = seasonKeepBadgesNull.Bool
 			reg.Season = &season
 		}
 
@@ -818,7 +1001,7 @@ func (db *Database) GetFilteredRegistrations(seasonID, searchQuery string, page,
 }
 
 // SaveTrack saves a track to the database
-func (db *Database) SaveTrack(track *Track) error {
+func (db *Database) SaveTrack(track *Track, actor string) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
@@ -851,6 +1034,17 @@ func (db *Database) SaveTrack(track *Track) error {
 		return fmt.Errorf("failed to save track: %w", err)
 	}
 
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntityTrack,
+		EntityID:   track.ID,
+		Action:     AuditActionCreate,
+		Actor:      actor,
+		Changes:    auditDiff(nil, track),
+	})
+	if err != nil {
+		return err
+	}
+
 	// Commit the transaction
 	err = tx.Commit()
 	if err != nil {
@@ -921,8 +1115,59 @@ func (db *Database) GetDefaultTrackForSeason(seasonID string) (*Track, error) {
 	return track, nil
 }
 
+// ErrRegistrationNotFound is returned when a scan is recorded for an unknown registration
+var ErrRegistrationNotFound = errors.New("registration not found")
+
+// ErrRegistrationWithdrawn is returned when a scan is recorded for a runner who has withdrawn
+var ErrRegistrationWithdrawn = errors.New("registration withdrawn")
+
+// ErrDuplicateScan is returned when a scan with the same client idempotency key was already recorded
+var ErrDuplicateScan = errors.New("scan already recorded")
+
+// ScanTooSoonError is returned when a scan is faster than the minimum pace since an adjacent scan
+type ScanTooSoonError struct {
+	MinutesSince float64
+	PacePerMile  float64
+}
+
+func (e *ScanTooSoonError) Error() string {
+	return fmt.Sprintf("scan rejected: too soon since last scan (%.1f minutes ago, %.1f min/mile pace). Minimum pace is 5 min/mile", e.MinutesSince, e.PacePerMile)
+}
+
+// Scan sources record how a run was captured
+const (
+	ScanSourceQR     = "qr"
+	ScanSourceManual = "manual"
+	ScanSourceImport = "import" // Copied by an admin from a paper scan log
+)
+
+// isValidScanSource checks if a source can be set by a scanner client
+func isValidScanSource(source string) bool {
+	return source == ScanSourceQR || source == ScanSourceManual
+}
+
+// ScanOptions holds optional details about how a scan was recorded
+type ScanOptions struct {
+	// ClientScanID makes the call idempotent: replaying it returns ErrDuplicateScan
+	ClientScanID string
+	// RecordedBy is the username of the scanner who recorded the scan
+	RecordedBy string
+	// Source is how the scan was captured; defaults to ScanSourceQR
+	Source string
+}
+
 // RecordScan records a new scan in the database
 func (db *Database) RecordScan(registrationID string, trackID *string) (*ScanRecord, *Registration, error) {
+	return db.RecordScanAt(registrationID, trackID, time.Now(), ScanOptions{})
+}
+
+// RecordScanAt records a scan that happened at scannedAt, which may be in the past
+// for scans queued offline
+func (db *Database) RecordScanAt(registrationID string, trackID *string, scannedAt time.Time, opts ScanOptions) (*ScanRecord, *Registration, error) {
+	if opts.Source == "" {
+		opts.Source = ScanSourceQR
+	}
+
 	// Note: Removed mutex lock - SQLite with WAL mode handles concurrency
 	// Begin a transaction
 	tx, err := db.db.Begin()
@@ -935,30 +1180,100 @@ func (db *Database) RecordScan(registrationID string, trackID *string) (*ScanRec
 		}
 	}()
 
+	scan, reg, err := recordScan(tx, registrationID, trackID, scannedAt, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("committing transaction")
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// If track ID was provided, fetch the track info
+	log.Printf("getting track info")
+	if trackID != nil && *trackID != "" {
+		track := &Track{}
+		err = db.db.QueryRow(
+			`SELECT id, season_id, name, distance_miles, is_default, created_at 
+			FROM tracks WHERE id = ?`,
+			*trackID,
+		).Scan(
+			&track.ID, &track.SeasonID, &track.Name, &track.DistanceMiles,
+			&track.IsDefault, &track.CreatedAt,
+		)
+		if err == nil {
+			scan.Track = track
+		}
+	}
+
+	return scan, reg, nil
+}
+
+// recordScan records a scan within tx, checking the runner can be scanned and
+// the minimum pace since their adjacent scans
+func recordScan(tx *sql.Tx, registrationID string, trackID *string, scannedAt time.Time, opts ScanOptions) (*ScanRecord, *Registration, error) {
+	clientScanID := opts.ClientScanID
+	var err error
+
+	// Scan times are stored and compared as text, so backdated scans, like
+	// those from a paper log in the club's timezone, must be in the same
+	// zone as live ones
+	scannedAt = scannedAt.In(time.Local)
+
+	// Check whether this client scan was already recorded
+	if clientScanID != "" {
+		var existing string
+		err = tx.QueryRow("SELECT id FROM scan_records WHERE client_scan_id = ?", clientScanID).Scan(&existing)
+		if err == nil {
+			err = ErrDuplicateScan
+			return nil, nil, err
+		}
+		if err != sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("failed to check for duplicate scan: %w", err)
+		}
+	}
+
+	// A badge from an earlier season may stand in for this season's registration
+	registrationID, err = resolveBadgeCode(tx, registrationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve badge: %w", err)
+	}
+
 	// Check if the registration exists and get its season ID
 	reg := &Registration{}
 	var seasonID sql.NullString
 	var genderNull sql.NullString
+	var withdrawn bool
 	log.Printf("checking if reg exists")
 	err = tx.QueryRow(
 		`SELECT
 			id, season_id, first_name, last_name, grade, teacher, gender,
-			parent_contact_number, backup_contact_number, parent_email, registered_at
+			parent_contact_number, backup_contact_number, parent_email, registered_at,
+			COALESCE(opt_out_website_display, 0), withdrawn_at IS NOT NULL
 		FROM registrations WHERE id = ?`,
 		registrationID,
 	).Scan(
 		&reg.ID, &seasonID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &genderNull,
 		&reg.ParentContactNumber, &reg.BackupContactNumber, &reg.ParentEmail, &reg.RegisteredAt,
+		&reg.OptOutWebsiteDisplay, &withdrawn,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, nil, fmt.Errorf("registration not found")
+		err = ErrRegistrationNotFound
+		return nil, nil, err
 	}
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get registration: %w", err)
 	}
 
+	if withdrawn {
+		err = ErrRegistrationWithdrawn
+		return nil, nil, err
+	}
+
 	// Handle NULL gender value
 	if genderNull.Valid {
 		reg.Gender = genderNull.String
@@ -1012,50 +1327,89 @@ func (db *Database) RecordScan(registrationID string, trackID *string) (*ScanRec
 		}
 	}
 
-	// Check for last scan to implement debounce (5 min/mile minimum pace)
-	now := time.Now()
+	// Check for adjacent scans to implement debounce (5 min/mile minimum pace).
+	// Offline scans can arrive out of order, so check the nearest scan on both sides.
 	if trackDistance > 0 {
+		// Calculate minimum time required for this distance at 5 min/mile pace
+		minimumTime := time.Duration(trackDistance * 5 * float64(time.Minute))
+
 		var lastScanTime sql.NullTime
 		log.Printf("checking last scan time")
 		err = tx.QueryRow(
-			"SELECT scanned_at FROM scan_records WHERE registration_id = ? ORDER BY scanned_at DESC LIMIT 1",
-			registrationID,
+			"SELECT scanned_at FROM scan_records WHERE registration_id = ? AND voided_at IS NULL AND scanned_at <= ? ORDER BY scanned_at DESC LIMIT 1",
+			registrationID, scannedAt,
 		).Scan(&lastScanTime)
 
 		if err == nil && lastScanTime.Valid {
 			// Calculate time since last scan
-			timeSinceLastScan := now.Sub(lastScanTime.Time)
-
-			// Calculate minimum time required for this distance at 5 min/mile pace
-			minimumTime := time.Duration(trackDistance * 5 * float64(time.Minute))
+			timeSinceLastScan := scannedAt.Sub(lastScanTime.Time)
 
 			// Reject if scan is too soon
 			if timeSinceLastScan < minimumTime {
 				minutesSince := timeSinceLastScan.Minutes()
-				pacePerMile := minutesSince / trackDistance
-				return nil, nil, fmt.Errorf("scan rejected: too soon since last scan (%.1f minutes ago, %.1f min/mile pace). Minimum pace is 5 min/mile", minutesSince, pacePerMile)
+				err = &ScanTooSoonError{MinutesSince: minutesSince, PacePerMile: minutesSince / trackDistance}
+				return nil, nil, err
+			}
+		}
+
+		var nextScanTime sql.NullTime
+		err = tx.QueryRow(
+			"SELECT scanned_at FROM scan_records WHERE registration_id = ? AND voided_at IS NULL AND scanned_at > ? ORDER BY scanned_at ASC LIMIT 1",
+			registrationID, scannedAt,
+		).Scan(&nextScanTime)
+
+		if err == nil && nextScanTime.Valid {
+			timeUntilNextScan := nextScanTime.Time.Sub(scannedAt)
+			if timeUntilNextScan < minimumTime {
+				minutesUntil := timeUntilNextScan.Minutes()
+				err = &ScanTooSoonError{MinutesSince: minutesUntil, PacePerMile: minutesUntil / trackDistance}
+				return nil, nil, err
 			}
 		}
+		err = nil
 	}
 
 	// Create a new scan record
 	scanID := uuid.New().String()
 
 	// Insert the scan record with season ID and track ID
+	var clientScanIDValue, recordedByValue interface{}
+	if clientScanID != "" {
+		clientScanIDValue = clientScanID
+	}
+	if opts.RecordedBy != "" {
+		recordedByValue = opts.RecordedBy
+	}
+	// Attach the scan to the practice it was recorded during
+	practiceID, err := findPracticeForScan(tx, *reg.SeasonID, scannedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find practice: %w", err)
+	}
+
 	log.Printf("inserting scan record")
 	_, err = tx.Exec(
-		`INSERT INTO scan_records (id, registration_id, season_id, track_id, scanned_at) 
-		VALUES (?, ?, ?, ?, ?)`,
-		scanID, registrationID, reg.SeasonID, trackID, now,
+		`INSERT INTO scan_records (id, registration_id, season_id, track_id, scanned_at, client_scan_id, recorded_by, source, practice_id) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		scanID, registrationID, reg.SeasonID, trackID, scannedAt, clientScanIDValue, recordedByValue, opts.Source, practiceID,
 	)
 	if err != nil {
+		// A concurrent replay of the same client scan lost the race on the unique index
+		if clientScanID != "" && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			err = ErrDuplicateScan
+			return nil, nil, err
+		}
 		return nil, nil, fmt.Errorf("failed to insert scan record: %w", err)
 	}
 
-	log.Printf("committing transaction")
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	// Check whether this scan carried the runner past any milestones. Scans
+	// without a track don't count toward distance, matching the statistics.
+	var scanDistance float64
+	if trackID != nil && *trackID != "" {
+		scanDistance = trackDistance
+	}
+	milestones, err := recordMilestonesReached(tx, registrationID, *reg.SeasonID, scanID, scanDistance, scannedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check milestones: %w", err)
 	}
 
 	// Create the scan record to return
@@ -1064,25 +1418,26 @@ func (db *Database) RecordScan(registrationID string, trackID *string) (*ScanRec
 		RegistrationID: registrationID,
 		SeasonID:       *reg.SeasonID,
 		TrackID:        trackID,
-		ScannedAt:      now,
+		ScannedAt:      scannedAt,
+		RecordedBy:     opts.RecordedBy,
+		Source:         opts.Source,
 		Season:         reg.Season,
+		Milestones:     milestones,
+	}
+	if practiceID.Valid {
+		scan.PracticeID = &practiceID.String
 	}
 
-	// If track ID was provided, fetch the track info
-	log.Printf("getting track info")
-	if trackID != nil && *trackID != "" {
-		track := &Track{}
-		err = db.db.QueryRow(
-			`SELECT id, season_id, name, distance_miles, is_default, created_at 
-			FROM tracks WHERE id = ?`,
-			*trackID,
-		).Scan(
-			&track.ID, &track.SeasonID, &track.Name, &track.DistanceMiles,
-			&track.IsDefault, &track.CreatedAt,
-		)
-		if err == nil {
-			scan.Track = track
-		}
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityScan,
+		EntityID:       scanID,
+		RegistrationID: registrationID,
+		Action:         AuditActionCreate,
+		Actor:          opts.RecordedBy,
+		Changes:        auditDiff(nil, scan),
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return scan, reg, nil
@@ -1094,13 +1449,13 @@ func (db *Database) GetPreviousScan(registrationID string, currentScanTime time.
 	var trackID sql.NullString
 	var trackName sql.NullString
 	var trackDistance sql.NullFloat64
-	
+
 	err := db.db.QueryRow(
 		`SELECT sr.id, sr.registration_id, sr.season_id, sr.track_id, sr.scanned_at,
 		t.id, t.name, t.distance_miles
 		FROM scan_records sr
 		LEFT JOIN tracks t ON sr.track_id = t.id
-		WHERE sr.registration_id = ? AND sr.scanned_at < ?
+		WHERE sr.registration_id = ? AND sr.scanned_at < ? AND sr.voided_at IS NULL
 		ORDER BY sr.scanned_at DESC
 		LIMIT 1`,
 		registrationID, currentScanTime,
@@ -1108,15 +1463,15 @@ func (db *Database) GetPreviousScan(registrationID string, currentScanTime time.
 		&scan.ID, &scan.RegistrationID, &scan.SeasonID, &trackID, &scan.ScannedAt,
 		&sql.NullString{}, &trackName, &trackDistance,
 	)
-	
+
 	if err == sql.ErrNoRows {
 		return nil, nil // No previous scan
 	}
-	
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to get previous scan: %w", err)
 	}
-	
+
 	if trackID.Valid {
 		scan.TrackID = &trackID.String
 		if trackName.Valid && trackDistance.Valid {
@@ -1127,7 +1482,7 @@ func (db *Database) GetPreviousScan(registrationID string, currentScanTime time.
 			}
 		}
 	}
-	
+
 	return scan, nil
 }
 
@@ -1139,7 +1494,7 @@ func (db *Database) GetScansByRegistrationID(registrationID string) ([]*ScanReco
 		s.id, s.name, s.is_active, s.created_at
 		FROM scan_records sr
 		LEFT JOIN seasons s ON sr.season_id = s.id
-		WHERE sr.registration_id = ?
+		WHERE sr.registration_id = ? AND sr.voided_at IS NULL
 		ORDER BY sr.scanned_at DESC`,
 		registrationID,
 	)
@@ -1193,13 +1548,14 @@ func (db *Database) GetAllScans(seasonID string) ([]*ScanRecord, error) {
 		s.id, s.name, s.is_active, s.created_at
 	FROM scan_records sr
 	JOIN registrations r ON sr.registration_id = r.id
-	LEFT JOIN seasons s ON sr.season_id = s.id`
+	LEFT JOIN seasons s ON sr.season_id = s.id
+	WHERE sr.voided_at IS NULL`
 
 	args := []interface{}{}
 
 	// Add season filter if provided
 	if seasonID != "" {
-		query += " WHERE sr.season_id = ?"
+		query += " AND sr.season_id = ?"
 		args = append(args, seasonID)
 	}
 
@@ -1251,13 +1607,14 @@ func (db *Database) GetAllScans(seasonID string) ([]*ScanRecord, error) {
 	return scans, nil
 }
 
-// GetRegistrationCountForSeason returns the count of registrations for a given season
+// GetRegistrationCountForSeason returns the count of registrations for a given
+// season, not counting withdrawn runners
 func (db *Database) GetRegistrationCountForSeason(seasonID string) (int, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
 	var count int
-	err := db.db.QueryRow("SELECT COUNT(*) FROM registrations WHERE season_id = ?", seasonID).Scan(&count)
+	err := db.db.QueryRow("SELECT COUNT(*) FROM registrations WHERE season_id = ? AND withdrawn_at IS NULL", seasonID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count registrations for season: %w", err)
 	}
@@ -1271,7 +1628,7 @@ func (db *Database) GetScanCountForSeason(seasonID string) (int, error) {
 	defer db.mutex.RUnlock()
 
 	var count int
-	err := db.db.QueryRow("SELECT COUNT(*) FROM scan_records WHERE season_id = ?", seasonID).Scan(&count)
+	err := db.db.QueryRow("SELECT COUNT(*) FROM scan_records WHERE season_id = ? AND voided_at IS NULL", seasonID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count scans for season: %w", err)
 	}
@@ -1304,7 +1661,7 @@ func (db *Database) GetSeasonStatistics(seasonID string) (*SeasonStats, error) {
 			COALESCE(SUM(t.distance_miles), 0) as total_distance
 		FROM scan_records sr
 		LEFT JOIN tracks t ON sr.track_id = t.id
-		WHERE sr.season_id = ?
+		WHERE sr.season_id = ? AND sr.voided_at IS NULL
 	`, seasonID).Scan(&stats.TotalRuns, &stats.TotalDistance)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total runs and distance: %w", err)
@@ -1315,6 +1672,25 @@ func (db *Database) GetSeasonStatistics(seasonID string) (*SeasonStats, error) {
 		stats.AveragePerRun = stats.TotalDistance / float64(stats.TotalRuns)
 	}
 
+	// Get runs checked in by name instead of a badge scan
+	err = db.db.QueryRow(
+		"SELECT COUNT(*) FROM scan_records WHERE season_id = ? AND source = ? AND voided_at IS NULL",
+		seasonID, ScanSourceManual,
+	).Scan(&stats.ManualRuns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manual runs: %w", err)
+	}
+	if stats.TotalRuns > 0 {
+		stats.ManualRunPercent = float64(stats.ManualRuns) / float64(stats.TotalRuns) * 100
+	}
+
+	// Runners who check in by name the most probably need a new badge
+	manualRunners, err := db.getTopManualCheckInRunners(seasonID, 10)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manual check-in runners: %w", err)
+	}
+	stats.ManualCheckInRunners = manualRunners
+
 	// Get grade statistics
 	gradeRows, err := db.db.Query(`
 		SELECT 
@@ -1323,7 +1699,7 @@ func (db *Database) GetSeasonStatistics(seasonID string) (*SeasonStats, error) {
 			COUNT(sr.id) as total_runs,
 			COALESCE(SUM(t.distance_miles), 0) as total_distance
 		FROM registrations r
-		LEFT JOIN scan_records sr ON r.id = sr.registration_id
+		LEFT JOIN scan_records sr ON r.id = sr.registration_id AND sr.voided_at IS NULL
 		LEFT JOIN tracks t ON sr.track_id = t.id
 		WHERE r.season_id = ?
 		GROUP BY r.grade
@@ -1368,7 +1744,7 @@ func (db *Database) GetSeasonStatistics(seasonID string) (*SeasonStats, error) {
 			COUNT(*) as usage_count
 		FROM scan_records sr
 		JOIN tracks t ON sr.track_id = t.id
-		WHERE sr.season_id = ?
+		WHERE sr.season_id = ? AND sr.voided_at IS NULL
 		GROUP BY t.id, t.name
 		ORDER BY usage_count DESC
 	`, seasonID)
@@ -1399,13 +1775,14 @@ func (db *Database) getTopRunnersForGrade(seasonID, grade string, limit int) ([]
 			r.last_name,
 			r.grade,
 			r.teacher,
+			COALESCE(r.opt_out_website_display, 0),
 			COUNT(sr.id) as run_count,
 			COALESCE(SUM(t.distance_miles), 0) as total_distance
 		FROM registrations r
-		LEFT JOIN scan_records sr ON r.id = sr.registration_id
+		LEFT JOIN scan_records sr ON r.id = sr.registration_id AND sr.voided_at IS NULL
 		LEFT JOIN tracks t ON sr.track_id = t.id
 		WHERE r.season_id = ? AND r.grade = ?
-		GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher
+		GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher, r.opt_out_website_display
 		HAVING run_count > 0
 		ORDER BY total_distance DESC, run_count DESC
 		LIMIT ?
@@ -1420,7 +1797,7 @@ func (db *Database) getTopRunnersForGrade(seasonID, grade string, limit int) ([]
 		var rs RunnerStats
 		err := rows.Scan(
 			&rs.RegistrationID, &rs.FirstName, &rs.LastName,
-			&rs.Grade, &rs.Teacher, &rs.RunCount, &rs.TotalDistance,
+			&rs.Grade, &rs.Teacher, &rs.OptOutWebsiteDisplay, &rs.RunCount, &rs.TotalDistance,
 		)
 		if err != nil {
 			return nil, err
@@ -1431,8 +1808,9 @@ func (db *Database) getTopRunnersForGrade(seasonID, grade string, limit int) ([]
 	return runners, nil
 }
 
-// getTopRunners returns the top N runners overall
-func (db *Database) getTopRunners(seasonID string, limit int) ([]RunnerStats, error) {
+// getTopManualCheckInRunners returns the runners with the most manual check-ins,
+// with RunCount holding the number of manual check-ins
+func (db *Database) getTopManualCheckInRunners(seasonID string, limit int) ([]RunnerStats, error) {
 	rows, err := db.db.Query(`
 		SELECT 
 			r.id,
@@ -1440,17 +1818,16 @@ func (db *Database) getTopRunners(seasonID string, limit int) ([]RunnerStats, er
 			r.last_name,
 			r.grade,
 			r.teacher,
-			COUNT(sr.id) as run_count,
+			COUNT(sr.id) as manual_count,
 			COALESCE(SUM(t.distance_miles), 0) as total_distance
 		FROM registrations r
-		LEFT JOIN scan_records sr ON r.id = sr.registration_id
+		JOIN scan_records sr ON r.id = sr.registration_id AND sr.voided_at IS NULL AND sr.source = ?
 		LEFT JOIN tracks t ON sr.track_id = t.id
 		WHERE r.season_id = ?
 		GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher
-		HAVING run_count > 0
-		ORDER BY total_distance DESC, run_count DESC
+		ORDER BY manual_count DESC, r.last_name, r.first_name
 		LIMIT ?
-	`, seasonID, limit)
+	`, ScanSourceManual, seasonID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -1469,5 +1846,3766 @@ func (db *Database) getTopRunners(seasonID string, limit int) ([]RunnerStats, er
 		runners = append(runners, rs)
 	}
 
-	return runners, nil
+	return runners, rows.Err()
+}
+
+// getTopRunners returns the top N runners overall
+func (db *Database) getTopRunners(seasonID string, limit int) ([]RunnerStats, error) {
+	rows, err := db.db.Query(`
+		SELECT 
+			r.id,
+			r.first_name,
+			r.last_name,
+			r.grade,
+			r.teacher,
+			COALESCE(r.opt_out_website_display, 0),
+			COUNT(sr.id) as run_count,
+			COALESCE(SUM(t.distance_miles), 0) as total_distance
+		FROM registrations r
+		LEFT JOIN scan_records sr ON r.id = sr.registration_id AND sr.voided_at IS NULL
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE r.season_id = ?
+		GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher, r.opt_out_website_display
+		HAVING run_count > 0
+		ORDER BY total_distance DESC, run_count DESC
+		LIMIT ?
+	`, seasonID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runners []RunnerStats
+	for rows.Next() {
+		var rs RunnerStats
+		err := rows.Scan(
+			&rs.RegistrationID, &rs.FirstName, &rs.LastName,
+			&rs.Grade, &rs.Teacher, &rs.OptOutWebsiteDisplay, &rs.RunCount, &rs.TotalDistance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		runners = append(runners, rs)
+	}
+
+	return runners, nil
+}
+
+// CreateUser saves a new user account to the database
+func (db *Database) CreateUser(user *User) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec(
+		`INSERT INTO users (id, username, password_hash, role, is_disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, user.IsDisabled, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (db *Database) GetUser(id string) (*User, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	user := &User{}
+	err := db.db.QueryRow(
+		`SELECT id, username, password_hash, role, is_disabled, created_at, updated_at FROM users WHERE id = ?`,
+		id,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsDisabled, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, true, nil
+}
+
+// GetUserByUsername retrieves a user by username (case-insensitive)
+func (db *Database) GetUserByUsername(username string) (*User, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	user := &User{}
+	err := db.db.QueryRow(
+		`SELECT id, username, password_hash, role, is_disabled, created_at, updated_at FROM users WHERE username = ? COLLATE NOCASE`,
+		username,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsDisabled, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user by username: %w", err)
+	}
+
+	return user, true, nil
+}
+
+// GetAllUsers returns all user accounts ordered by username
+func (db *Database) GetAllUsers() ([]*User, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT id, username, password_hash, role, is_disabled, created_at, updated_at
+		FROM users
+		ORDER BY username COLLATE NOCASE`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user := &User{}
+		err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.IsDisabled, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetUserCount returns the number of user accounts
+func (db *Database) GetUserCount() (int, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var count int
+	err := db.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetUserDisabled enables or disables a user account
+func (db *Database) SetUserDisabled(id string, disabled bool) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	result, err := db.db.Exec(
+		"UPDATE users SET is_disabled = ?, updated_at = ? WHERE id = ?",
+		disabled, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user not found: %s", id)
+	}
+
+	return nil
+}
+
+// UpdateUserPassword replaces the password hash for a user account
+func (db *Database) UpdateUserPassword(id, passwordHash string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	result, err := db.db.Exec(
+		"UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?",
+		passwordHash, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user not found: %s", id)
+	}
+
+	return nil
+}
+
+// CreateSession saves a new login session
+func (db *Database) CreateSession(s *UserSession) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var userID interface{}
+	if s.UserID != "" {
+		userID = s.UserID
+	}
+
+	_, err := db.db.Exec(
+		`INSERT INTO sessions (id, user_id, data, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, userID, s.Data, s.UserAgent, s.IPAddress, s.CreatedAt, s.LastSeenAt, s.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSessionData replaces the stored values of an existing session.
+// The creation time and absolute expiry are left unchanged.
+func (db *Database) UpdateSessionData(s *UserSession) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var userID interface{}
+	if s.UserID != "" {
+		userID = s.UserID
+	}
+
+	_, err := db.db.Exec(
+		`UPDATE sessions SET user_id = ?, data = ?, user_agent = ?, ip_address = ?, last_seen_at = ? WHERE id = ?`,
+		userID, s.Data, s.UserAgent, s.IPAddress, s.LastSeenAt, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession retrieves a session by its hashed ID
+func (db *Database) GetSession(id string) (*UserSession, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	s := &UserSession{}
+	var userID, username sql.NullString
+	err := db.db.QueryRow(
+		`SELECT s.id, s.user_id, u.username, s.data, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at
+		FROM sessions s
+		LEFT JOIN users u ON s.user_id = u.id
+		WHERE s.id = ?`,
+		id,
+	).Scan(&s.ID, &userID, &username, &s.Data, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	s.UserID = userID.String
+	s.Username = username.String
+
+	return s, true, nil
+}
+
+// TouchSession records activity on a session for the idle timeout
+func (db *Database) TouchSession(id string, seenAt time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", seenAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSession revokes a single session
+func (db *Database) DeleteSession(id string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("DELETE FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUserSessions revokes every session belonging to a user
+func (db *Database) DeleteUserSessions(userID string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions past their absolute expiry or idle since idleCutoff
+func (db *Database) DeleteExpiredSessions(now, idleCutoff time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("DELETE FROM sessions WHERE expires_at < ? OR last_seen_at < ?", now, idleCutoff)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	return nil
+}
+
+// GetActiveSessions returns unexpired sessions that belong to a user, grouped by username
+func (db *Database) GetActiveSessions(now, idleCutoff time.Time) ([]*UserSession, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT s.id, s.user_id, u.username, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.expires_at >= ? AND s.last_seen_at >= ?
+		ORDER BY u.username COLLATE NOCASE, s.last_seen_at DESC`,
+		now, idleCutoff,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*UserSession
+	for rows.Next() {
+		s := &UserSession{}
+		err := rows.Scan(&s.ID, &s.UserID, &s.Username, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session row: %w", err)
+		}
+		result = append(result, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// ErrScanNotFound is returned when a scan record does not exist
+var ErrScanNotFound = errors.New("scan not found")
+
+// ErrScanVoided is returned when changing a scan that has already been voided
+var ErrScanVoided = errors.New("scan has already been voided")
+
+// ErrTrackNotFound is returned when a scan is moved to an unknown track
+var ErrTrackNotFound = errors.New("track not found")
+
+// ErrWrongSeason is returned when a scan is moved to a runner or track from another season
+var ErrWrongSeason = errors.New("must belong to the same season as the scan")
+
+// scanColumns lists the scan_records columns read by scanScanRecord
+const scanColumns = `sr.id, sr.registration_id, sr.season_id, sr.track_id, sr.scanned_at, sr.source,
+	sr.recorded_by, sr.voided_at, sr.voided_by, sr.void_reason, sr.practice_id,
+	t.id, t.name, t.distance_miles`
+
+// scanScanRecord reads a row selected with scanColumns, including the joined track
+func scanScanRecord(row interface{ Scan(...interface{}) error }) (*ScanRecord, error) {
+	scan := &ScanRecord{}
+	var trackID, recordedBy, voidedBy, voidReason, practiceID, joinedTrackID, trackName sql.NullString
+	var voidedAt sql.NullTime
+	var trackDistance sql.NullFloat64
+
+	err := row.Scan(
+		&scan.ID, &scan.RegistrationID, &scan.SeasonID, &trackID, &scan.ScannedAt, &scan.Source,
+		&recordedBy, &voidedAt, &voidedBy, &voidReason, &practiceID,
+		&joinedTrackID, &trackName, &trackDistance,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if trackID.Valid {
+		scan.TrackID = &trackID.String
+	}
+	scan.RecordedBy = recordedBy.String
+	if voidedAt.Valid {
+		scan.VoidedAt = &voidedAt.Time
+	}
+	scan.VoidedBy = voidedBy.String
+	scan.VoidReason = voidReason.String
+	if practiceID.Valid {
+		scan.PracticeID = &practiceID.String
+	}
+	if joinedTrackID.Valid {
+		scan.Track = &Track{
+			ID:            joinedTrackID.String,
+			SeasonID:      scan.SeasonID,
+			Name:          trackName.String,
+			DistanceMiles: trackDistance.Float64,
+		}
+	}
+
+	return scan, nil
+}
+
+// GetScan retrieves a single scan record, including voided scans
+func (db *Database) GetScan(id string) (*ScanRecord, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	scan, err := scanScanRecord(db.db.QueryRow(
+		`SELECT `+scanColumns+`
+		FROM scan_records sr
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE sr.id = ?`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get scan: %w", err)
+	}
+
+	return scan, true, nil
+}
+
+// GetScanHistory returns every scan for a registration, newest first, including voided scans
+func (db *Database) GetScanHistory(registrationID string) ([]*ScanRecord, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT `+scanColumns+`
+		FROM scan_records sr
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE sr.registration_id = ?
+		ORDER BY sr.scanned_at DESC`,
+		registrationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scan history: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []*ScanRecord
+	for rows.Next() {
+		scan, err := scanScanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		scans = append(scans, scan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scan rows: %w", err)
+	}
+
+	return scans, nil
+}
+
+// VoidScan marks a scan as voided so it no longer counts toward laps or statistics.
+// The record is kept so the change can be reviewed later.
+func (db *Database) VoidScan(id, voidedBy, reason string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var registrationID string
+	var voidedAt sql.NullTime
+	err = tx.QueryRow("SELECT registration_id, voided_at FROM scan_records WHERE id = ?", id).Scan(&registrationID, &voidedAt)
+	if err == sql.ErrNoRows {
+		err = ErrScanNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check scan: %w", err)
+	}
+	if voidedAt.Valid {
+		err = ErrScanVoided
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"UPDATE scan_records SET voided_at = ?, voided_by = ?, void_reason = ? WHERE id = ?",
+		now, voidedBy, reason, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to void scan: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityScan,
+		EntityID:       id,
+		RegistrationID: registrationID,
+		Action:         AuditActionVoid,
+		Actor:          voidedBy,
+		Changes: []AuditChange{
+			{Field: "voidedAt", New: auditTime(now)},
+			{Field: "voidReason", New: reason},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReassignScan moves a scan recorded against the wrong runner to another
+// registration in the same season
+func (db *Database) ReassignScan(id, registrationID, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var scanSeasonID, oldRegistrationID string
+	var voidedAt sql.NullTime
+	err = tx.QueryRow("SELECT season_id, registration_id, voided_at FROM scan_records WHERE id = ?", id).Scan(&scanSeasonID, &oldRegistrationID, &voidedAt)
+	if err == sql.ErrNoRows {
+		err = ErrScanNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get scan: %w", err)
+	}
+	if voidedAt.Valid {
+		err = ErrScanVoided
+		return err
+	}
+
+	var regSeasonID string
+	err = tx.QueryRow("SELECT season_id FROM registrations WHERE id = ?", registrationID).Scan(&regSeasonID)
+	if err == sql.ErrNoRows {
+		err = ErrRegistrationNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get registration: %w", err)
+	}
+	if regSeasonID != scanSeasonID {
+		err = ErrWrongSeason
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE scan_records SET registration_id = ? WHERE id = ?", registrationID, id)
+	if err != nil {
+		return fmt.Errorf("failed to reassign scan: %w", err)
+	}
+
+	// The change is logged on the runner the scan was taken from; the change
+	// itself names the runner it moved to
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityScan,
+		EntityID:       id,
+		RegistrationID: oldRegistrationID,
+		Action:         AuditActionUpdate,
+		Actor:          actor,
+		Changes:        []AuditChange{{Field: "registrationId", Old: oldRegistrationID, New: registrationID}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateScanTrack changes the track a scan was recorded on
+func (db *Database) UpdateScanTrack(id, trackID, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var scanSeasonID, registrationID string
+	var oldTrackID sql.NullString
+	var voidedAt sql.NullTime
+	err = tx.QueryRow("SELECT season_id, registration_id, track_id, voided_at FROM scan_records WHERE id = ?", id).Scan(&scanSeasonID, &registrationID, &oldTrackID, &voidedAt)
+	if err == sql.ErrNoRows {
+		err = ErrScanNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get scan: %w", err)
+	}
+	if voidedAt.Valid {
+		err = ErrScanVoided
+		return err
+	}
+
+	var trackSeasonID string
+	err = tx.QueryRow("SELECT season_id FROM tracks WHERE id = ?", trackID).Scan(&trackSeasonID)
+	if err == sql.ErrNoRows {
+		err = ErrTrackNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get track: %w", err)
+	}
+	if trackSeasonID != scanSeasonID {
+		err = ErrWrongSeason
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE scan_records SET track_id = ? WHERE id = ?", trackID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update scan track: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityScan,
+		EntityID:       id,
+		RegistrationID: registrationID,
+		Action:         AuditActionUpdate,
+		Actor:          actor,
+		Changes:        []AuditChange{{Field: "trackId", Old: oldTrackID.String, New: trackID}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetScanTotalsSince returns the number of runs and miles in a season since a point
+// in time, which is moved into the server's zone as scan times are stored as text in it
+func (db *Database) GetScanTotalsSince(seasonID string, since time.Time) (int, float64, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var runs int
+	var distance float64
+	err := db.db.QueryRow(`
+		SELECT 
+			COUNT(*),
+			COALESCE(SUM(t.distance_miles), 0)
+		FROM scan_records sr
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE sr.season_id = ? AND sr.scanned_at >= ? AND sr.voided_at IS NULL
+	`, seasonID, since.In(time.Local)).Scan(&runs, &distance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get scan totals: %w", err)
+	}
+
+	return runs, distance, nil
+}
+
+// RecentLap is a recent scan with the runner details needed for public display
+type RecentLap struct {
+	FirstName            string
+	LastName             string
+	Grade                string
+	OptOutWebsiteDisplay bool
+	TrackName            string
+	ScannedAt            time.Time
+}
+
+// GetRecentLaps returns the most recent scans in a season since a point in time,
+// compared in the server's zone like GetScanTotalsSince
+func (db *Database) GetRecentLaps(seasonID string, since time.Time, limit int) ([]RecentLap, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(`
+		SELECT 
+			r.first_name, r.last_name, r.grade, COALESCE(r.opt_out_website_display, 0),
+			COALESCE(t.name, ''), sr.scanned_at
+		FROM scan_records sr
+		JOIN registrations r ON sr.registration_id = r.id
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE sr.season_id = ? AND sr.scanned_at >= ? AND sr.voided_at IS NULL
+		ORDER BY sr.scanned_at DESC
+		LIMIT ?
+	`, seasonID, since.In(time.Local), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent laps: %w", err)
+	}
+	defer rows.Close()
+
+	var laps []RecentLap
+	for rows.Next() {
+		var lap RecentLap
+		err := rows.Scan(&lap.FirstName, &lap.LastName, &lap.Grade, &lap.OptOutWebsiteDisplay, &lap.TrackName, &lap.ScannedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recent lap: %w", err)
+		}
+		laps = append(laps, lap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recent laps: %w", err)
+	}
+
+	return laps, nil
+}
+
+// CreateDisplayToken saves a new display token
+func (db *Database) CreateDisplayToken(token *DisplayToken) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec(
+		`INSERT INTO display_tokens (id, token, name, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		token.ID, token.Token, token.Name, token.CreatedBy, token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create display token: %w", err)
+	}
+
+	return nil
+}
+
+// displayTokenColumns lists the display_tokens columns read by scanDisplayToken
+const displayTokenColumns = `id, token, name, COALESCE(created_by, ''), created_at, last_used_at, revoked_at`
+
+// scanDisplayToken reads a row selected with displayTokenColumns
+func scanDisplayToken(row interface{ Scan(...interface{}) error }) (*DisplayToken, error) {
+	token := &DisplayToken{}
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.Token, &token.Name, &token.CreatedBy, &token.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// GetDisplayTokenByToken looks up a display token by its secret value, including revoked tokens
+func (db *Database) GetDisplayTokenByToken(value string) (*DisplayToken, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	token, err := scanDisplayToken(db.db.QueryRow(
+		`SELECT `+displayTokenColumns+` FROM display_tokens WHERE token = ?`,
+		value,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get display token: %w", err)
+	}
+
+	return token, true, nil
+}
+
+// GetAllDisplayTokens returns every display token, newest first
+func (db *Database) GetAllDisplayTokens() ([]*DisplayToken, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT ` + displayTokenColumns + ` FROM display_tokens ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query display tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*DisplayToken
+	for rows.Next() {
+		token, err := scanDisplayToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan display token row: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating display token rows: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// TouchDisplayToken records when a display token was last used
+func (db *Database) TouchDisplayToken(id string, usedAt time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("UPDATE display_tokens SET last_used_at = ? WHERE id = ?", usedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update display token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeDisplayToken stops a display token from working
+func (db *Database) RevokeDisplayToken(id string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("UPDATE display_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke display token: %w", err)
+	}
+
+	return nil
+}
+
+// milestoneEpsilon absorbs floating point error when summing track distances
+const milestoneEpsilon = 1e-6
+
+// recordMilestonesReached records the milestones a new scan carried the runner
+// across and returns them. A milestone is only ever reached once per runner.
+func recordMilestonesReached(tx *sql.Tx, registrationID, seasonID, scanID string, scanDistance float64, reachedAt time.Time) ([]*Milestone, error) {
+	if scanDistance <= 0 {
+		return nil, nil
+	}
+
+	var total float64
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(t.distance_miles), 0)
+		FROM scan_records sr
+		JOIN tracks t ON sr.track_id = t.id
+		WHERE sr.registration_id = ? AND sr.voided_at IS NULL
+	`, registrationID).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+	before := total - scanDistance
+
+	rows, err := tx.Query(
+		`SELECT id, season_id, miles, name FROM milestones
+		WHERE season_id = ? AND miles > ? AND miles <= ?
+		ORDER BY miles`,
+		seasonID, before+milestoneEpsilon, total+milestoneEpsilon,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var crossed []*Milestone
+	for rows.Next() {
+		m := &Milestone{}
+		if err := rows.Scan(&m.ID, &m.SeasonID, &m.Miles, &m.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		crossed = append(crossed, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var reached []*Milestone
+	for _, m := range crossed {
+		result, err := tx.Exec(
+			`INSERT OR IGNORE INTO milestone_awards (id, registration_id, milestone_id, scan_id, reached_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			uuid.New().String(), registrationID, m.ID, scanID, reachedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// Already reached before, e.g. by a scan that was later voided
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			reached = append(reached, m)
+		}
+	}
+
+	return reached, nil
+}
+
+// GetMilestonesBySeasonID returns a season's milestones, smallest first
+func (db *Database) GetMilestonesBySeasonID(seasonID string) ([]*Milestone, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT id, season_id, miles, name, created_at FROM milestones
+		WHERE season_id = ? ORDER BY miles`,
+		seasonID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query milestones: %w", err)
+	}
+	defer rows.Close()
+
+	var milestones []*Milestone
+	for rows.Next() {
+		m := &Milestone{}
+		if err := rows.Scan(&m.ID, &m.SeasonID, &m.Miles, &m.Name, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan milestone row: %w", err)
+		}
+		milestones = append(milestones, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating milestone rows: %w", err)
+	}
+
+	return milestones, nil
+}
+
+// SaveMilestone adds a milestone to a season
+func (db *Database) SaveMilestone(m *Milestone) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec(
+		"INSERT INTO milestones (id, season_id, miles, name, created_at) VALUES (?, ?, ?, ?, ?)",
+		m.ID, m.SeasonID, m.Miles, m.Name, m.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save milestone: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMilestone removes a milestone and any record of runners reaching it
+func (db *Database) DeleteMilestone(id string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM milestone_awards WHERE milestone_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete milestone awards: %w", err)
+	}
+	_, err = tx.Exec("DELETE FROM milestones WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete milestone: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetMilestoneAwards returns every milestone each runner in a season has
+// reached based on their current distance, so voided or corrected scans are
+// accounted for. Awards already handed out are left out unless includeAwarded is set.
+func (db *Database) GetMilestoneAwards(seasonID string, includeAwarded bool) ([]*MilestoneAward, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	query := `
+		WITH totals AS (
+			SELECT
+				r.id, r.first_name, r.last_name, r.grade, COALESCE(r.teacher, '') AS teacher,
+				COALESCE(SUM(t.distance_miles), 0) AS total_distance
+			FROM registrations r
+			LEFT JOIN scan_records sr ON r.id = sr.registration_id AND sr.voided_at IS NULL
+			LEFT JOIN tracks t ON sr.track_id = t.id
+			WHERE r.season_id = ?
+			GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher
+		)
+		SELECT
+			totals.id, totals.first_name, totals.last_name, totals.grade, totals.teacher, totals.total_distance,
+			m.id, m.name, m.miles,
+			a.reached_at, a.awarded_at, COALESCE(a.awarded_by, '')
+		FROM totals
+		JOIN milestones m ON m.season_id = ? AND m.miles <= totals.total_distance + ?
+		LEFT JOIN milestone_awards a ON a.registration_id = totals.id AND a.milestone_id = m.id`
+	if !includeAwarded {
+		query += " WHERE a.awarded_at IS NULL"
+	}
+	query += " ORDER BY totals.teacher, totals.last_name, totals.first_name, m.miles"
+
+	rows, err := db.db.Query(query, seasonID, seasonID, milestoneEpsilon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query milestone awards: %w", err)
+	}
+	defer rows.Close()
+
+	var awards []*MilestoneAward
+	for rows.Next() {
+		a := &MilestoneAward{}
+		var reachedAt, awardedAt sql.NullTime
+		err := rows.Scan(
+			&a.RegistrationID, &a.FirstName, &a.LastName, &a.Grade, &a.Teacher, &a.TotalDistance,
+			&a.MilestoneID, &a.MilestoneName, &a.Miles,
+			&reachedAt, &awardedAt, &a.AwardedBy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan milestone award row: %w", err)
+		}
+		if reachedAt.Valid {
+			a.ReachedAt = &reachedAt.Time
+		}
+		if awardedAt.Valid {
+			a.AwardedAt = &awardedAt.Time
+		}
+		awards = append(awards, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating milestone award rows: %w", err)
+	}
+
+	return awards, nil
+}
+
+// MarkMilestoneAwarded records that a runner has been handed the award for a milestone
+func (db *Database) MarkMilestoneAwarded(registrationID, milestoneID, awardedBy string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec(
+		`INSERT INTO milestone_awards (id, registration_id, milestone_id, awarded_at, awarded_by)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(registration_id, milestone_id) DO UPDATE SET
+			awarded_at = excluded.awarded_at,
+			awarded_by = excluded.awarded_by`,
+		uuid.New().String(), registrationID, milestoneID, time.Now(), awardedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark milestone awarded: %w", err)
+	}
+
+	return nil
+}
+
+// ErrPracticeNotFound is returned when a practice doesn't exist
+var ErrPracticeNotFound = errors.New("practice not found")
+
+// findPracticeForScan returns the practice a scan at scannedAt belongs to, if any.
+// Cancelled practices never have scans attached.
+func findPracticeForScan(tx *sql.Tx, seasonID string, scannedAt time.Time) (sql.NullString, error) {
+	date := scannedAt.In(clubLocation()).Format(practiceDateLayout)
+	rows, err := tx.Query(
+		`SELECT id, season_id, date, start_time, end_time FROM practices
+		WHERE season_id = ? AND date = ? AND cancelled = 0
+		ORDER BY start_time`,
+		seasonID, date,
+	)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := &Practice{}
+		if err := rows.Scan(&p.ID, &p.SeasonID, &p.Date, &p.StartTime, &p.EndTime); err != nil {
+			return sql.NullString{}, err
+		}
+		if p.Includes(scannedAt) {
+			return sql.NullString{String: p.ID, Valid: true}, nil
+		}
+	}
+
+	return sql.NullString{}, rows.Err()
+}
+
+// SavePractice creates a practice and attaches any scans already recorded during it
+func (db *Database) SavePractice(p *Practice) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(
+		`INSERT INTO practices (id, season_id, date, start_time, end_time, location, cancelled, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.SeasonID, p.Date, p.StartTime, p.EndTime, p.Location, p.Cancelled, p.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save practice: %w", err)
+	}
+
+	if !p.Cancelled {
+		err = attachScansToPractice(tx, p)
+		if err != nil {
+			return fmt.Errorf("failed to attach scans to practice: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// attachScansToPractice attaches scans that aren't part of any practice yet,
+// for practices scheduled after the fact
+func attachScansToPractice(tx *sql.Tx, p *Practice) error {
+	rows, err := tx.Query(
+		"SELECT id, scanned_at FROM scan_records WHERE season_id = ? AND practice_id IS NULL",
+		p.SeasonID,
+	)
+	if err != nil {
+		return err
+	}
+	var scanIDs []string
+	for rows.Next() {
+		var id string
+		var scannedAt time.Time
+		if err := rows.Scan(&id, &scannedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if p.Includes(scannedAt) {
+			scanIDs = append(scanIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range scanIDs {
+		if _, err := tx.Exec("UPDATE scan_records SET practice_id = ? WHERE id = ?", p.ID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// practiceColumns lists the practices columns read by scanPractice, including
+// how many runners were present
+const practiceColumns = `p.id, p.season_id, p.date, p.start_time, p.end_time, COALESCE(p.location, ''), p.cancelled, p.created_at,
+	(SELECT COUNT(DISTINCT sr.registration_id) FROM scan_records sr WHERE sr.practice_id = p.id AND sr.voided_at IS NULL)`
+
+// scanPractice reads a row selected with practiceColumns
+func scanPractice(row interface{ Scan(...interface{}) error }) (*Practice, error) {
+	p := &Practice{}
+	err := row.Scan(&p.ID, &p.SeasonID, &p.Date, &p.StartTime, &p.EndTime, &p.Location, &p.Cancelled, &p.CreatedAt, &p.PresentCount)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPracticesBySeasonID returns a season's practices in date order
+func (db *Database) GetPracticesBySeasonID(seasonID string) ([]*Practice, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT `+practiceColumns+`
+		FROM practices p
+		WHERE p.season_id = ?
+		ORDER BY p.date, p.start_time`,
+		seasonID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query practices: %w", err)
+	}
+	defer rows.Close()
+
+	var practices []*Practice
+	for rows.Next() {
+		p, err := scanPractice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan practice row: %w", err)
+		}
+		practices = append(practices, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating practice rows: %w", err)
+	}
+
+	return practices, nil
+}
+
+// GetPractice retrieves a practice by ID
+func (db *Database) GetPractice(id string) (*Practice, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	p, err := scanPractice(db.db.QueryRow(
+		`SELECT `+practiceColumns+`
+		FROM practices p
+		WHERE p.id = ?`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get practice: %w", err)
+	}
+
+	return p, true, nil
+}
+
+// SetPracticeCancelled cancels or reinstates a practice. Scans already
+// attached stay attached; cancelled practices are left out of attendance.
+// Reinstating a practice attaches the scans recorded while it was cancelled.
+func (db *Database) SetPracticeCancelled(id string, cancelled bool) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec("UPDATE practices SET cancelled = ? WHERE id = ?", cancelled, id)
+	if err != nil {
+		return fmt.Errorf("failed to update practice: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update practice: %w", err)
+	}
+	if n == 0 {
+		err = ErrPracticeNotFound
+		return err
+	}
+
+	if !cancelled {
+		var p *Practice
+		p, err = scanPractice(tx.QueryRow(`SELECT `+practiceColumns+` FROM practices p WHERE p.id = ?`, id))
+		if err != nil {
+			return fmt.Errorf("failed to get practice: %w", err)
+		}
+		err = attachScansToPractice(tx, p)
+		if err != nil {
+			return fmt.Errorf("failed to attach scans to practice: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetPracticeRoster returns every runner in the season with how many laps
+// they ran at the practice
+func (db *Database) GetPracticeRoster(practiceID, seasonID string) ([]*PracticeAttendee, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(`
+		SELECT r.id, r.first_name, r.last_name, r.grade, COALESCE(r.teacher, ''), r.registered_at, COUNT(sr.id)
+		FROM registrations r
+		LEFT JOIN scan_records sr ON sr.registration_id = r.id AND sr.practice_id = ? AND sr.voided_at IS NULL
+		WHERE r.season_id = ? AND r.withdrawn_at IS NULL
+		GROUP BY r.id, r.first_name, r.last_name, r.grade, r.teacher, r.registered_at
+		ORDER BY r.last_name, r.first_name
+	`, practiceID, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query practice roster: %w", err)
+	}
+	defer rows.Close()
+
+	var roster []*PracticeAttendee
+	for rows.Next() {
+		a := &PracticeAttendee{}
+		err := rows.Scan(&a.RegistrationID, &a.FirstName, &a.LastName, &a.Grade, &a.Teacher, &a.RegisteredAt, &a.Laps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan practice roster row: %w", err)
+		}
+		roster = append(roster, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating practice roster rows: %w", err)
+	}
+
+	return roster, nil
+}
+
+// GetAttendedPracticeIDs returns the practices a runner has a non-voided scan at
+func (db *Database) GetAttendedPracticeIDs(registrationID string) (map[string]bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT DISTINCT practice_id FROM scan_records
+		WHERE registration_id = ? AND practice_id IS NOT NULL AND voided_at IS NULL`,
+		registrationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attended practices: %w", err)
+	}
+	defer rows.Close()
+
+	attended := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan attended practice row: %w", err)
+		}
+		attended[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attended practice rows: %w", err)
+	}
+
+	return attended, nil
+}
+
+// linkRunner sets reg.RunnerID if it isn't already set. A runner with the same
+// name and parent email is the same child; otherwise a new runner is created
+// with the registration's ID, and any runners with the same name are queued
+// for an admin to review.
+func linkRunner(tx *sql.Tx, reg *Registration) error {
+	if reg.RunnerID != "" {
+		return nil
+	}
+
+	email := strings.TrimSpace(reg.ParentEmail)
+	if email != "" {
+		err := tx.QueryRow(
+			`SELECT COALESCE(merged_into, id) FROM runners
+			WHERE lower(first_name) = lower(?) AND lower(last_name) = lower(?) AND lower(parent_email) = lower(?)
+			ORDER BY created_at LIMIT 1`,
+			strings.TrimSpace(reg.FirstName), strings.TrimSpace(reg.LastName), email,
+		).Scan(&reg.RunnerID)
+		if err == nil {
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	reg.RunnerID = reg.ID
+	_, err := tx.Exec(
+		"INSERT INTO runners (id, first_name, last_name, parent_email, created_at) VALUES (?, ?, ?, ?, ?)",
+		reg.RunnerID, strings.TrimSpace(reg.FirstName), strings.TrimSpace(reg.LastName), email, reg.RegisteredAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT OR IGNORE INTO runner_match_reviews (id, runner_id, candidate_runner_id, reason, created_at)
+		SELECT lower(hex(randomblob(16))), id, ?, ?, ?
+		FROM runners
+		WHERE lower(first_name) = lower(?) AND lower(last_name) = lower(?) AND id <> ? AND merged_into IS NULL`,
+		reg.RunnerID, runnerMatchReasonSameName, time.Now(),
+		strings.TrimSpace(reg.FirstName), strings.TrimSpace(reg.LastName), reg.RunnerID,
+	)
+	return err
+}
+
+// resolveBadgeCode returns the registration a scanned badge refers to. When the
+// active season keeps badges, a runner ID or a registration ID from any season
+// is matched to the runner's registration in the active season; otherwise the
+// code is used as is.
+func resolveBadgeCode(tx *sql.Tx, code string) (string, error) {
+	var registrationID string
+	err := tx.QueryRow(`
+		SELECT r.id
+		FROM registrations r
+		JOIN seasons s ON r.season_id = s.id AND s.is_active = 1 AND s.keep_badges = 1
+		WHERE r.runner_id = COALESCE((SELECT runner_id FROM registrations WHERE id = ?), ?)
+		ORDER BY r.id = ? DESC, r.registered_at
+		LIMIT 1
+	`, code, code, code).Scan(&registrationID)
+	if err == sql.ErrNoRows {
+		return code, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return registrationID, nil
+}
+
+// GetRunnerHistory returns a runner's registrations in every season with
+// their runs and distance, oldest season first
+func (db *Database) GetRunnerHistory(runnerID string) ([]*RunnerSeasonSummary, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(`
+		SELECT
+			r.id, s.id, s.name, r.grade, COALESCE(r.teacher, ''),
+			COUNT(sr.id),
+			COALESCE(SUM(t.distance_miles), 0)
+		FROM registrations r
+		JOIN seasons s ON r.season_id = s.id
+		LEFT JOIN scan_records sr ON sr.registration_id = r.id AND sr.voided_at IS NULL
+		LEFT JOIN tracks t ON sr.track_id = t.id
+		WHERE r.runner_id = ?
+		GROUP BY r.id, s.id, s.name, s.created_at, r.grade, r.teacher
+		ORDER BY s.created_at
+	`, runnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runner history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*RunnerSeasonSummary
+	for rows.Next() {
+		h := &RunnerSeasonSummary{}
+		err := rows.Scan(&h.RegistrationID, &h.SeasonID, &h.SeasonName, &h.Grade, &h.Teacher, &h.TotalRuns, &h.TotalDistance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan runner history row: %w", err)
+		}
+		history = append(history, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating runner history rows: %w", err)
+	}
+
+	return history, nil
+}
+
+// ErrRunnerMatchNotFound is returned when a runner match review doesn't exist or is already resolved
+var ErrRunnerMatchNotFound = errors.New("runner match not found")
+
+// GetPendingRunnerMatches returns the possible runner matches waiting for an admin
+func (db *Database) GetPendingRunnerMatches() ([]*RunnerMatch, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(`
+		SELECT id, runner_id, candidate_runner_id, reason, created_at
+		FROM runner_match_reviews
+		WHERE resolved_at IS NULL
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runner matches: %w", err)
+	}
+
+	var matches []*RunnerMatch
+	for rows.Next() {
+		m := &RunnerMatch{}
+		if err := rows.Scan(&m.ID, &m.RunnerID, &m.CandidateRunnerID, &m.Reason, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan runner match row: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating runner match rows: %w", err)
+	}
+
+	// Show each side's registrations so the admin can compare them
+	for _, m := range matches {
+		m.Runner, err = getRunnerRegistrations(db.db, m.RunnerID)
+		if err != nil {
+			return nil, err
+		}
+		m.Candidate, err = getRunnerRegistrations(db.db, m.CandidateRunnerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+// getRunnerRegistrations returns the registrations linked to a runner, oldest first
+func getRunnerRegistrations(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, runnerID string) ([]*Registration, error) {
+	rows, err := q.Query(`
+		SELECT r.id, r.first_name, r.last_name, r.grade, COALESCE(r.teacher, ''), r.parent_email, r.registered_at, s.id, s.name
+		FROM registrations r
+		JOIN seasons s ON r.season_id = s.id
+		WHERE r.runner_id = ?
+		ORDER BY s.created_at
+	`, runnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runner registrations: %w", err)
+	}
+	defer rows.Close()
+
+	var regs []*Registration
+	for rows.Next() {
+		reg := &Registration{RunnerID: runnerID, Season: &Season{}}
+		err := rows.Scan(&reg.ID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &reg.ParentEmail, &reg.RegisteredAt, &reg.Season.ID, &reg.Season.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan runner registration row: %w", err)
+		}
+		reg.SeasonID = &reg.Season.ID
+		regs = append(regs, reg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating runner registration rows: %w", err)
+	}
+
+	return regs, nil
+}
+
+// ResolveRunnerMatch records an admin's decision on a possible match. When the
+// runners are the same child, the candidate's registrations move to the other
+// runner, and the merged runner points at the one kept.
+func (db *Database) ResolveRunnerMatch(id string, sameRunner bool, resolvedBy string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var keepID, mergeID string
+	err = tx.QueryRow(
+		"SELECT runner_id, candidate_runner_id FROM runner_match_reviews WHERE id = ? AND resolved_at IS NULL",
+		id,
+	).Scan(&keepID, &mergeID)
+	if err == sql.ErrNoRows {
+		err = ErrRunnerMatchNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get runner match: %w", err)
+	}
+
+	resolution := RunnerMatchDifferent
+	if sameRunner {
+		resolution = RunnerMatchMerged
+	}
+	_, err = tx.Exec(
+		"UPDATE runner_match_reviews SET resolved_at = ?, resolved_by = ?, resolution = ? WHERE id = ?",
+		time.Now(), resolvedBy, resolution, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to resolve runner match: %w", err)
+	}
+
+	if sameRunner {
+		if err = mergeRunners(tx, keepID, mergeID, resolvedBy); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// mergeRunners moves every registration of the runner mergeID onto keepID and
+// marks mergeID as merged, so later registrations link to keepID
+func mergeRunners(tx *sql.Tx, keepID, mergeID, actor string) error {
+	moved, err := getRunnerRegistrations(tx, mergeID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE registrations SET runner_id = ? WHERE runner_id = ?", keepID, mergeID)
+	if err != nil {
+		return fmt.Errorf("failed to move registrations: %w", err)
+	}
+	for _, reg := range moved {
+		err = writeAudit(tx, &AuditEntry{
+			EntityType:     AuditEntityRegistration,
+			EntityID:       reg.ID,
+			RegistrationID: reg.ID,
+			Action:         AuditActionUpdate,
+			Actor:          actor,
+			Changes:        []AuditChange{{Field: "runnerId", Old: mergeID, New: keepID}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE runners SET merged_into = ? WHERE id = ? OR merged_into = ?", keepID, mergeID, mergeID)
+	if err != nil {
+		return fmt.Errorf("failed to mark runner merged: %w", err)
+	}
+
+	// Other open reviews for the merged runner now apply to the runner it was merged into
+	_, err = tx.Exec(
+		`UPDATE OR IGNORE runner_match_reviews SET runner_id = ? WHERE runner_id = ? AND resolved_at IS NULL`,
+		keepID, mergeID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update runner matches: %w", err)
+	}
+	_, err = tx.Exec(
+		`UPDATE OR IGNORE runner_match_reviews SET candidate_runner_id = ? WHERE candidate_runner_id = ? AND resolved_at IS NULL`,
+		keepID, mergeID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update runner matches: %w", err)
+	}
+	_, err = tx.Exec(
+		`DELETE FROM runner_match_reviews
+		WHERE resolved_at IS NULL AND (runner_id = candidate_runner_id OR runner_id = ? OR candidate_runner_id = ?)`,
+		mergeID, mergeID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clean up runner matches: %w", err)
+	}
+	return nil
+}
+
+// UpdateRegistration saves edits to a registration loaded with
+// GetRegistration, recording the old and new values in the audit log. The
+// season, runner and registration time stay as they were.
+func (db *Database) UpdateRegistration(reg *Registration, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = updateRegistration(tx, reg, actor); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// updateRegistration saves a registration's editable details within a
+// transaction and records the changes in the audit log
+func updateRegistration(tx *sql.Tx, reg *Registration, actor string) error {
+	old, found, err := getRegistration(tx, reg.ID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrRegistrationNotFound
+	}
+
+	_, err = tx.Exec(
+		`UPDATE registrations SET
+			first_name = ?, last_name = ?, grade = ?, teacher = ?, gender = ?, tshirt_size = ?,
+			parent_first_name = ?, parent_last_name = ?, parent_contact_number = ?, backup_contact_number = ?, parent_email = ?,
+			dismissal_method = ?, allergies = ?, medical_info = ?, register_for_spring = ?, opt_out_website_display = ?, opt_out_photo_sharing = ?,
+			waiver_version = ?, waiver_signed_name = ?, waiver_signed_at = ?, waiver_signed_ip = ?, fee_status = ?,
+			household_id = ?, external_id = ?
+		WHERE id = ?`,
+		reg.FirstName, reg.LastName, reg.Grade, reg.Teacher, reg.Gender, reg.TshirtSize,
+		reg.ParentFirstName, reg.ParentLastName, reg.ParentContactNumber, reg.BackupContactNumber, reg.ParentEmail,
+		reg.DismissalMethod, reg.Allergies, reg.MedicalInfo, reg.RegisterForSpring, reg.OptOutWebsiteDisplay, reg.OptOutPhotoSharing,
+		reg.WaiverVersion, reg.WaiverSignedName, reg.WaiverSignedAt, reg.WaiverSignedIP, reg.FeeStatus,
+		reg.HouseholdID, reg.ExternalID,
+		reg.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update registration: %w", err)
+	}
+
+	if changes := auditDiff(old, reg); len(changes) > 0 {
+		return writeAudit(tx, &AuditEntry{
+			EntityType:     AuditEntityRegistration,
+			EntityID:       reg.ID,
+			RegistrationID: reg.ID,
+			Action:         AuditActionUpdate,
+			Actor:          actor,
+			Changes:        changes,
+		})
+	}
+	return nil
+}
+
+// WithdrawRegistration takes a runner off the season's rosters, badges and
+// capacity count. Their scans are kept, so totals and history still add up.
+func (db *Database) WithdrawRegistration(id, withdrawnBy, reason string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var withdrawnAt sql.NullTime
+	err = tx.QueryRow("SELECT withdrawn_at FROM registrations WHERE id = ?", id).Scan(&withdrawnAt)
+	if err == sql.ErrNoRows {
+		err = ErrRegistrationNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check registration: %w", err)
+	}
+	if withdrawnAt.Valid {
+		err = ErrRegistrationWithdrawn
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"UPDATE registrations SET withdrawn_at = ?, withdrawn_by = ?, withdraw_reason = ? WHERE id = ?",
+		now, withdrawnBy, reason, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to withdraw registration: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       id,
+		RegistrationID: id,
+		Action:         AuditActionWithdraw,
+		Actor:          withdrawnBy,
+		Changes: []AuditChange{
+			{Field: "withdrawnAt", New: auditTime(now)},
+			{Field: "withdrawReason", New: reason},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReinstateRegistration puts a withdrawn runner back on the season's rosters
+func (db *Database) ReinstateRegistration(id, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var withdrawnAt sql.NullTime
+	var reason string
+	err = tx.QueryRow("SELECT withdrawn_at, COALESCE(withdraw_reason, '') FROM registrations WHERE id = ?", id).Scan(&withdrawnAt, &reason)
+	if err == sql.ErrNoRows {
+		err = ErrRegistrationNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check registration: %w", err)
+	}
+	if !withdrawnAt.Valid {
+		return tx.Rollback()
+	}
+
+	_, err = tx.Exec(
+		"UPDATE registrations SET withdrawn_at = NULL, withdrawn_by = NULL, withdraw_reason = NULL WHERE id = ?",
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reinstate registration: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       id,
+		RegistrationID: id,
+		Action:         AuditActionReinstate,
+		Actor:          actor,
+		Changes: []AuditChange{
+			{Field: "withdrawnAt", Old: auditTime(withdrawnAt.Time)},
+			{Field: "withdrawReason", Old: reason},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetWithdrawnRegistrations returns the runners who have withdrawn from a
+// season, most recent first
+func (db *Database) GetWithdrawnRegistrations(seasonID string) ([]*Registration, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(`
+		SELECT id, first_name, last_name, grade, COALESCE(teacher, ''), withdrawn_at,
+			COALESCE(withdrawn_by, ''), COALESCE(withdraw_reason, '')
+		FROM registrations
+		WHERE season_id = ? AND withdrawn_at IS NOT NULL
+		ORDER BY withdrawn_at DESC
+	`, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query withdrawn registrations: %w", err)
+	}
+	defer rows.Close()
+
+	var regs []*Registration
+	for rows.Next() {
+		reg := &Registration{}
+		var withdrawnAt time.Time
+		err := rows.Scan(&reg.ID, &reg.FirstName, &reg.LastName, &reg.Grade, &reg.Teacher, &withdrawnAt,
+			&reg.WithdrawnBy, &reg.WithdrawReason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan withdrawn registration row: %w", err)
+		}
+		reg.WithdrawnAt = &withdrawnAt
+		regs = append(regs, reg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating withdrawn registration rows: %w", err)
+	}
+
+	return regs, nil
+}
+
+// writeAudit appends an entry to the audit log as part of the change's transaction
+func writeAudit(tx *sql.Tx, entry *AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	changes := entry.Changes
+	if changes == nil {
+		changes = []AuditChange{}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	var registrationID interface{}
+	if entry.RegistrationID != "" {
+		registrationID = entry.RegistrationID
+	}
+	result, err := tx.Exec(
+		`INSERT INTO audit_log (entity_type, entity_id, registration_id, action, actor, changes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.EntityType, entry.EntityID, registrationID, entry.Action, entry.Actor, string(changesJSON), entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	entry.ID, _ = result.LastInsertId()
+
+	return nil
+}
+
+// auditColumns are the audit log columns read by scanAuditEntry, with the
+// runner's name when the entry belongs to one
+const auditColumns = `a.id, a.entity_type, a.entity_id, COALESCE(a.registration_id, ''), a.action, a.actor, a.changes, a.created_at,
+	COALESCE(r.first_name || ' ' || r.last_name, '')`
+
+// scanAuditEntry scans a row selected with auditColumns
+func scanAuditEntry(row interface{ Scan(...interface{}) error }) (*AuditEntry, error) {
+	e := &AuditEntry{}
+	var changes string
+	err := row.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.RegistrationID, &e.Action, &e.Actor, &changes, &e.CreatedAt, &e.RunnerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode audit changes: %w", err)
+	}
+	return e, nil
+}
+
+// queryAuditLog runs an audit log query and scans every entry
+func (db *Database) queryAuditLog(query string, args ...interface{}) ([]*AuditEntry, error) {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetRegistrationAuditLog returns the changes made to a runner's registration
+// and scans, newest first. Scans being recorded aren't included; they're
+// already in the runner's scan history.
+func (db *Database) GetRegistrationAuditLog(registrationID string) ([]*AuditEntry, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.queryAuditLog(`
+		SELECT `+auditColumns+`
+		FROM audit_log a
+		LEFT JOIN registrations r ON r.id = a.registration_id
+		WHERE a.registration_id = ? AND NOT (a.entity_type = ? AND a.action = ?)
+		ORDER BY a.id DESC
+	`, registrationID, AuditEntityScan, AuditActionCreate)
+}
+
+// SearchAuditLog returns the most recent audit log entries, optionally only
+// for one entity type and matching a search of the actor, runner name, IDs
+// and changed values
+func (db *Database) SearchAuditLog(searchQuery, entityType string, limit int) ([]*AuditEntry, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	query := `SELECT ` + auditColumns + `
+		FROM audit_log a
+		LEFT JOIN registrations r ON r.id = a.registration_id
+		WHERE 1 = 1`
+	args := []interface{}{}
+
+	if entityType != "" {
+		query += " AND a.entity_type = ?"
+		args = append(args, entityType)
+	}
+	if searchQuery != "" {
+		searchTerm := "%" + searchQuery + "%"
+		query += ` AND (
+			a.actor LIKE ? OR
+			a.entity_id LIKE ? OR
+			a.registration_id LIKE ? OR
+			a.changes LIKE ? OR
+			r.first_name || ' ' || r.last_name LIKE ?
+		)`
+		args = append(args, searchTerm, searchTerm, searchTerm, searchTerm, searchTerm)
+	}
+
+	query += " ORDER BY a.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	return db.queryAuditLog(query, args...)
+}
+
+// ErrWaitlistEntryNotFound is returned when a waitlist entry doesn't exist
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+// ErrWaitlistEntryResolved is returned when a waitlist entry has already been promoted or removed
+var ErrWaitlistEntryResolved = errors.New("waitlist entry is no longer waiting")
+
+// waitlistColumns are the waitlist columns read by scanWaitlistEntry
+const waitlistColumns = `id, season_id, position, details, status, created_at, resolved_at,
+	COALESCE(resolved_by, ''), COALESCE(registration_id, ''), notified_at`
+
+// scanWaitlistEntry scans a row selected with waitlistColumns
+func scanWaitlistEntry(row interface{ Scan(...interface{}) error }) (*WaitlistEntry, error) {
+	e := &WaitlistEntry{}
+	var details string
+	var resolvedAt, notifiedAt sql.NullTime
+	err := row.Scan(&e.ID, &e.SeasonID, &e.Position, &details, &e.Status, &e.CreatedAt, &resolvedAt,
+		&e.ResolvedBy, &e.RegistrationID, &notifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(details), &e.Registration); err != nil {
+		return nil, fmt.Errorf("failed to decode waitlist details: %w", err)
+	}
+	if resolvedAt.Valid {
+		e.ResolvedAt = &resolvedAt.Time
+	}
+	if notifiedAt.Valid {
+		e.NotifiedAt = &notifiedAt.Time
+	}
+	return e, nil
+}
+
+// AddToWaitlist puts a child at the back of their season's waitlist, setting
+// the entry's position
+func (db *Database) AddToWaitlist(entry *WaitlistEntry) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	details, err := json.Marshal(waitlistDetails(entry.Registration))
+	if err != nil {
+		return fmt.Errorf("failed to encode waitlist details: %w", err)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var last int
+	err = tx.QueryRow(
+		"SELECT COALESCE(MAX(position), 0) FROM waitlist_entries WHERE season_id = ? AND status = ?",
+		entry.SeasonID, WaitlistStatusWaiting,
+	).Scan(&last)
+	if err != nil {
+		return fmt.Errorf("failed to get waitlist position: %w", err)
+	}
+
+	entry.Position = last + 1
+	entry.Status = WaitlistStatusWaiting
+	_, err = tx.Exec(
+		`INSERT INTO waitlist_entries (id, season_id, position, details, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.SeasonID, entry.Position, string(details), entry.Status, entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save waitlist entry: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetWaitlistEntry retrieves a waitlist entry by ID
+func (db *Database) GetWaitlistEntry(id string) (*WaitlistEntry, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	e, err := scanWaitlistEntry(db.db.QueryRow("SELECT "+waitlistColumns+" FROM waitlist_entries WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	return e, true, nil
+}
+
+// GetWaitlist returns a season's waitlist: children still waiting in line
+// order, then those already promoted or removed, most recent first
+func (db *Database) GetWaitlist(seasonID string) ([]*WaitlistEntry, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		"SELECT "+waitlistColumns+" FROM waitlist_entries WHERE season_id = ? ORDER BY position, created_at",
+		seasonID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waitlist: %w", err)
+	}
+	defer rows.Close()
+
+	var waiting, resolved []*WaitlistEntry
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist row: %w", err)
+		}
+		if e.IsWaiting() {
+			waiting = append(waiting, e)
+		} else {
+			resolved = append(resolved, e)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating waitlist rows: %w", err)
+	}
+
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return resolved[i].ResolvedAt.After(*resolved[j].ResolvedAt)
+	})
+	return append(waiting, resolved...), nil
+}
+
+// GetNextWaitlistEntry returns the child at the front of a season's waitlist
+func (db *Database) GetNextWaitlistEntry(seasonID string) (*WaitlistEntry, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	e, err := scanWaitlistEntry(db.db.QueryRow(
+		"SELECT "+waitlistColumns+" FROM waitlist_entries WHERE season_id = ? AND status = ? ORDER BY position LIMIT 1",
+		seasonID, WaitlistStatusWaiting,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get next waitlist entry: %w", err)
+	}
+	return e, true, nil
+}
+
+// resolveWaitlistEntry takes a waiting entry out of line and moves everyone
+// behind it up one place
+func resolveWaitlistEntry(tx *sql.Tx, id, status, actor, registrationID string, at time.Time) (*WaitlistEntry, error) {
+	e, err := scanWaitlistEntry(tx.QueryRow("SELECT "+waitlistColumns+" FROM waitlist_entries WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWaitlistEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	if !e.IsWaiting() {
+		return nil, ErrWaitlistEntryResolved
+	}
+
+	var regID interface{}
+	if registrationID != "" {
+		regID = registrationID
+	}
+	_, err = tx.Exec(
+		"UPDATE waitlist_entries SET status = ?, resolved_at = ?, resolved_by = ?, registration_id = ? WHERE id = ?",
+		status, at, actor, regID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE waitlist_entries SET position = position - 1 WHERE season_id = ? AND status = ? AND position > ?",
+		e.SeasonID, WaitlistStatusWaiting, e.Position,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to move waitlist up: %w", err)
+	}
+
+	e.Status = status
+	e.ResolvedAt = &at
+	e.ResolvedBy = actor
+	e.RegistrationID = registrationID
+	return e, nil
+}
+
+// PromoteWaitlistEntry registers a waitlisted child for their season with the
+// details their parent gave, and takes them off the waitlist
+func (db *Database) PromoteWaitlistEntry(id, actor string) (*WaitlistEntry, *Registration, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	regID := uuid.New().String()
+	entry, err := resolveWaitlistEntry(tx, id, WaitlistStatusPromoted, actor, regID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reg := *entry.Registration
+	reg.ID = regID
+	reg.SeasonID = &entry.SeasonID
+	reg.RegisteredAt = now
+	err = insertRegistration(tx, &reg, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return entry, &reg, nil
+}
+
+// RemoveWaitlistEntry takes a child off the waitlist without registering them
+func (db *Database) RemoveWaitlistEntry(id, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = resolveWaitlistEntry(tx, id, WaitlistStatusRemoved, actor, "", time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// MarkWaitlistNotified records that the parent of a promoted child was notified
+func (db *Database) MarkWaitlistNotified(id string, notifiedAt time.Time) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	_, err := db.db.Exec("UPDATE waitlist_entries SET notified_at = ? WHERE id = ?", notifiedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark waitlist entry notified: %w", err)
+	}
+	return nil
+}
+
+// GetSeasonCapacity counts a season's runners, overall and by grade.
+// Withdrawn runners aren't counted.
+func (db *Database) GetSeasonCapacity(season *Season) (*SeasonCapacity, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		"SELECT grade, COUNT(*) FROM registrations WHERE season_id = ? AND withdrawn_at IS NULL GROUP BY grade",
+		season.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count registrations by grade: %w", err)
+	}
+	defer rows.Close()
+
+	capacity := &SeasonCapacity{Season: season, ByGrade: make(map[string]int)}
+	for rows.Next() {
+		var grade string
+		var count int
+		if err := rows.Scan(&grade, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan grade count row: %w", err)
+		}
+		capacity.ByGrade[grade] = count
+		capacity.Total += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating grade count rows: %w", err)
+	}
+
+	return capacity, nil
+}
+
+// ErrQuestionNotFound is returned when a season question doesn't exist
+var ErrQuestionNotFound = errors.New("question not found")
+
+const questionColumns = "id, season_id, label, kind, options, required, sort_order, created_at"
+
+// scanQuestion reads a season question selected with questionColumns
+func scanQuestion(row interface{ Scan(...interface{}) error }) (*SeasonQuestion, error) {
+	q := &SeasonQuestion{}
+	var options sql.NullString
+	err := row.Scan(&q.ID, &q.SeasonID, &q.Label, &q.Kind, &options, &q.Required, &q.SortOrder, &q.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if options.Valid && options.String != "" {
+		if err := json.Unmarshal([]byte(options.String), &q.Options); err != nil {
+			return nil, fmt.Errorf("failed to decode question options: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// SaveSeasonQuestion adds a question to the end of a season's registration form
+func (db *Database) SaveSeasonQuestion(q *SeasonQuestion, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var options sql.NullString
+	if len(q.Options) > 0 {
+		encoded, err := json.Marshal(q.Options)
+		if err != nil {
+			return fmt.Errorf("failed to encode question options: %w", err)
+		}
+		options = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRow(
+		"SELECT COALESCE(MAX(sort_order), 0) + 1 FROM season_questions WHERE season_id = ?",
+		q.SeasonID,
+	).Scan(&q.SortOrder)
+	if err != nil {
+		return fmt.Errorf("failed to get question order: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO season_questions ("+questionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		q.ID, q.SeasonID, q.Label, q.Kind, options, q.Required, q.SortOrder, q.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save question: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntityQuestion,
+		EntityID:   q.ID,
+		Action:     AuditActionCreate,
+		Actor:      actor,
+		Changes:    auditDiff(nil, q),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteSeasonQuestion removes a question from its season's registration
+// form, along with every answer given to it
+func (db *Database) DeleteSeasonQuestion(id, actor string) (*SeasonQuestion, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	q, err := scanQuestion(tx.QueryRow("SELECT "+questionColumns+" FROM season_questions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		err = ErrQuestionNotFound
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get question: %w", err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM registration_answers WHERE question_id = ?", id); err != nil {
+		return nil, fmt.Errorf("failed to delete answers: %w", err)
+	}
+	if _, err = tx.Exec("DELETE FROM season_questions WHERE id = ?", id); err != nil {
+		return nil, fmt.Errorf("failed to delete question: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntityQuestion,
+		EntityID:   q.ID,
+		Action:     AuditActionDelete,
+		Actor:      actor,
+		Changes:    auditDiff(q, &SeasonQuestion{}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return q, nil
+}
+
+// GetSeasonQuestions lists a season's questions in the order they're asked
+func (db *Database) GetSeasonQuestions(seasonID string) ([]*SeasonQuestion, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return queryQuestions(db.db,
+		"SELECT "+questionColumns+" FROM season_questions WHERE season_id = ? ORDER BY sort_order, created_at",
+		seasonID,
+	)
+}
+
+// GetAllSeasonQuestions lists every season's questions, grouped by season
+func (db *Database) GetAllSeasonQuestions() (map[string][]*SeasonQuestion, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	questions, err := queryQuestions(db.db,
+		"SELECT "+questionColumns+" FROM season_questions ORDER BY season_id, sort_order, created_at",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bySeason := make(map[string][]*SeasonQuestion)
+	for _, q := range questions {
+		bySeason[q.SeasonID] = append(bySeason[q.SeasonID], q)
+	}
+	return bySeason, nil
+}
+
+// queryQuestions runs a query selecting questionColumns
+func queryQuestions(db *sql.DB, query string, args ...interface{}) ([]*SeasonQuestion, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query questions: %w", err)
+	}
+	defer rows.Close()
+
+	var questions []*SeasonQuestion
+	for rows.Next() {
+		q, err := scanQuestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question row: %w", err)
+		}
+		questions = append(questions, q)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating question rows: %w", err)
+	}
+
+	return questions, nil
+}
+
+// saveAnswers stores a new registration's answers to its season's questions.
+// Answers to questions the season doesn't have, such as one deleted while a
+// child was on the waitlist, are dropped.
+func saveAnswers(tx *sql.Tx, reg *Registration) error {
+	if reg.SeasonID == nil {
+		return nil
+	}
+	for questionID, values := range reg.Answers {
+		for _, value := range values {
+			_, err := tx.Exec(
+				`INSERT OR IGNORE INTO registration_answers (registration_id, question_id, value)
+				SELECT ?, id, ? FROM season_questions WHERE id = ? AND season_id = ?`,
+				reg.ID, value, questionID, *reg.SeasonID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save answer: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetRegistrationAnswers returns a registration's answers keyed by question ID
+func (db *Database) GetRegistrationAnswers(registrationID string) (map[string][]string, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	answers, err := queryAnswers(db.db,
+		"SELECT registration_id, question_id, value FROM registration_answers WHERE registration_id = ? ORDER BY rowid",
+		registrationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return answers[registrationID], nil
+}
+
+// GetSeasonAnswers returns the answers given in a season keyed by
+// registration and then question ID, or in every season if seasonID is empty
+func (db *Database) GetSeasonAnswers(seasonID string) (map[string]map[string][]string, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if seasonID == "" {
+		return queryAnswers(db.db, "SELECT registration_id, question_id, value FROM registration_answers ORDER BY rowid")
+	}
+	return queryAnswers(db.db,
+		`SELECT a.registration_id, a.question_id, a.value
+		FROM registration_answers a
+		INNER JOIN season_questions q ON q.id = a.question_id
+		WHERE q.season_id = ?
+		ORDER BY a.rowid`,
+		seasonID,
+	)
+}
+
+// queryAnswers runs a query selecting registration ID, question ID and value
+func queryAnswers(db *sql.DB, query string, args ...interface{}) (map[string]map[string][]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query answers: %w", err)
+	}
+	defer rows.Close()
+
+	answers := make(map[string]map[string][]string)
+	for rows.Next() {
+		var registrationID, questionID, value string
+		if err := rows.Scan(&registrationID, &questionID, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan answer row: %w", err)
+		}
+		if answers[registrationID] == nil {
+			answers[registrationID] = make(map[string][]string)
+		}
+		answers[registrationID][questionID] = append(answers[registrationID][questionID], value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating answer rows: %w", err)
+	}
+
+	return answers, nil
+}
+
+// ErrMergeMismatch is returned when asked to merge a registration into itself
+// or into a registration from another season
+var ErrMergeMismatch = errors.New("registrations can't be merged")
+
+// MergeRegistrations folds a duplicate registration into the one being kept.
+// The duplicate's scans, milestone awards and question answers move to the
+// kept registration, details missing from the kept registration are filled in
+// from the duplicate, and the duplicate is deleted. It returns the kept
+// registration and how many scans were moved.
+func (db *Database) MergeRegistrations(keepID, duplicateID, actor string) (*Registration, int, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	kept, found, err := getRegistration(tx, keepID)
+	if err == nil && !found {
+		err = ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	dup, found, err := getRegistration(tx, duplicateID)
+	if err == nil && !found {
+		err = ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if kept.ID == dup.ID || kept.SeasonID == nil || dup.SeasonID == nil || *kept.SeasonID != *dup.SeasonID {
+		err = ErrMergeMismatch
+		return nil, 0, err
+	}
+
+	// Keep anything the parent only gave on the duplicate
+	fill := func(kept *string, dup string) {
+		if strings.TrimSpace(*kept) == "" {
+			*kept = dup
+		}
+	}
+	fill(&kept.Teacher, dup.Teacher)
+	fill(&kept.Gender, dup.Gender)
+	fill(&kept.TshirtSize, dup.TshirtSize)
+	fill(&kept.ParentFirstName, dup.ParentFirstName)
+	fill(&kept.ParentLastName, dup.ParentLastName)
+	fill(&kept.BackupContactNumber, dup.BackupContactNumber)
+	fill(&kept.ParentEmail, dup.ParentEmail)
+	fill(&kept.DismissalMethod, dup.DismissalMethod)
+	fill(&kept.Allergies, dup.Allergies)
+	fill(&kept.MedicalInfo, dup.MedicalInfo)
+	fill(&kept.FeeStatus, dup.FeeStatus)
+	fill(&kept.ExternalID, dup.ExternalID)
+	if dup.WaiverVersion > kept.WaiverVersion {
+		kept.WaiverVersion = dup.WaiverVersion
+		kept.WaiverSignedName = dup.WaiverSignedName
+		kept.WaiverSignedAt = dup.WaiverSignedAt
+		kept.WaiverSignedIP = dup.WaiverSignedIP
+	}
+	if err = updateRegistration(tx, kept, actor); err != nil {
+		return nil, 0, err
+	}
+
+	result, err := tx.Exec("UPDATE scan_records SET registration_id = ? WHERE registration_id = ?", kept.ID, dup.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to move scans: %w", err)
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count moved scans: %w", err)
+	}
+
+	// Awards and answers the kept registration already has are dropped
+	for _, table := range []string{"milestone_awards", "registration_answers"} {
+		_, err = tx.Exec("UPDATE OR IGNORE "+table+" SET registration_id = ? WHERE registration_id = ?", kept.ID, dup.ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to move %s: %w", table, err)
+		}
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE registration_id = ?", dup.ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to clean up %s: %w", table, err)
+		}
+	}
+	_, err = tx.Exec("UPDATE waitlist_entries SET registration_id = ? WHERE registration_id = ?", kept.ID, dup.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to move waitlist entries: %w", err)
+	}
+	_, err = tx.Exec("UPDATE registration_change_requests SET registration_id = ? WHERE registration_id = ?", kept.ID, dup.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to move change requests: %w", err)
+	}
+	_, err = tx.Exec("UPDATE payments SET registration_id = ? WHERE registration_id = ?", kept.ID, dup.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to move payments: %w", err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM registrations WHERE id = ?", dup.ID); err != nil {
+		return nil, 0, fmt.Errorf("failed to delete duplicate registration: %w", err)
+	}
+
+	// The duplicate was the same child, so their runners are the same too
+	if dup.RunnerID != "" && kept.RunnerID != "" && dup.RunnerID != kept.RunnerID {
+		if err = mergeRunners(tx, kept.RunnerID, dup.RunnerID, actor); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The duplicate's details are kept in its own history
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       dup.ID,
+		RegistrationID: dup.ID,
+		Action:         AuditActionMerge,
+		Actor:          actor,
+		Changes:        append([]AuditChange{{Field: "mergedInto", New: kept.ID}}, auditDiff(dup, &Registration{})...),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       kept.ID,
+		RegistrationID: kept.ID,
+		Action:         AuditActionMerge,
+		Actor:          actor,
+		Changes: []AuditChange{
+			{Field: "mergedFrom", New: dup.ID},
+			{Field: "scansMoved", New: fmt.Sprint(moved)},
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return kept, int(moved), nil
+}
+
+// ErrChangeRequestNotFound is returned when a change request doesn't exist
+var ErrChangeRequestNotFound = errors.New("change request not found")
+
+// ErrChangeRequestResolved is returned when a change request has already been approved or rejected
+var ErrChangeRequestResolved = errors.New("change request is no longer pending")
+
+// changeRequestColumns are the change request columns read by scanChangeRequest
+const changeRequestColumns = `id, registration_id, first_name, last_name, grade, status, requested_at,
+	resolved_at, COALESCE(resolved_by, '')`
+
+// scanChangeRequest scans a row selected with changeRequestColumns
+func scanChangeRequest(row interface{ Scan(...interface{}) error }) (*ChangeRequest, error) {
+	req := &ChangeRequest{}
+	var resolvedAt sql.NullTime
+	err := row.Scan(&req.ID, &req.RegistrationID, &req.FirstName, &req.LastName, &req.Grade, &req.Status,
+		&req.RequestedAt, &resolvedAt, &req.ResolvedBy)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		req.ResolvedAt = &resolvedAt.Time
+	}
+	return req, nil
+}
+
+// RequestRegistrationChange saves a parent's request to change a runner's
+// name or grade, replacing any request still waiting for an admin. The
+// request is recorded in the runner's audit log.
+func (db *Database) RequestRegistrationChange(req *ChangeRequest, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	reg, found, err := getRegistration(tx, req.RegistrationID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err = ErrRegistrationNotFound
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM registration_change_requests WHERE registration_id = ? AND status = ?",
+		req.RegistrationID, ChangeRequestPending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to replace pending change request: %w", err)
+	}
+
+	req.Status = ChangeRequestPending
+	_, err = tx.Exec(
+		`INSERT INTO registration_change_requests (id, registration_id, first_name, last_name, grade, status, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		req.ID, req.RegistrationID, req.FirstName, req.LastName, req.Grade, req.Status, req.RequestedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save change request: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityRegistration,
+		EntityID:       reg.ID,
+		RegistrationID: reg.ID,
+		Action:         AuditActionRequest,
+		Actor:          actor,
+		Changes:        auditDiff(reg, req.apply(reg)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingChangeRequest returns the change request for a registration that
+// is waiting for an admin, if there is one
+func (db *Database) GetPendingChangeRequest(registrationID string) (*ChangeRequest, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	req, err := scanChangeRequest(db.db.QueryRow(
+		"SELECT "+changeRequestColumns+" FROM registration_change_requests WHERE registration_id = ? AND status = ?",
+		registrationID, ChangeRequestPending,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get change request: %w", err)
+	}
+	return req, true, nil
+}
+
+// GetPendingChangeRequests returns the change requests waiting for an admin,
+// oldest first, with the registrations they'd change
+func (db *Database) GetPendingChangeRequests() ([]*ChangeRequest, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		"SELECT "+changeRequestColumns+" FROM registration_change_requests WHERE status = ? ORDER BY requested_at",
+		ChangeRequestPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query change requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*ChangeRequest
+	for rows.Next() {
+		req, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change request row: %w", err)
+		}
+		requests = append(requests, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating change request rows: %w", err)
+	}
+	rows.Close()
+
+	var withRegistrations []*ChangeRequest
+	for _, req := range requests {
+		reg, found, err := getRegistration(db.db, req.RegistrationID)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			req.Registration = reg
+			withRegistrations = append(withRegistrations, req)
+		}
+	}
+
+	return withRegistrations, nil
+}
+
+// ResolveChangeRequest approves or rejects a parent's change request. An
+// approved request is applied to the registration and audited as an edit by
+// actor.
+func (db *Database) ResolveChangeRequest(id string, approve bool, actor string) (*ChangeRequest, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	req, err := scanChangeRequest(tx.QueryRow(
+		"SELECT "+changeRequestColumns+" FROM registration_change_requests WHERE id = ?", id,
+	))
+	if err == sql.ErrNoRows {
+		err = ErrChangeRequestNotFound
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get change request: %w", err)
+	}
+	if req.Status != ChangeRequestPending {
+		err = ErrChangeRequestResolved
+		return nil, err
+	}
+
+	reg, found, err := getRegistration(tx, req.RegistrationID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err = ErrRegistrationNotFound
+		return nil, err
+	}
+
+	req.Status = ChangeRequestRejected
+	if approve {
+		req.Status = ChangeRequestApproved
+		reg = req.apply(reg)
+		if err = updateRegistration(tx, reg, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"UPDATE registration_change_requests SET status = ?, resolved_at = ?, resolved_by = ? WHERE id = ?",
+		req.Status, now, actor, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update change request: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	req.ResolvedAt = &now
+	req.ResolvedBy = actor
+	req.Registration = reg
+	return req, nil
+}
+
+// waiverColumns are the waiver columns read by scanWaiver
+const waiverColumns = "id, season_id, version, body, created_at, COALESCE(created_by, '')"
+
+// scanWaiver scans a row selected with waiverColumns
+func scanWaiver(row interface{ Scan(...interface{}) error }) (*SeasonWaiver, error) {
+	w := &SeasonWaiver{}
+	err := row.Scan(&w.ID, &w.SeasonID, &w.Version, &w.Body, &w.CreatedAt, &w.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// PublishSeasonWaiver saves new waiver text for a season as its next
+// version, setting the waiver's version. Parents who agreed to an earlier
+// version are then listed as not having consented.
+func (db *Database) PublishSeasonWaiver(w *SeasonWaiver, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRow(
+		"SELECT COALESCE(MAX(version), 0) + 1 FROM season_waivers WHERE season_id = ?",
+		w.SeasonID,
+	).Scan(&w.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get waiver version: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO season_waivers (id, season_id, version, body, created_at, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		w.ID, w.SeasonID, w.Version, w.Body, w.CreatedAt, w.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save waiver: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType: AuditEntityWaiver,
+		EntityID:   w.ID,
+		Action:     AuditActionCreate,
+		Actor:      actor,
+		Changes:    auditDiff(nil, w),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetCurrentWaiver returns the latest version of a season's waiver
+func (db *Database) GetCurrentWaiver(seasonID string) (*SeasonWaiver, bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	w, err := scanWaiver(db.db.QueryRow(
+		"SELECT "+waiverColumns+" FROM season_waivers WHERE season_id = ? ORDER BY version DESC LIMIT 1",
+		seasonID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get waiver: %w", err)
+	}
+	return w, true, nil
+}
+
+// GetCurrentWaivers returns the latest waiver of every season that has one, by season ID
+func (db *Database) GetCurrentWaivers() (map[string]*SeasonWaiver, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query(
+		`SELECT ` + waiverColumns + ` FROM season_waivers w
+		WHERE version = (SELECT MAX(version) FROM season_waivers WHERE season_id = w.season_id)`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waivers: %w", err)
+	}
+	defer rows.Close()
+
+	waivers := make(map[string]*SeasonWaiver)
+	for rows.Next() {
+		w, err := scanWaiver(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waiver row: %w", err)
+		}
+		waivers[w.SeasonID] = w
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating waiver rows: %w", err)
+	}
+	return waivers, nil
+}
+
+// ErrSeasonNotFound is returned when changing a season that doesn't exist
+var ErrSeasonNotFound = errors.New("season not found")
+
+// ErrPaymentNotFound is returned when deleting a payment that doesn't exist
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// SetSeasonFees changes the fee runners pay for a season and the discount
+// for each child after the first in a family
+func (db *Database) SetSeasonFees(seasonID string, feeCents, siblingDiscountCents int, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	old, err := scanSeason(tx.QueryRow("SELECT "+seasonColumns+" FROM seasons WHERE id = ?", seasonID))
+	if err == sql.ErrNoRows {
+		err = ErrSeasonNotFound
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get season: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE seasons SET fee_cents = ?, sibling_discount_cents = ? WHERE id = ?",
+		feeCents, siblingDiscountCents, seasonID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update season fees: %w", err)
+	}
+
+	season := *old
+	season.FeeCents = feeCents
+	season.SiblingDiscountCents = siblingDiscountCents
+	if changes := auditDiff(old, &season); len(changes) > 0 {
+		err = writeAudit(tx, &AuditEntry{
+			EntityType: AuditEntitySeason,
+			EntityID:   seasonID,
+			Action:     AuditActionUpdate,
+			Actor:      actor,
+			Changes:    changes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SetFeeStatus marks whether a runner pays the season fee, has a
+// scholarship, or has had the fee waived
+func (db *Database) SetFeeStatus(registrationID, status, actor string) (*Registration, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	reg, found, err := getRegistration(tx, registrationID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err = ErrRegistrationNotFound
+		return nil, err
+	}
+
+	reg.FeeStatus = status
+	if err = updateRegistration(tx, reg, actor); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return reg, nil
+}
+
+// paymentColumns are the payment columns read by scanPayment
+const paymentColumns = "p.id, p.registration_id, p.amount_cents, p.method, COALESCE(p.reference, ''), p.paid_at, COALESCE(p.recorded_by, ''), p.recorded_at"
+
+// scanPayment scans a row selected with paymentColumns
+func scanPayment(row interface{ Scan(...interface{}) error }) (*Payment, error) {
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.RegistrationID, &p.AmountCents, &p.Method, &p.Reference, &p.PaidAt, &p.RecordedBy, &p.RecordedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RecordPayments saves payments toward runners' fees. Either every payment
+// is saved or, if any of them fails, none are.
+func (db *Database) RecordPayments(payments []*Payment, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, p := range payments {
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM registrations WHERE id = ?)", p.RegistrationID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check registration: %w", err)
+		}
+		if !exists {
+			err = ErrRegistrationNotFound
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO payments (id, registration_id, amount_cents, method, reference, paid_at, recorded_by, recorded_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.ID, p.RegistrationID, p.AmountCents, p.Method, p.Reference, p.PaidAt, p.RecordedBy, p.RecordedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save payment: %w", err)
+		}
+
+		err = writeAudit(tx, &AuditEntry{
+			EntityType:     AuditEntityPayment,
+			EntityID:       p.ID,
+			RegistrationID: p.RegistrationID,
+			Action:         AuditActionCreate,
+			Actor:          actor,
+			Changes:        auditDiff(nil, p),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeletePayment removes a payment recorded by mistake
+func (db *Database) DeletePayment(id, actor string) (*Payment, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	p, err := scanPayment(tx.QueryRow("SELECT "+paymentColumns+" FROM payments p WHERE p.id = ?", id))
+	if err == sql.ErrNoRows {
+		err = ErrPaymentNotFound
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM payments WHERE id = ?", id); err != nil {
+		return nil, fmt.Errorf("failed to delete payment: %w", err)
+	}
+
+	err = writeAudit(tx, &AuditEntry{
+		EntityType:     AuditEntityPayment,
+		EntityID:       p.ID,
+		RegistrationID: p.RegistrationID,
+		Action:         AuditActionDelete,
+		Actor:          actor,
+		Changes:        auditDiff(p, &Payment{}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return p, nil
+}
+
+// GetPayments returns payments by registration ID, oldest first, optionally
+// filtered by season
+func (db *Database) GetPayments(seasonID string) (map[string][]*Payment, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	query := "SELECT " + paymentColumns + " FROM payments p"
+	var args []interface{}
+	if seasonID != "" {
+		query += " INNER JOIN registrations r ON r.id = p.registration_id WHERE r.season_id = ?"
+		args = append(args, seasonID)
+	}
+	query += " ORDER BY p.paid_at, p.recorded_at"
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make(map[string][]*Payment)
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment row: %w", err)
+		}
+		payments[p.RegistrationID] = append(payments[p.RegistrationID], p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment rows: %w", err)
+	}
+	return payments, nil
+}
+
+// GetPaymentReferences returns the references of every payment made with a
+// method, so imported statements don't record the same payment twice
+func (db *Database) GetPaymentReferences(method string) (map[string]bool, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query("SELECT reference FROM payments WHERE method = ? AND reference IS NOT NULL AND reference != ''", method)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment references: %w", err)
+	}
+	defer rows.Close()
+
+	references := make(map[string]bool)
+	for rows.Next() {
+		var reference string
+		if err := rows.Scan(&reference); err != nil {
+			return nil, fmt.Errorf("failed to scan payment reference: %w", err)
+		}
+		references[reference] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment references: %w", err)
+	}
+	return references, nil
+}
+
+// ErrHouseholdNotFound is returned when a household doesn't exist
+var ErrHouseholdNotFound = errors.New("household not found")
+
+// runnerHousehold returns the household of a runner's most recent
+// registration, or "" if they don't have one yet
+func runnerHousehold(tx *sql.Tx, runnerID string) (string, error) {
+	var householdID string
+	err := tx.QueryRow(
+		`SELECT household_id FROM registrations
+		WHERE runner_id = ? AND household_id IS NOT NULL
+		ORDER BY registered_at DESC LIMIT 1`,
+		runnerID,
+	).Scan(&householdID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return householdID, err
+}
+
+// linkHousehold puts a new registration in a household: the one it was
+// given, otherwise the household of the runner's last registration,
+// otherwise a new household of its own
+func linkHousehold(tx *sql.Tx, reg *Registration) error {
+	if reg.HouseholdID == "" && reg.RunnerID != "" {
+		householdID, err := runnerHousehold(tx, reg.RunnerID)
+		if err != nil {
+			return err
+		}
+		reg.HouseholdID = householdID
+	}
+	if reg.HouseholdID == "" {
+		reg.HouseholdID = uuid.New().String()
+	}
+
+	// A waitlisted sibling's household is created when they're promoted
+	_, err := tx.Exec("INSERT OR IGNORE INTO households (id, created_at) VALUES (?, ?)", reg.HouseholdID, time.Now())
+	return err
+}
+
+// SaveRegistrations saves siblings registered together on one form, all of
+// them or none. They share a household: the one any of them already
+// belongs to from an earlier season, or a new one.
+func (db *Database) SaveRegistrations(regs []*Registration, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var householdID string
+	for _, reg := range regs {
+		if err = linkRunner(tx, reg); err != nil {
+			return fmt.Errorf("failed to link runner: %w", err)
+		}
+		if householdID == "" {
+			householdID = reg.HouseholdID
+		}
+		if householdID == "" {
+			if householdID, err = runnerHousehold(tx, reg.RunnerID); err != nil {
+				return fmt.Errorf("failed to find household: %w", err)
+			}
+		}
+	}
+	if householdID == "" {
+		householdID = uuid.New().String()
+	}
+
+	for _, reg := range regs {
+		reg.HouseholdID = householdID
+		if err = insertRegistration(tx, reg, actor); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetHouseholdRegistrations returns every registration in a household,
+// including withdrawn ones, newest first
+func (db *Database) GetHouseholdRegistrations(householdID string) ([]*Registration, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	rows, err := db.db.Query("SELECT id FROM registrations WHERE household_id = ? ORDER BY registered_at DESC, id", householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household registrations: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan household registration: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating household registrations: %w", err)
+	}
+
+	var regs []*Registration
+	for _, id := range ids {
+		reg, found, err := getRegistration(db.db, id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			regs = append(regs, reg)
+		}
+	}
+	return regs, nil
+}
+
+// MoveToHousehold moves a runner into another household, or into a new
+// household of their own when householdID is empty. Every season's
+// registration for the runner moves with them. It returns the registration
+// that was moved.
+func (db *Database) MoveToHousehold(registrationID, householdID, actor string) (*Registration, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	reg, found, err := getRegistration(tx, registrationID)
+	if err == nil && !found {
+		err = ErrRegistrationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if householdID == "" {
+		householdID = uuid.New().String()
+		_, err = tx.Exec("INSERT INTO households (id, created_at) VALUES (?, ?)", householdID, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create household: %w", err)
+		}
+	} else {
+		var exists int
+		err = tx.QueryRow("SELECT 1 FROM households WHERE id = ?", householdID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			err = ErrHouseholdNotFound
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get household: %w", err)
+		}
+	}
+
+	runnerID := reg.RunnerID
+	if runnerID == "" {
+		runnerID = reg.ID
+	}
+	rows, err := tx.Query("SELECT id FROM registrations WHERE COALESCE(runner_id, id) = ?", runnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runner registrations: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan runner registration: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating runner registrations: %w", err)
+	}
+
+	for _, id := range ids {
+		var moved *Registration
+		if moved, _, err = getRegistration(tx, id); err != nil {
+			return nil, err
+		}
+		if moved.HouseholdID == householdID {
+			continue
+		}
+		moved.HouseholdID = householdID
+		if err = updateRegistration(tx, moved, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	reg.HouseholdID = householdID
+	return reg, nil
+}
+
+// ImportRegistrations saves the new runners and updated registrations in a
+// bulk import, all of them or none. Each returning runner rejoins their
+// household, and new children in the import with the same parent email
+// share one.
+func (db *Database) ImportRegistrations(regs, updates []*Registration, actor string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	households := make(map[string]string)
+	for _, reg := range regs {
+		if err = linkRunner(tx, reg); err != nil {
+			return fmt.Errorf("failed to link runner: %w", err)
+		}
+		email := strings.ToLower(strings.TrimSpace(reg.ParentEmail))
+		if reg.HouseholdID == "" && email != "" {
+			if reg.HouseholdID, err = runnerHousehold(tx, reg.RunnerID); err != nil {
+				return fmt.Errorf("failed to find household: %w", err)
+			}
+			if reg.HouseholdID == "" {
+				reg.HouseholdID = households[email]
+			}
+		}
+		if err = insertRegistration(tx, reg, actor); err != nil {
+			return err
+		}
+		if email != "" && households[email] == "" {
+			households[email] = reg.HouseholdID
+		}
+	}
+	for _, reg := range updates {
+		if err = updateRegistration(tx, reg, actor); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ImportScans records scans copied from a paper scan log, as actor, in one
+// transaction. Each scan is checked as if it had been scanned, against the
+// runner's other scans and those before it in the import. It returns why
+// each scan that can't be recorded was rejected, by its index; if any were,
+// or commit is false, nothing is saved.
+func (db *Database) ImportScans(scans []*ScanRecord, actor string, commit bool) (map[int]error, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rejected := make(map[int]error)
+	opts := ScanOptions{RecordedBy: actor, Source: ScanSourceImport}
+	for i, scan := range scans {
+		_, _, err = recordScan(tx, scan.RegistrationID, scan.TrackID, scan.ScannedAt, opts)
+		var tooSoon *ScanTooSoonError
+		if errors.Is(err, ErrRegistrationNotFound) || errors.Is(err, ErrRegistrationWithdrawn) || errors.As(err, &tooSoon) {
+			rejected[i] = err
+			err = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rejected) > 0 || !commit {
+		if err = tx.Rollback(); err != nil {
+			return nil, fmt.Errorf("failed to roll back transaction: %w", err)
+		}
+		return rejected, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return rejected, nil
+}
+
+// ScanExportFilter narrows the scans EachScanForExport reads. Empty fields don't filter.
+type ScanExportFilter struct {
+	SeasonID string
+	Grade    string
+	Teacher  string // Matched ignoring case
+	TrackID  string
+	From     time.Time // Scans at or after From
+	To       time.Time // Scans before To
+}
+
+// ScanExportRow is a scan with the runner and track details the scans export needs
+type ScanExportRow struct {
+	ID             string    `json:"id"`
+	ScannedAt      time.Time `json:"scannedAt"` // In the club's timezone
+	RegistrationID string    `json:"registrationId"`
+	FirstName      string    `json:"firstName"`
+	LastName       string    `json:"lastName"`
+	Grade          string    `json:"grade"`
+	Teacher        string    `json:"teacher"`
+	SeasonID       string    `json:"seasonId"`
+	Season         string    `json:"season"`
+	TrackID        string    `json:"trackId,omitempty"`
+	Track          string    `json:"track,omitempty"`
+	DistanceMiles  float64   `json:"distanceMiles"`
+	LapTime        *float64  `json:"lapTime,omitempty"` // Minutes since the runner's previous scan that day
+	Source         string    `json:"source"`
+	RecordedBy     string    `json:"recordedBy,omitempty"`
+}
+
+// EachScanForExport calls fn with each scan matching filter, oldest first,
+// reading them from the database one at a time so a whole season's scans
+// are never held in memory. Voided scans are left out. Lap times count
+// every scan the runner made that day, including those the date and track
+// filters leave out.
+//
+// It doesn't hold db.mutex, as fn may be writing to a slow client.
+func (db *Database) EachScanForExport(filter ScanExportFilter, fn func(*ScanExportRow) error) error {
+	query := `SELECT sr.id, sr.scanned_at, sr.registration_id, r.first_name, r.last_name, r.grade, r.teacher,
+		sr.season_id, COALESCE(s.name, ''), COALESCE(sr.track_id, ''), COALESCE(t.name, ''), COALESCE(t.distance_miles, 0),
+		COALESCE(sr.source, ''), COALESCE(sr.recorded_by, '')
+	FROM scan_records sr
+	JOIN registrations r ON sr.registration_id = r.id
+	LEFT JOIN seasons s ON sr.season_id = s.id
+	LEFT JOIN tracks t ON sr.track_id = t.id
+	WHERE sr.voided_at IS NULL`
+	var args []interface{}
+	if filter.SeasonID != "" {
+		query += " AND sr.season_id = ?"
+		args = append(args, filter.SeasonID)
+	}
+	if filter.Grade != "" {
+		query += " AND r.grade = ?"
+		args = append(args, filter.Grade)
+	}
+	if filter.Teacher != "" {
+		query += " AND r.teacher = ? COLLATE NOCASE"
+		args = append(args, filter.Teacher)
+	}
+	// Scan times are stored as text in the zone they were recorded in, so
+	// the query allows a day either side and the exact range is checked below
+	if !filter.From.IsZero() {
+		query += " AND sr.scanned_at >= ?"
+		args = append(args, filter.From.AddDate(0, 0, -1))
+	}
+	if !filter.To.IsZero() {
+		query += " AND sr.scanned_at < ?"
+		args = append(args, filter.To.AddDate(0, 0, 1))
+	}
+	query += " ORDER BY sr.scanned_at ASC"
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query scans: %w", err)
+	}
+	defer rows.Close()
+
+	loc := clubLocation()
+	previous := make(map[string]time.Time) // Each runner's last scan so far
+	for rows.Next() {
+		row := &ScanExportRow{}
+		err := rows.Scan(
+			&row.ID, &row.ScannedAt, &row.RegistrationID, &row.FirstName, &row.LastName, &row.Grade, &row.Teacher,
+			&row.SeasonID, &row.Season, &row.TrackID, &row.Track, &row.DistanceMiles,
+			&row.Source, &row.RecordedBy,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		row.ScannedAt = row.ScannedAt.In(loc)
+
+		if last, ok := previous[row.RegistrationID]; ok && last.Before(row.ScannedAt) {
+			lastYear, lastMonth, lastDay := last.Date()
+			year, month, day := row.ScannedAt.Date()
+			if lastYear == year && lastMonth == month && lastDay == day {
+				lapTime := row.ScannedAt.Sub(last).Minutes()
+				row.LapTime = &lapTime
+			}
+		}
+		previous[row.RegistrationID] = row.ScannedAt
+
+		if (!filter.From.IsZero() && row.ScannedAt.Before(filter.From)) ||
+			(!filter.To.IsZero() && !row.ScannedAt.Before(filter.To)) ||
+			(filter.TrackID != "" && row.TrackID != filter.TrackID) {
+			continue
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
@@ -31,7 +31,7 @@ func TestDatabaseOperations(t *testing.T) {
 	}
 
 	// Save the season
-	err = db.SaveSeason(testSeason)
+	err = db.SaveSeason(testSeason, "admin")
 	if err != nil {
 		t.Fatalf("Failed to create test season: %v", err)
 	}
@@ -56,7 +56,7 @@ func TestDatabaseOperations(t *testing.T) {
 		}
 
 		// Save registration
-		err := db.SaveRegistration(reg)
+		err := db.SaveRegistration(reg, "admin")
 		if err != nil {
 			t.Fatalf("Failed to save registration: %v", err)
 		}
@@ -104,7 +104,7 @@ func TestDatabaseOperations(t *testing.T) {
 		}
 
 		// Save registration
-		err := db.SaveRegistration(reg)
+		err := db.SaveRegistration(reg, "admin")
 		if err != nil {
 			t.Fatalf("Failed to save registration: %v", err)
 		}
@@ -160,6 +160,144 @@ func TestDatabaseOperations(t *testing.T) {
 		}
 	})
 
+	// Test voiding, reassigning and changing the track of scans
+	t.Run("Scan Corrections", func(t *testing.T) {
+		seasonID := testSeason.ID
+		track := &Track{
+			ID:            uuid.New().String(),
+			SeasonID:      seasonID,
+			Name:          "Long Loop",
+			DistanceMiles: 2.0,
+			CreatedAt:     time.Now(),
+		}
+		if err := db.SaveTrack(track, "admin"); err != nil {
+			t.Fatalf("Failed to save track: %v", err)
+		}
+
+		var regs []*Registration
+		for _, name := range []string{"Alex", "Sam"} {
+			reg := &Registration{
+				ID:                  uuid.New().String(),
+				SeasonID:            &seasonID,
+				FirstName:           name,
+				LastName:            "Corrections",
+				Grade:               "4",
+				ParentContactNumber: "555-1111",
+				ParentEmail:         "corrections@example.com",
+				RegisteredAt:        time.Now(),
+			}
+			if err := db.SaveRegistration(reg, "admin"); err != nil {
+				t.Fatalf("Failed to save registration: %v", err)
+			}
+			regs = append(regs, reg)
+		}
+
+		scan, _, err := db.RecordScan(regs[0].ID, nil)
+		if err != nil {
+			t.Fatalf("Failed to record scan: %v", err)
+		}
+		runsBefore, err := db.GetScanCountForSeason(seasonID)
+		if err != nil {
+			t.Fatalf("Failed to count scans: %v", err)
+		}
+
+		// Move the scan to the right runner and track
+		if err := db.ReassignScan(scan.ID, regs[1].ID, "admin"); err != nil {
+			t.Fatalf("Failed to reassign scan: %v", err)
+		}
+		if err := db.UpdateScanTrack(scan.ID, track.ID, "admin"); err != nil {
+			t.Fatalf("Failed to change scan track: %v", err)
+		}
+		moved, exists, err := db.GetScan(scan.ID)
+		if err != nil || !exists {
+			t.Fatalf("Failed to get scan: %v", err)
+		}
+		if moved.RegistrationID != regs[1].ID {
+			t.Errorf("Scan registration mismatch: got %s, want %s", moved.RegistrationID, regs[1].ID)
+		}
+		if moved.Track == nil || moved.Track.ID != track.ID {
+			t.Errorf("Expected scan to be on track %s, got %+v", track.ID, moved.Track)
+		}
+		if err := db.ReassignScan(scan.ID, uuid.New().String(), "admin"); err != ErrRegistrationNotFound {
+			t.Errorf("Expected ErrRegistrationNotFound, got %v", err)
+		}
+
+		// A voided scan no longer counts and can't be voided twice
+		if err := db.VoidScan(scan.ID, "admin", "Wrong kid"); err != nil {
+			t.Fatalf("Failed to void scan: %v", err)
+		}
+		if err := db.VoidScan(scan.ID, "admin", "Again"); err != ErrScanVoided {
+			t.Errorf("Expected ErrScanVoided, got %v", err)
+		}
+		if err := db.VoidScan(uuid.New().String(), "admin", ""); err != ErrScanNotFound {
+			t.Errorf("Expected ErrScanNotFound, got %v", err)
+		}
+		runsAfter, err := db.GetScanCountForSeason(seasonID)
+		if err != nil {
+			t.Fatalf("Failed to count scans: %v", err)
+		}
+		if runsAfter != runsBefore-1 {
+			t.Errorf("Expected %d scans after voiding, got %d", runsBefore-1, runsAfter)
+		}
+		history, err := db.GetScanHistory(regs[1].ID)
+		if err != nil {
+			t.Fatalf("Failed to get scan history: %v", err)
+		}
+		if len(history) != 1 || history[0].VoidedAt == nil || history[0].VoidReason != "Wrong kid" {
+			t.Errorf("Expected voided scan in history, got %+v", history)
+		}
+	})
+
+	// Test user operations
+	t.Run("User Operations", func(t *testing.T) {
+		user, err := newUser("volunteer1", "correct-horse", RoleScanner)
+		if err != nil {
+			t.Fatalf("Failed to build user: %v", err)
+		}
+		if err := db.CreateUser(user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		// Usernames are unique regardless of case
+		dup, _ := newUser("Volunteer1", "another-password", RoleViewer)
+		if err := db.CreateUser(dup); err == nil {
+			t.Errorf("Expected error creating duplicate username")
+		}
+
+		retrieved, exists, err := db.GetUserByUsername("VOLUNTEER1")
+		if err != nil {
+			t.Fatalf("Error retrieving user: %v", err)
+		}
+		if !exists {
+			t.Fatalf("User should exist but doesn't")
+		}
+		if retrieved.Role != RoleScanner {
+			t.Errorf("Role mismatch: got %s, want %s", retrieved.Role, RoleScanner)
+		}
+		if !retrieved.checkPassword("correct-horse") || retrieved.checkPassword("wrong") {
+			t.Errorf("Password check did not match stored hash")
+		}
+
+		// Disable the account
+		if err := db.SetUserDisabled(user.ID, true); err != nil {
+			t.Fatalf("Failed to disable user: %v", err)
+		}
+		retrieved, _, _ = db.GetUser(user.ID)
+		if !retrieved.IsDisabled {
+			t.Errorf("User should be disabled")
+		}
+
+		// Reset the password
+		hash, _ := hashPassword("new-password")
+		if err := db.UpdateUserPassword(user.ID, hash); err != nil {
+			t.Fatalf("Failed to reset password: %v", err)
+		}
+		retrieved, _, _ = db.GetUser(user.ID)
+		if !retrieved.checkPassword("new-password") {
+			t.Errorf("Password was not reset")
+		}
+	})
+
 	// Test nonexistent registration
 	t.Run("Nonexistent Registration", func(t *testing.T) {
 		_, exists, err := db.GetRegistration("nonexistent-id")
@@ -175,4 +313,4 @@ func TestDatabaseOperations(t *testing.T) {
 			t.Errorf("Expected error when recording scan for nonexistent registration")
 		}
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "time/tzdata" // the club timezone must load even on hosts without zoneinfo
+)
+
+// defaultClubTimeZone is used when CLUB_TIMEZONE is not set
+const defaultClubTimeZone = "America/Chicago"
+
+// displayTokenCheckInterval is how often an open leaderboard stream re-checks
+// that its display token hasn't been revoked
+const displayTokenCheckInterval = time.Minute
+
+// leaderboardRecentLaps is how many of today's laps the big-screen leaderboard lists
+const leaderboardRecentLaps = 8
+
+// DisplayToken grants access to the big-screen leaderboard without a login
+type DisplayToken struct {
+	ID         string     `json:"id"`
+	Token      string     `json:"-"`
+	Name       string     `json:"name"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// clubLocation returns the club's timezone from CLUB_TIMEZONE, defaulting to America/Chicago
+func clubLocation() *time.Location {
+	name := os.Getenv("CLUB_TIMEZONE")
+	if name == "" {
+		name = defaultClubTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Invalid CLUB_TIMEZONE %q, using %s: %v", name, defaultClubTimeZone, err)
+		loc, _ = time.LoadLocation(defaultClubTimeZone)
+	}
+	return loc
+}
+
+// startOfClubDay returns midnight of the current day in the club's timezone
+func startOfClubDay(now time.Time) time.Time {
+	local := now.In(clubLocation())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+}
+
+// publicRunnerName returns the name to show on public screens: the full
+// name, or only initials for runners who opted out of website display
+func publicRunnerName(firstName, lastName string, optOut bool) string {
+	if !optOut {
+		return strings.TrimSpace(firstName + " " + lastName)
+	}
+	initials := ""
+	for _, name := range []string{firstName, lastName} {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			initials += strings.ToUpper(string([]rune(name)[0])) + "."
+		}
+	}
+	return initials
+}
+
+// LeaderboardRunner is a runner shown on the big-screen leaderboard
+type LeaderboardRunner struct {
+	Name          string  `json:"name"`
+	RunCount      int     `json:"runCount"`
+	TotalDistance float64 `json:"totalDistance"`
+}
+
+// LeaderboardGrade holds the top runners for one grade
+type LeaderboardGrade struct {
+	Grade   string              `json:"grade"`
+	Runners []LeaderboardRunner `json:"runners"`
+}
+
+// LeaderboardLap is a lap shown on the big-screen leaderboard
+type LeaderboardLap struct {
+	Name       string    `json:"name"`
+	Grade      string    `json:"grade"`
+	TrackName  string    `json:"trackName,omitempty"`
+	TrackMiles float64   `json:"trackMiles,omitempty"`
+	ScannedAt  time.Time `json:"scannedAt"`
+}
+
+// LeaderboardData is everything the big-screen leaderboard shows
+type LeaderboardData struct {
+	SeasonName    string             `json:"seasonName"`
+	TotalRuns     int                `json:"totalRuns"`
+	TotalDistance float64            `json:"totalDistance"`
+	TodayRuns     int                `json:"todayRuns"`
+	TodayDistance float64            `json:"todayDistance"`
+	Grades        []LeaderboardGrade `json:"grades"`
+	RecentLaps    []LeaderboardLap   `json:"recentLaps"`
+	UpdatedAt     time.Time          `json:"updatedAt"`
+}
+
+// buildLeaderboard gathers the leaderboard for a season, hiding the names of opted-out runners
+func buildLeaderboard(season *Season, now time.Time) (*LeaderboardData, error) {
+	stats, err := database.GetSeasonStatistics(season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &LeaderboardData{
+		SeasonName:    season.Name,
+		TotalRuns:     stats.TotalRuns,
+		TotalDistance: stats.TotalDistance,
+		Grades:        []LeaderboardGrade{},
+		RecentLaps:    []LeaderboardLap{},
+		UpdatedAt:     now,
+	}
+
+	for _, gs := range stats.GradeStats {
+		if len(gs.TopRunners) == 0 {
+			continue
+		}
+		grade := LeaderboardGrade{Grade: gs.Grade}
+		for _, runner := range gs.TopRunners {
+			grade.Runners = append(grade.Runners, LeaderboardRunner{
+				Name:          publicRunnerName(runner.FirstName, runner.LastName, runner.OptOutWebsiteDisplay),
+				RunCount:      runner.RunCount,
+				TotalDistance: runner.TotalDistance,
+			})
+		}
+		data.Grades = append(data.Grades, grade)
+	}
+
+	today := startOfClubDay(now)
+	data.TodayRuns, data.TodayDistance, err = database.GetScanTotalsSince(season.ID, today)
+	if err != nil {
+		return nil, err
+	}
+
+	laps, err := database.GetRecentLaps(season.ID, today, leaderboardRecentLaps)
+	if err != nil {
+		return nil, err
+	}
+	for _, lap := range laps {
+		data.RecentLaps = append(data.RecentLaps, LeaderboardLap{
+			Name:      publicRunnerName(lap.FirstName, lap.LastName, lap.OptOutWebsiteDisplay),
+			Grade:     lap.Grade,
+			TrackName: lap.TrackName,
+			ScannedAt: lap.ScannedAt,
+		})
+	}
+
+	return data, nil
+}
+
+// validDisplayToken returns the display token from the request if it exists and hasn't been revoked
+func validDisplayToken(r *http.Request) (*DisplayToken, bool) {
+	value := r.URL.Query().Get("token")
+	if value == "" {
+		return nil, false
+	}
+
+	token, exists, err := database.GetDisplayTokenByToken(value)
+	if err != nil {
+		log.Printf("Error getting display token: %v", err)
+		return nil, false
+	}
+	if !exists || token.RevokedAt != nil {
+		return nil, false
+	}
+	return token, true
+}
+
+// displayLeaderboardHandler serves the big-screen leaderboard page to a TV holding a display token
+func displayLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := validDisplayToken(r)
+	if !ok {
+		http.Error(w, "This display link is invalid or has been revoked", http.StatusForbidden)
+		return
+	}
+	if err := database.TouchDisplayToken(token.ID, time.Now()); err != nil {
+		log.Printf("Error updating display token: %v", err)
+	}
+
+	activeSeason, _, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+	}
+
+	renderTemplate(w, "display_leaderboard", PageData{
+		Title:        "Run Club Leaderboard",
+		ActiveSeason: activeSeason,
+		DisplayToken: r.URL.Query().Get("token"),
+	})
+}
+
+// displayLeaderboardDataHandler returns the current leaderboard as JSON
+func displayLeaderboardDataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := validDisplayToken(r); !ok {
+		sendJSONResponse(w, map[string]string{"error": "This display link is invalid or has been revoked"}, http.StatusForbidden)
+		return
+	}
+
+	activeSeason, exists, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		sendJSONResponse(w, map[string]string{"error": "No active season"}, http.StatusNotFound)
+		return
+	}
+
+	data, err := buildLeaderboard(activeSeason, time.Now())
+	if err != nil {
+		log.Printf("Error building leaderboard: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, data, http.StatusOK)
+}
+
+// displayLeaderboardStreamHandler streams laps for the active season to the
+// leaderboard, with opted-out runners shown by initials only. The stream ends
+// once the display token is revoked.
+func displayLeaderboardStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := validDisplayToken(r)
+	if !ok {
+		http.Error(w, "This display link is invalid or has been revoked", http.StatusForbidden)
+		return
+	}
+
+	activeSeason, exists, err := database.GetActiveSeason()
+	if err != nil || !exists {
+		http.Error(w, "No active season", http.StatusNotFound)
+		return
+	}
+
+	ch, unsubscribe := scanBroadcaster.Subscribe()
+	defer unsubscribe()
+
+	writeScanEvents(w, r, ch, scanStreamOptions{
+		EventName: "lap",
+		Transform: func(event ScanEvent) (interface{}, bool) {
+			if event.SeasonID != activeSeason.ID {
+				return nil, false
+			}
+			return LeaderboardLap{
+				Name:       publicRunnerName(event.FirstName, event.LastName, event.OptOutWebsiteDisplay),
+				Grade:      event.Grade,
+				TrackName:  event.TrackName,
+				TrackMiles: event.TrackMiles,
+				ScannedAt:  event.ScannedAt,
+			}, true
+		},
+		CheckInterval: displayTokenCheckInterval,
+		StillAllowed: func() bool {
+			current, exists, err := database.GetDisplayTokenByToken(token.Token)
+			if err != nil {
+				// Keep the TV running through a transient database error
+				log.Printf("Error checking display token: %v", err)
+				return true
+			}
+			return exists && current.RevokedAt == nil
+		},
+	})
+}
+
+// displayTokensHandler lists display tokens and creates new ones
+func displayTokensHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	// Construct base URL from request
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	data := PageData{
+		Title:   "Run Club - Display Screens",
+		User:    username,
+		Role:    role,
+		BaseURL: fmt.Sprintf("%s://%s", scheme, r.Host),
+	}
+
+	// For POST requests, create the new token then fall through to render the list
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			data.Message = "Display name is required"
+		} else {
+			token := &DisplayToken{
+				ID:        uuid.New().String(),
+				Token:     uuid.New().String(),
+				Name:      name,
+				CreatedBy: username,
+				CreatedAt: time.Now(),
+			}
+			if err := database.CreateDisplayToken(token); err != nil {
+				log.Printf("Error creating display token: %v", err)
+				data.Message = "Failed to create display link"
+			} else {
+				data.Success = true
+				data.Message = "Created display link for " + name
+			}
+		}
+	} else if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokens, err := database.GetAllDisplayTokens()
+	if err != nil {
+		log.Printf("Error getting display tokens: %v", err)
+		http.Error(w, "Failed to retrieve display links", http.StatusInternalServerError)
+		return
+	}
+	data.DisplayTokens = tokens
+
+	renderTemplate(w, "display_tokens", data)
+}
+
+// revokeDisplayTokenHandler revokes a display token so its screen stops updating
+func revokeDisplayTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Display token ID is required", http.StatusBadRequest)
+		return
+	}
+	if err := database.RevokeDisplayToken(id); err != nil {
+		log.Printf("Error revoking display token: %v", err)
+		http.Error(w, "Failed to revoke display link", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/display-tokens", http.StatusSeeOther)
+}
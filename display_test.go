@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPublicRunnerName(t *testing.T) {
+	tests := []struct {
+		first, last string
+		optOut      bool
+		want        string
+	}{
+		{"Jane", "Smith", false, "Jane Smith"},
+		{"Jane", "Smith", true, "J.S."},
+		{"jane", "", true, "J."},
+	}
+	for _, tt := range tests {
+		if got := publicRunnerName(tt.first, tt.last, tt.optOut); got != tt.want {
+			t.Errorf("publicRunnerName(%q, %q, %v) = %q, want %q", tt.first, tt.last, tt.optOut, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayLeaderboardData(t *testing.T) {
+	// Save original database and restore after tests
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	// Setup test database
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shown := createTestRegistration(t, db, activeSeason.ID)
+	hidden := &Registration{
+		ID:                   uuid.New().String(),
+		SeasonID:             &activeSeason.ID,
+		FirstName:            "Private",
+		LastName:             "Kid",
+		Grade:                "3",
+		ParentContactNumber:  "555-1234",
+		ParentEmail:          "private@example.com",
+		OptOutWebsiteDisplay: true,
+		RegisteredAt:         time.Now(),
+	}
+	if err := db.SaveRegistration(hidden, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	for _, reg := range []*Registration{shown, hidden} {
+		if _, _, err := db.RecordScan(reg.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token := &DisplayToken{
+		ID:        uuid.New().String(),
+		Token:     uuid.New().String(),
+		Name:      "Gym TV",
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateDisplayToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	getData := func(value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/display/leaderboard/data?token="+value, nil)
+		rr := httptest.NewRecorder()
+		displayLeaderboardDataHandler(rr, req)
+		return rr
+	}
+
+	if rr := getData("not-a-token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for an unknown token, got %d", rr.Code)
+	}
+
+	rr := getData(token.Token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if strings.Contains(body, "Private") {
+		t.Errorf("Leaderboard shows the name of an opted-out runner: %s", body)
+	}
+
+	var data LeaderboardData
+	if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if data.TodayRuns != 2 || data.TotalRuns != 2 || len(data.RecentLaps) != 2 {
+		t.Errorf("Expected 2 laps today, got %+v", data)
+	}
+	names := map[string]bool{}
+	for _, grade := range data.Grades {
+		for _, runner := range grade.Runners {
+			names[runner.Name] = true
+		}
+	}
+	if !names["Test Runner"] || !names["P.K."] {
+		t.Errorf("Expected full name and initials on the leaderboard, got %v", names)
+	}
+
+	// A revoked token stops working
+	if err := db.RevokeDisplayToken(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if rr := getData(token.Token); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a revoked token, got %d", rr.Code)
+	}
+}
+
+func TestBuildLeaderboardToday(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := createTestRegistration(t, db, activeSeason.ID)
+
+	// A lap the evening before falls on the next day in UTC, but isn't
+	// one of today's in the club's timezone
+	now := time.Date(2026, 9, 15, 16, 0, 0, 0, clubLocation())
+	for _, at := range []time.Time{now.Add(-20 * time.Hour), now.Add(-30 * time.Minute)} {
+		if _, _, err := db.RecordScanAt(reg.ID, nil, at.In(time.Local), ScanOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := buildLeaderboard(activeSeason, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.TotalRuns != 2 || data.TodayRuns != 1 || len(data.RecentLaps) != 1 {
+		t.Errorf("Expected 1 of 2 laps today, got %d of %d with %d recent", data.TodayRuns, data.TotalRuns, len(data.RecentLaps))
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DuplicatePair is two registrations in a season that look like the same child
+type DuplicatePair struct {
+	Original  *Registration // The earlier registration
+	Duplicate *Registration
+}
+
+// normalizeName folds a name for duplicate checks, ignoring case, spacing and
+// punctuation, so "Mary-Kate O'Neil" matches "mary kate oneil"
+func normalizeName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizePhone keeps only the digits of a phone number
+func normalizePhone(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isLikelyDuplicate reports whether two registrations look like the same
+// child: the same name and grade, and a parent email or phone number in common
+func isLikelyDuplicate(a, b *Registration) bool {
+	if normalizeName(a.FirstName) != normalizeName(b.FirstName) ||
+		normalizeName(a.LastName) != normalizeName(b.LastName) ||
+		a.Grade != b.Grade {
+		return false
+	}
+
+	emailA := strings.ToLower(strings.TrimSpace(a.ParentEmail))
+	if emailA != "" && emailA == strings.ToLower(strings.TrimSpace(b.ParentEmail)) {
+		return true
+	}
+	for _, phoneA := range []string{a.ParentContactNumber, a.BackupContactNumber} {
+		phoneA = normalizePhone(phoneA)
+		if phoneA == "" {
+			continue
+		}
+		for _, phoneB := range []string{b.ParentContactNumber, b.BackupContactNumber} {
+			if phoneA == normalizePhone(phoneB) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findDuplicate returns the registration in existing that reg looks like a
+// duplicate of, or nil if there isn't one
+func findDuplicate(reg *Registration, existing []*Registration) *Registration {
+	for _, other := range existing {
+		if other.ID != reg.ID && isLikelyDuplicate(reg, other) {
+			return other
+		}
+	}
+	return nil
+}
+
+// findDuplicatePairs lists the likely duplicates among a season's
+// registrations, pairing each with the earliest registration it matches
+func findDuplicatePairs(regs []*Registration) []DuplicatePair {
+	regs = append([]*Registration(nil), regs...)
+	sort.SliceStable(regs, func(i, j int) bool {
+		return regs[i].RegisteredAt.Before(regs[j].RegisteredAt)
+	})
+
+	var pairs []DuplicatePair
+	for i, dup := range regs {
+		if original := findDuplicate(dup, regs[:i]); original != nil {
+			pairs = append(pairs, DuplicatePair{Original: original, Duplicate: dup})
+		}
+	}
+	return pairs
+}
+
+// duplicateRegistrationError tells a parent their child is already signed up
+func duplicateRegistrationError(existing *Registration, season *Season) error {
+	return errRegistrationInput(fmt.Sprintf(
+		"It looks like %s %s is already registered for %s, so we haven't registered them again. "+
+			"If you need to change their details, please contact Run Club.",
+		existing.FirstName, existing.LastName, season.Name))
+}
+
+// checkPublicDuplicate returns an error if a child signing up on the public
+// form is already registered or waiting on the season's waitlist
+func checkPublicDuplicate(reg *Registration, season *Season) error {
+	regs, err := database.GetAllRegistrations(season.ID)
+	if err != nil {
+		return err
+	}
+	if existing := findDuplicate(reg, regs); existing != nil {
+		return duplicateRegistrationError(existing, season)
+	}
+
+	entries, err := database.GetWaitlist(season.ID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsWaiting() && isLikelyDuplicate(reg, entry.Registration) {
+			return errRegistrationInput(fmt.Sprintf(
+				"%s %s is already on the waitlist for %s, so we haven't added them again. We'll email you if a spot opens up.",
+				entry.Registration.FirstName, entry.Registration.LastName, season.Name))
+		}
+	}
+	return nil
+}
+
+// duplicatesURL returns the duplicates page for a season, with an optional message to show
+func duplicatesURL(seasonID, message string) string {
+	u := "/registrations/duplicates?season_id=" + url.QueryEscape(seasonID)
+	if message != "" {
+		u += "&message=" + url.QueryEscape(message)
+	}
+	return u
+}
+
+// duplicatesHandler lists registrations in a season that look like the same child
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Duplicate Registrations",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+		Message: r.URL.Query().Get("message"),
+		Success: r.URL.Query().Get("message") != "",
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+
+		regs, err := database.GetAllRegistrations(season.ID)
+		if err != nil {
+			log.Printf("Error getting registrations: %v", err)
+			http.Error(w, "Failed to retrieve registrations", http.StatusInternalServerError)
+			return
+		}
+		data.DuplicatePairs = findDuplicatePairs(regs)
+	}
+
+	renderTemplate(w, "duplicates", data)
+}
+
+// registrationMergeHandler merges a duplicate registration into the one being
+// kept, moving its scans onto the kept registration
+func registrationMergeHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	keepID := r.FormValue("keep_id")
+	duplicateID := r.FormValue("duplicate_id")
+	if keepID == "" || duplicateID == "" {
+		http.Error(w, "Both registrations are required", http.StatusBadRequest)
+		return
+	}
+
+	kept, moved, err := database.MergeRegistrations(keepID, duplicateID, username)
+	switch {
+	case errors.Is(err, ErrRegistrationNotFound):
+		http.Error(w, "Registration not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrMergeMismatch):
+		http.Error(w, "Only two different registrations in the same season can be merged", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Error merging registrations: %v", err)
+		http.Error(w, "Failed to merge registrations", http.StatusInternalServerError)
+		return
+	}
+
+	message := fmt.Sprintf("Merged the duplicate registration into %s %s and moved %d scans", kept.FirstName, kept.LastName, moved)
+	if r.FormValue("return") == "duplicates" {
+		http.Redirect(w, r, duplicatesURL(*kept.SeasonID, message), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, runnerDetailURL(kept.ID, message), http.StatusSeeOther)
+}
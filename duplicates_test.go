@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestIsLikelyDuplicate(t *testing.T) {
+	original := &Registration{FirstName: "Mary-Kate", LastName: "O'Neil", Grade: "2", ParentEmail: "Pat@Example.com", ParentContactNumber: "555-555-1234"}
+
+	tests := []struct {
+		name string
+		reg  Registration
+		want bool
+	}{
+		{"same email, different spelling", Registration{FirstName: "mary kate", LastName: "ONeil", Grade: "2", ParentEmail: " pat@example.com"}, true},
+		{"same phone", Registration{FirstName: "Mary-Kate", LastName: "O'Neil", Grade: "2", ParentEmail: "other@example.com", ParentContactNumber: "(555) 555 1234"}, true},
+		{"backup phone", Registration{FirstName: "Mary-Kate", LastName: "O'Neil", Grade: "2", BackupContactNumber: "5555551234"}, true},
+		{"different grade", Registration{FirstName: "Mary-Kate", LastName: "O'Neil", Grade: "3", ParentEmail: "pat@example.com"}, false},
+		{"different contact", Registration{FirstName: "Mary-Kate", LastName: "O'Neil", Grade: "2", ParentEmail: "other@example.com", ParentContactNumber: "555-555-9999"}, false},
+		{"sibling", Registration{FirstName: "Sam", LastName: "O'Neil", Grade: "2", ParentEmail: "pat@example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLikelyDuplicate(&tt.reg, original); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMergeRegistrations(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := newTestRegistration(season.ID, "Anna", "Doe", "anna@example.com")
+	dup := newTestRegistration(season.ID, "Anna", "Doe", "other@example.com")
+	dup.Allergies = "Peanuts"
+	dup.RegisteredAt = kept.RegisteredAt.Add(time.Minute)
+	for _, reg := range []*Registration{kept, dup} {
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := db.RecordScanAt(kept.ID, nil, time.Now().Add(-2*time.Hour), ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.RecordScanAt(dup.ID, nil, time.Now().Add(-time.Hour), ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	regs, err := db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := findDuplicatePairs(regs)
+	if len(pairs) != 1 || pairs[0].Original.ID != kept.ID || pairs[0].Duplicate.ID != dup.ID {
+		t.Fatalf("Expected the later registration to be flagged, got %+v", pairs)
+	}
+
+	merged, moved, err := db.MergeRegistrations(kept.ID, dup.ID, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 || merged.Allergies != "Peanuts" {
+		t.Errorf("Expected 1 scan moved and allergies filled in, got %d and %q", moved, merged.Allergies)
+	}
+
+	scans, err := db.GetScanHistory(kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 2 {
+		t.Errorf("Expected both scans on the kept registration, got %d", len(scans))
+	}
+	if _, found, err := db.GetRegistration(dup.ID); err != nil || found {
+		t.Errorf("Expected the duplicate to be deleted, found=%v err=%v", found, err)
+	}
+
+	entries, err := db.GetRegistrationAuditLog(kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || entries[0].Action != AuditActionMerge {
+		t.Errorf("Expected the merge at the top of the change history, got %+v", entries)
+	}
+
+	if _, _, err := db.MergeRegistrations(kept.ID, kept.ID, "admin"); !errors.Is(err, ErrMergeMismatch) {
+		t.Errorf("Expected ErrMergeMismatch merging a registration into itself, got %v", err)
+	}
+	if _, _, err := db.MergeRegistrations(kept.ID, dup.ID, "admin"); !errors.Is(err, ErrRegistrationNotFound) {
+		t.Errorf("Expected ErrRegistrationNotFound merging twice, got %v", err)
+	}
+}
+
+func TestDuplicateRegistrationsRejected(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	publicStore = sessions.NewCookieStore([]byte("test-secret"))
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Public", func(t *testing.T) {
+		form := url.Values{
+			"firstName":           {"Wes"},
+			"lastName":            {"Twice"},
+			"grade":               {"2"},
+			"parentFirstName":     {"Pat"},
+			"parentContactNumber": {"555-555-1234"},
+			"parentEmail":         {"pat@example.com"},
+		}
+		submit := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/public/register?token="+season.RegistrationToken, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			publicRegisterHandler(rr, req)
+			return rr
+		}
+
+		if rr := submit(); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected the first sign-up to register, got %d: %s", rr.Code, rr.Body.String())
+		}
+		form.Set("parentEmail", "PAT@example.com ")
+		rr := submit()
+		if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "already registered") {
+			t.Errorf("Expected the second sign-up to be rejected, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		csv := "FirstName,LastName,Grade,Teacher,Gender,ParentContactNumber,BackupContactNumber,ParentEmail,DismissalMethod,Allergies,MedicalInfo\n" +
+			"Anna,Doe,K,Smith,Female,555-555-1234,,parent@example.com,Car Pickup,,\n" +
+			"Anna,Doe,K,Smith,Female,555-555-1234,,parent@example.com,Car Pickup,,\n"
+
+		imp, err := parseCSVImport(csv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processCsvImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		if imp.Count(csvRowReady) != 1 || imp.Rows[1].Status != csvRowSkipped || imp.Rows[1].Line != 3 {
+			t.Errorf("Expected the repeated row to be skipped, got %+v", imp.Rows)
+		}
+
+		// Uploading the same file again adds no one
+		imp, err = parseCSVImport(csv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processCsvImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		if imp.Count(csvRowReady) != 0 || imp.Count(csvRowSkipped) != 2 {
+			t.Errorf("Expected both rows to be skipped on re-upload, got %+v", imp.Rows)
+		}
+	})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxSiblingsPerForm caps how many children one public registration form can register
+const maxSiblingsPerForm = 8
+
+// registrationChildFields are the registration form fields that belong to
+// each child rather than to the family. The season's custom questions are
+// asked about each child too.
+var registrationChildFields = []string{"firstName", "lastName", "grade", "teacher", "gender", "tshirtSize", "allergies", "medicalInfo"}
+
+// siblingFieldPrefix matches the prefix of an extra child's fields on the registration form, like "child2."
+var siblingFieldPrefix = regexp.MustCompile(`^child(\d+)\.`)
+
+// Household is a family: every child registered under it, across seasons
+type Household struct {
+	ID      string
+	Contact *Registration      // The most recent registration, whose parent details are the family's
+	Members []*HouseholdMember // Each child, by first name
+}
+
+// HouseholdMember is one child in a household
+type HouseholdMember struct {
+	Registration *Registration   // The child's most recent registration
+	Lifetime     *RunnerLifetime // Runs and distance in every season
+}
+
+// Current returns the child's runs and distance in the season of their most
+// recent registration, or nil if there aren't any
+func (m *HouseholdMember) Current() *RunnerSeasonSummary {
+	if m.Lifetime == nil {
+		return nil
+	}
+	for _, s := range m.Lifetime.Seasons {
+		if s.RegistrationID == m.Registration.ID {
+			return s
+		}
+	}
+	return nil
+}
+
+// householdURL returns a household's page, with an optional message to show
+func householdURL(id, message string) string {
+	u := "/household/" + url.PathEscape(id)
+	if message != "" {
+		u += "?message=" + url.QueryEscape(message)
+	}
+	return u
+}
+
+// siblingForms splits a registration form that may register several
+// children into one form per child. The first child's fields have their
+// usual names; each extra child's have a prefix like "child2.", and share
+// the parent details, preferences and waiver signature with the first.
+func siblingForms(form url.Values, questions []*SeasonQuestion) ([]url.Values, error) {
+	childFields := append([]string{}, registrationChildFields...)
+	for _, q := range questions {
+		childFields = append(childFields, q.FieldName())
+	}
+
+	shared := url.Values{}
+	prefixes := make(map[int]string)
+	for key, values := range form {
+		m := siblingFieldPrefix.FindStringSubmatch(key)
+		if m == nil {
+			shared[key] = values
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			prefixes[n] = m[0]
+		}
+	}
+	if len(prefixes)+1 > maxSiblingsPerForm {
+		return nil, errRegistrationInput(fmt.Sprintf("Please register at most %d children on one form", maxSiblingsPerForm))
+	}
+
+	numbers := make([]int, 0, len(prefixes))
+	for n := range prefixes {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	forms := []url.Values{shared}
+	for _, n := range numbers {
+		child := url.Values{}
+		for key, values := range shared {
+			child[key] = values
+		}
+		for _, field := range childFields {
+			delete(child, field)
+			if values, ok := form[prefixes[n]+field]; ok {
+				child[field] = values
+			}
+		}
+		forms = append(forms, child)
+	}
+	return forms, nil
+}
+
+// formRequest returns a copy of r that reads its form values from form
+func formRequest(r *http.Request, form url.Values) *http.Request {
+	child := *r
+	child.Form = form
+	child.PostForm = form
+	return &child
+}
+
+// siblingRegistrations reads a registration for each child on a public
+// registration form and validates them, along with their answers to the
+// season's questions and the parent's waiver signature
+func siblingRegistrations(r *http.Request, season *Season, questions []*SeasonQuestion, waiver *SeasonWaiver) ([]*Registration, error) {
+	forms, err := siblingForms(r.Form, questions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var regs []*Registration
+	for i, form := range forms {
+		child := formRequest(r, form)
+		reg := registrationFromForm(child)
+		reg.ID = uuid.New().String()
+		reg.SeasonID = &season.ID
+		reg.RegisteredAt = now
+		reg.Season = season
+
+		err := validateRegistration(reg)
+		if err == nil {
+			reg.Answers, err = answersFromForm(child, questions)
+		}
+		if err == nil {
+			err = consentFromForm(child, reg, waiver)
+		}
+		for _, sibling := range regs {
+			if err == nil && isLikelyDuplicate(reg, sibling) {
+				err = errRegistrationInput(fmt.Sprintf("%s %s is on the form twice", reg.FirstName, reg.LastName))
+			}
+		}
+		if err != nil {
+			if len(forms) > 1 {
+				return nil, errRegistrationInput(fmt.Sprintf("Child %d: %v", i+1, err))
+			}
+			return nil, err
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+// loadHousehold gathers a household's children and their runs across seasons
+func loadHousehold(id string) (*Household, bool, error) {
+	regs, err := database.GetHouseholdRegistrations(id)
+	if err != nil || len(regs) == 0 {
+		return nil, false, err
+	}
+
+	household := &Household{ID: id, Contact: regs[0]}
+	for _, reg := range regs {
+		if reg.WithdrawnAt == nil {
+			household.Contact = reg
+			break
+		}
+	}
+
+	// Registrations come newest first, so each child's first is their latest
+	seen := make(map[string]bool)
+	for _, reg := range regs {
+		runnerID := reg.RunnerID
+		if runnerID == "" {
+			runnerID = reg.ID
+		}
+		if seen[runnerID] {
+			continue
+		}
+		seen[runnerID] = true
+
+		member := &HouseholdMember{Registration: reg}
+		if reg.RunnerID != "" {
+			history, err := database.GetRunnerHistory(reg.RunnerID)
+			if err != nil {
+				return nil, false, err
+			}
+			member.Lifetime = newRunnerLifetime(history)
+		}
+		household.Members = append(household.Members, member)
+	}
+	sort.SliceStable(household.Members, func(i, j int) bool {
+		return strings.ToLower(household.Members[i].Registration.FirstName) < strings.ToLower(household.Members[j].Registration.FirstName)
+	})
+	return household, true, nil
+}
+
+// householdHandler shows a family's contact details and each child's runs
+func householdHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 3 || parts[2] == "" {
+		http.Error(w, "Invalid household ID", http.StatusBadRequest)
+		return
+	}
+
+	household, found, err := loadHousehold(parts[2])
+	if err != nil {
+		log.Printf("Error getting household: %v", err)
+		http.Error(w, "Failed to retrieve household", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Household not found", http.StatusNotFound)
+		return
+	}
+
+	// Children in the same season who could be added as siblings
+	var others []*Registration
+	if household.Contact.SeasonID != nil {
+		regs, err := database.GetAllRegistrations(*household.Contact.SeasonID)
+		if err != nil {
+			log.Printf("Error getting registrations: %v", err)
+		}
+		for _, reg := range regs {
+			if reg.HouseholdID != household.ID {
+				others = append(others, reg)
+			}
+		}
+		sort.Slice(others, func(i, j int) bool {
+			if others[i].LastName != others[j].LastName {
+				return others[i].LastName < others[j].LastName
+			}
+			return others[i].FirstName < others[j].FirstName
+		})
+	}
+
+	renderTemplate(w, "household", PageData{
+		Title:         fmt.Sprintf("Run Club - %s %s's Household", household.Contact.ParentFirstName, household.Contact.ParentLastName),
+		User:          username,
+		Role:          role,
+		Household:     household,
+		Registrations: others,
+		Message:       r.URL.Query().Get("message"),
+		Success:       r.URL.Query().Get("message") != "",
+	})
+}
+
+// householdUpdateHandler adds a runner to a household, or moves them out
+// into a household of their own
+func householdUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	householdID := r.FormValue("household_id")
+	registrationID := r.FormValue("registration_id")
+	if householdID == "" || registrationID == "" {
+		http.Error(w, "Household and runner are required", http.StatusBadRequest)
+		return
+	}
+
+	var reg *Registration
+	var message string
+	switch r.FormValue("action") {
+	case "add":
+		reg, err = database.MoveToHousehold(registrationID, householdID, username)
+		if err == nil {
+			message = fmt.Sprintf("Added %s %s to the household", reg.FirstName, reg.LastName)
+		}
+	case "remove":
+		reg, err = database.MoveToHousehold(registrationID, "", username)
+		if err == nil {
+			message = fmt.Sprintf("Moved %s %s to a household of their own", reg.FirstName, reg.LastName)
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrRegistrationNotFound):
+		http.Error(w, "Runner not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrHouseholdNotFound):
+		http.Error(w, "Household not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error updating household: %v", err)
+		http.Error(w, "Failed to update household", http.StatusInternalServerError)
+		return
+	}
+
+	// A household left without anyone in it has no page to go back to
+	if regs, err := database.GetHouseholdRegistrations(householdID); err == nil && len(regs) == 0 {
+		http.Redirect(w, r, runnerDetailURL(reg.ID, message), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, householdURL(householdID, message), http.StatusSeeOther)
+}
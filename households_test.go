@@ -0,0 +1,335 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+)
+
+func TestSiblingForms(t *testing.T) {
+	question := &SeasonQuestion{ID: "q1"}
+	form := url.Values{
+		"firstName":                      {"Anna"},
+		"grade":                          {"2"},
+		question.FieldName():             {"Tuesday"},
+		"parentEmail":                    {"pat@example.com"},
+		"optOutPhotoSharing":             {"true"},
+		"child3.firstName":               {"Cara"},
+		"child3.grade":                   {"K"},
+		"child2.firstName":               {"Ben"},
+		"child2.grade":                   {"4"},
+		"child2." + question.FieldName(): {"Thursday"},
+	}
+
+	forms, err := siblingForms(form, []*SeasonQuestion{question})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 3 {
+		t.Fatalf("Expected 3 children, got %d", len(forms))
+	}
+	for i, want := range []struct{ first, grade, answer string }{
+		{"Anna", "2", "Tuesday"},
+		{"Ben", "4", "Thursday"},
+		{"Cara", "K", ""},
+	} {
+		f := forms[i]
+		if f.Get("firstName") != want.first || f.Get("grade") != want.grade || f.Get(question.FieldName()) != want.answer {
+			t.Errorf("Child %d: got %v", i+1, f)
+		}
+		if f.Get("parentEmail") != "pat@example.com" || f.Get("optOutPhotoSharing") != "true" {
+			t.Errorf("Child %d: expected the family's details to be shared, got %v", i+1, f)
+		}
+		if f.Get("child2.firstName") != "" {
+			t.Errorf("Child %d: expected the other children's fields to be left out", i+1)
+		}
+	}
+
+	for i := 2; i <= maxSiblingsPerForm+1; i++ {
+		form.Set("child"+strings.Repeat("1", i)+".firstName", "Extra")
+	}
+	if _, err := siblingForms(form, nil); err == nil {
+		t.Error("Expected too many children to be rejected")
+	}
+}
+
+func TestHouseholds(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	store = sessions.NewCookieStore([]byte("test-secret"))
+	publicStore = sessions.NewCookieStore([]byte("test-secret"))
+
+	fall, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/public/register?token="+fall.RegistrationToken, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		publicRegisterHandler(rr, req)
+		return rr
+	}
+	form := url.Values{
+		"firstName":           {"Anna"},
+		"lastName":            {"Doe"},
+		"grade":               {"2"},
+		"child2.firstName":    {"Ben"},
+		"child2.grade":        {"4"},
+		"parentFirstName":     {"Pat"},
+		"parentLastName":      {"Doe"},
+		"parentContactNumber": {"555-555-1234"},
+		"parentEmail":         {"pat@example.com"},
+		"registerForSpring":   {"true"},
+	}
+	if rr := register(form); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Child 2:") {
+		t.Errorf("Expected a sibling without a last name to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	form.Set("child2.lastName", "Doe")
+	rr := register(form)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected both children to be registered, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/public/success" || len(location.Query()["id"]) != 2 {
+		t.Errorf("Expected the success page to show both children, got %s", location)
+	}
+
+	regs, err := db.GetAllRegistrations(fall.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regs) != 2 || regs[0].HouseholdID == "" || regs[0].HouseholdID != regs[1].HouseholdID {
+		t.Fatalf("Expected two registrations in one household, got %+v", regs)
+	}
+	householdID := regs[0].HouseholdID
+	byName := make(map[string]*Registration)
+	for _, reg := range regs {
+		byName[reg.FirstName] = reg
+		if reg.ParentEmail != "pat@example.com" || reg.ParentFirstName != "Pat" {
+			t.Errorf("Expected %s to share the parent details, got %+v", reg.FirstName, reg)
+		}
+	}
+	if byName["Ben"].Grade != "4" || byName["Ben"].LastName != "Doe" {
+		t.Errorf("Expected Ben's own details, got %+v", byName["Ben"])
+	}
+
+	// Submitting the same siblings again doesn't register them twice
+	if rr := register(form); rr.Code != http.StatusConflict {
+		t.Errorf("Expected a duplicate submission to be rejected, got %d", rr.Code)
+	}
+
+	household, found, err := loadHousehold(householdID)
+	if err != nil || !found {
+		t.Fatalf("Expected the household to load, got %v, %v", found, err)
+	}
+	if len(household.Members) != 2 || household.Members[0].Registration.FirstName != "Anna" || household.Contact.ParentFirstName != "Pat" {
+		t.Errorf("Unexpected household %+v", household)
+	}
+
+	t.Run("CopySpringKeepsHousehold", func(t *testing.T) {
+		spring := &Season{ID: uuid.New().String(), Name: "Spring", CreatedAt: time.Now()}
+		if err := db.SaveSeason(spring, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CopySpringRegistrations(fall.ID, spring.ID, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		copied, err := db.GetAllRegistrations(spring.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(copied) != 2 || copied[0].HouseholdID != householdID || copied[1].HouseholdID != householdID {
+			t.Errorf("Expected the copied siblings to stay in household %s, got %+v", householdID, copied)
+		}
+
+		household, _, err := loadHousehold(householdID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(household.Members) != 2 {
+			t.Errorf("Expected each child once, got %d members", len(household.Members))
+		}
+		for _, m := range household.Members {
+			if m.Lifetime == nil || len(m.Lifetime.Seasons) != 2 {
+				t.Errorf("Expected %s's stats in both seasons, got %+v", m.Registration.FirstName, m.Lifetime)
+			}
+		}
+	})
+
+	t.Run("MoveToHousehold", func(t *testing.T) {
+		cousin := newTestRegistration(fall.ID, "Cara", "Lee", "lee@example.com")
+		if err := db.SaveRegistration(cousin, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if cousin.HouseholdID == "" || cousin.HouseholdID == householdID {
+			t.Fatalf("Expected a household of her own, got %q", cousin.HouseholdID)
+		}
+
+		post := func(form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/households/update", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			session, _ := store.Get(req, "run-club-session")
+			session.Values["username"] = "admin"
+			rr := httptest.NewRecorder()
+			householdUpdateHandler(rr, req)
+			return rr
+		}
+		if rr := post(url.Values{"action": {"add"}, "household_id": {householdID}, "registration_id": {cousin.ID}}); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected Cara to be added, got %d: %s", rr.Code, rr.Body.String())
+		}
+		moved, _, err := db.GetRegistration(cousin.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.HouseholdID != householdID {
+			t.Errorf("Expected Cara in household %s, got %q", householdID, moved.HouseholdID)
+		}
+
+		if rr := post(url.Values{"action": {"add"}, "household_id": {"missing"}, "registration_id": {cousin.ID}}); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected an unknown household to be rejected, got %d", rr.Code)
+		}
+		if rr := post(url.Values{"action": {"remove"}, "household_id": {householdID}, "registration_id": {cousin.ID}}); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected Cara to be moved out, got %d: %s", rr.Code, rr.Body.String())
+		}
+		moved, _, err = db.GetRegistration(cousin.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.HouseholdID == "" || moved.HouseholdID == householdID {
+			t.Errorf("Expected Cara in a new household, got %q", moved.HouseholdID)
+		}
+		if _, err := db.MoveToHousehold("missing", householdID, "admin"); !errors.Is(err, ErrRegistrationNotFound) {
+			t.Errorf("Expected ErrRegistrationNotFound, got %v", err)
+		}
+
+		entries, err := db.GetRegistrationAuditLog(cousin.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var moves int
+		for _, e := range entries {
+			for _, c := range e.Changes {
+				if e.Action == AuditActionUpdate && c.Field == "householdId" {
+					moves++
+				}
+			}
+		}
+		if moves != 2 {
+			t.Errorf("Expected both moves in Cara's history, got %d", moves)
+		}
+	})
+
+	t.Run("ReturningRunnerKeepsHousehold", func(t *testing.T) {
+		winter := &Season{ID: uuid.New().String(), Name: "Winter", CreatedAt: time.Now()}
+		if err := db.SaveSeason(winter, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		anna := newTestRegistration(winter.ID, "Anna", "Doe", "pat@example.com")
+		if err := db.SaveRegistration(anna, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if anna.HouseholdID != householdID {
+			t.Errorf("Expected Anna to rejoin household %s, got %q", householdID, anna.HouseholdID)
+		}
+	})
+}
+
+func TestHouseholdsMigration(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	conn, err := sql.Open("sqlite", tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Build the schema as it was before households existed
+	mm := NewMigrationManager(conn)
+	if err := mm.LoadMigrationsFromDir("migrations"); err != nil {
+		t.Fatal(err)
+	}
+	all := mm.migrations
+	var householdsMigration int
+	for i, m := range all {
+		if m.Description == "add households" {
+			householdsMigration = i
+		}
+	}
+	mm.migrations = all[:householdsMigration]
+	if err := mm.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Exec("INSERT INTO seasons (id, name, created_at) VALUES ('fall', 'Fall', ?)", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	registrations := []struct {
+		id, first, email string
+		registeredAt     time.Time
+	}{
+		{"anna", "Anna", "pat@example.com", time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"ben", "Ben", " PAT@example.com", time.Date(2024, 8, 2, 0, 0, 0, 0, time.UTC)},
+		{"cara", "Cara", "lee@example.com", time.Date(2024, 8, 3, 0, 0, 0, 0, time.UTC)},
+		{"dev", "Dev", "", time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, r := range registrations {
+		_, err := conn.Exec(
+			`INSERT INTO registrations (id, season_id, first_name, last_name, grade, teacher, parent_contact_number, backup_contact_number, parent_email, registered_at)
+			VALUES (?, 'fall', ?, 'Doe', '3', '', '555-1234', '', ?, ?)`,
+			r.id, r.first, r.email, r.registeredAt,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mm.migrations = all
+	if err := mm.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	householdOf := func(regID string) string {
+		var householdID string
+		if err := conn.QueryRow("SELECT household_id FROM registrations WHERE id = ?", regID).Scan(&householdID); err != nil {
+			t.Fatal(err)
+		}
+		return householdID
+	}
+	if householdOf("anna") != "anna" || householdOf("ben") != "anna" {
+		t.Errorf("Expected Anna and Ben in the same household, got %s and %s", householdOf("anna"), householdOf("ben"))
+	}
+	if householdOf("cara") != "cara" || householdOf("dev") != "dev" {
+		t.Errorf("Expected Cara and Dev in households of their own, got %s and %s", householdOf("cara"), householdOf("dev"))
+	}
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM households").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 households, got %d", count)
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,39 +32,28 @@ const (
 	RoleViewer  = "viewer"
 )
 
-// Hardcoded users
-var users = map[string]struct {
-	Password string
-	Role     string
-}{
-	"admin": {
-		Password: "admin123",
-		Role:     RoleAdmin,
-	},
-	"scanner": {
-		Password: "scanner123",
-		Role:     RoleScanner,
-	},
-	"viewer": {
-		Password: "viewer123",
-		Role:     RoleViewer,
-	},
-}
-
 // Season represents a running season
 type Season struct {
-	ID                        string     `json:"id"`
-	Name                      string     `json:"name"`
-	IsActive                  bool       `json:"isActive"`
-	CreatedAt                 time.Time  `json:"createdAt"`
-	RegistrationToken         string     `json:"registrationToken"`
-	SpringRegistrationEnabled bool       `json:"springRegistrationEnabled"`
-	RegistrationStartsAt      *time.Time `json:"registrationStartsAt"`
-	MaxRegistrations          int        `json:"maxRegistrations"`
+	ID                        string         `json:"id"`
+	Name                      string         `json:"name"`
+	IsActive                  bool           `json:"isActive"`
+	CreatedAt                 time.Time      `json:"createdAt"`
+	RegistrationToken         string         `json:"registrationToken"`
+	SpringRegistrationEnabled bool           `json:"springRegistrationEnabled"`
+	RegistrationStartsAt      *time.Time     `json:"registrationStartsAt"`
+	MaxRegistrations          int            `json:"maxRegistrations"`
+	KeepBadges                bool           `json:"keepBadges"` // Runners' badges from earlier seasons still scan
+	RegistrationEndsAt        *time.Time     `json:"registrationEndsAt"`
+	GradeCapacities           map[string]int `json:"gradeCapacities,omitempty"` // Most runners allowed per grade; unlisted grades have no limit of their own
+	FeeCents                  int            `json:"feeCents"`                  // The club fee per runner; 0 if the season is free
+	SiblingDiscountCents      int            `json:"siblingDiscountCents"`      // Taken off the fee for each child after the first in a family
 }
 
 // IsRegistrationOpen checks if registration is currently open for this season
 func (s *Season) IsRegistrationOpen() bool {
+	if s.IsRegistrationClosed() {
+		return false
+	}
 	if s.RegistrationStartsAt == nil {
 		// If no start date is set, registration is open
 		return true
@@ -72,6 +61,11 @@ func (s *Season) IsRegistrationOpen() bool {
 	return time.Now().After(*s.RegistrationStartsAt)
 }
 
+// IsRegistrationClosed checks if the season's registration closing date has passed
+func (s *Season) IsRegistrationClosed() bool {
+	return s.RegistrationEndsAt != nil && !time.Now().Before(*s.RegistrationEndsAt)
+}
+
 // IsRegistrationFull checks if the season has reached max registrations
 func (s *Season) IsRegistrationFull(currentCount int) bool {
 	if s.MaxRegistrations <= 0 {
@@ -81,29 +75,48 @@ func (s *Season) IsRegistrationFull(currentCount int) bool {
 	return currentCount >= s.MaxRegistrations
 }
 
+// IsGradeFull checks if a grade has reached its capacity for the season
+func (s *Season) IsGradeFull(grade string, gradeCount int) bool {
+	capacity, ok := s.GradeCapacities[grade]
+	return ok && gradeCount >= capacity
+}
+
 // Registration represents a runner registration
 type Registration struct {
-	ID                    string    `json:"id"`
-	SeasonID              *string   `json:"seasonId"`
-	FirstName             string    `json:"firstName"`
-	LastName              string    `json:"lastName"`
-	Grade                 string    `json:"grade"`
-	Teacher               string    `json:"teacher"`
-	Gender                string    `json:"gender"`
-	TshirtSize            string    `json:"tshirtSize"`
-	ParentFirstName       string    `json:"parentFirstName"`
-	ParentLastName        string    `json:"parentLastName"`
-	ParentContactNumber   string    `json:"parentContactNumber"`
-	BackupContactNumber   string    `json:"backupContactNumber"`
-	ParentEmail           string    `json:"parentEmail"`
-	DismissalMethod       string    `json:"dismissalMethod"`
-	Allergies             string    `json:"allergies"`
-	MedicalInfo           string    `json:"medicalInfo"`
-	RegisteredAt          time.Time `json:"registeredAt"`
-	RegisterForSpring     bool      `json:"registerForSpring"`
-	OptOutWebsiteDisplay  bool      `json:"optOutWebsiteDisplay"`
-	OptOutPhotoSharing    bool      `json:"optOutPhotoSharing"`
-	Season                *Season   `json:"season,omitempty"`
+	ID                   string              `json:"id"`
+	SeasonID             *string             `json:"seasonId"`
+	RunnerID             string              `json:"runnerId,omitempty"`    // The child across seasons
+	HouseholdID          string              `json:"householdId,omitempty"` // The family, shared by siblings
+	ExternalID           string              `json:"externalId,omitempty"`  // The student's ID in the school's SIS, from a roster import
+	FirstName            string              `json:"firstName"`
+	LastName             string              `json:"lastName"`
+	Grade                string              `json:"grade"`
+	Teacher              string              `json:"teacher"`
+	Gender               string              `json:"gender"`
+	TshirtSize           string              `json:"tshirtSize"`
+	ParentFirstName      string              `json:"parentFirstName"`
+	ParentLastName       string              `json:"parentLastName"`
+	ParentContactNumber  string              `json:"parentContactNumber"`
+	BackupContactNumber  string              `json:"backupContactNumber"`
+	ParentEmail          string              `json:"parentEmail"`
+	DismissalMethod      string              `json:"dismissalMethod"`
+	Allergies            string              `json:"allergies"`
+	MedicalInfo          string              `json:"medicalInfo"`
+	RegisteredAt         time.Time           `json:"registeredAt"`
+	RegisterForSpring    bool                `json:"registerForSpring"`
+	OptOutWebsiteDisplay bool                `json:"optOutWebsiteDisplay"`
+	OptOutPhotoSharing   bool                `json:"optOutPhotoSharing"`
+	WithdrawnAt          *time.Time          `json:"withdrawnAt,omitempty"` // Set when the runner has left the club
+	WithdrawnBy          string              `json:"withdrawnBy,omitempty"`
+	WithdrawReason       string              `json:"withdrawReason,omitempty"`
+	Answers              map[string][]string `json:"answers,omitempty"`       // Custom question answers by question ID
+	EditToken            string              `json:"-"`                       // Secret in the parent's edit link
+	WaiverVersion        int                 `json:"waiverVersion,omitempty"` // The season waiver version the parent last signed
+	WaiverSignedName     string              `json:"waiverSignedName,omitempty"`
+	WaiverSignedAt       *time.Time          `json:"waiverSignedAt,omitempty"`
+	WaiverSignedIP       string              `json:"waiverSignedIp,omitempty"`
+	FeeStatus            string              `json:"feeStatus,omitempty"` // FeeStatusScholarship or FeeStatusWaived if the runner doesn't pay the fee
+	Season               *Season             `json:"season,omitempty"`
 }
 
 // Track represents a running track/route
@@ -118,14 +131,21 @@ type Track struct {
 
 // ScanRecord represents a record of a QR code scan
 type ScanRecord struct {
-	ID             string    `json:"id"`
-	RegistrationID string    `json:"registrationId"`
-	SeasonID       string    `json:"seasonId"`
-	TrackID        *string   `json:"trackId,omitempty"`
-	ScannedAt      time.Time `json:"scannedAt"`
-	RunnerName     string    `json:"runnerName,omitempty"` // Populated for API responses
-	Season         *Season   `json:"season,omitempty"`
-	Track          *Track    `json:"track,omitempty"`
+	ID             string       `json:"id"`
+	RegistrationID string       `json:"registrationId"`
+	SeasonID       string       `json:"seasonId"`
+	TrackID        *string      `json:"trackId,omitempty"`
+	ScannedAt      time.Time    `json:"scannedAt"`
+	RecordedBy     string       `json:"recordedBy,omitempty"`
+	Source         string       `json:"source,omitempty"`
+	VoidedAt       *time.Time   `json:"voidedAt,omitempty"`
+	VoidedBy       string       `json:"voidedBy,omitempty"`
+	VoidReason     string       `json:"voidReason,omitempty"`
+	PracticeID     *string      `json:"practiceId,omitempty"` // Practice the scan was recorded during
+	Milestones     []*Milestone `json:"milestones,omitempty"` // Milestones this scan reached, set by RecordScanAt
+	RunnerName     string       `json:"runnerName,omitempty"` // Populated for API responses
+	Season         *Season      `json:"season,omitempty"`
+	Track          *Track       `json:"track,omitempty"`
 }
 
 // ScanResult represents the result of a scan operation
@@ -137,39 +157,81 @@ type ScanResult struct {
 	LapTime      *float64      `json:"lapTime,omitempty"`      // Time in minutes since last scan
 	Pace         *float64      `json:"pace,omitempty"`         // Minutes per mile
 	PreviousScan *ScanRecord   `json:"previousScan,omitempty"` // Previous scan for reference
+	Milestones   []*Milestone  `json:"milestones,omitempty"`   // Milestones reached by this scan
 }
 
 // PageData holds data to be passed to templates
 type PageData struct {
-	Title            string
-	Registration     *Registration
-	ScanResult       *ScanResult
-	User             string
-	Role             string
-	ActiveSeason     *Season
-	Seasons          []*Season
-	Tracks           []*Track
-	SeasonStats      []SeasonStat
-	Success          bool
-	Message          string
-	SuccessCount     int
-	ErrorCount       int
-	Errors           []string
-	Registrations    []*Registration
-	SelectedSeasonID string
-	SearchQuery      string
-	CurrentPage      int
-	TotalPages       int
-	TotalRunners     int
-	PrefillData      map[string]string
-	RegistrationLink string
-	RegistrationOpen bool
-	RegistrationFull bool
-	RegistrationCount int
-	RunnersPerPage   int
-	BaseURL          string
-	Stats            *SeasonStats
-	SelectedSeason   *Season
+	Title              string
+	Registration       *Registration
+	ScanResult         *ScanResult
+	User               string
+	Role               string
+	ActiveSeason       *Season
+	Seasons            []*Season
+	Tracks             []*Track
+	SeasonStats        []SeasonStat
+	Success            bool
+	Message            string
+	CSVImport          *CSVImport
+	Roster             *RosterImport
+	ScanImport         *ScanImport
+	Registrations      []*Registration
+	SelectedSeasonID   string
+	SearchQuery        string
+	CurrentPage        int
+	TotalPages         int
+	TotalRunners       int
+	PrefillData        map[string]string
+	RegistrationLink   string
+	RegistrationOpen   bool
+	RegistrationFull   bool
+	RegistrationCount  int
+	RegistrationClosed bool
+	GradeSpots         []GradeSpots
+	SeasonCapacities   map[string]*SeasonCapacity
+	RunnersPerPage     int
+	BaseURL            string
+	Stats              *SeasonStats
+	SelectedSeason     *Season
+	Users              []*User
+	Sessions           []*UserSession
+	CurrentSessionID   string
+	Scans              []*ScanRecord
+	DisplayToken       string
+	DisplayTokens      []*DisplayToken
+	Milestones         []*Milestone
+	AwardsByTeacher    []TeacherAwards
+	AwardsDueCount     int
+	Practices          []*Practice
+	Practice           *Practice
+	PresentRunners     []*PracticeAttendee
+	AbsentRunners      []*PracticeAttendee
+	Attendance         *RunnerAttendance
+	Lifetime           *RunnerLifetime
+	RunnerMatches      []*RunnerMatch
+	WithdrawnRunners   []*Registration
+	AuditEntries       []*AuditEntry
+	AuditEntityType    string
+	WaitlistEntries    []*WaitlistEntry
+	Questions          []*SeasonQuestion
+	SeasonQuestions    map[string][]*SeasonQuestion
+	AnswerFilter       string
+	DuplicatePairs     []DuplicatePair
+	EditURL            string // The parent's private link for updating the registration
+	ChangeRequest      *ChangeRequest
+	ChangeRequests     []*ChangeRequest
+	Waiver             *SeasonWaiver
+	SeasonWaivers      map[string]*SeasonWaiver
+	Balance            *Balance
+	Balances           []*Balance
+	PaymentSummary     *PaymentSummary
+	PaymentImport      *PaymentImport
+	PaymentMethods     []string
+	OutstandingOnly    bool
+	Household          *Household
+	Siblings           []*Registration
+	EditURLs           map[string]string // Parents' private edit links by registration ID
 }
 
 // SeasonStat represents statistics for a season
@@ -189,6 +251,8 @@ type RunnerStats struct {
 	Teacher        string
 	RunCount       int
 	TotalDistance  float64
+	// OptOutWebsiteDisplay hides the runner's name on public displays
+	OptOutWebsiteDisplay bool
 }
 
 // GradeStats represents statistics for a grade
@@ -209,12 +273,18 @@ type SeasonStats struct {
 	GradeStats    []GradeStats
 	TopRunners    []RunnerStats
 	TrackUsage    map[string]int
+	// Runs checked in by name search rather than a badge scan
+	ManualRuns           int
+	ManualRunPercent     float64
+	ManualCheckInRunners []RunnerStats
 }
 
 var (
 	database  *Database
 	templates map[string]*template.Template
-	store     *sessions.CookieStore
+	store     sessions.Store
+	// publicStore holds the prefill data for the public registration form
+	publicStore *sessions.CookieStore
 )
 
 func main() {
@@ -237,12 +307,20 @@ func main() {
 	// Close the database when the program exits
 	defer database.Close()
 
-	// Initialize session store
-	store = sessions.NewCookieStore([]byte("run-club-secret-key"))
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   86400 * 7, // 1 week
-		HttpOnly: true,
+	// Initialize session stores
+	if err := initSessionStores(database); err != nil {
+		log.Fatal("Error initializing session store: ", err)
+	}
+
+	// Set up parent notifications
+	notifier, err = newNotifierFromEnv()
+	if err != nil {
+		log.Fatal("Error configuring notifications: ", err)
+	}
+
+	// Create the initial admin account on first run
+	if err := ensureDefaultAdmin(database); err != nil {
+		log.Fatal("Error creating initial admin account: ", err)
 	}
 
 	// Load templates
@@ -257,24 +335,72 @@ func main() {
 	http.HandleFunc("/success", loggingMiddleware(authMiddleware(successHandler, []string{RoleAdmin})))
 	http.HandleFunc("/seasons", loggingMiddleware(authMiddleware(seasonsHandler, []string{RoleAdmin})))
 	http.HandleFunc("/seasons/activate", loggingMiddleware(authMiddleware(activateSeasonHandler, []string{RoleAdmin})))
+	http.HandleFunc("/seasons/questions", loggingMiddleware(authMiddleware(seasonQuestionsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/seasons/waiver", loggingMiddleware(authMiddleware(seasonWaiverHandler, []string{RoleAdmin})))
+	http.HandleFunc("/seasons/fees", loggingMiddleware(authMiddleware(seasonFeesHandler, []string{RoleAdmin})))
 	http.HandleFunc("/tracks", loggingMiddleware(authMiddleware(tracksHandler, []string{RoleAdmin})))
 	http.HandleFunc("/stats", loggingMiddleware(authMiddleware(statsHandler, []string{RoleAdmin, RoleViewer})))
+	http.HandleFunc("/milestones", loggingMiddleware(authMiddleware(milestonesHandler, []string{RoleAdmin})))
+	http.HandleFunc("/milestones/delete", loggingMiddleware(authMiddleware(deleteMilestoneHandler, []string{RoleAdmin})))
+	http.HandleFunc("/awards", loggingMiddleware(authMiddleware(awardsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/awards/mark", loggingMiddleware(authMiddleware(markAwardsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/practices", loggingMiddleware(authMiddleware(practicesHandler, []string{RoleAdmin})))
+	http.HandleFunc("/practices/", loggingMiddleware(authMiddleware(practiceDetailHandler, []string{RoleAdmin})))
+	http.HandleFunc("/practices/cancel", loggingMiddleware(authMiddleware(cancelPracticeHandler, []string{RoleAdmin})))
+	http.HandleFunc("/display-tokens", loggingMiddleware(authMiddleware(displayTokensHandler, []string{RoleAdmin})))
+	http.HandleFunc("/display-tokens/revoke", loggingMiddleware(authMiddleware(revokeDisplayTokenHandler, []string{RoleAdmin})))
+	http.HandleFunc("/scans/live", loggingMiddleware(authMiddleware(liveFeedHandler, []string{RoleAdmin, RoleScanner, RoleViewer})))
 	http.HandleFunc("/csv-upload", loggingMiddleware(authMiddleware(csvUploadHandler, []string{RoleAdmin})))
+	http.HandleFunc("/roster-import", loggingMiddleware(authMiddleware(rosterImportHandler, []string{RoleAdmin})))
 	http.HandleFunc("/runners", loggingMiddleware(authMiddleware(runnersHandler, []string{RoleAdmin})))
 	http.HandleFunc("/runners/export", loggingMiddleware(authMiddleware(runnersExportHandler, []string{RoleAdmin})))
 	http.HandleFunc("/runner/", loggingMiddleware(authMiddleware(runnerDetailHandler, []string{RoleAdmin})))
+	http.HandleFunc("/household/", loggingMiddleware(authMiddleware(householdHandler, []string{RoleAdmin})))
+	http.HandleFunc("/households/update", loggingMiddleware(authMiddleware(householdUpdateHandler, []string{RoleAdmin})))
+	http.HandleFunc("/runners/matches", loggingMiddleware(authMiddleware(runnerMatchesHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/update", loggingMiddleware(authMiddleware(registrationUpdateHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/withdraw", loggingMiddleware(authMiddleware(registrationWithdrawHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/duplicates", loggingMiddleware(authMiddleware(duplicatesHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/merge", loggingMiddleware(authMiddleware(registrationMergeHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/changes", loggingMiddleware(authMiddleware(changeRequestsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/changes/resolve", loggingMiddleware(authMiddleware(changeRequestResolveHandler, []string{RoleAdmin})))
+	http.HandleFunc("/registrations/waivers", loggingMiddleware(authMiddleware(waiversHandler, []string{RoleAdmin})))
+	http.HandleFunc("/payments", loggingMiddleware(authMiddleware(paymentsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/payments/record", loggingMiddleware(authMiddleware(paymentRecordHandler, []string{RoleAdmin})))
+	http.HandleFunc("/scans/update", loggingMiddleware(authMiddleware(scanUpdateHandler, []string{RoleAdmin})))
+	http.HandleFunc("/scans/import", loggingMiddleware(authMiddleware(scanImportHandler, []string{RoleAdmin})))
+	http.HandleFunc("/scans/export", loggingMiddleware(authMiddleware(scansExportHandler, []string{RoleAdmin})))
 	http.HandleFunc("/badges", loggingMiddleware(authMiddleware(badgesHandler, []string{RoleAdmin})))
 	http.HandleFunc("/badges2x4", loggingMiddleware(authMiddleware(badges2x4Handler, []string{RoleAdmin})))
+	http.HandleFunc("/users", loggingMiddleware(authMiddleware(usersHandler, []string{RoleAdmin})))
+	http.HandleFunc("/users/update", loggingMiddleware(authMiddleware(userUpdateHandler, []string{RoleAdmin})))
+	http.HandleFunc("/sessions", loggingMiddleware(authMiddleware(sessionsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/sessions/revoke", loggingMiddleware(authMiddleware(revokeSessionHandler, []string{RoleAdmin})))
+	http.HandleFunc("/waitlist", loggingMiddleware(authMiddleware(waitlistHandler, []string{RoleAdmin})))
+	http.HandleFunc("/waitlist/update", loggingMiddleware(authMiddleware(waitlistUpdateHandler, []string{RoleAdmin})))
+	http.HandleFunc("/audit", loggingMiddleware(authMiddleware(auditHandler, []string{RoleAdmin})))
 
 	// API endpoints
 	http.HandleFunc("/api/registrations", loggingMiddleware(authMiddleware(apiRegistrationsHandler, []string{RoleAdmin})))
+	http.HandleFunc("/api/registrations/", loggingMiddleware(authMiddleware(apiRegistrationHandler, []string{RoleAdmin})))
 	http.HandleFunc("/api/scan", loggingMiddleware(authMiddleware(apiScanHandler, []string{RoleAdmin, RoleScanner})))
 	http.HandleFunc("/api/scans", loggingMiddleware(authMiddleware(apiScansHandler, []string{RoleAdmin, RoleScanner})))
+	http.HandleFunc("/api/scans/batch", loggingMiddleware(authMiddleware(apiScansBatchHandler, []string{RoleAdmin, RoleScanner})))
+	http.HandleFunc("/api/runners/search", loggingMiddleware(authMiddleware(apiRunnerSearchHandler, []string{RoleAdmin, RoleScanner})))
+	http.HandleFunc("/api/scans/stream", loggingMiddleware(authMiddleware(apiScansStreamHandler, []string{RoleAdmin, RoleScanner, RoleViewer})))
+	http.HandleFunc("/api/scans/undo", loggingMiddleware(authMiddleware(apiScanUndoHandler, []string{RoleAdmin, RoleScanner})))
+	http.HandleFunc("/api/users", loggingMiddleware(authMiddleware(apiUsersHandler, []string{RoleAdmin})))
+	http.HandleFunc("/api/users/", loggingMiddleware(authMiddleware(apiUserHandler, []string{RoleAdmin})))
 
 	// Public registration endpoints (no auth required)
 	http.HandleFunc("/public/register", loggingMiddleware(publicRegisterHandler))
 	http.HandleFunc("/public/success", loggingMiddleware(publicSuccessHandler))
+	http.HandleFunc("/public/waitlisted", loggingMiddleware(publicWaitlistedHandler))
+	http.HandleFunc("/public/edit", loggingMiddleware(publicEditHandler))
 	http.HandleFunc("/info", loggingMiddleware(infoHandler))
+	http.HandleFunc("/display/leaderboard", loggingMiddleware(displayLeaderboardHandler))
+	http.HandleFunc("/display/leaderboard/data", loggingMiddleware(displayLeaderboardDataHandler))
+	http.HandleFunc("/display/leaderboard/stream", loggingMiddleware(displayLeaderboardStreamHandler))
 
 	// Debug endpoints (pprof is automatically registered by importing _ "net/http/pprof")
 	// This adds: /debug/pprof/, /debug/pprof/cmdline, /debug/pprof/profile, /debug/pprof/symbol, /debug/pprof/trace
@@ -344,10 +470,17 @@ func loadTemplates() {
 		"mod": func(a, b int) int {
 			return a % b
 		},
+		"deref": func(s *string) string {
+			if s == nil {
+				return ""
+			}
+			return *s
+		},
+		"dollars": formatDollars,
 	}
 
 	// Load each template
-	templateFiles := []string{"home", "scan", "register", "success", "login", "seasons", "tracks", "csv_upload", "runners", "badges", "badges_2x4", "stats", "info", "runner_detail"}
+	templateFiles := []string{"home", "scan", "register", "success", "login", "seasons", "tracks", "csv_upload", "runners", "badges", "badges_2x4", "stats", "info", "runner_detail", "users", "sessions", "live_feed", "display_tokens", "display_leaderboard", "milestones", "awards", "practices", "practice_detail", "runner_matches", "audit", "waitlist", "waitlisted", "duplicates", "registration_edit", "change_requests", "waivers", "payments", "household", "roster_import", "scan_import"}
 	for _, name := range templateFiles {
 		tmpl, err := template.New(name + ".html").Funcs(funcMap).ParseFiles(fmt.Sprintf("templates/%s.html", name))
 		if err != nil {
@@ -377,6 +510,13 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Flush sends buffered data to the client so streaming responses work through the logging middleware
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // loggingMiddleware logs all requests and responses
 func loggingMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -397,9 +537,11 @@ func loggingMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 		// Log request details
 		log.Printf("REQUEST: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 		if r.URL.RawQuery != "" {
-			log.Printf("  Query: %s", r.URL.RawQuery)
+			log.Printf("  Query: %s", redactQuery(r.URL.Query()))
 		}
-		if len(requestBody) > 0 && len(requestBody) < 1000 {
+		if len(requestBody) > 0 && hasSensitiveBody(r.URL.Path) {
+			log.Printf("  Body: [redacted]")
+		} else if len(requestBody) > 0 && len(requestBody) < 1000 {
 			log.Printf("  Body: %s", string(requestBody))
 		} else if len(requestBody) >= 1000 {
 			log.Printf("  Body: %s... (truncated)", string(requestBody[:1000]))
@@ -425,6 +567,22 @@ func loggingMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// hasSensitiveBody reports whether request bodies for a path may contain
+// passwords, or a parent's medical and contact details
+func hasSensitiveBody(path string) bool {
+	return path == "/login" || strings.HasPrefix(path, "/users") || strings.HasPrefix(path, "/api/users") ||
+		path == "/public/register" || path == "/public/edit"
+}
+
+// redactQuery encodes a query string for the log without its secret token,
+// which opens a parent's edit link, the registration form, or a display screen
+func redactQuery(query url.Values) string {
+	if query.Has("token") {
+		query.Set("token", "redacted")
+	}
+	return query.Encode()
+}
+
 // authMiddleware checks if the user is authenticated and has the required role
 func authMiddleware(handler http.HandlerFunc, roles []string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -437,12 +595,27 @@ func authMiddleware(handler http.HandlerFunc, roles []string) http.HandlerFunc {
 			return
 		}
 
-		// Check if user has required role
-		userRole, ok := session.Values["role"].(string)
+		// Look up the user so disabled accounts and role changes take effect immediately
+		userID, ok := session.Values["user_id"].(string)
 		if !ok {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
+		user, exists, err := database.GetUser(userID)
+		if err != nil {
+			log.Printf("Error getting user: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !exists || user.IsDisabled {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		// Handlers read the username and role from the session
+		session.Values["username"] = user.Username
+		session.Values["role"] = user.Role
+		userRole := user.Role
 
 		// Check if the role is allowed
 		allowed := false
@@ -479,11 +652,20 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		password := r.FormValue("password")
 
 		// Check credentials
-		user, exists := users[username]
-		if exists && user.Password == password {
+		user, exists, err := database.GetUserByUsername(username)
+		if err != nil {
+			log.Printf("Error getting user: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if exists && !user.IsDisabled && user.checkPassword(password) {
+			// Start a fresh session so a pre-login session ID can't be reused
+			regenerateSession(session)
+
 			// Set user as authenticated
 			session.Values["authenticated"] = true
-			session.Values["username"] = username
+			session.Values["user_id"] = user.ID
+			session.Values["username"] = user.Username
 			session.Values["role"] = user.Role
 			err := session.Save(r, w)
 			if err != nil {
@@ -497,7 +679,8 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Render login page with error
 		renderTemplate(w, "login", PageData{
-			Title: "Run Club - Login",
+			Title:   "Run Club - Login",
+			Message: "Invalid username or password",
 		})
 		return
 	}
@@ -631,17 +814,7 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Add active season data if available
 		if hasActiveSeason {
-			data.ActiveSeason = activeSeason
-			data.RegistrationOpen = activeSeason.IsRegistrationOpen()
-			
-			// Check registration capacity
-			count, err := database.GetRegistrationCountForSeason(activeSeason.ID)
-			if err != nil {
-				log.Printf("Error getting registration count: %v", err)
-			} else {
-				data.RegistrationCount = count
-				data.RegistrationFull = activeSeason.IsRegistrationFull(count)
-			}
+			setRegistrationForm(&data, activeSeason)
 		}
 
 		// Check if we have prefill data in session
@@ -682,20 +855,20 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Check if registration is open
-		if !activeSeason.IsRegistrationOpen() {
-			http.Error(w, "Registration is not yet open for this season", http.StatusBadRequest)
+		if err := checkRegistrationWindow(activeSeason); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Check if registration is full
-		count, err := database.GetRegistrationCountForSeason(activeSeason.ID)
+		capacity, err := database.GetSeasonCapacity(activeSeason)
 		if err != nil {
 			log.Printf("Error getting registration count: %v", err)
 			http.Error(w, "Failed to check registration capacity", http.StatusInternalServerError)
 			return
 		}
-		if activeSeason.IsRegistrationFull(count) {
-			http.Error(w, "Registration is full for this season", http.StatusBadRequest)
+		if capacity.IsFull() {
+			http.Error(w, errSeasonFull.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -706,46 +879,48 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Validate phone numbers
-		parentPhone := r.FormValue("parentContactNumber")
-		if !validatePhoneNumber(parentPhone) {
-			http.Error(w, "Invalid parent/guardian contact number. Please use format: 123-456-7890", http.StatusBadRequest)
+		// Create a new registration from the form and validate it
+		reg := registrationFromForm(r)
+		reg.ID = uuid.New().String()
+		reg.SeasonID = &activeSeason.ID
+		reg.RegisteredAt = time.Now()
+		reg.Season = activeSeason
+		if err := validateRegistration(reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		backupPhone := r.FormValue("backupContactNumber")
-		if backupPhone != "" && !validatePhoneNumber(backupPhone) {
-			http.Error(w, "Invalid backup contact number. Please use format: 123-456-7890", http.StatusBadRequest)
+		// Read the answers to the season's custom questions
+		questions, err := database.GetSeasonQuestions(activeSeason.ID)
+		if err != nil {
+			log.Printf("Error getting season questions: %v", err)
+			http.Error(w, "Failed to retrieve season questions", http.StatusInternalServerError)
+			return
+		}
+		reg.Answers, err = answersFromForm(r, questions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Create a new registration
-		reg := &Registration{
-			ID:                  uuid.New().String(),
-			SeasonID:            &activeSeason.ID,
-			FirstName:           r.FormValue("firstName"),
-			LastName:            r.FormValue("lastName"),
-			Grade:               r.FormValue("grade"),
-			Teacher:             r.FormValue("teacher"),
-			Gender:              r.FormValue("gender"),
-			TshirtSize:          r.FormValue("tshirtSize"),
-			ParentFirstName:     r.FormValue("parentFirstName"),
-			ParentLastName:      r.FormValue("parentLastName"),
-			ParentContactNumber: r.FormValue("parentContactNumber"),
-			BackupContactNumber:  r.FormValue("backupContactNumber"),
-			ParentEmail:          r.FormValue("parentEmail"),
-			DismissalMethod:      r.FormValue("dismissalMethod"),
-			Allergies:            r.FormValue("allergies"),
-			MedicalInfo:          r.FormValue("medicalInfo"),
-			RegisterForSpring:    r.FormValue("registerForSpring") == "true",
-			OptOutWebsiteDisplay: r.FormValue("optOutWebsiteDisplay") == "true",
-			OptOutPhotoSharing:   r.FormValue("optOutPhotoSharing") == "true",
-			RegisteredAt:         time.Now(),
-			Season:               activeSeason,
+		// The parent has to agree to the season's waiver
+		waiver, err := currentWaiver(activeSeason.ID)
+		if err != nil {
+			log.Printf("Error getting waiver: %v", err)
+			http.Error(w, "Failed to retrieve waiver", http.StatusInternalServerError)
+			return
+		}
+		if err := consentFromForm(r, reg, waiver); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := capacity.Check(reg.Grade); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
 		// Save the registration to the database
-		err = database.SaveRegistration(reg)
+		err = database.SaveRegistration(reg, username)
 		if err != nil {
 			log.Printf("Error saving registration: %v", err)
 			http.Error(w, "Failed to save registration", http.StatusInternalServerError)
@@ -796,10 +971,11 @@ func successHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Render the success page with registration details
 	renderTemplate(w, "success", PageData{
-		Title:        "Registration Successful",
-		Registration: reg,
-		User:         username,
-		Role:         role,
+		Title:         "Registration Successful",
+		Registration:  reg,
+		Registrations: []*Registration{reg},
+		User:          username,
+		Role:          role,
 	})
 }
 
@@ -824,12 +1000,18 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Error getting active season: %v", err)
 		}
 
-		// Get statistics for each season
+		// Get statistics and remaining spots for each season
 		var seasonStats []SeasonStat
+		capacities := make(map[string]*SeasonCapacity)
 		for _, season := range seasons {
 			// Get counts for each season
 			regCount, _ := database.GetRegistrationCountForSeason(season.ID)
 			scanCount, _ := database.GetScanCountForSeason(season.ID)
+			if capacity, err := database.GetSeasonCapacity(season); err == nil {
+				capacities[season.ID] = capacity
+			} else {
+				log.Printf("Error getting capacity for season %s: %v", season.ID, err)
+			}
 
 			seasonStats = append(seasonStats, SeasonStat{
 				SeasonID:    season.ID,
@@ -839,6 +1021,16 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 
+		// Custom questions asked on each season's registration form
+		questions, err := database.GetAllSeasonQuestions()
+		if err != nil {
+			log.Printf("Error getting season questions: %v", err)
+		}
+		waivers, err := database.GetCurrentWaivers()
+		if err != nil {
+			log.Printf("Error getting waivers: %v", err)
+		}
+
 		// Construct base URL from request
 		scheme := "http"
 		if r.TLS != nil {
@@ -848,13 +1040,18 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Render the seasons page
 		renderTemplate(w, "seasons", PageData{
-			Title:        "Run Club - Manage Seasons",
-			User:         username,
-			Role:         role,
-			ActiveSeason: activeSeason,
-			Seasons:      seasons,
-			SeasonStats:  seasonStats,
-			BaseURL:      baseURL,
+			Title:            "Run Club - Manage Seasons",
+			User:             username,
+			Role:             role,
+			ActiveSeason:     activeSeason,
+			Seasons:          seasons,
+			SeasonStats:      seasonStats,
+			SeasonCapacities: capacities,
+			SeasonQuestions:  questions,
+			SeasonWaivers:    waivers,
+			BaseURL:          baseURL,
+			Message:          r.URL.Query().Get("message"),
+			Success:          r.URL.Query().Get("message") != "",
 		})
 		return
 	}
@@ -878,6 +1075,7 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 		// Determine if it should be active
 		isActive := r.FormValue("is_active") == "true"
 		springRegistrationEnabled := r.FormValue("spring_registration_enabled") == "true"
+		keepBadges := r.FormValue("keep_badges") == "true"
 		copyFromSeasonID := r.FormValue("copy_from_season")
 
 		// Parse max registrations (default to 95)
@@ -904,6 +1102,53 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 
 		}
 
+		// Parse registration close date if provided, also in the club's timezone
+		var registrationEndsAt *time.Time
+		if regEndStr := r.FormValue("registration_ends_at"); regEndStr != "" {
+			parsedTime, err := time.ParseInLocation("2006-01-02T15:04", regEndStr, clubLocation())
+			if err != nil {
+				http.Error(w, "Invalid registration close date", http.StatusBadRequest)
+				return
+			}
+			if registrationStartsAt != nil && !parsedTime.After(*registrationStartsAt) {
+				http.Error(w, "Registration must close after it opens", http.StatusBadRequest)
+				return
+			}
+			registrationEndsAt = &parsedTime
+		}
+
+		// Parse per-grade limits; a blank grade has no limit of its own
+		gradeCapacities := make(map[string]int)
+		for _, grade := range schoolGrades {
+			value := strings.TrimSpace(r.FormValue("grade_capacity_" + grade))
+			if value == "" {
+				continue
+			}
+			capacity, err := strconv.Atoi(value)
+			if err != nil || capacity < 1 {
+				http.Error(w, fmt.Sprintf("Invalid limit for %s", gradeLabel(grade)), http.StatusBadRequest)
+				return
+			}
+			gradeCapacities[grade] = capacity
+		}
+
+		// Parse the club fee; a blank fee means the season is free
+		var feeCents, siblingDiscountCents int
+		if value := strings.TrimSpace(r.FormValue("fee")); value != "" {
+			feeCents, err = parseDollars(value)
+			if err != nil || feeCents < 0 {
+				http.Error(w, "Invalid fee", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := strings.TrimSpace(r.FormValue("sibling_discount")); value != "" {
+			siblingDiscountCents, err = parseDollars(value)
+			if err != nil || siblingDiscountCents < 0 || siblingDiscountCents > feeCents {
+				http.Error(w, "The sibling discount must be between $0 and the fee", http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Create the season
 		season := &Season{
 			ID:                        uuid.New().String(),
@@ -911,12 +1156,17 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 			IsActive:                  isActive,
 			SpringRegistrationEnabled: springRegistrationEnabled,
 			RegistrationStartsAt:      registrationStartsAt,
+			RegistrationEndsAt:        registrationEndsAt,
 			MaxRegistrations:          maxRegistrations,
+			GradeCapacities:           gradeCapacities,
+			FeeCents:                  feeCents,
+			SiblingDiscountCents:      siblingDiscountCents,
+			KeepBadges:                keepBadges,
 			CreatedAt:                 time.Now(),
 		}
 
 		// Save the season
-		err = database.SaveSeason(season)
+		err = database.SaveSeason(season, username)
 		if err != nil {
 			log.Printf("Error saving season: %v", err)
 			http.Error(w, "Failed to save season", http.StatusInternalServerError)
@@ -925,7 +1175,7 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 
 		// If copying from another season, copy runners who opted for spring
 		if copyFromSeasonID != "" {
-			err = database.CopySpringRegistrations(copyFromSeasonID, season.ID)
+			err = database.CopySpringRegistrations(copyFromSeasonID, season.ID, username)
 			if err != nil {
 				log.Printf("Error copying spring registrations: %v", err)
 				// Don't fail the whole operation, just log the error
@@ -942,6 +1192,9 @@ func seasonsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func activateSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
 	// Only allow POST requests
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -963,7 +1216,7 @@ func activateSeasonHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Activate the season
-	err = database.SetActiveSeason(seasonID)
+	err = database.SetActiveSeason(seasonID, username)
 	if err != nil {
 		log.Printf("Error activating season: %v", err)
 		http.Error(w, "Failed to activate season", http.StatusInternalServerError)
@@ -1041,7 +1294,7 @@ func tracksHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Save the track
-		err = database.SaveTrack(track)
+		err = database.SaveTrack(track, username)
 		if err != nil {
 			log.Printf("Error saving track: %v", err)
 			http.Error(w, "Failed to save track", http.StatusInternalServerError)
@@ -1133,6 +1386,15 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Admins can export the season's scans by track
+	var tracks []*Track
+	if seasonID != "" && role == RoleAdmin {
+		tracks, err = database.GetTracksBySeasonID(seasonID)
+		if err != nil {
+			log.Printf("Error getting tracks: %v", err)
+		}
+	}
+
 	data := PageData{
 		Title:            "Run Club - Statistics",
 		User:             username,
@@ -1142,6 +1404,7 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 		SelectedSeasonID: seasonID,
 		Stats:            stats,
 		SelectedSeason:   selectedSeason,
+		Tracks:           tracks,
 	}
 
 	renderTemplate(w, "stats", data)
@@ -1154,10 +1417,11 @@ func apiScanHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse JSON request
+	// Parse JSON request. Source is "manual" when the scanner looked the runner up by name.
 	var request struct {
 		Code    string  `json:"code"`
 		TrackID *string `json:"trackId,omitempty"`
+		Source  string  `json:"source,omitempty"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -1180,16 +1444,36 @@ func apiScanHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if request.Source != "" && !isValidScanSource(request.Source) {
+		sendJSONResponse(w, ScanResult{
+			Success: false,
+			Message: "Invalid scan source",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	session, _ := store.Get(r, "run-club-session")
+	username, _ := session.Values["username"].(string)
+
 	// Record the scan
 	log.Printf("Recording scan for code: %s", request.Code)
-	scan, reg, err := database.RecordScan(request.Code, request.TrackID)
+	scan, reg, err := database.RecordScanAt(request.Code, request.TrackID, time.Now(), ScanOptions{
+		RecordedBy: username,
+		Source:     request.Source,
+	})
 	if err != nil {
 		// Check if it's a debounce error
-		if strings.Contains(err.Error(), "scan rejected") {
+		var tooSoon *ScanTooSoonError
+		if errors.As(err, &tooSoon) {
 			sendJSONResponse(w, ScanResult{
 				Success: false,
 				Message: err.Error(),
 			}, http.StatusOK)
+		} else if errors.Is(err, ErrRegistrationWithdrawn) {
+			sendJSONResponse(w, ScanResult{
+				Success: false,
+				Message: "Runner has withdrawn from run club",
+			}, http.StatusOK)
 		} else {
 			sendJSONResponse(w, ScanResult{
 				Success: false,
@@ -1199,8 +1483,16 @@ func apiScanHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Return success response and share it with the live feed
+	result := buildScanResult(scan, reg)
+	publishScan(result)
+	sendJSONResponse(w, result, http.StatusOK)
+}
+
+// buildScanResult creates a successful ScanResult, with lap time and pace if the runner has a previous scan
+func buildScanResult(scan *ScanRecord, reg *Registration) ScanResult {
 	// Get previous scan to calculate lap time and pace
-	previousScan, err := database.GetPreviousScan(request.Code, scan.ScannedAt)
+	previousScan, err := database.GetPreviousScan(scan.RegistrationID, scan.ScannedAt)
 	if err != nil {
 		log.Printf("Error getting previous scan: %v", err)
 		// Continue without lap time data
@@ -1212,6 +1504,10 @@ func apiScanHandler(w http.ResponseWriter, r *http.Request) {
 		Registration: reg,
 		ScanRecord:   scan,
 		PreviousScan: previousScan,
+		Milestones:   scan.Milestones,
+	}
+	if len(scan.Milestones) > 0 {
+		result.Message += fmt.Sprintf(" - milestone reached: %s!", milestoneNames(scan.Milestones))
 	}
 
 	// Calculate lap time and pace if we have a previous scan
@@ -1226,8 +1522,143 @@ func apiScanHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Return success response
-	sendJSONResponse(w, result, http.StatusOK)
+	return result
+}
+
+// Batch scan result statuses
+const (
+	BatchScanRecorded        = "recorded"
+	BatchScanDuplicate       = "duplicate"
+	BatchScanRejectedTooFast = "rejected_too_fast"
+	BatchScanUnknownRunner   = "unknown_runner"
+	BatchScanInvalid         = "invalid"
+)
+
+// maxBatchScans limits how many queued scans a single batch request can sync
+const maxBatchScans = 500
+
+// maxScanClockSkew is how far in the future a client timestamp may be before it is rejected
+const maxScanClockSkew = 5 * time.Minute
+
+// BatchScanItem is a single scan queued on a scanner device
+type BatchScanItem struct {
+	IdempotencyKey string    `json:"idempotencyKey"`
+	Code           string    `json:"code"`
+	TrackID        *string   `json:"trackId,omitempty"`
+	ScannedAt      time.Time `json:"scannedAt"`
+	Source         string    `json:"source,omitempty"`
+}
+
+// BatchScanItemResult is the outcome of recording one queued scan
+type BatchScanItemResult struct {
+	IdempotencyKey string `json:"idempotencyKey"`
+	Status         string `json:"status"`
+	ScanResult
+}
+
+// apiScansBatchHandler records scans that were queued offline, using each scan's
+// client timestamp for debounce and lap calculations
+func apiScansBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Scans []BatchScanItem `json:"scans"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Printf("Error decoding batch scan request: %v", err)
+		sendJSONResponse(w, map[string]string{"error": "Invalid request format"}, http.StatusBadRequest)
+		return
+	}
+	if len(request.Scans) > maxBatchScans {
+		sendJSONResponse(w, map[string]string{"error": fmt.Sprintf("Too many scans in one batch (max %d)", maxBatchScans)}, http.StatusBadRequest)
+		return
+	}
+
+	// Record oldest scans first so lap times are computed against earlier laps,
+	// but return results in the order the client sent them
+	order := make([]int, len(request.Scans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return request.Scans[order[i]].ScannedAt.Before(request.Scans[order[j]].ScannedAt)
+	})
+
+	session, _ := store.Get(r, "run-club-session")
+	username, _ := session.Values["username"].(string)
+
+	results := make([]BatchScanItemResult, len(request.Scans))
+	for _, i := range order {
+		results[i] = recordBatchScan(request.Scans[i], username)
+	}
+
+	sendJSONResponse(w, map[string]interface{}{"results": results}, http.StatusOK)
+}
+
+// recordBatchScan validates and records a single queued scan
+func recordBatchScan(item BatchScanItem, recordedBy string) BatchScanItemResult {
+	result := BatchScanItemResult{IdempotencyKey: item.IdempotencyKey}
+
+	invalid := func(message string) BatchScanItemResult {
+		result.Status = BatchScanInvalid
+		result.Message = message
+		return result
+	}
+
+	if item.IdempotencyKey == "" {
+		return invalid("Missing idempotency key")
+	}
+	if item.ScannedAt.IsZero() {
+		return invalid("Missing scan time")
+	}
+	if item.ScannedAt.After(time.Now().Add(maxScanClockSkew)) {
+		return invalid("Scan time is in the future")
+	}
+	if _, err := uuid.Parse(item.Code); err != nil {
+		return invalid("Invalid QR code format")
+	}
+	if item.Source != "" && !isValidScanSource(item.Source) {
+		return invalid("Invalid scan source")
+	}
+
+	// Scanners send UTC times, but scan times are stored and compared as
+	// text, so record them in the zone time.Now() gives live scans
+	scan, reg, err := database.RecordScanAt(item.Code, item.TrackID, item.ScannedAt.In(time.Local), ScanOptions{
+		ClientScanID: item.IdempotencyKey,
+		RecordedBy:   recordedBy,
+		Source:       item.Source,
+	})
+	var tooSoon *ScanTooSoonError
+	switch {
+	case err == nil:
+		result.ScanResult = buildScanResult(scan, reg)
+		result.Status = BatchScanRecorded
+		publishScan(result.ScanResult)
+	case errors.Is(err, ErrDuplicateScan):
+		result.Status = BatchScanDuplicate
+		result.Success = true
+		result.Message = "Scan was already recorded"
+	case errors.As(err, &tooSoon):
+		result.Status = BatchScanRejectedTooFast
+		result.Message = err.Error()
+	case errors.Is(err, ErrRegistrationNotFound):
+		result.Status = BatchScanUnknownRunner
+		result.Message = "Runner not found"
+	case errors.Is(err, ErrRegistrationWithdrawn):
+		result.Status = BatchScanUnknownRunner
+		result.Message = "Runner has withdrawn from run club"
+	default:
+		log.Printf("Error recording batch scan %s: %v", item.IdempotencyKey, err)
+		result.Status = BatchScanInvalid
+		result.Message = "Failed to record scan"
+	}
+
+	return result
 }
 
 func apiScansHandler(w http.ResponseWriter, r *http.Request) {
@@ -1269,228 +1700,184 @@ func apiScansHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func sendJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// maxRunnerSearchResults limits the matches returned for a manual check-in search
+const maxRunnerSearchResults = 10
+
+// RunnerSearchResult is a runner match for manual check-in. It leaves out
+// parent contact details since scanner volunteers don't need them.
+type RunnerSearchResult struct {
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Grade     string `json:"grade"`
+	Teacher   string `json:"teacher"`
+}
 
-	err := json.NewEncoder(w).Encode(data)
-	if err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+// apiRunnerSearchHandler finds runners in the active season by name so a
+// scanner can check in a runner who doesn't have their badge
+func apiRunnerSearchHandler(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-}
 
-func csvUploadHandler(w http.ResponseWriter, r *http.Request) {
-	session, _ := store.Get(r, "run-club-session")
-	username := session.Values["username"].(string)
-	role := session.Values["role"].(string)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) < 2 {
+		sendJSONResponse(w, []RunnerSearchResult{}, http.StatusOK)
+		return
+	}
 
-	// Get active season
-	activeSeason, hasActiveSeason, err := database.GetActiveSeason()
+	activeSeason, exists, err := database.GetActiveSeason()
 	if err != nil {
 		log.Printf("Error getting active season: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
-
-	// Create base page data
-	data := PageData{
-		Title: "Run Club - Bulk Register",
-		User:  username,
-		Role:  role,
+	if !exists {
+		sendJSONResponse(w, map[string]string{"error": "No active season"}, http.StatusNotFound)
+		return
 	}
 
-	// Add active season data if available
-	if hasActiveSeason {
-		data.ActiveSeason = activeSeason
+	regs, _, err := database.GetFilteredRegistrations(activeSeason.ID, query, nil, 1, maxRunnerSearchResults)
+	if err != nil {
+		log.Printf("Error searching registrations: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	// For GET requests, just show the form
-	if r.Method == http.MethodGet {
-		renderTemplate(w, "csv_upload", data)
-		return
+	results := make([]RunnerSearchResult, 0, len(regs))
+	for _, reg := range regs {
+		results = append(results, RunnerSearchResult{
+			ID:        reg.ID,
+			FirstName: reg.FirstName,
+			LastName:  reg.LastName,
+			Grade:     reg.Grade,
+			Teacher:   reg.Teacher,
+		})
 	}
 
-	// For POST requests, process the CSV file
-	if r.Method == http.MethodPost {
-		// Check if there's an active season
-		if !hasActiveSeason {
-			data.Success = false
-			data.Message = "Cannot register runners without an active season"
-			renderTemplate(w, "csv_upload", data)
-			return
-		}
+	sendJSONResponse(w, results, http.StatusOK)
+}
 
-		// Parse the multipart form to get the file
-		err := r.ParseMultipartForm(10 << 20) // Max 10MB
-		if err != nil {
-			data.Success = false
-			data.Message = "Error parsing form"
-			renderTemplate(w, "csv_upload", data)
-			return
-		}
+// scanUndoWindow is how long a scanner volunteer can undo a scan they just recorded
+const scanUndoWindow = 2 * time.Minute
 
-		// Get the file from the form
-		file, header, err := r.FormFile("csv-file")
-		if err != nil {
-			data.Success = false
-			data.Message = "Error retrieving file from form"
-			renderTemplate(w, "csv_upload", data)
-			return
-		}
-		defer file.Close()
+// scanUpdateHandler voids, reassigns or changes the track of a scan from the runner detail page
+func scanUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		// Check if the file is a CSV
-		if !strings.HasSuffix(header.Filename, ".csv") {
-			data.Success = false
-			data.Message = "Uploaded file is not a CSV"
-			renderTemplate(w, "csv_upload", data)
-			return
-		}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
 
-		// Process the CSV file and register runners
-		successCount, errorCount, errors := processCsvFile(file, activeSeason)
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
 
-		// Prepare data for the template
-		data.Success = errorCount == 0
-		data.SuccessCount = successCount
-		data.ErrorCount = errorCount
-		data.Errors = errors
+	scan, exists, err := database.GetScan(r.FormValue("id"))
+	if err != nil {
+		log.Printf("Error getting scan: %v", err)
+		http.Error(w, "Failed to retrieve scan", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Scan not found", http.StatusNotFound)
+		return
+	}
 
-		if data.Success {
-			data.Message = "Successfully registered all runners!"
+	switch r.FormValue("action") {
+	case "void":
+		reason := strings.TrimSpace(r.FormValue("reason"))
+		if reason == "" {
+			reason = "Voided by admin"
+		}
+		err = database.VoidScan(scan.ID, username, reason)
+	case "reassign":
+		err = database.ReassignScan(scan.ID, r.FormValue("registration_id"), username)
+	case "change_track":
+		err = database.UpdateScanTrack(scan.ID, r.FormValue("track_id"), username)
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating scan %s: %v", scan.ID, err)
+		if errors.Is(err, ErrScanVoided) || errors.Is(err, ErrWrongSeason) ||
+			errors.Is(err, ErrRegistrationNotFound) || errors.Is(err, ErrTrackNotFound) {
+			http.Error(w, "Cannot update scan: "+err.Error(), http.StatusBadRequest)
 		} else {
-			if successCount > 0 {
-				data.Message = "Partially successful registration. See errors below."
-			} else {
-				data.Message = "Failed to register runners. See errors below."
-			}
+			http.Error(w, "Failed to update scan", http.StatusInternalServerError)
 		}
-
-		renderTemplate(w, "csv_upload", data)
 		return
 	}
 
-	// Method not allowed for other HTTP methods
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	http.Redirect(w, r, "/runner/"+scan.RegistrationID, http.StatusSeeOther)
 }
 
-// processCsvFile processes a CSV file and registers the runners
-func processCsvFile(file io.Reader, season *Season) (successCount, errorCount int, errors []string) {
-	// Create a new CSV reader
-	reader := csv.NewReader(file)
+// apiScanUndoHandler lets a scanner void a scan they recorded within the last few minutes
+func apiScanUndoHandler(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		ScanID string `json:"scanId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ScanID == "" {
+		sendJSONResponse(w, map[string]string{"error": "Invalid request format"}, http.StatusBadRequest)
+		return
+	}
+
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
 
-	// Read header row first to validate
-	header, err := reader.Read()
+	scan, exists, err := database.GetScan(request.ScanID)
 	if err != nil {
-		return 0, 1, []string{"Error reading CSV header: " + err.Error()}
+		log.Printf("Error getting scan: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Scanners may only undo their own scans, so don't reveal whether another user's scan exists
+	if !exists || scan.RecordedBy != username {
+		sendJSONResponse(w, map[string]string{"error": "Scan not found"}, http.StatusNotFound)
+		return
+	}
+	if time.Since(scan.ScannedAt) > scanUndoWindow {
+		sendJSONResponse(w, map[string]string{"error": "This scan is too old to undo. Ask an admin to correct it."}, http.StatusConflict)
+		return
 	}
 
-	// Check for expected headers
-	expectedHeaders := []string{"FirstName", "LastName", "Grade", "Teacher", "Gender", "ParentContactNumber", "BackupContactNumber", "ParentEmail", "DismissalMethod", "Allergies", "MedicalInfo"}
-	if !reflect.DeepEqual(header, expectedHeaders) {
-		return 0, 1, []string{"CSV headers do not match expected format. Please use the template provided."}
+	err = database.VoidScan(scan.ID, username, "Undone by scanner")
+	if errors.Is(err, ErrScanVoided) {
+		sendJSONResponse(w, map[string]string{"error": "This scan was already undone"}, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error undoing scan: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	// Process rows
-	lineNum := 1 // Start at line 1 (header row)
-	for {
-		lineNum++
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Error reading line %d: %v", lineNum, err))
-			errorCount++
-			continue
-		}
-
-		// Skip empty rows
-		if len(row) == 0 || (len(row) == 1 && row[0] == "") {
-			continue
-		}
-
-		// Validate row length
-		if len(row) != len(expectedHeaders) {
-			errors = append(errors, fmt.Sprintf("Line %d: Wrong number of columns", lineNum))
-			errorCount++
-			continue
-		}
-
-		// Extract values
-		firstName := strings.TrimSpace(row[0])
-		lastName := strings.TrimSpace(row[1])
-		grade := strings.TrimSpace(row[2])
-		teacher := strings.TrimSpace(row[3])
-		gender := strings.TrimSpace(row[4])
-		parentContactNumber := strings.TrimSpace(row[5])
-		backupContactNumber := strings.TrimSpace(row[6])
-		parentEmail := strings.TrimSpace(row[7])
-		dismissalMethod := strings.TrimSpace(row[8])
-		allergies := strings.TrimSpace(row[9])
-		medicalInfo := strings.TrimSpace(row[10])
-
-		// Validate required fields
-		if firstName == "" || lastName == "" || grade == "" || teacher == "" || gender == "" || parentContactNumber == "" || parentEmail == "" || dismissalMethod == "" {
-			errors = append(errors, fmt.Sprintf("Line %d: Missing required field(s)", lineNum))
-			errorCount++
-			continue
-		}
-
-		// Validate grade
-		validGrades := map[string]bool{"K": true, "1": true, "2": true, "3": true, "4": true, "5": true}
-		if !validGrades[grade] {
-			errors = append(errors, fmt.Sprintf("Line %d: Invalid grade '%s'", lineNum, grade))
-			errorCount++
-			continue
-		}
-
-		// Validate gender
-		validGenders := map[string]bool{"Male": true, "Female": true, "Other": true, "Prefer not to say": true}
-		if !validGenders[gender] {
-			errors = append(errors, fmt.Sprintf("Line %d: Invalid gender '%s'", lineNum, gender))
-			errorCount++
-			continue
-		}
-
-		// Validate dismissal method
-		validDismissalMethods := map[string]bool{"Walking Unescorted": true, "Walking Escorted": true, "Car Pickup": true, "Clayton Crew": true}
-		if !validDismissalMethods[dismissalMethod] {
-			errors = append(errors, fmt.Sprintf("Line %d: Invalid dismissal method '%s'", lineNum, dismissalMethod))
-			errorCount++
-			continue
-		}
-
-		// Create registration
-		reg := &Registration{
-			ID:                  uuid.New().String(),
-			SeasonID:            &season.ID,
-			FirstName:           firstName,
-			LastName:            lastName,
-			Grade:               grade,
-			Teacher:             teacher,
-			Gender:              gender,
-			ParentContactNumber: parentContactNumber,
-			BackupContactNumber: backupContactNumber,
-			ParentEmail:         parentEmail,
-			DismissalMethod:     dismissalMethod,
-			Allergies:           allergies,
-			MedicalInfo:         medicalInfo,
-			RegisteredAt:        time.Now(),
-			Season:              season,
-		}
-
-		// Save to database
-		err = database.SaveRegistration(reg)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Line %d: Error saving to database: %v", lineNum, err))
-			errorCount++
-			continue
-		}
+	sendJSONResponse(w, map[string]interface{}{"success": true, "scanId": scan.ID}, http.StatusOK)
+}
 
-		successCount++
-	}
+func sendJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 
-	return successCount, errorCount, errors
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
 }
 
 func runnersHandler(w http.ResponseWriter, r *http.Request) {
@@ -1527,8 +1914,26 @@ func runnersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	perPage := 20 // Number of runners per page
 
+	// A season's custom questions can narrow the list to the runners who gave an answer
+	var questions []*SeasonQuestion
+	var answerFilter *AnswerFilter
+	if seasonID != "" {
+		questions, err = database.GetSeasonQuestions(seasonID)
+		if err != nil {
+			log.Printf("Error getting season questions: %v", err)
+		}
+		// Ignore a filter left over from another season's questions
+		if filter := parseAnswerFilter(r.URL.Query().Get("answer")); filter != nil {
+			for _, q := range questions {
+				if q.ID == filter.QuestionID {
+					answerFilter = filter
+				}
+			}
+		}
+	}
+
 	// Get filtered registrations with pagination
-	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, page, perPage)
+	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, answerFilter, page, perPage)
 	if err != nil {
 		log.Printf("Error getting registrations: %v", err)
 		http.Error(w, "Failed to retrieve registrations", http.StatusInternalServerError)
@@ -1553,6 +1958,18 @@ func runnersHandler(w http.ResponseWriter, r *http.Request) {
 		CurrentPage:      page,
 		TotalPages:       totalPages,
 		TotalRunners:     totalCount,
+		Questions:        questions,
+	}
+	if answerFilter != nil {
+		data.AnswerFilter = answerFilter.String()
+	}
+
+	// Withdrawn runners are listed separately so they can be found and reinstated
+	if seasonID != "" {
+		data.WithdrawnRunners, err = database.GetWithdrawnRegistrations(seasonID)
+		if err != nil {
+			log.Printf("Error getting withdrawn registrations: %v", err)
+		}
 	}
 
 	renderTemplate(w, "runners", data)
@@ -1571,6 +1988,14 @@ func runnersExportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Work out fees before filtering so siblings are still found
+	balances, err := loadBalances(registrations, seasonID)
+	if err != nil {
+		log.Printf("Error getting balances: %v", err)
+		http.Error(w, "Failed to retrieve payments", http.StatusInternalServerError)
+		return
+	}
+
 	// Filter by search query if provided
 	if searchQuery != "" {
 		searchTerm := strings.ToLower(searchQuery)
@@ -1587,6 +2012,31 @@ func runnersExportHandler(w http.ResponseWriter, r *http.Request) {
 		registrations = filtered
 	}
 
+	// Each custom question gets a column
+	questions, questionHeaders, err := exportQuestions(seasonID)
+	if err != nil {
+		log.Printf("Error getting season questions: %v", err)
+		http.Error(w, "Failed to retrieve season questions", http.StatusInternalServerError)
+		return
+	}
+	answers, err := database.GetSeasonAnswers(seasonID)
+	if err != nil {
+		log.Printf("Error getting answers: %v", err)
+		http.Error(w, "Failed to retrieve answers", http.StatusInternalServerError)
+		return
+	}
+
+	// Filter by answer if provided
+	if answerFilter := parseAnswerFilter(r.URL.Query().Get("answer")); answerFilter != nil && seasonID != "" {
+		var filtered []*Registration
+		for _, reg := range registrations {
+			if answerFilter.Matches(answers[reg.ID]) {
+				filtered = append(filtered, reg)
+			}
+		}
+		registrations = filtered
+	}
+
 	// Set headers for CSV download
 	w.Header().Set("Content-Type", "text/csv")
 	w.Header().Set("Content-Disposition", "attachment; filename=runners.csv")
@@ -1599,7 +2049,9 @@ func runnersExportHandler(w http.ResponseWriter, r *http.Request) {
 	header := []string{
 		"ID", "First Name", "Last Name", "Grade", "Teacher", "Gender",
 		"Parent Contact", "Backup Contact", "Parent Email", "Season", "Registered On",
+		"Fee Status", "Fee", "Paid", "Balance",
 	}
+	header = append(header, questionHeaders...)
 	if err := csvWriter.Write(header); err != nil {
 		log.Printf("Error writing CSV header: %v", err)
 		http.Error(w, "Failed to generate CSV", http.StatusInternalServerError)
@@ -1626,6 +2078,18 @@ func runnersExportHandler(w http.ResponseWriter, r *http.Request) {
 			seasonName,
 			reg.RegisteredAt.Format("2006-01-02"),
 		}
+		if b := balances[reg.ID]; b != nil {
+			status := reg.FeeStatus
+			if status == "" && b.SiblingDiscount > 0 {
+				status = "sibling discount"
+			}
+			row = append(row, status, formatDollars(b.Fee), formatDollars(b.Paid), formatDollars(b.Outstanding()))
+		} else {
+			row = append(row, reg.FeeStatus, "", "", "")
+		}
+		for _, q := range questions {
+			row = append(row, strings.Join(answers[reg.ID][q.ID], answerSeparator))
+		}
 
 		if err := csvWriter.Write(row); err != nil {
 			log.Printf("Error writing CSV row: %v", err)
@@ -1667,12 +2131,115 @@ func runnerDetailHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error getting active season: %v", err)
 	}
 
+	// Get scan history, including voided scans, plus the choices for correcting a scan
+	scans, err := database.GetScanHistory(runnerID)
+	if err != nil {
+		log.Printf("Error getting scan history: %v", err)
+		http.Error(w, "Failed to retrieve scan history", http.StatusInternalServerError)
+		return
+	}
+	var tracks []*Track
+	var seasonRunners []*Registration
+	var attendance *RunnerAttendance
+	var questions []*SeasonQuestion
+	var waiver *SeasonWaiver
+	if runner.SeasonID != nil {
+		tracks, err = database.GetTracksBySeasonID(*runner.SeasonID)
+		if err != nil {
+			log.Printf("Error getting tracks: %v", err)
+		}
+		questions, err = database.GetSeasonQuestions(*runner.SeasonID)
+		if err != nil {
+			log.Printf("Error getting season questions: %v", err)
+		}
+		runner.Answers, err = database.GetRegistrationAnswers(runnerID)
+		if err != nil {
+			log.Printf("Error getting answers: %v", err)
+		}
+		waiver, err = currentWaiver(*runner.SeasonID)
+		if err != nil {
+			log.Printf("Error getting waiver: %v", err)
+		}
+		seasonRunners, err = database.GetAllRegistrations(*runner.SeasonID)
+		if err != nil {
+			log.Printf("Error getting registrations: %v", err)
+		}
+		sort.Slice(seasonRunners, func(i, j int) bool {
+			if seasonRunners[i].LastName != seasonRunners[j].LastName {
+				return seasonRunners[i].LastName < seasonRunners[j].LastName
+			}
+			return seasonRunners[i].FirstName < seasonRunners[j].FirstName
+		})
+
+		practices, err := database.GetPracticesBySeasonID(*runner.SeasonID)
+		if err != nil {
+			log.Printf("Error getting practices: %v", err)
+		}
+		attended, err := database.GetAttendedPracticeIDs(runnerID)
+		if err != nil {
+			log.Printf("Error getting attended practices: %v", err)
+		}
+		attendance = calculateAttendance(practices, attended, runner.RegisteredAt, time.Now())
+	}
+
+	// Totals across every season this child has registered for
+	var lifetime *RunnerLifetime
+	if runner.RunnerID != "" {
+		history, err := database.GetRunnerHistory(runner.RunnerID)
+		if err != nil {
+			log.Printf("Error getting runner history: %v", err)
+		} else {
+			lifetime = newRunnerLifetime(history)
+		}
+	}
+
+	// Who changed this runner's registration and scans, and when
+	auditEntries, err := database.GetRegistrationAuditLog(runnerID)
+	if err != nil {
+		log.Printf("Error getting audit log: %v", err)
+	}
+
+	// A name or grade change the parent is waiting on
+	changeRequest, _, err := database.GetPendingChangeRequest(runnerID)
+	if err != nil {
+		log.Printf("Error getting change request: %v", err)
+	}
+
+	// Brothers and sisters registered this season
+	var siblings []*Registration
+	for _, reg := range seasonRunners {
+		if runner.HouseholdID != "" && reg.HouseholdID == runner.HouseholdID && reg.ID != runner.ID {
+			siblings = append(siblings, reg)
+		}
+	}
+
+	// What the runner owes for the season and what's been paid
+	balance, err := runnerBalance(runner, seasonRunners)
+	if err != nil {
+		log.Printf("Error getting balance: %v", err)
+	}
+
 	data := PageData{
-		Title:         fmt.Sprintf("Run Club - %s %s", runner.FirstName, runner.LastName),
-		User:          username,
-		Role:          role,
-		ActiveSeason:  activeSeason,
-		Registration:  runner,
+		Title:          fmt.Sprintf("Run Club - %s %s", runner.FirstName, runner.LastName),
+		User:           username,
+		Role:           role,
+		ActiveSeason:   activeSeason,
+		Registration:   runner,
+		Scans:          scans,
+		Tracks:         tracks,
+		Registrations:  seasonRunners,
+		Attendance:     attendance,
+		Lifetime:       lifetime,
+		AuditEntries:   auditEntries,
+		Questions:      questions,
+		EditURL:        registrationEditURL(requestBaseURL(r), runner),
+		ChangeRequest:  changeRequest,
+		Waiver:         waiver,
+		Balance:        balance,
+		PaymentMethods: paymentMethods,
+		Siblings:       siblings,
+		Message:        r.URL.Query().Get("message"),
+		Success:        r.URL.Query().Get("message") != "",
 	}
 
 	renderTemplate(w, "runner_detail", data)
@@ -1720,7 +2287,7 @@ func badgesHandler(w http.ResponseWriter, r *http.Request) {
 	runnersPerPage := 8
 
 	// Get filtered registrations with pagination
-	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, page, runnersPerPage)
+	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, nil, page, runnersPerPage)
 	if err != nil {
 		log.Printf("Error getting registrations: %v", err)
 		http.Error(w, "Failed to retrieve registrations", http.StatusInternalServerError)
@@ -1794,7 +2361,7 @@ func badges2x4Handler(w http.ResponseWriter, r *http.Request) {
 	runnersPerPage := 10
 
 	// Get filtered registrations with pagination
-	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, page, runnersPerPage)
+	registrations, totalCount, err := database.GetFilteredRegistrations(seasonID, searchQuery, nil, page, runnersPerPage)
 	if err != nil {
 		log.Printf("Error getting registrations: %v", err)
 		http.Error(w, "Failed to retrieve registrations", http.StatusInternalServerError)
@@ -1848,25 +2415,15 @@ func publicRegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	// For GET requests, show the form
 	if r.Method == http.MethodGet {
-		session, _ := store.Get(r, "run-club-public-session")
+		session, _ := publicStore.Get(r, "run-club-public-session")
 
 		data := PageData{
-			Title:            fmt.Sprintf("Run Club - Register for %s", season.Name),
-			ActiveSeason:     season,
-			RegistrationOpen: season.IsRegistrationOpen(),
+			Title: fmt.Sprintf("Run Club - Register for %s", season.Name),
 			// For public registration, we don't have a logged-in user
 			User: "",
 			Role: "",
 		}
-		
-		// Check registration capacity
-		count, err := database.GetRegistrationCountForSeason(season.ID)
-		if err != nil {
-			log.Printf("Error getting registration count: %v", err)
-		} else {
-			data.RegistrationCount = count
-			data.RegistrationFull = season.IsRegistrationFull(count)
-		}
+		setRegistrationForm(&data, season)
 
 		// Check if we have prefill data in session
 		prefillData := make(map[string]string)
@@ -1900,22 +2457,17 @@ func publicRegisterHandler(w http.ResponseWriter, r *http.Request) {
 	// For POST requests, process the form submission
 	if r.Method == http.MethodPost {
 		// Check if registration is open
-		if !season.IsRegistrationOpen() {
-			http.Error(w, "Registration is not yet open for this season", http.StatusBadRequest)
+		if err := checkRegistrationWindow(season); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Check if registration is full
-		count, err := database.GetRegistrationCountForSeason(season.ID)
+		capacity, err := database.GetSeasonCapacity(season)
 		if err != nil {
 			log.Printf("Error getting registration count: %v", err)
 			http.Error(w, "Failed to check registration capacity", http.StatusInternalServerError)
 			return
 		}
-		if season.IsRegistrationFull(count) {
-			http.Error(w, "Registration is full for this season", http.StatusBadRequest)
-			return
-		}
 
 		// Parse form data
 		err = r.ParseForm()
@@ -1924,64 +2476,115 @@ func publicRegisterHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Validate phone numbers
-		parentPhone := r.FormValue("parentContactNumber")
-		if !validatePhoneNumber(parentPhone) {
-			http.Error(w, "Invalid parent/guardian contact number. Please use format: 123-456-7890", http.StatusBadRequest)
+		// Read the season's custom questions and the waiver the parent has to agree to
+		questions, err := database.GetSeasonQuestions(season.ID)
+		if err != nil {
+			log.Printf("Error getting season questions: %v", err)
+			http.Error(w, "Failed to retrieve season questions", http.StatusInternalServerError)
+			return
+		}
+		waiver, err := currentWaiver(season.ID)
+		if err != nil {
+			log.Printf("Error getting waiver: %v", err)
+			http.Error(w, "Failed to retrieve waiver", http.StatusInternalServerError)
 			return
 		}
 
-		backupPhone := r.FormValue("backupContactNumber")
-		if backupPhone != "" && !validatePhoneNumber(backupPhone) {
-			http.Error(w, "Invalid backup contact number. Please use format: 123-456-7890", http.StatusBadRequest)
+		// A parent can register several children at once; read and validate each of them
+		regs, err := siblingRegistrations(r, season, questions, waiver)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Create a new registration
-		reg := &Registration{
-			ID:                  uuid.New().String(),
-			SeasonID:            &season.ID,
-			FirstName:           r.FormValue("firstName"),
-			LastName:            r.FormValue("lastName"),
-			Grade:               r.FormValue("grade"),
-			Teacher:             r.FormValue("teacher"),
-			Gender:              r.FormValue("gender"),
-			TshirtSize:          r.FormValue("tshirtSize"),
-			ParentFirstName:     r.FormValue("parentFirstName"),
-			ParentLastName:      r.FormValue("parentLastName"),
-			ParentContactNumber: r.FormValue("parentContactNumber"),
-			BackupContactNumber:  r.FormValue("backupContactNumber"),
-			ParentEmail:          r.FormValue("parentEmail"),
-			DismissalMethod:      r.FormValue("dismissalMethod"),
-			Allergies:            r.FormValue("allergies"),
-			MedicalInfo:          r.FormValue("medicalInfo"),
-			RegisterForSpring:    r.FormValue("registerForSpring") == "true",
-			OptOutWebsiteDisplay: r.FormValue("optOutWebsiteDisplay") == "true",
-			OptOutPhotoSharing:   r.FormValue("optOutPhotoSharing") == "true",
-			RegisteredAt:         time.Now(),
-			Season:               season,
+		// Don't register the same child twice when a parent submits the form again
+		for _, reg := range regs {
+			if err := checkPublicDuplicate(reg, season); err != nil {
+				if _, ok := err.(errRegistrationInput); ok {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				log.Printf("Error checking for duplicate registrations: %v", err)
+				http.Error(w, "Failed to check existing registrations", http.StatusInternalServerError)
+				return
+			}
 		}
 
-		// Save the registration to the database
-		err = database.SaveRegistration(reg)
-		if err != nil {
-			log.Printf("Error saving registration: %v", err)
-			http.Error(w, "Failed to save registration", http.StatusInternalServerError)
-			return
+		// Once the season or a child's grade is full, children go on the waitlist instead
+		var registered, waitlisted []*Registration
+		for _, reg := range regs {
+			if capacity.Check(reg.Grade) != nil {
+				waitlisted = append(waitlisted, reg)
+				continue
+			}
+			capacity.Add(reg.Grade)
+			registered = append(registered, reg)
 		}
 
-		// Store parent data in session for next registration
-		session, _ := store.Get(r, "run-club-public-session")
-		session.Values["prefill_parentFirstName"] = reg.ParentFirstName
-		session.Values["prefill_parentLastName"] = reg.ParentLastName
-		session.Values["prefill_parentContactNumber"] = reg.ParentContactNumber
-		session.Values["prefill_backupContactNumber"] = reg.BackupContactNumber
-		session.Values["prefill_parentEmail"] = reg.ParentEmail
-		session.Values["prefill_dismissalMethod"] = reg.DismissalMethod
-		session.Save(r, w)
+		// Save the registrations to the database. Siblings share a household.
+		if len(registered) > 0 {
+			if err := database.SaveRegistrations(registered, auditActorPublic); err != nil {
+				log.Printf("Error saving registration: %v", err)
+				http.Error(w, "Failed to save registration", http.StatusInternalServerError)
+				return
+			}
+		}
 
-		// Redirect to public success page with token
-		http.Redirect(w, r, fmt.Sprintf("/public/success?id=%s&token=%s", reg.ID, token), http.StatusSeeOther)
+		// Waitlisted siblings join the household when they're promoted
+		householdID := ""
+		if len(registered) > 0 {
+			householdID = registered[0].HouseholdID
+		} else if len(waitlisted) > 1 {
+			householdID = uuid.New().String()
+		}
+		var entries []*WaitlistEntry
+		for _, reg := range waitlisted {
+			reg.HouseholdID = householdID
+			entry := &WaitlistEntry{
+				ID:           uuid.New().String(),
+				SeasonID:     season.ID,
+				Registration: reg,
+				CreatedAt:    reg.RegisteredAt,
+			}
+			if err := database.AddToWaitlist(entry); err != nil {
+				log.Printf("Error adding to waitlist: %v", err)
+				http.Error(w, "Failed to join the waitlist", http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, entry)
+		}
+
+		// Send the parent the link for updating each registration later
+		for _, reg := range registered {
+			if err := notifyRegistrationEditLink(reg, season, registrationEditURL(requestBaseURL(r), reg)); err != nil && !errors.Is(err, ErrNoRecipient) {
+				log.Printf("Error sending edit link for registration %s: %v", reg.ID, err)
+			}
+		}
+
+		// Let the success page show the edit links once; after that
+		// they're only in the parent's email
+		savePublicEditLinks(r, registered)
+
+		// Store parent data in session for next registration
+		savePublicPrefill(w, r, regs[0])
+
+		// Redirect to the public success page with token, or to the waitlist
+		// page if none of the children got a spot
+		query := url.Values{"token": {token}}
+		if len(registered) == 0 {
+			for _, entry := range entries {
+				query.Add("id", entry.ID)
+			}
+			http.Redirect(w, r, "/public/waitlisted?"+query.Encode(), http.StatusSeeOther)
+			return
+		}
+		for _, reg := range registered {
+			query.Add("id", reg.ID)
+		}
+		for _, entry := range entries {
+			query.Add("waitlisted", entry.ID)
+		}
+		http.Redirect(w, r, "/public/success?"+query.Encode(), http.StatusSeeOther)
 		return
 	}
 
@@ -1989,6 +2592,86 @@ func publicRegisterHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
+// setRegistrationForm fills in whether a season's registration form is
+// open, how many spots are left, overall and by grade, and the season's
+// custom questions and waiver
+func setRegistrationForm(data *PageData, season *Season) {
+	data.ActiveSeason = season
+	data.RegistrationOpen = season.IsRegistrationOpen()
+	data.RegistrationClosed = season.IsRegistrationClosed()
+
+	questions, err := database.GetSeasonQuestions(season.ID)
+	if err != nil {
+		log.Printf("Error getting season questions: %v", err)
+	}
+	data.Questions = questions
+
+	data.Waiver, err = currentWaiver(season.ID)
+	if err != nil {
+		log.Printf("Error getting waiver: %v", err)
+	}
+
+	capacity, err := database.GetSeasonCapacity(season)
+	if err != nil {
+		log.Printf("Error getting registration count: %v", err)
+		return
+	}
+	data.RegistrationCount = capacity.Total
+	data.RegistrationFull = capacity.IsFull()
+	data.GradeSpots = capacity.GradeSpots()
+}
+
+// savePublicPrefill remembers the parent's details so the public form is
+// prefilled when they register a sibling
+func savePublicPrefill(w http.ResponseWriter, r *http.Request, reg *Registration) {
+	session, _ := publicStore.Get(r, "run-club-public-session")
+	session.Values["prefill_parentFirstName"] = reg.ParentFirstName
+	session.Values["prefill_parentLastName"] = reg.ParentLastName
+	session.Values["prefill_parentContactNumber"] = reg.ParentContactNumber
+	session.Values["prefill_backupContactNumber"] = reg.BackupContactNumber
+	session.Values["prefill_parentEmail"] = reg.ParentEmail
+	session.Values["prefill_dismissalMethod"] = reg.DismissalMethod
+	session.Save(r, w)
+}
+
+// publicEditLinkFlash is the public session flash holding the registrations
+// whose edit links the success page may show
+const publicEditLinkFlash = "edit_link"
+
+// savePublicEditLinks lets the next success page show the edit links for
+// regs. The session is saved with the parent's prefill details.
+func savePublicEditLinks(r *http.Request, regs []*Registration) {
+	session, _ := publicStore.Get(r, "run-club-public-session")
+	for _, reg := range regs {
+		session.AddFlash(reg.ID, publicEditLinkFlash)
+	}
+}
+
+// publicEditURLs returns the edit links for the registrations just made on
+// the public form, by ID, and forgets them. Anyone with the season's token
+// and a child's badge code can open the success page, so it can't show an
+// edit link for every registration it's given.
+func publicEditURLs(w http.ResponseWriter, r *http.Request, regs []*Registration) map[string]string {
+	session, _ := publicStore.Get(r, "run-club-public-session")
+	flashes := session.Flashes(publicEditLinkFlash)
+	if len(flashes) == 0 {
+		return nil
+	}
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving public session: %v", err)
+	}
+
+	editURLs := make(map[string]string)
+	for _, reg := range regs {
+		for _, flash := range flashes {
+			if id, ok := flash.(string); ok && id == reg.ID {
+				editURLs[reg.ID] = registrationEditURL(requestBaseURL(r), reg)
+			}
+		}
+	}
+	return editURLs
+}
+
 // infoHandler shows public information page about Run Club
 func infoHandler(w http.ResponseWriter, r *http.Request) {
 	// Get active season for registration link
@@ -2011,13 +2694,15 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 	renderTemplate(w, "info", data)
 }
 
-// publicSuccessHandler shows success page for public registrations
+// publicSuccessHandler shows success page for public registrations. Siblings
+// registered together are shown together, along with any of them who went
+// on the waitlist.
 func publicSuccessHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the registration ID and token from URL query parameters
-	id := r.URL.Query().Get("id")
+	// Get the registration IDs and token from URL query parameters
+	ids := r.URL.Query()["id"]
 	token := r.URL.Query().Get("token")
 
-	if id == "" || token == "" {
+	if len(ids) == 0 || token == "" {
 		http.Error(w, "Missing parameters", http.StatusBadRequest)
 		return
 	}
@@ -2029,28 +2714,38 @@ func publicSuccessHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Look up the registration
-	reg, exists, err := database.GetRegistration(id)
-	if err != nil {
-		log.Printf("Error getting registration: %v", err)
-		http.Error(w, "Failed to retrieve registration", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "Registration not found", http.StatusNotFound)
-		return
+	// Look up the registrations
+	var regs []*Registration
+	for _, id := range ids {
+		reg, exists, err := database.GetRegistration(id)
+		if err != nil {
+			log.Printf("Error getting registration: %v", err)
+			http.Error(w, "Failed to retrieve registration", http.StatusInternalServerError)
+			return
+		}
+
+		// Verify registration belongs to the season
+		if !exists || reg.SeasonID == nil || *reg.SeasonID != season.ID {
+			http.Error(w, "Registration not found", http.StatusNotFound)
+			return
+		}
+		regs = append(regs, reg)
 	}
+	editURLs := publicEditURLs(w, r, regs)
 
-	// Verify registration belongs to the season
-	if reg.SeasonID == nil || *reg.SeasonID != season.ID {
-		http.Error(w, "Registration not found", http.StatusNotFound)
+	entries, ok := publicWaitlistEntries(w, r.URL.Query()["waitlisted"], season)
+	if !ok {
 		return
 	}
 
 	// Render the success page with registration details
 	data := PageData{
-		Title:        "Run Club - Registration Successful",
-		Registration: reg,
+		Title:           "Run Club - Registration Successful",
+		Registration:    regs[0],
+		Registrations:   regs,
+		EditURL:         editURLs[regs[0].ID],
+		EditURLs:        editURLs,
+		WaitlistEntries: entries,
 		// For public registration, we don't have a logged-in user
 		User: "",
 		Role: "",
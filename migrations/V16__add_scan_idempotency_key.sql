@@ -0,0 +1,7 @@
+-- Migration: Add client idempotency keys to scan records
+
+-- Scans queued offline on a scanner phone carry a client-generated key so a
+-- replayed batch never records the same lap twice
+ALTER TABLE scan_records ADD COLUMN client_scan_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scan_records_client_scan_id ON scan_records(client_scan_id) WHERE client_scan_id IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Migration: Add soft delete and attribution to scan records
+
+-- Who recorded the scan, so scanners can undo their own mistakes
+ALTER TABLE scan_records ADD COLUMN recorded_by TEXT;
+
+-- Voided scans stay in the table but are ignored by statistics
+ALTER TABLE scan_records ADD COLUMN voided_at TIMESTAMP;
+ALTER TABLE scan_records ADD COLUMN voided_by TEXT;
+ALTER TABLE scan_records ADD COLUMN void_reason TEXT;
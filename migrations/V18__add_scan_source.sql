@@ -0,0 +1,4 @@
+-- Migration: Record how each scan was captured
+
+-- 'qr' for badge scans, 'manual' for scanner check-ins by name search
+ALTER TABLE scan_records ADD COLUMN source TEXT NOT NULL DEFAULT 'qr';
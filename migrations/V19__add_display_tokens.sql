@@ -0,0 +1,15 @@
+-- Migration: Add display tokens for the big-screen leaderboard
+
+-- Each token is a link an admin can hand to a gym TV without sharing a login.
+-- Revoked tokens are kept so admins can see what was issued.
+CREATE TABLE IF NOT EXISTS display_tokens (
+    id TEXT PRIMARY KEY,
+    token TEXT NOT NULL,
+    name TEXT NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_display_tokens_token ON display_tokens(token);
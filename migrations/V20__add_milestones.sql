@@ -0,0 +1,40 @@
+-- Migration: Add mileage milestones and award tracking
+
+-- Cumulative mileage thresholds that earn an award, configured per season
+CREATE TABLE IF NOT EXISTS milestones (
+    id TEXT PRIMARY KEY,
+    season_id TEXT NOT NULL,
+    miles REAL NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (season_id) REFERENCES seasons(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_milestones_season_miles ON milestones(season_id, miles);
+
+-- One row per runner per milestone reached; awarded_at is set once the award is handed out
+CREATE TABLE IF NOT EXISTS milestone_awards (
+    id TEXT PRIMARY KEY,
+    registration_id TEXT NOT NULL,
+    milestone_id TEXT NOT NULL,
+    scan_id TEXT,
+    reached_at TIMESTAMP,
+    awarded_at TIMESTAMP,
+    awarded_by TEXT,
+    FOREIGN KEY (registration_id) REFERENCES registrations(id),
+    FOREIGN KEY (milestone_id) REFERENCES milestones(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_milestone_awards_registration_milestone ON milestone_awards(registration_id, milestone_id);
+
+-- Give existing seasons the club's usual charm thresholds
+INSERT INTO milestones (id, season_id, miles, name)
+SELECT lower(hex(randomblob(16))), s.id, d.miles, d.name
+FROM seasons s
+CROSS JOIN (
+    SELECT 5 AS miles, '5 Miles' AS name
+    UNION ALL SELECT 10, '10 Miles'
+    UNION ALL SELECT 25, '25 Miles'
+    UNION ALL SELECT 50, '50 Miles'
+    UNION ALL SELECT 100, '100 Miles'
+) d;
@@ -0,0 +1,23 @@
+-- Migration: Add practice sessions and attach scans to them
+
+-- Dates and times are wall clock values in the club's timezone, e.g.
+-- date '2025-09-16', start_time '15:30', so a practice means the same thing
+-- no matter where the server runs.
+CREATE TABLE IF NOT EXISTS practices (
+    id TEXT PRIMARY KEY,
+    season_id TEXT NOT NULL,
+    date TEXT NOT NULL,
+    start_time TEXT NOT NULL,
+    end_time TEXT NOT NULL,
+    location TEXT,
+    cancelled INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (season_id) REFERENCES seasons(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_practices_season_date ON practices(season_id, date);
+
+-- The practice a scan was recorded during, if any
+ALTER TABLE scan_records ADD COLUMN practice_id TEXT REFERENCES practices(id);
+
+CREATE INDEX IF NOT EXISTS idx_scan_records_practice_id ON scan_records(practice_id);
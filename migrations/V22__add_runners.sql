@@ -0,0 +1,75 @@
+-- Migration: Add runners so a child keeps one identity across seasons
+
+-- A runner is the child; each season's registration links to one. A runner's
+-- ID is the ID of their first registration, so badges printed that season
+-- keep working as the runner's badge. Runners found to be the same child are
+-- merged, leaving merged_into pointing at the runner that was kept.
+CREATE TABLE IF NOT EXISTS runners (
+    id TEXT PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    parent_email TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    merged_into TEXT
+);
+
+ALTER TABLE registrations ADD COLUMN runner_id TEXT REFERENCES runners(id);
+
+CREATE INDEX IF NOT EXISTS idx_registrations_runner_id ON registrations(runner_id);
+
+-- Seasons that keep badges accept a runner's badge from any season
+ALTER TABLE seasons ADD COLUMN keep_badges INTEGER NOT NULL DEFAULT 0;
+
+-- Possible matches between runners that an admin needs to confirm or reject
+CREATE TABLE IF NOT EXISTS runner_match_reviews (
+    id TEXT PRIMARY KEY,
+    runner_id TEXT NOT NULL,
+    candidate_runner_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    resolution TEXT,
+    FOREIGN KEY (runner_id) REFERENCES runners(id),
+    FOREIGN KEY (candidate_runner_id) REFERENCES runners(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_runner_match_reviews_pair ON runner_match_reviews(runner_id, candidate_runner_id);
+
+-- Link existing registrations. The same name and parent email is a confident
+-- match: the earliest such registration becomes the runner.
+INSERT INTO runners (id, first_name, last_name, parent_email, created_at)
+SELECT r.id, trim(r.first_name), trim(r.last_name), trim(COALESCE(r.parent_email, '')), r.registered_at
+FROM registrations r
+WHERE trim(COALESCE(r.parent_email, '')) = ''
+   OR NOT EXISTS (
+        SELECT 1 FROM registrations e
+        WHERE lower(trim(e.first_name)) = lower(trim(r.first_name))
+          AND lower(trim(e.last_name)) = lower(trim(r.last_name))
+          AND lower(trim(e.parent_email)) = lower(trim(r.parent_email))
+          AND (e.registered_at < r.registered_at OR (e.registered_at = r.registered_at AND e.id < r.id))
+    );
+
+UPDATE registrations
+SET runner_id = CASE
+    WHEN trim(COALESCE(parent_email, '')) = '' THEN id
+    ELSE (
+        SELECT ru.id FROM runners ru
+        WHERE lower(ru.first_name) = lower(trim(registrations.first_name))
+          AND lower(ru.last_name) = lower(trim(registrations.last_name))
+          AND lower(ru.parent_email) = lower(trim(registrations.parent_email))
+        ORDER BY ru.created_at
+        LIMIT 1
+    )
+END;
+
+-- The same name with a different or missing parent email is uncertain: it
+-- could be the same child with a new email, or a different child.
+INSERT INTO runner_match_reviews (id, runner_id, candidate_runner_id, reason, created_at)
+SELECT lower(hex(randomblob(16))), a.id, b.id, 'Same name, different parent email', CURRENT_TIMESTAMP
+FROM runners a
+JOIN runners b
+  ON lower(a.first_name) = lower(b.first_name)
+ AND lower(a.last_name) = lower(b.last_name)
+ AND a.id <> b.id
+ AND (a.created_at < b.created_at OR (a.created_at = b.created_at AND a.id < b.id));
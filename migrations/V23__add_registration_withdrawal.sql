@@ -0,0 +1,7 @@
+-- Migration: Let admins withdraw a registration without deleting it
+
+-- A withdrawn registration keeps its scan history but is left off rosters,
+-- badges and the season's capacity count
+ALTER TABLE registrations ADD COLUMN withdrawn_at TIMESTAMP;
+ALTER TABLE registrations ADD COLUMN withdrawn_by TEXT;
+ALTER TABLE registrations ADD COLUMN withdraw_reason TEXT;
@@ -0,0 +1,30 @@
+-- Migration: Add an append-only audit log of changes to registrations, seasons, tracks and scans
+
+-- changes is a JSON array of {"field", "old", "new"} values. registration_id
+-- is the runner the change belongs to, so it can be shown on their page.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    registration_id TEXT,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    changes TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_registration_id ON audit_log(registration_id);
+
+-- Entries can be added but never changed or removed
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update
+BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete
+BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
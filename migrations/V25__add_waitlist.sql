@@ -0,0 +1,21 @@
+-- Migration: Add a waitlist for children who sign up once a season is full
+
+-- details holds the registration form as JSON, so a promoted entry becomes a
+-- registration exactly as the parent filled it in. position is the place in
+-- line while the entry is waiting, and the place it left from afterwards.
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id TEXT PRIMARY KEY,
+    season_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    details TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'waiting',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    registration_id TEXT,
+    notified_at TIMESTAMP,
+    FOREIGN KEY (season_id) REFERENCES seasons(id),
+    FOREIGN KEY (registration_id) REFERENCES registrations(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_season ON waitlist_entries(season_id, status, position);
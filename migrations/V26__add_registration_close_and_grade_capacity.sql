@@ -0,0 +1,7 @@
+-- Migration: Add a registration closing date and per-grade capacity limits to seasons
+
+ALTER TABLE seasons ADD COLUMN registration_ends_at TIMESTAMP;
+
+-- JSON object of grade to the most runners allowed in it, e.g. {"K": 15}.
+-- Grades that aren't listed are only limited by max_registrations.
+ALTER TABLE seasons ADD COLUMN grade_capacities TEXT;
@@ -0,0 +1,29 @@
+-- Migration: Add per-season custom questions to the registration form
+
+-- kind is text, yes_no, single_choice or multi_choice. options is a JSON
+-- array of the choices for the choice kinds.
+CREATE TABLE IF NOT EXISTS season_questions (
+    id TEXT PRIMARY KEY,
+    season_id TEXT NOT NULL,
+    label TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    options TEXT,
+    required BOOLEAN NOT NULL DEFAULT 0,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (season_id) REFERENCES seasons(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_season_questions_season ON season_questions(season_id, sort_order);
+
+-- One row per answer; a multi choice question has a row for each option picked
+CREATE TABLE IF NOT EXISTS registration_answers (
+    registration_id TEXT NOT NULL,
+    question_id TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (registration_id, question_id, value),
+    FOREIGN KEY (registration_id) REFERENCES registrations(id),
+    FOREIGN KEY (question_id) REFERENCES season_questions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_registration_answers_question ON registration_answers(question_id, value);
@@ -0,0 +1,26 @@
+-- Migration: Let parents update their registration through a private edit link
+
+-- edit_token is the secret in the parent's edit link. Existing registrations
+-- get a random one so every parent can be sent a link.
+ALTER TABLE registrations ADD COLUMN edit_token TEXT;
+
+UPDATE registrations SET edit_token = lower(hex(randomblob(16))) WHERE edit_token IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_registrations_edit_token ON registrations(edit_token);
+
+-- A parent's request to change a runner's name or grade, which an admin has to
+-- approve. status is pending, approved or rejected.
+CREATE TABLE IF NOT EXISTS registration_change_requests (
+    id TEXT PRIMARY KEY,
+    registration_id TEXT NOT NULL,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    grade TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    requested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    FOREIGN KEY (registration_id) REFERENCES registrations(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_registration_change_requests_status ON registration_change_requests(status, requested_at);
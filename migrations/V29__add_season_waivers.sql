@@ -0,0 +1,21 @@
+-- Migration: Add versioned waivers that parents sign when registering
+
+-- Each season can publish new versions of its waiver; the highest version is
+-- the current one. Earlier versions are kept so old signatures can be read
+-- against the text that was signed.
+CREATE TABLE IF NOT EXISTS season_waivers (
+    id TEXT PRIMARY KEY,
+    season_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT,
+    UNIQUE (season_id, version),
+    FOREIGN KEY (season_id) REFERENCES seasons(id)
+);
+
+-- The latest waiver the parent agreed to for this registration
+ALTER TABLE registrations ADD COLUMN waiver_version INTEGER;
+ALTER TABLE registrations ADD COLUMN waiver_signed_name TEXT;
+ALTER TABLE registrations ADD COLUMN waiver_signed_at TIMESTAMP;
+ALTER TABLE registrations ADD COLUMN waiver_signed_ip TEXT;
@@ -0,0 +1,25 @@
+-- Migration: Add season fees and per-registration payment records
+
+-- Amounts are stored in cents. A fee of 0 means the season is free.
+ALTER TABLE seasons ADD COLUMN fee_cents INTEGER NOT NULL DEFAULT 0;
+-- Taken off the fee of every child after the first in a family
+ALTER TABLE seasons ADD COLUMN sibling_discount_cents INTEGER NOT NULL DEFAULT 0;
+
+-- Empty for runners who pay the fee, or 'scholarship' or 'waived'
+ALTER TABLE registrations ADD COLUMN fee_status TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS payments (
+    id TEXT PRIMARY KEY,
+    registration_id TEXT NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    method TEXT NOT NULL,
+    -- Check number, Venmo transaction ID, etc.
+    reference TEXT,
+    paid_at TIMESTAMP NOT NULL,
+    recorded_by TEXT,
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (registration_id) REFERENCES registrations(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_registration_id ON payments(registration_id);
+CREATE INDEX IF NOT EXISTS idx_payments_reference ON payments(method, reference);
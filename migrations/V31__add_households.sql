@@ -0,0 +1,37 @@
+-- Migration: Add households so siblings share one family across seasons
+
+-- A household is the family a registration belongs to. Siblings registered
+-- on one form share a household, and a runner's next registration joins the
+-- household of their last one.
+CREATE TABLE IF NOT EXISTS households (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE registrations ADD COLUMN household_id TEXT REFERENCES households(id);
+
+CREATE INDEX IF NOT EXISTS idx_registrations_household_id ON registrations(household_id);
+
+-- Group existing registrations by parent email. Each household's ID is the
+-- ID of the earliest registration with that email; registrations without an
+-- email get a household of their own.
+INSERT INTO households (id, created_at)
+SELECT r.id, r.registered_at
+FROM registrations r
+WHERE trim(COALESCE(r.parent_email, '')) = ''
+   OR NOT EXISTS (
+        SELECT 1 FROM registrations e
+        WHERE lower(trim(e.parent_email)) = lower(trim(r.parent_email))
+          AND (e.registered_at < r.registered_at OR (e.registered_at = r.registered_at AND e.id < r.id))
+    );
+
+UPDATE registrations
+SET household_id = CASE
+    WHEN trim(COALESCE(parent_email, '')) = '' THEN id
+    ELSE (
+        SELECT e.id FROM registrations e
+        WHERE lower(trim(e.parent_email)) = lower(trim(registrations.parent_email))
+        ORDER BY e.registered_at, e.id
+        LIMIT 1
+    )
+END;
@@ -0,0 +1,7 @@
+-- Migration: Add the student's SIS ID to registrations
+
+-- Set by a OneRoster roster import: the student's sourcedId in the school's
+-- student information system, so later roster imports find them again.
+ALTER TABLE registrations ADD COLUMN external_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_registrations_external_id ON registrations(external_id);
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultMilestoneMiles are the thresholds every new season starts with
+var defaultMilestoneMiles = []float64{5, 10, 25, 50, 100}
+
+// Milestone is a cumulative distance a runner is recognized for reaching in a season
+type Milestone struct {
+	ID        string    `json:"id"`
+	SeasonID  string    `json:"seasonId"`
+	Miles     float64   `json:"miles"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MilestoneAward is a milestone a runner has reached, and whether they've been given the award for it
+type MilestoneAward struct {
+	RegistrationID string
+	FirstName      string
+	LastName       string
+	Grade          string
+	Teacher        string
+	TotalDistance  float64
+	MilestoneID    string
+	MilestoneName  string
+	Miles          float64
+	ReachedAt      *time.Time // Nil when reached before milestones were tracked
+	AwardedAt      *time.Time
+	AwardedBy      string
+}
+
+// TeacherAwards groups the awards to hand out by classroom
+type TeacherAwards struct {
+	Teacher string
+	Awards  []*MilestoneAward
+}
+
+// defaultMilestoneName names a milestone after its distance, e.g. "25 Miles"
+func defaultMilestoneName(miles float64) string {
+	return strconv.FormatFloat(miles, 'f', -1, 64) + " Miles"
+}
+
+// groupAwardsByTeacher groups awards that are already sorted by teacher
+func groupAwardsByTeacher(awards []*MilestoneAward) []TeacherAwards {
+	var groups []TeacherAwards
+	for _, award := range awards {
+		if len(groups) == 0 || groups[len(groups)-1].Teacher != award.Teacher {
+			groups = append(groups, TeacherAwards{Teacher: award.Teacher})
+		}
+		last := &groups[len(groups)-1]
+		last.Awards = append(last.Awards, award)
+	}
+	return groups
+}
+
+// milestoneNames lists milestone names for messages, e.g. "5 Miles, 10 Miles"
+func milestoneNames(milestones []*Milestone) string {
+	names := make([]string, len(milestones))
+	for i, m := range milestones {
+		names[i] = m.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectedSeasonFromQuery returns the season chosen by the season_id query parameter,
+// or the active season when none is chosen
+func selectedSeasonFromQuery(r *http.Request, seasons []*Season) *Season {
+	seasonID := r.URL.Query().Get("season_id")
+	for _, s := range seasons {
+		if (seasonID != "" && s.ID == seasonID) || (seasonID == "" && s.IsActive) {
+			return s
+		}
+	}
+	return nil
+}
+
+// milestonesHandler shows and adds the milestone thresholds for a season
+func milestonesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	// For POST requests, add a milestone to the season
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		seasonID := r.FormValue("season_id")
+		if _, found, err := database.GetSeason(seasonID); err != nil || !found {
+			http.Error(w, "Season not found", http.StatusBadRequest)
+			return
+		}
+
+		miles, err := strconv.ParseFloat(r.FormValue("miles"), 64)
+		if err != nil || miles <= 0 {
+			http.Error(w, "Invalid distance value", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			name = defaultMilestoneName(miles)
+		}
+
+		milestone := &Milestone{
+			ID:        uuid.New().String(),
+			SeasonID:  seasonID,
+			Miles:     miles,
+			Name:      name,
+			CreatedAt: time.Now(),
+		}
+		if err := database.SaveMilestone(milestone); err != nil {
+			log.Printf("Error saving milestone: %v", err)
+			http.Error(w, "Failed to save milestone. Each season can only have one milestone per distance.", http.StatusBadRequest)
+			return
+		}
+
+		http.Redirect(w, r, "/milestones?season_id="+url.QueryEscape(seasonID), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Milestones",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+		milestones, err := database.GetMilestonesBySeasonID(season.ID)
+		if err != nil {
+			log.Printf("Error getting milestones: %v", err)
+			http.Error(w, "Failed to retrieve milestones", http.StatusInternalServerError)
+			return
+		}
+		data.Milestones = milestones
+	}
+
+	renderTemplate(w, "milestones", data)
+}
+
+// deleteMilestoneHandler removes a milestone from a season
+func deleteMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Milestone ID is required", http.StatusBadRequest)
+		return
+	}
+	if err := database.DeleteMilestone(id); err != nil {
+		log.Printf("Error deleting milestone: %v", err)
+		http.Error(w, "Failed to delete milestone", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/milestones?season_id="+url.QueryEscape(r.FormValue("season_id")), http.StatusSeeOther)
+}
+
+// awardsHandler shows the milestone awards waiting to be handed out, grouped by teacher
+func awardsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Awards Due",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+		Message: r.URL.Query().Get("message"),
+		Success: r.URL.Query().Get("message") != "",
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+		awards, err := database.GetMilestoneAwards(season.ID, false)
+		if err != nil {
+			log.Printf("Error getting milestone awards: %v", err)
+			http.Error(w, "Failed to retrieve awards", http.StatusInternalServerError)
+			return
+		}
+		data.AwardsByTeacher = groupAwardsByTeacher(awards)
+		data.AwardsDueCount = len(awards)
+	}
+
+	renderTemplate(w, "awards", data)
+}
+
+// markAwardsHandler records that milestone awards have been handed out. Each
+// award is posted as "registrationID:milestoneID" so a teacher's whole list
+// can be marked at once.
+func markAwardsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	awards := r.Form["award"]
+	if len(awards) == 0 {
+		http.Error(w, "No awards selected", http.StatusBadRequest)
+		return
+	}
+
+	marked := 0
+	for _, award := range awards {
+		registrationID, milestoneID, ok := strings.Cut(award, ":")
+		if !ok || registrationID == "" || milestoneID == "" {
+			http.Error(w, "Invalid award", http.StatusBadRequest)
+			return
+		}
+		if err := database.MarkMilestoneAwarded(registrationID, milestoneID, username); err != nil {
+			log.Printf("Error marking milestone award: %v", err)
+			http.Error(w, "Failed to mark award as handed out", http.StatusInternalServerError)
+			return
+		}
+		marked++
+	}
+
+	message := fmt.Sprintf("Marked %d award(s) as handed out", marked)
+	http.Redirect(w, r, "/awards?season_id="+url.QueryEscape(r.FormValue("season_id"))+"&message="+url.QueryEscape(message), http.StatusSeeOther)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMilestones(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("New Seasons Get Default Milestones", func(t *testing.T) {
+		milestones, err := db.GetMilestonesBySeasonID(activeSeason.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(milestones) != len(defaultMilestoneMiles) {
+			t.Fatalf("Expected %d milestones, got %d", len(defaultMilestoneMiles), len(milestones))
+		}
+		if milestones[0].Miles != 5 || milestones[0].Name != "5 Miles" {
+			t.Errorf("Expected first milestone to be 5 Miles, got %v %q", milestones[0].Miles, milestones[0].Name)
+		}
+	})
+
+	t.Run("Scan Crossing A Milestone", func(t *testing.T) {
+		reg := createTestRegistration(t, db, activeSeason.ID)
+		track := &Track{
+			ID:            uuid.New().String(),
+			SeasonID:      activeSeason.ID,
+			Name:          "Long Loop",
+			DistanceMiles: 2.5,
+			CreatedAt:     time.Now(),
+		}
+		if err := db.SaveTrack(track, "admin"); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now().Add(-2 * time.Hour)
+		scanAt := func(minutes int) *ScanRecord {
+			scan, _, err := db.RecordScanAt(reg.ID, &track.ID, start.Add(time.Duration(minutes)*time.Minute), ScanOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return scan
+		}
+
+		if scan := scanAt(0); len(scan.Milestones) != 0 {
+			t.Errorf("Expected no milestone at 2.5 miles, got %d", len(scan.Milestones))
+		}
+		second := scanAt(20)
+		if len(second.Milestones) != 1 || second.Milestones[0].Miles != 5 {
+			t.Fatalf("Expected the 5 mile milestone at 5 miles, got %+v", second.Milestones)
+		}
+
+		// Voiding and re-running the lap must not announce the milestone twice
+		if err := db.VoidScan(second.ID, "admin", "double scan"); err != nil {
+			t.Fatal(err)
+		}
+		if scan := scanAt(40); len(scan.Milestones) != 0 {
+			t.Errorf("Expected milestone to be reached only once, got %d", len(scan.Milestones))
+		}
+
+		awards, err := db.GetMilestoneAwards(activeSeason.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(awards) != 1 || awards[0].RegistrationID != reg.ID || awards[0].Miles != 5 {
+			t.Fatalf("Expected one 5 mile award due, got %+v", awards)
+		}
+		if awards[0].ReachedAt == nil {
+			t.Error("Expected award to record when the milestone was reached")
+		}
+
+		if err := db.MarkMilestoneAwarded(reg.ID, awards[0].MilestoneID, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		awards, err = db.GetMilestoneAwards(activeSeason.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(awards) != 0 {
+			t.Errorf("Expected no awards due after handing out, got %d", len(awards))
+		}
+
+		awards, err = db.GetMilestoneAwards(activeSeason.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(awards) != 1 || awards[0].AwardedAt == nil || awards[0].AwardedBy != "admin" {
+			t.Errorf("Expected handed out award to be recorded, got %+v", awards)
+		}
+	})
+}
+
+func TestGroupAwardsByTeacher(t *testing.T) {
+	awards := []*MilestoneAward{
+		{Teacher: "Ms. Jones", FirstName: "A"},
+		{Teacher: "Ms. Jones", FirstName: "B"},
+		{Teacher: "Mr. Lee", FirstName: "C"},
+	}
+	groups := groupAwardsByTeacher(awards)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+	if groups[0].Teacher != "Ms. Jones" || len(groups[0].Awards) != 2 {
+		t.Errorf("Unexpected first group: %+v", groups[0])
+	}
+	if groups[1].Teacher != "Mr. Lee" || len(groups[1].Awards) != 1 {
+		t.Errorf("Unexpected second group: %+v", groups[1])
+	}
+}
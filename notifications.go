@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// ErrNoRecipient is returned when there is no email address to notify
+var ErrNoRecipient = errors.New("no email address to notify")
+
+// Notifier sends a message to a parent
+type Notifier interface {
+	Notify(to, subject, body string) error
+}
+
+// notifier sends parent notifications. It's replaced in tests.
+var notifier Notifier = logNotifier{}
+
+// logNotifier records notifications in the server log, for when email isn't
+// set up. Only the recipient and subject are logged, since bodies carry
+// parents' private edit links.
+type logNotifier struct{}
+
+func (logNotifier) Notify(to, subject, body string) error {
+	if to == "" {
+		return ErrNoRecipient
+	}
+	log.Printf("Notification to %s not sent (email is not configured): %s", to, subject)
+	return nil
+}
+
+// smtpNotifier sends notifications by email
+type smtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func (n *smtpNotifier) Notify(to, subject, body string) error {
+	if to == "" {
+		return ErrNoRecipient
+	}
+	// Don't let a stray newline in an address or subject add headers
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	msg := "From: " + n.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+	if err := smtp.SendMail(n.addr, n.auth, n.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// newNotifierFromEnv sends email through SMTP_HOST when it's set, and
+// otherwise only logs notifications
+func newNotifierFromEnv() (Notifier, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("WARNING: SMTP_HOST is not set; parent notifications will not be sent")
+		return logNotifier{}, nil
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM must be set when SMTP_HOST is set")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	n := &smtpNotifier{addr: host + ":" + port, from: from}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		n.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return n, nil
+}
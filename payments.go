@@ -0,0 +1,499 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fee statuses for runners who don't pay the season fee. Runners who pay
+// have an empty status.
+const (
+	FeeStatusScholarship = "scholarship"
+	FeeStatusWaived      = "waived"
+)
+
+// Payment methods
+const (
+	PaymentMethodVenmo = "venmo"
+	PaymentMethodCash  = "cash"
+	PaymentMethodCheck = "check"
+	PaymentMethodOther = "other"
+)
+
+// paymentMethods are the methods offered when recording a payment by hand
+var paymentMethods = []string{PaymentMethodVenmo, PaymentMethodCash, PaymentMethodCheck, PaymentMethodOther}
+
+// Payment is money received toward a runner's season fee
+type Payment struct {
+	ID             string    `json:"id"`
+	RegistrationID string    `json:"registrationId"`
+	AmountCents    int       `json:"amountCents"`
+	Method         string    `json:"method"`
+	Reference      string    `json:"reference,omitempty"` // Check number, Venmo transaction ID, etc.
+	PaidAt         time.Time `json:"paidAt"`
+	RecordedBy     string    `json:"recordedBy,omitempty"`
+	RecordedAt     time.Time `json:"recordedAt"`
+}
+
+// Balance is what a runner owes for their season and what they've paid
+type Balance struct {
+	Registration    *Registration
+	Fee             int // Cents owed before payments, after any sibling discount
+	SiblingDiscount int // Cents taken off because an older sibling pays the full fee
+	Payments        []*Payment
+	Paid            int
+}
+
+// Outstanding is how many cents the runner still owes. It's negative if
+// they've paid more than the fee.
+func (b *Balance) Outstanding() int {
+	return b.Fee - b.Paid
+}
+
+// PaymentSummary totals a season's balances
+type PaymentSummary struct {
+	Fees        int
+	Paid        int
+	Outstanding int // Only counts runners who still owe something
+	Unpaid      int // Runners who still owe something
+}
+
+// parseDollars parses an amount like "25", "$25.50", "1,025.00" or Venmo's
+// "+ $25.00" into cents
+func parseDollars(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimSpace(strings.TrimLeft(s, "+-"))
+	s = strings.ReplaceAll(strings.TrimPrefix(s, "$"), ",", "")
+
+	if s == "" || s == "." {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	dollars, err := strconv.Atoi(whole)
+	if err != nil || dollars < 0 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	cents, err := strconv.Atoi(fraction)
+	if err != nil || cents < 0 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	amount := dollars*100 + cents
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// formatDollars formats cents as dollars, e.g. "$25.00" or "-$5.50"
+func formatDollars(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
+// familyKeys are the household and contact details that tie siblings' registrations together
+func familyKeys(reg *Registration) []string {
+	var keys []string
+	if reg.HouseholdID != "" {
+		keys = append(keys, "household:"+reg.HouseholdID)
+	}
+	if email := strings.ToLower(strings.TrimSpace(reg.ParentEmail)); email != "" {
+		keys = append(keys, "email:"+email)
+	}
+	if phone := normalizePhone(reg.ParentContactNumber); phone != "" {
+		keys = append(keys, "phone:"+phone)
+	}
+	return keys
+}
+
+// computeBalances works out what each of a season's runners owes. Runners
+// in the same household, or whose parents share an email address or phone
+// number, are one family; the first of them to register who pays the fee
+// pays it in full, and each sibling after them gets the season's sibling
+// discount. Runners with a scholarship or a waived fee owe nothing.
+// Balances are returned in the same order as regs.
+func computeBalances(season *Season, regs []*Registration, payments map[string][]*Payment) []*Balance {
+	ordered := make([]*Registration, len(regs))
+	copy(ordered, regs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].RegisteredAt.Before(ordered[j].RegisteredAt)
+	})
+
+	// Group siblings, joining families that share any contact detail
+	family := make([]int, len(ordered))
+	var find func(int) int
+	find = func(i int) int {
+		if family[i] != i {
+			family[i] = find(family[i])
+		}
+		return family[i]
+	}
+	firstWithKey := make(map[string]int)
+	for i, reg := range ordered {
+		family[i] = i
+		for _, key := range familyKeys(reg) {
+			if j, ok := firstWithKey[key]; ok {
+				family[find(i)] = find(j)
+			} else {
+				firstWithKey[key] = i
+			}
+		}
+	}
+
+	discount := season.SiblingDiscountCents
+	if discount > season.FeeCents {
+		discount = season.FeeCents
+	}
+	paying := make(map[int]bool)
+	byID := make(map[string]*Balance, len(ordered))
+	for i, reg := range ordered {
+		b := &Balance{Registration: reg, Payments: payments[reg.ID]}
+		for _, p := range b.Payments {
+			b.Paid += p.AmountCents
+		}
+		if reg.FeeStatus == "" {
+			b.Fee = season.FeeCents
+			if paying[find(i)] {
+				b.SiblingDiscount = discount
+				b.Fee -= discount
+			}
+			paying[find(i)] = true
+		}
+		byID[reg.ID] = b
+	}
+
+	balances := make([]*Balance, len(regs))
+	for i, reg := range regs {
+		balances[i] = byID[reg.ID]
+	}
+	return balances
+}
+
+// summarizeBalances totals a season's balances
+func summarizeBalances(balances []*Balance) *PaymentSummary {
+	summary := &PaymentSummary{}
+	for _, b := range balances {
+		summary.Fees += b.Fee
+		summary.Paid += b.Paid
+		if b.Outstanding() > 0 {
+			summary.Outstanding += b.Outstanding()
+			summary.Unpaid++
+		}
+	}
+	return summary
+}
+
+// loadBalances works out the balances of registrations from any number of
+// seasons, by registration ID. Pass a season ID if every registration is
+// from that season to load only its payments. Siblings are only found among
+// regs, so regs should hold each season's full list of runners.
+func loadBalances(regs []*Registration, seasonID string) (map[string]*Balance, error) {
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		return nil, err
+	}
+	payments, err := database.GetPayments(seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	bySeason := make(map[string][]*Registration)
+	for _, reg := range regs {
+		if reg.SeasonID != nil {
+			bySeason[*reg.SeasonID] = append(bySeason[*reg.SeasonID], reg)
+		}
+	}
+	balances := make(map[string]*Balance, len(regs))
+	for _, season := range seasons {
+		for _, b := range computeBalances(season, bySeason[season.ID], payments) {
+			balances[b.Registration.ID] = b
+		}
+	}
+	return balances, nil
+}
+
+// runnerBalance works out one runner's balance from their season's runners.
+// A withdrawn runner owes nothing, but their payments are still listed.
+func runnerBalance(reg *Registration, seasonRunners []*Registration) (*Balance, error) {
+	if reg.SeasonID == nil {
+		return nil, nil
+	}
+	balances, err := loadBalances(seasonRunners, *reg.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := balances[reg.ID]; ok {
+		return b, nil
+	}
+
+	payments, err := database.GetPayments(*reg.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+	b := &Balance{Registration: reg, Payments: payments[reg.ID]}
+	for _, p := range b.Payments {
+		b.Paid += p.AmountCents
+	}
+	return b, nil
+}
+
+// paymentFromForm reads a payment recorded by hand
+func paymentFromForm(r *http.Request) (*Payment, error) {
+	amount, err := parseDollars(r.FormValue("amount"))
+	if err != nil || amount == 0 {
+		return nil, errRegistrationInput("Please enter the amount paid")
+	}
+
+	method := r.FormValue("method")
+	valid := false
+	for _, m := range paymentMethods {
+		valid = valid || m == method
+	}
+	if !valid {
+		return nil, errRegistrationInput("Please choose how the payment was made")
+	}
+
+	paidAt := time.Now()
+	if value := r.FormValue("paid_at"); value != "" {
+		paidAt, err = time.ParseInLocation("2006-01-02", value, clubLocation())
+		if err != nil {
+			return nil, errRegistrationInput("Invalid payment date")
+		}
+	}
+
+	return &Payment{
+		ID:             uuid.New().String(),
+		RegistrationID: r.FormValue("registration_id"),
+		AmountCents:    amount,
+		Method:         method,
+		Reference:      strings.TrimSpace(r.FormValue("reference")),
+		PaidAt:         paidAt,
+		RecordedAt:     time.Now(),
+	}, nil
+}
+
+// seasonFeesHandler sets a season's fee and sibling discount
+func seasonFeesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	fee, err := parseDollars(r.FormValue("fee"))
+	if err != nil || fee < 0 {
+		http.Error(w, "Invalid fee", http.StatusBadRequest)
+		return
+	}
+	discount, err := parseDollars(r.FormValue("sibling_discount"))
+	if err != nil || discount < 0 || discount > fee {
+		http.Error(w, "The sibling discount must be between $0 and the fee", http.StatusBadRequest)
+		return
+	}
+
+	err = database.SetSeasonFees(r.FormValue("season_id"), fee, discount, username)
+	if errors.Is(err, ErrSeasonNotFound) {
+		http.Error(w, "Season not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error setting season fees: %v", err)
+		http.Error(w, "Failed to save fees", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, seasonsURL("Saved the season fee"), http.StatusSeeOther)
+}
+
+// paymentsHandler shows what each of a season's runners owes and has paid.
+// Posting a Venmo statement records the payments in it first.
+func paymentsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Payments",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+		Message: r.URL.Query().Get("message"),
+		Success: r.URL.Query().Get("message") != "",
+	}
+
+	season := selectedSeasonFromQuery(r, seasons)
+	if season == nil {
+		renderTemplate(w, "payments", data)
+		return
+	}
+	data.SelectedSeason = season
+	data.SelectedSeasonID = season.ID
+	data.OutstandingOnly = r.URL.Query().Get("outstanding") == "true"
+
+	if r.Method == http.MethodPost {
+		data.PaymentImport, err = importVenmoStatement(r, season, username)
+		data.Success = err == nil
+		if err != nil {
+			data.Message = err.Error()
+		} else {
+			data.Message = fmt.Sprintf("Recorded %d Venmo payments", len(data.PaymentImport.Matched))
+		}
+	}
+
+	balances, err := seasonBalances(season)
+	if err != nil {
+		log.Printf("Error getting balances: %v", err)
+		http.Error(w, "Failed to retrieve payments", http.StatusInternalServerError)
+		return
+	}
+	data.PaymentSummary = summarizeBalances(balances)
+	if data.OutstandingOnly {
+		var outstanding []*Balance
+		for _, b := range balances {
+			if b.Outstanding() > 0 {
+				outstanding = append(outstanding, b)
+			}
+		}
+		balances = outstanding
+	}
+	data.Balances = balances
+
+	renderTemplate(w, "payments", data)
+}
+
+// seasonBalances works out the balance of every runner in a season, sorted by name
+func seasonBalances(season *Season) ([]*Balance, error) {
+	regs, err := database.GetAllRegistrations(season.ID)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := database.GetPayments(season.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].LastName != regs[j].LastName {
+			return regs[i].LastName < regs[j].LastName
+		}
+		return regs[i].FirstName < regs[j].FirstName
+	})
+	return computeBalances(season, regs, payments), nil
+}
+
+// paymentRecordHandler records a payment toward a runner's fee, deletes one
+// recorded by mistake, or changes whether the runner pays the fee
+func paymentRecordHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	registrationID := r.FormValue("registration_id")
+	var message string
+	switch r.FormValue("action") {
+	case "add":
+		p, err := paymentFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.RecordedBy = username
+		err = database.RecordPayments([]*Payment{p}, username)
+		if errors.Is(err, ErrRegistrationNotFound) {
+			http.Error(w, "Registration not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error recording payment: %v", err)
+			http.Error(w, "Failed to record payment", http.StatusInternalServerError)
+			return
+		}
+		message = fmt.Sprintf("Recorded a %s payment", formatDollars(p.AmountCents))
+	case "delete":
+		p, err := database.DeletePayment(r.FormValue("id"), username)
+		if errors.Is(err, ErrPaymentNotFound) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting payment: %v", err)
+			http.Error(w, "Failed to delete payment", http.StatusInternalServerError)
+			return
+		}
+		registrationID = p.RegistrationID
+		message = fmt.Sprintf("Deleted the %s payment", formatDollars(p.AmountCents))
+	case "status":
+		status := r.FormValue("fee_status")
+		if status != "" && status != FeeStatusScholarship && status != FeeStatusWaived {
+			http.Error(w, "Invalid fee status", http.StatusBadRequest)
+			return
+		}
+		_, err := database.SetFeeStatus(registrationID, status, username)
+		if errors.Is(err, ErrRegistrationNotFound) {
+			http.Error(w, "Registration not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error setting fee status: %v", err)
+			http.Error(w, "Failed to save fee status", http.StatusInternalServerError)
+			return
+		}
+		message = "Saved the fee status"
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, runnerDetailURL(registrationID, message), http.StatusSeeOther)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+)
+
+func TestParseDollars(t *testing.T) {
+	tests := map[string]int{
+		"25":        2500,
+		"$25.50":    2550,
+		"25.5":      2550,
+		".75":       75,
+		"1,025.00":  102500,
+		"+ $25.00":  2500,
+		"- $10.00":  -1000,
+		" $0.00 ":   0,
+		"$40.00":    4000,
+		"$1,000.01": 100001,
+	}
+	for input, want := range tests {
+		got, err := parseDollars(input)
+		if err != nil {
+			t.Errorf("parseDollars(%q): %v", input, err)
+		} else if got != want {
+			t.Errorf("parseDollars(%q) = %d, want %d", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"abc", "25.005", "$", "12.3.4"} {
+		if _, err := parseDollars(input); err == nil {
+			t.Errorf("parseDollars(%q): expected an error", input)
+		}
+	}
+
+	if got := formatDollars(-550); got != "-$5.50" {
+		t.Errorf("formatDollars(-550) = %q", got)
+	}
+}
+
+func TestComputeBalances(t *testing.T) {
+	season := &Season{ID: "s", FeeCents: 4000, SiblingDiscountCents: 1000}
+	start := time.Date(2026, 8, 20, 9, 0, 0, 0, time.UTC)
+	reg := func(id, email, phone string, minutes int) *Registration {
+		return &Registration{ID: id, FirstName: id, ParentEmail: email, ParentContactNumber: phone, RegisteredAt: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	// The waived runner registered first, so the second child pays in full;
+	// the third shares only a phone number with the second but is still family
+	waived := reg("waived", "pat@example.com", "555-555-0001", 0)
+	waived.FeeStatus = FeeStatusWaived
+	oldest := reg("oldest", "PAT@example.com ", "555-555-0002", 5)
+	middle := reg("middle", "other@example.com", "(555) 555-0002", 10)
+	other := reg("other", "sam@example.com", "555-555-0003", 1)
+	scholarship := reg("scholarship", "lee@example.com", "555-555-0004", 2)
+	scholarship.FeeStatus = FeeStatusScholarship
+
+	regs := []*Registration{middle, other, oldest, waived, scholarship}
+	payments := map[string][]*Payment{
+		"oldest": {{AmountCents: 2500}, {AmountCents: 1500}},
+		"middle": {{AmountCents: 1000}},
+	}
+	balances := computeBalances(season, regs, payments)
+
+	for i, b := range balances {
+		if b.Registration != regs[i] {
+			t.Fatalf("Expected balances in the order given, got %s at %d", b.Registration.ID, i)
+		}
+	}
+	want := map[string]struct{ fee, discount, outstanding int }{
+		"middle":      {3000, 1000, 2000},
+		"other":       {4000, 0, 4000},
+		"oldest":      {4000, 0, 0},
+		"waived":      {0, 0, 0},
+		"scholarship": {0, 0, 0},
+	}
+	for _, b := range balances {
+		w := want[b.Registration.ID]
+		if b.Fee != w.fee || b.SiblingDiscount != w.discount || b.Outstanding() != w.outstanding {
+			t.Errorf("%s: got fee %d, discount %d, outstanding %d; want %+v", b.Registration.ID, b.Fee, b.SiblingDiscount, b.Outstanding(), w)
+		}
+	}
+
+	summary := summarizeBalances(balances)
+	if summary.Fees != 11000 || summary.Paid != 5000 || summary.Outstanding != 6000 || summary.Unpaid != 2 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
+
+func TestPayments(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	store = sessions.NewCookieStore([]byte("test-secret"))
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSeasonFees(season.ID, 4000, 1000, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSeasonFees("missing", 4000, 0, "admin"); !errors.Is(err, ErrSeasonNotFound) {
+		t.Errorf("Expected ErrSeasonNotFound, got %v", err)
+	}
+	season, _, err = db.GetSeason(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if season.FeeCents != 4000 || season.SiblingDiscountCents != 1000 {
+		t.Fatalf("Expected the fees to be saved, got %+v", season)
+	}
+
+	reg := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+	if err := db.SaveRegistration(reg, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments/record", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		session, _ := store.Get(req, "run-club-session")
+		session.Values["username"] = "admin"
+		rr := httptest.NewRecorder()
+		paymentRecordHandler(rr, req)
+		return rr
+	}
+
+	rr := post(url.Values{"action": {"add"}, "registration_id": {reg.ID}, "amount": {"$25"}, "method": {"check"}, "reference": {"1042"}, "paid_at": {"2026-09-01"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the payment to be recorded, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := post(url.Values{"action": {"add"}, "registration_id": {reg.ID}, "amount": {"25"}, "method": {"bitcoin"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown method to be rejected, got %d", rr.Code)
+	}
+	if rr := post(url.Values{"action": {"add"}, "registration_id": {"missing"}, "amount": {"25"}, "method": {"cash"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown registration to be rejected, got %d", rr.Code)
+	}
+
+	payments, err := db.GetPayments(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments[reg.ID]) != 1 {
+		t.Fatalf("Expected one payment, got %+v", payments)
+	}
+	p := payments[reg.ID][0]
+	if p.AmountCents != 2500 || p.Method != PaymentMethodCheck || p.Reference != "1042" || p.RecordedBy != "admin" || p.PaidAt.Format("2006-01-02") != "2026-09-01" {
+		t.Errorf("Unexpected payment %+v", p)
+	}
+
+	balance, err := runnerBalance(reg, []*Registration{reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Outstanding() != 1500 {
+		t.Errorf("Expected $15 outstanding, got %d", balance.Outstanding())
+	}
+
+	if rr := post(url.Values{"action": {"status"}, "registration_id": {reg.ID}, "fee_status": {FeeStatusScholarship}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the fee status to be saved, got %d: %s", rr.Code, rr.Body.String())
+	}
+	updated, _, err := db.GetRegistration(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.FeeStatus != FeeStatusScholarship {
+		t.Errorf("Expected a scholarship, got %q", updated.FeeStatus)
+	}
+
+	if rr := post(url.Values{"action": {"delete"}, "id": {p.ID}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the payment to be deleted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := db.DeletePayment(p.ID, "admin"); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("Expected ErrPaymentNotFound deleting twice, got %v", err)
+	}
+
+	entries, err := db.GetRegistrationAuditLog(reg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paymentEntries int
+	for _, e := range entries {
+		if e.EntityType == AuditEntityPayment {
+			paymentEntries++
+		}
+	}
+	if paymentEntries != 2 {
+		t.Errorf("Expected the payment and its deletion in the runner's history, got %d entries", paymentEntries)
+	}
+
+	t.Run("MergeMovesPayments", func(t *testing.T) {
+		dup := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+		if err := db.SaveRegistration(dup, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		err := db.RecordPayments([]*Payment{{
+			ID: uuid.New().String(), RegistrationID: dup.ID, AmountCents: 4000, Method: PaymentMethodCash, PaidAt: time.Now(), RecordedAt: time.Now(),
+		}}, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := db.MergeRegistrations(reg.ID, dup.ID, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		payments, err := db.GetPayments(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payments[reg.ID]) != 1 || len(payments[dup.ID]) != 0 {
+			t.Errorf("Expected the duplicate's payment to move, got %+v", payments)
+		}
+	})
+}
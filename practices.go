@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Practice dates and times are stored as wall clock values in the club's timezone
+const (
+	practiceDateLayout = "2006-01-02"
+	practiceTimeLayout = "15:04"
+)
+
+// practiceCheckInGrace lets scans shortly before a practice starts or after it
+// ends still count toward it
+const practiceCheckInGrace = 15 * time.Minute
+
+// Practice is a scheduled practice session in a season
+type Practice struct {
+	ID        string    `json:"id"`
+	SeasonID  string    `json:"seasonId"`
+	Date      string    `json:"date"`      // YYYY-MM-DD in the club's timezone
+	StartTime string    `json:"startTime"` // HH:MM in the club's timezone
+	EndTime   string    `json:"endTime"`   // HH:MM in the club's timezone
+	Location  string    `json:"location"`
+	Cancelled bool      `json:"cancelled"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	PresentCount int `json:"presentCount"` // Runners with at least one scan during the practice
+}
+
+// parsePracticeTime combines a practice date and HH:MM time in the club's timezone
+func parsePracticeTime(date, clock string) (time.Time, error) {
+	return time.ParseInLocation(practiceDateLayout+" "+practiceTimeLayout, date+" "+clock, clubLocation())
+}
+
+// StartsAt returns when the practice starts
+func (p *Practice) StartsAt() time.Time {
+	t, _ := parsePracticeTime(p.Date, p.StartTime)
+	return t
+}
+
+// EndsAt returns when the practice ends
+func (p *Practice) EndsAt() time.Time {
+	t, _ := parsePracticeTime(p.Date, p.EndTime)
+	return t
+}
+
+// Includes reports whether a scan at t belongs to this practice
+func (p *Practice) Includes(t time.Time) bool {
+	return !t.Before(p.StartsAt().Add(-practiceCheckInGrace)) && !t.After(p.EndsAt().Add(practiceCheckInGrace))
+}
+
+// DisplayDate formats the practice date for pages, e.g. "Tue, Sep 16, 2025"
+func (p *Practice) DisplayDate() string {
+	t, err := time.Parse(practiceDateLayout, p.Date)
+	if err != nil {
+		return p.Date
+	}
+	return t.Format("Mon, Jan 2, 2006")
+}
+
+// DisplayTime formats the practice start and end, e.g. "3:30 PM - 4:30 PM"
+func (p *Practice) DisplayTime() string {
+	return p.StartsAt().Format("3:04 PM") + " - " + p.EndsAt().Format("3:04 PM")
+}
+
+// PracticeAttendee is a runner on a practice roster
+type PracticeAttendee struct {
+	RegistrationID string
+	FirstName      string
+	LastName       string
+	Grade          string
+	Teacher        string
+	RegisteredAt   time.Time
+	Laps           int
+}
+
+// Present reports whether the runner was scanned during the practice
+func (a *PracticeAttendee) Present() bool {
+	return a.Laps > 0
+}
+
+// PracticeAttendance is whether a runner came to one practice
+type PracticeAttendance struct {
+	Practice *Practice
+	Present  bool
+}
+
+// RunnerAttendance summarizes a runner's attendance over a season's practices
+type RunnerAttendance struct {
+	Attended          int
+	Total             int
+	Rate              float64 // Percent of practices attended
+	ConsecutiveMissed int     // Practices missed since the runner last came
+	Practices         []PracticeAttendance
+}
+
+// calculateAttendance works out a runner's attendance. Cancelled practices,
+// practices that haven't started yet, and practices before the runner
+// registered don't count.
+func calculateAttendance(practices []*Practice, attended map[string]bool, registeredAt, now time.Time) *RunnerAttendance {
+	registeredOn := registeredAt.In(clubLocation()).Format(practiceDateLayout)
+
+	attendance := &RunnerAttendance{}
+	for _, p := range practices {
+		if p.Cancelled || p.StartsAt().After(now) || p.Date < registeredOn {
+			continue
+		}
+		present := attended[p.ID]
+		attendance.Practices = append(attendance.Practices, PracticeAttendance{Practice: p, Present: present})
+		attendance.Total++
+		if present {
+			attendance.Attended++
+			attendance.ConsecutiveMissed = 0
+		} else {
+			attendance.ConsecutiveMissed++
+		}
+	}
+	if attendance.Total > 0 {
+		attendance.Rate = float64(attendance.Attended) / float64(attendance.Total) * 100
+	}
+
+	return attendance
+}
+
+// practicesHandler lists a season's practices and schedules new ones
+func practicesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	// For POST requests, schedule a new practice
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		seasonID := r.FormValue("season_id")
+		if _, found, err := database.GetSeason(seasonID); err != nil || !found {
+			http.Error(w, "Season not found", http.StatusBadRequest)
+			return
+		}
+
+		practice := &Practice{
+			ID:        uuid.New().String(),
+			SeasonID:  seasonID,
+			Date:      r.FormValue("date"),
+			StartTime: r.FormValue("start_time"),
+			EndTime:   r.FormValue("end_time"),
+			Location:  strings.TrimSpace(r.FormValue("location")),
+			CreatedAt: time.Now(),
+		}
+		startsAt, err := parsePracticeTime(practice.Date, practice.StartTime)
+		if err != nil {
+			http.Error(w, "Invalid practice date or start time", http.StatusBadRequest)
+			return
+		}
+		endsAt, err := parsePracticeTime(practice.Date, practice.EndTime)
+		if err != nil {
+			http.Error(w, "Invalid practice end time", http.StatusBadRequest)
+			return
+		}
+		if !endsAt.After(startsAt) {
+			http.Error(w, "Practice must end after it starts", http.StatusBadRequest)
+			return
+		}
+
+		if err := database.SavePractice(practice); err != nil {
+			log.Printf("Error saving practice: %v", err)
+			http.Error(w, "Failed to save practice", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/practices?season_id="+url.QueryEscape(seasonID), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Practices",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+		practices, err := database.GetPracticesBySeasonID(season.ID)
+		if err != nil {
+			log.Printf("Error getting practices: %v", err)
+			http.Error(w, "Failed to retrieve practices", http.StatusInternalServerError)
+			return
+		}
+		data.Practices = practices
+	}
+
+	renderTemplate(w, "practices", data)
+}
+
+// practiceDetailHandler shows who was present and absent at a practice
+func practiceDetailHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	practiceID := strings.TrimPrefix(r.URL.Path, "/practices/")
+	if practiceID == "" || strings.Contains(practiceID, "/") {
+		http.Error(w, "Invalid practice ID", http.StatusBadRequest)
+		return
+	}
+
+	practice, found, err := database.GetPractice(practiceID)
+	if err != nil {
+		log.Printf("Error getting practice: %v", err)
+		http.Error(w, "Failed to retrieve practice", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Practice not found", http.StatusNotFound)
+		return
+	}
+
+	roster, err := database.GetPracticeRoster(practice.ID, practice.SeasonID)
+	if err != nil {
+		log.Printf("Error getting practice roster: %v", err)
+		http.Error(w, "Failed to retrieve attendance", http.StatusInternalServerError)
+		return
+	}
+
+	// Runners who signed up after the practice weren't expected to be there
+	var present, absent []*PracticeAttendee
+	for _, attendee := range roster {
+		if attendee.Present() {
+			present = append(present, attendee)
+		} else if attendee.RegisteredAt.In(clubLocation()).Format(practiceDateLayout) <= practice.Date {
+			absent = append(absent, attendee)
+		}
+	}
+
+	renderTemplate(w, "practice_detail", PageData{
+		Title:          "Run Club - Practice Attendance",
+		User:           username,
+		Role:           role,
+		Practice:       practice,
+		PresentRunners: present,
+		AbsentRunners:  absent,
+	})
+}
+
+// cancelPracticeHandler cancels a practice, or reinstates a cancelled one
+func cancelPracticeHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Practice ID is required", http.StatusBadRequest)
+		return
+	}
+	cancelled := r.FormValue("cancelled") == "true"
+
+	err = database.SetPracticeCancelled(id, cancelled)
+	if errors.Is(err, ErrPracticeNotFound) {
+		http.Error(w, "Practice not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating practice: %v", err)
+		http.Error(w, "Failed to update practice", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/practices?season_id="+url.QueryEscape(r.FormValue("season_id")), http.StatusSeeOther)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCalculateAttendance(t *testing.T) {
+	practices := []*Practice{
+		{ID: "before-registration", Date: "2025-09-02", StartTime: "15:30", EndTime: "16:30"},
+		{ID: "attended", Date: "2025-09-09", StartTime: "15:30", EndTime: "16:30"},
+		{ID: "cancelled", Date: "2025-09-11", StartTime: "15:30", EndTime: "16:30", Cancelled: true},
+		{ID: "missed-1", Date: "2025-09-16", StartTime: "15:30", EndTime: "16:30"},
+		{ID: "missed-2", Date: "2025-09-18", StartTime: "15:30", EndTime: "16:30"},
+		{ID: "upcoming", Date: "2025-09-30", StartTime: "15:30", EndTime: "16:30"},
+	}
+	attended := map[string]bool{"attended": true, "cancelled": true}
+	registeredAt := time.Date(2025, 9, 5, 12, 0, 0, 0, clubLocation())
+	now := time.Date(2025, 9, 20, 12, 0, 0, 0, clubLocation())
+
+	attendance := calculateAttendance(practices, attended, registeredAt, now)
+
+	if attendance.Total != 3 {
+		t.Errorf("Expected 3 practices to count, got %d", attendance.Total)
+	}
+	if attendance.Attended != 1 {
+		t.Errorf("Expected 1 practice attended, got %d", attendance.Attended)
+	}
+	if attendance.ConsecutiveMissed != 2 {
+		t.Errorf("Expected 2 practices missed in a row, got %d", attendance.ConsecutiveMissed)
+	}
+	if attendance.Rate < 33.3 || attendance.Rate > 33.4 {
+		t.Errorf("Expected attendance rate of 33.3%%, got %.1f", attendance.Rate)
+	}
+}
+
+func TestPracticeScans(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	activeSeason, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := createTestRegistration(t, db, activeSeason.ID)
+	absent := &Registration{
+		ID:                  uuid.New().String(),
+		SeasonID:            &activeSeason.ID,
+		FirstName:           "Absent",
+		LastName:            "Kid",
+		Grade:               "4",
+		ParentContactNumber: "555-1234",
+		ParentEmail:         "absent@example.com",
+		RegisteredAt:        time.Now(),
+	}
+	if err := db.SaveRegistration(absent, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	practiceStart := time.Date(2025, 9, 16, 15, 30, 0, 0, clubLocation())
+
+	// A scan recorded before the practice was scheduled gets attached when it is
+	early, _, err := db.RecordScanAt(reg.ID, nil, practiceStart.Add(-10*time.Minute), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if early.PracticeID != nil {
+		t.Fatal("Expected no practice for a scan before any practice is scheduled")
+	}
+
+	practice := &Practice{
+		ID:        uuid.New().String(),
+		SeasonID:  activeSeason.ID,
+		Date:      "2025-09-16",
+		StartTime: "15:30",
+		EndTime:   "16:30",
+		Location:  "School Field",
+		CreatedAt: time.Now(),
+	}
+	if err := db.SavePractice(practice); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, _, err := db.GetScan(early.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.PracticeID == nil || *saved.PracticeID != practice.ID {
+		t.Error("Expected existing scan to be attached to the new practice")
+	}
+
+	during, _, err := db.RecordScanAt(reg.ID, nil, practiceStart.Add(20*time.Minute), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during.PracticeID == nil || *during.PracticeID != practice.ID {
+		t.Error("Expected scan during practice to be attached to it")
+	}
+
+	after, _, err := db.RecordScanAt(reg.ID, nil, practiceStart.Add(3*time.Hour), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.PracticeID != nil {
+		t.Error("Expected scan long after practice not to be attached to it")
+	}
+
+	got, found, err := db.GetPractice(practice.ID)
+	if err != nil || !found {
+		t.Fatalf("Expected to find practice: %v", err)
+	}
+	if got.PresentCount != 1 {
+		t.Errorf("Expected 1 runner present, got %d", got.PresentCount)
+	}
+
+	roster, err := db.GetPracticeRoster(practice.ID, activeSeason.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roster) != 2 {
+		t.Fatalf("Expected 2 runners on roster, got %d", len(roster))
+	}
+	for _, attendee := range roster {
+		if attendee.RegistrationID == reg.ID && (!attendee.Present() || attendee.Laps != 2) {
+			t.Errorf("Expected runner to be present with 2 laps, got %d", attendee.Laps)
+		}
+		if attendee.RegistrationID == absent.ID && attendee.Present() {
+			t.Error("Expected other runner to be absent")
+		}
+	}
+
+	if err := db.SetPracticeCancelled(practice.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = db.GetPractice(practice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Cancelled {
+		t.Error("Expected practice to be cancelled")
+	}
+	if err := db.SetPracticeCancelled(uuid.New().String(), true); err != ErrPracticeNotFound {
+		t.Errorf("Expected ErrPracticeNotFound, got %v", err)
+	}
+
+	// Runners scanned while the practice was marked cancelled count once it's reinstated
+	late, _, err := db.RecordScanAt(absent.ID, nil, practiceStart.Add(40*time.Minute), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if late.PracticeID != nil {
+		t.Error("Expected no practice for a scan during a cancelled practice")
+	}
+	if err := db.SetPracticeCancelled(practice.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	roster, err = db.GetPracticeRoster(practice.ID, activeSeason.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, attendee := range roster {
+		if attendee.RegistrationID == absent.ID && (!attendee.Present() || attendee.Laps != 1) {
+			t.Errorf("Expected the runner scanned while it was cancelled to be present, got %d laps", attendee.Laps)
+		}
+	}
+	if saved, _, _ := db.GetScan(after.ID); saved.PracticeID != nil {
+		t.Error("Expected the scan long after practice to stay unattached")
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of custom registration question
+const (
+	QuestionKindText         = "text"
+	QuestionKindYesNo        = "yes_no"
+	QuestionKindSingleChoice = "single_choice"
+	QuestionKindMultiChoice  = "multi_choice"
+)
+
+// answerAny is the answer filter value that matches any answer to a question
+const answerAny = "*"
+
+// answerSeparator joins the options picked for a multi choice question
+const answerSeparator = "; "
+
+// SeasonQuestion is a custom question a season asks on its registration form
+type SeasonQuestion struct {
+	ID        string    `json:"id"`
+	SeasonID  string    `json:"seasonId"`
+	Label     string    `json:"label"`
+	Kind      string    `json:"kind"`
+	Options   []string  `json:"options,omitempty"` // The choices for single and multi choice questions
+	Required  bool      `json:"required"`
+	SortOrder int       `json:"sortOrder"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FieldName is the registration form field the question is answered in
+func (q *SeasonQuestion) FieldName() string {
+	return "q_" + q.ID
+}
+
+// KindLabel names the kind of question for the seasons page
+func (q *SeasonQuestion) KindLabel() string {
+	switch q.Kind {
+	case QuestionKindYesNo:
+		return "Yes/No"
+	case QuestionKindSingleChoice:
+		return "Single choice"
+	case QuestionKindMultiChoice:
+		return "Multiple choice"
+	}
+	return "Text"
+}
+
+// Choices lists the answers a parent can pick from, or nil for a text question
+func (q *SeasonQuestion) Choices() []string {
+	switch q.Kind {
+	case QuestionKindYesNo:
+		return []string{"Yes", "No"}
+	case QuestionKindSingleChoice, QuestionKindMultiChoice:
+		return q.Options
+	}
+	return nil
+}
+
+// isChoice reports whether value is one of the question's choices
+func (q *SeasonQuestion) isChoice(value string) bool {
+	for _, choice := range q.Choices() {
+		if choice == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AnswerFilterOption is one way to filter the runners list by a question
+type AnswerFilterOption struct {
+	Value string // The encoded AnswerFilter
+	Label string
+}
+
+// FilterOptions lists the ways the runners list can be filtered by the question
+func (q *SeasonQuestion) FilterOptions() []AnswerFilterOption {
+	var options []AnswerFilterOption
+	for _, choice := range q.Choices() {
+		filter := AnswerFilter{QuestionID: q.ID, Value: choice}
+		options = append(options, AnswerFilterOption{Value: filter.String(), Label: choice})
+	}
+	answered := AnswerFilter{QuestionID: q.ID, Value: answerAny}
+	unanswered := AnswerFilter{QuestionID: q.ID}
+	return append(options,
+		AnswerFilterOption{Value: answered.String(), Label: "Answered"},
+		AnswerFilterOption{Value: unanswered.String(), Label: "Not answered"},
+	)
+}
+
+// AnswerFilter narrows the runners list to the answers given to one question
+type AnswerFilter struct {
+	QuestionID string
+	Value      string // The answer to match, answerAny for any answer, or "" for no answer
+}
+
+// parseAnswerFilter reads an answer filter encoded as "questionID:value",
+// returning nil if there isn't one
+func parseAnswerFilter(s string) *AnswerFilter {
+	questionID, value, found := strings.Cut(s, ":")
+	if !found || questionID == "" {
+		return nil
+	}
+	return &AnswerFilter{QuestionID: questionID, Value: value}
+}
+
+// String encodes the filter for a query string
+func (f AnswerFilter) String() string {
+	return f.QuestionID + ":" + f.Value
+}
+
+// Matches reports whether a runner's answers pass the filter
+func (f *AnswerFilter) Matches(answers map[string][]string) bool {
+	values := answers[f.QuestionID]
+	switch f.Value {
+	case "":
+		return len(values) == 0
+	case answerAny:
+		return len(values) > 0
+	}
+	for _, value := range values {
+		if value == f.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Answer returns the registration's answer to a question, with multiple
+// choices joined together
+func (r *Registration) Answer(questionID string) string {
+	return strings.Join(r.Answers[questionID], answerSeparator)
+}
+
+// questionFromForm reads a new question from the seasons page. Choices are
+// entered one per line.
+func questionFromForm(r *http.Request) (*SeasonQuestion, error) {
+	q := &SeasonQuestion{
+		ID:        uuid.New().String(),
+		SeasonID:  r.FormValue("season_id"),
+		Label:     strings.TrimSpace(r.FormValue("label")),
+		Kind:      r.FormValue("kind"),
+		Required:  r.FormValue("required") == "true",
+		CreatedAt: time.Now(),
+	}
+	if q.Label == "" {
+		return nil, errRegistrationInput("Question text is required")
+	}
+
+	switch q.Kind {
+	case QuestionKindText, QuestionKindYesNo:
+	case QuestionKindSingleChoice, QuestionKindMultiChoice:
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(r.FormValue("options"), "\n") {
+			option := strings.TrimSpace(line)
+			if option == "" || seen[option] {
+				continue
+			}
+			seen[option] = true
+			q.Options = append(q.Options, option)
+		}
+		if len(q.Options) < 2 {
+			return nil, errRegistrationInput("Choice questions need at least two options")
+		}
+	default:
+		return nil, errRegistrationInput("Invalid question type")
+	}
+	return q, nil
+}
+
+// answersFromForm reads and checks the answers to a season's questions from
+// a registration form, keyed by question ID. Unanswered questions are left out.
+func answersFromForm(r *http.Request, questions []*SeasonQuestion) (map[string][]string, error) {
+	answers := make(map[string][]string)
+	for _, q := range questions {
+		var values []string
+		seen := make(map[string]bool)
+		for _, value := range r.Form[q.FieldName()] {
+			value = strings.TrimSpace(value)
+			if value == "" || seen[value] {
+				continue
+			}
+			seen[value] = true
+			values = append(values, value)
+		}
+
+		if len(values) == 0 {
+			if q.Required {
+				return nil, errRegistrationInput(fmt.Sprintf("Please answer %q", q.Label))
+			}
+			continue
+		}
+		if q.Kind != QuestionKindMultiChoice && len(values) > 1 {
+			return nil, errRegistrationInput(fmt.Sprintf("Please give one answer to %q", q.Label))
+		}
+		if q.Kind != QuestionKindText {
+			for _, value := range values {
+				if !q.isChoice(value) {
+					return nil, errRegistrationInput(fmt.Sprintf("Invalid answer to %q", q.Label))
+				}
+			}
+		}
+		answers[q.ID] = values
+	}
+	return answers, nil
+}
+
+// seasonsURL returns the seasons page, with an optional message to show
+func seasonsURL(message string) string {
+	if message == "" {
+		return "/seasons"
+	}
+	return "/seasons?message=" + url.QueryEscape(message)
+}
+
+// seasonQuestionsHandler adds a custom question to a season's registration
+// form, or deletes one along with its answers
+func seasonQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	var message string
+	switch r.FormValue("action") {
+	case "add":
+		q, err := questionFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, found, err := database.GetSeason(q.SeasonID)
+		if err != nil {
+			log.Printf("Error getting season: %v", err)
+			http.Error(w, "Failed to retrieve season", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Season not found", http.StatusNotFound)
+			return
+		}
+		if err := database.SaveSeasonQuestion(q, username); err != nil {
+			log.Printf("Error saving question: %v", err)
+			http.Error(w, "Failed to save question", http.StatusInternalServerError)
+			return
+		}
+		message = fmt.Sprintf("Added %q to the registration form", q.Label)
+	case "delete":
+		q, err := database.DeleteSeasonQuestion(r.FormValue("id"), username)
+		if errors.Is(err, ErrQuestionNotFound) {
+			http.Error(w, "Question not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting question: %v", err)
+			http.Error(w, "Failed to delete question", http.StatusInternalServerError)
+			return
+		}
+		message = fmt.Sprintf("Deleted %q and its answers", q.Label)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, seasonsURL(message), http.StatusSeeOther)
+}
+
+// exportQuestions lists the questions to add as columns to the runners
+// export, with their column headers. Exporting every season prefixes each
+// header with its season, since seasons ask different questions.
+func exportQuestions(seasonID string) ([]*SeasonQuestion, []string, error) {
+	var questions []*SeasonQuestion
+	var headers []string
+	if seasonID != "" {
+		questions, err := database.GetSeasonQuestions(seasonID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, q := range questions {
+			headers = append(headers, q.Label)
+		}
+		return questions, headers, nil
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		return nil, nil, err
+	}
+	bySeason, err := database.GetAllSeasonQuestions()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, season := range seasons {
+		for _, q := range bySeason[season.ID] {
+			questions = append(questions, q)
+			headers = append(headers, season.Name+": "+q.Label)
+		}
+	}
+	return questions, headers, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func addTestQuestion(t *testing.T, db *Database, seasonID, label, kind string, options ...string) *SeasonQuestion {
+	t.Helper()
+	q := &SeasonQuestion{
+		ID:        uuid.New().String(),
+		SeasonID:  seasonID,
+		Label:     label,
+		Kind:      kind,
+		Options:   options,
+		CreatedAt: time.Now(),
+	}
+	if err := db.SaveSeasonQuestion(q, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestAnswersFromForm(t *testing.T) {
+	shirt := &SeasonQuestion{ID: "shirt", Label: "Shirt color", Kind: QuestionKindSingleChoice, Options: []string{"Red", "Blue"}, Required: true}
+	days := &SeasonQuestion{ID: "days", Label: "Days", Kind: QuestionKindMultiChoice, Options: []string{"Mon", "Wed", "Fri"}}
+	photo := &SeasonQuestion{ID: "photo", Label: "Photo release", Kind: QuestionKindYesNo}
+	notes := &SeasonQuestion{ID: "notes", Label: "Notes", Kind: QuestionKindText}
+	questions := []*SeasonQuestion{shirt, days, photo, notes}
+
+	parse := func(form url.Values) (map[string][]string, error) {
+		r := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		return answersFromForm(r, questions)
+	}
+
+	answers, err := parse(url.Values{
+		"q_shirt": {"Blue"},
+		"q_days":  {"Mon", "Fri", "Mon"},
+		"q_photo": {"Yes"},
+		"q_notes": {"  "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(answers["days"], ","); got != "Mon,Fri" {
+		t.Errorf("Expected the picked days once each, got %q", got)
+	}
+	if answers["shirt"][0] != "Blue" || answers["photo"][0] != "Yes" {
+		t.Errorf("Expected single answers to be kept, got %v", answers)
+	}
+	if _, ok := answers["notes"]; ok {
+		t.Errorf("Expected a blank optional answer to be left out, got %v", answers["notes"])
+	}
+
+	for name, form := range map[string]url.Values{
+		"missing required":   {"q_days": {"Mon"}},
+		"unknown choice":     {"q_shirt": {"Green"}},
+		"two single answers": {"q_shirt": {"Red", "Blue"}},
+		"invalid yes/no":     {"q_shirt": {"Red"}, "q_photo": {"Maybe"}},
+	} {
+		if _, err := parse(form); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if _, ok := err.(errRegistrationInput); !ok {
+			t.Errorf("%s: expected an input error, got %v", name, err)
+		}
+	}
+}
+
+func TestSeasonQuestions(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shirt := addTestQuestion(t, db, season.ID, "Shirt color", QuestionKindSingleChoice, "Red", "Blue")
+	days := addTestQuestion(t, db, season.ID, "Days", QuestionKindMultiChoice, "Mon", "Wed", "Fri")
+	if shirt.SortOrder != 1 || days.SortOrder != 2 {
+		t.Errorf("Expected questions to be added in order, got %d and %d", shirt.SortOrder, days.SortOrder)
+	}
+
+	questions, err := db.GetSeasonQuestions(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 2 || questions[0].ID != shirt.ID || strings.Join(questions[1].Options, ",") != "Mon,Wed,Fri" {
+		t.Fatalf("Expected both questions with their options, got %+v", questions)
+	}
+
+	anna := newTestRegistration(season.ID, "Anna", "Doe", "anna@example.com")
+	anna.Answers = map[string][]string{shirt.ID: {"Red"}, days.ID: {"Mon", "Fri"}}
+	ben := newTestRegistration(season.ID, "Ben", "Doe", "ben@example.com")
+	ben.Answers = map[string][]string{shirt.ID: {"Blue"}, "deleted-question": {"Yes"}}
+	cara := newTestRegistration(season.ID, "Cara", "Doe", "cara@example.com")
+	for _, reg := range []*Registration{anna, ben, cara} {
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	answers, err := db.GetRegistrationAnswers(anna.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(answers[days.ID], ",") != "Mon,Fri" || answers[shirt.ID][0] != "Red" {
+		t.Errorf("Expected Anna's answers to be saved, got %v", answers)
+	}
+	answers, err = db.GetRegistrationAnswers(ben.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answers) != 1 {
+		t.Errorf("Expected the answer to an unknown question to be dropped, got %v", answers)
+	}
+
+	t.Run("Filter", func(t *testing.T) {
+		for filter, want := range map[string]string{
+			shirt.ID + ":Blue": "Ben",
+			days.ID + ":Fri":   "Anna",
+			days.ID + ":*":     "Anna",
+			days.ID + ":":      "Ben,Cara",
+		} {
+			regs, total, err := db.GetFilteredRegistrations(season.ID, "", parseAnswerFilter(filter), 1, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, reg := range regs {
+				names = append(names, reg.FirstName)
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, ","); got != want || total != len(names) {
+				t.Errorf("Filter %s: expected %s, got %s (total %d)", filter, want, got, total)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		deleted, err := db.DeleteSeasonQuestion(days.ID, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted.Label != "Days" {
+			t.Errorf("Expected the deleted question back, got %+v", deleted)
+		}
+
+		answers, err := db.GetSeasonAnswers(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := answers[anna.ID][days.ID]; ok {
+			t.Error("Expected the question's answers to be deleted")
+		}
+		if answers[anna.ID][shirt.ID][0] != "Red" {
+			t.Errorf("Expected other answers to be kept, got %v", answers[anna.ID])
+		}
+
+		if _, err := db.DeleteSeasonQuestion(days.ID, "admin"); err != ErrQuestionNotFound {
+			t.Errorf("Expected ErrQuestionNotFound deleting twice, got %v", err)
+		}
+	})
+}
+
+func TestRunnersExportIncludesAnswers(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := addTestQuestion(t, db, season.ID, "Days", QuestionKindMultiChoice, "Mon", "Wed", "Fri")
+	reg := newTestRegistration(season.ID, "Anna", "Doe", "anna@example.com")
+	reg.Answers = map[string][]string{days.ID: {"Mon", "Fri"}}
+	if err := db.SaveRegistration(reg, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, seasonID := range []string{season.ID, ""} {
+		req := httptest.NewRequest(http.MethodGet, "/runners/export?season_id="+seasonID, nil)
+		rr := httptest.NewRecorder()
+		runnersExportHandler(rr, req)
+
+		rows, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("Expected a header and one runner, got %v", rows)
+		}
+		header, row := rows[0][len(rows[0])-1], rows[1][len(rows[1])-1]
+		wantHeader := "Days"
+		if seasonID == "" {
+			wantHeader = "Test Season: Days"
+		}
+		if header != wantHeader || row != "Mon; Fri" {
+			t.Errorf("Season %q: expected %q column with \"Mon; Fri\", got %q and %q", seasonID, wantHeader, header, row)
+		}
+	}
+}
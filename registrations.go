@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errRegistrationInput is a validation error whose message is safe to show to the person registering
+type errRegistrationInput string
+
+func (e errRegistrationInput) Error() string {
+	return string(e)
+}
+
+// registrationFromForm reads the runner and parent details from a registration form
+func registrationFromForm(r *http.Request) *Registration {
+	return &Registration{
+		FirstName:            strings.TrimSpace(r.FormValue("firstName")),
+		LastName:             strings.TrimSpace(r.FormValue("lastName")),
+		Grade:                r.FormValue("grade"),
+		Teacher:              r.FormValue("teacher"),
+		Gender:               r.FormValue("gender"),
+		TshirtSize:           r.FormValue("tshirtSize"),
+		ParentFirstName:      r.FormValue("parentFirstName"),
+		ParentLastName:       r.FormValue("parentLastName"),
+		ParentContactNumber:  r.FormValue("parentContactNumber"),
+		BackupContactNumber:  r.FormValue("backupContactNumber"),
+		ParentEmail:          r.FormValue("parentEmail"),
+		DismissalMethod:      r.FormValue("dismissalMethod"),
+		Allergies:            r.FormValue("allergies"),
+		MedicalInfo:          r.FormValue("medicalInfo"),
+		RegisterForSpring:    r.FormValue("registerForSpring") == "true",
+		OptOutWebsiteDisplay: r.FormValue("optOutWebsiteDisplay") == "true",
+		OptOutPhotoSharing:   r.FormValue("optOutPhotoSharing") == "true",
+	}
+}
+
+// validateRegistration checks the rules every registration has to meet, whether
+// it's new or being edited
+func validateRegistration(reg *Registration) error {
+	if reg.FirstName == "" || reg.LastName == "" {
+		return errRegistrationInput("Runner first and last name are required")
+	}
+	if !validatePhoneNumber(reg.ParentContactNumber) {
+		return errRegistrationInput("Invalid parent/guardian contact number. Please use format: 123-456-7890")
+	}
+	if reg.BackupContactNumber != "" && !validatePhoneNumber(reg.BackupContactNumber) {
+		return errRegistrationInput("Invalid backup contact number. Please use format: 123-456-7890")
+	}
+	return nil
+}
+
+// applyRegistrationEdit copies the editable details onto an existing registration
+// and validates the result. The season, runner and registration time can't be edited.
+func applyRegistrationEdit(reg, edit *Registration) error {
+	reg.FirstName = strings.TrimSpace(edit.FirstName)
+	reg.LastName = strings.TrimSpace(edit.LastName)
+	reg.Grade = edit.Grade
+	reg.Teacher = edit.Teacher
+	reg.Gender = edit.Gender
+	reg.TshirtSize = edit.TshirtSize
+	reg.ParentFirstName = edit.ParentFirstName
+	reg.ParentLastName = edit.ParentLastName
+	reg.ParentContactNumber = edit.ParentContactNumber
+	reg.BackupContactNumber = edit.BackupContactNumber
+	reg.ParentEmail = edit.ParentEmail
+	reg.DismissalMethod = edit.DismissalMethod
+	reg.Allergies = edit.Allergies
+	reg.MedicalInfo = edit.MedicalInfo
+	reg.RegisterForSpring = edit.RegisterForSpring
+	reg.OptOutWebsiteDisplay = edit.OptOutWebsiteDisplay
+	reg.OptOutPhotoSharing = edit.OptOutPhotoSharing
+	return validateRegistration(reg)
+}
+
+// runnerDetailURL returns the runner detail page, with an optional message to show
+func runnerDetailURL(id, message string) string {
+	u := "/runner/" + url.PathEscape(id)
+	if message != "" {
+		u += "?message=" + url.QueryEscape(message)
+	}
+	return u
+}
+
+// registrationUpdateHandler saves an admin's edits from the runner detail page
+func registrationUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	reg, found, err := database.GetRegistration(r.FormValue("id"))
+	if err != nil {
+		log.Printf("Error getting registration: %v", err)
+		http.Error(w, "Failed to retrieve registration", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Registration not found", http.StatusNotFound)
+		return
+	}
+
+	if err := applyRegistrationEdit(reg, registrationFromForm(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := database.UpdateRegistration(reg, username); err != nil {
+		log.Printf("Error updating registration: %v", err)
+		http.Error(w, "Failed to save registration", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, runnerDetailURL(reg.ID, "Registration updated"), http.StatusSeeOther)
+}
+
+// registrationWithdrawHandler withdraws a runner from their season, or
+// reinstates one who was withdrawn
+func registrationWithdrawHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Registration ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var message string
+	switch r.FormValue("action") {
+	case "withdraw":
+		err = database.WithdrawRegistration(id, username, strings.TrimSpace(r.FormValue("reason")))
+		message = "Runner withdrawn"
+	case "reinstate":
+		err = database.ReinstateRegistration(id, username)
+		message = "Runner reinstated"
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrRegistrationNotFound):
+		http.Error(w, "Registration not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrRegistrationWithdrawn):
+		http.Error(w, "Runner has already withdrawn", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error updating registration status: %v", err)
+		http.Error(w, "Failed to update registration", http.StatusInternalServerError)
+		return
+	}
+
+	// A withdrawal frees a spot, so point the admin at whoever is next in line
+	if r.FormValue("action") == "withdraw" {
+		if reg, found, err := database.GetRegistration(id); err == nil && found && reg.SeasonID != nil {
+			if next := nextOnWaitlistMessage(*reg.SeasonID); next != "" {
+				message += ". " + next
+			}
+		}
+	}
+
+	http.Redirect(w, r, runnerDetailURL(id, message), http.StatusSeeOther)
+}
+
+// apiRegistrationHandler returns or updates a single registration
+func apiRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/registrations/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid registration ID", http.StatusBadRequest)
+		return
+	}
+
+	reg, found, err := database.GetRegistration(id)
+	if err != nil {
+		log.Printf("Error retrieving registration: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		sendJSONResponse(w, map[string]string{"error": "Registration not found"}, http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		session, _ := store.Get(r, "run-club-session")
+		username, _ := session.Values["username"].(string)
+
+		var edit Registration
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			sendJSONResponse(w, map[string]string{"error": "Invalid request format"}, http.StatusBadRequest)
+			return
+		}
+		if err := applyRegistrationEdit(reg, &edit); err != nil {
+			sendJSONResponse(w, map[string]string{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := database.UpdateRegistration(reg, username); err != nil {
+			log.Printf("Error updating registration: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	sendJSONResponse(w, reg, http.StatusOK)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		reg     Registration
+		wantErr bool
+	}{
+		{"Valid", Registration{FirstName: "Jane", LastName: "Doe", ParentContactNumber: "555-555-1234"}, false},
+		{"Valid With Backup", Registration{FirstName: "Jane", LastName: "Doe", ParentContactNumber: "555-555-1234", BackupContactNumber: "555-555-5678"}, false},
+		{"Missing Name", Registration{FirstName: "Jane", ParentContactNumber: "555-555-1234"}, true},
+		{"Bad Parent Phone", Registration{FirstName: "Jane", LastName: "Doe", ParentContactNumber: "5555551234"}, true},
+		{"Bad Backup Phone", Registration{FirstName: "Jane", LastName: "Doe", ParentContactNumber: "555-555-1234", BackupContactNumber: "555-5678"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegistration(&tt.reg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRegistration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithdrawRegistration(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := createTestRegistration(t, db, season.ID)
+	other := createTestRegistration(t, db, season.ID)
+
+	if _, _, err := db.RecordScanAt(reg.ID, nil, time.Now().Add(-time.Hour), ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.WithdrawRegistration(reg.ID, "admin", "Moved away"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Left Off Rosters And Capacity", func(t *testing.T) {
+		count, err := db.GetRegistrationCountForSeason(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 registration counted, got %d", count)
+		}
+
+		all, err := db.GetAllRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 1 || all[0].ID != other.ID {
+			t.Errorf("Expected only the other runner on the roster, got %d runners", len(all))
+		}
+
+		filtered, total, err := db.GetFilteredRegistrations(season.ID, "", nil, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(filtered) != 1 {
+			t.Errorf("Expected 1 runner for badges, got %d (total %d)", len(filtered), total)
+		}
+
+		withdrawn, err := db.GetWithdrawnRegistrations(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(withdrawn) != 1 || withdrawn[0].WithdrawReason != "Moved away" {
+			t.Errorf("Expected the withdrawn runner to be listed, got %+v", withdrawn)
+		}
+	})
+
+	t.Run("Keeps Scan History", func(t *testing.T) {
+		scans, err := db.GetScanHistory(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scans) != 1 {
+			t.Errorf("Expected 1 scan kept, got %d", len(scans))
+		}
+
+		got, _, err := db.GetRegistration(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.WithdrawnAt == nil || got.WithdrawnBy != "admin" {
+			t.Errorf("Expected registration to be marked withdrawn by admin, got %+v", got)
+		}
+	})
+
+	t.Run("Badge No Longer Scans", func(t *testing.T) {
+		_, _, err := db.RecordScanAt(reg.ID, nil, time.Now(), ScanOptions{})
+		if !errors.Is(err, ErrRegistrationWithdrawn) {
+			t.Errorf("Expected ErrRegistrationWithdrawn, got %v", err)
+		}
+	})
+
+	t.Run("Withdraw Twice", func(t *testing.T) {
+		if err := db.WithdrawRegistration(reg.ID, "admin", ""); !errors.Is(err, ErrRegistrationWithdrawn) {
+			t.Errorf("Expected ErrRegistrationWithdrawn, got %v", err)
+		}
+	})
+
+	t.Run("Reinstate", func(t *testing.T) {
+		if err := db.ReinstateRegistration(reg.ID, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		count, err := db.GetRegistrationCountForSeason(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("Expected 2 registrations counted after reinstating, got %d", count)
+		}
+	})
+}
+
+func TestAPIRegistrationHandlerPut(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := createTestRegistration(t, db, season.ID)
+
+	put := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPut, "/api/registrations/"+reg.ID, bytes.NewBuffer(bodyBytes))
+		rr := httptest.NewRecorder()
+		apiRegistrationHandler(rr, req)
+		return rr
+	}
+
+	t.Run("Invalid Phone", func(t *testing.T) {
+		rr := put(map[string]interface{}{"firstName": "Jane", "lastName": "Doe", "parentContactNumber": "555-1234"})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Valid Edit", func(t *testing.T) {
+		rr := put(map[string]interface{}{
+			"firstName":           "Jane",
+			"lastName":            "Doe",
+			"grade":               "4",
+			"parentContactNumber": "555-555-1234",
+			"seasonId":            "some-other-season",
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		got, _, err := db.GetRegistration(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FirstName != "Jane" || got.Grade != "4" || got.ParentContactNumber != "555-555-1234" {
+			t.Errorf("Expected edits to be saved, got %+v", got)
+		}
+		if got.SeasonID == nil || *got.SeasonID != season.ID {
+			t.Errorf("Expected season to stay %s", season.ID)
+		}
+	})
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RosterStudent is a student in a OneRoster export, and what importing them would do
+type RosterStudent struct {
+	SourcedID       string // The student's ID in the school's SIS
+	FirstName       string
+	LastName        string
+	Grade           string // As run club writes it, or "" if run club doesn't have the student's grade
+	RosterGrade     string // As the roster writes it, like "KG" or "03"
+	Gender          string
+	Teacher         string // The student's homeroom teacher, or the teacher of their first class
+	ParentFirstName string
+	ParentLastName  string
+	ParentPhone     string
+	ParentEmail     string
+
+	Registration *Registration // Their registration this season, if they have one
+	Changes      []*CSVChange  // What importing them changes on their registration
+	Problem      string        // Why they can't be imported
+
+	pending *Registration // The registration importing them saves
+}
+
+// CanImport reports whether importing the student would add or change a registration
+func (s *RosterStudent) CanImport() bool {
+	return s.Problem == "" && (s.Registration == nil || len(s.Changes) > 0)
+}
+
+// RosterImport is an uploaded OneRoster export and its students
+type RosterImport struct {
+	Data     string // The zip file, base64 encoded, carried from picking students to the import
+	Students []*RosterStudent
+}
+
+// The files of a OneRoster 1.1 CSV export the import reads
+const (
+	rosterUsers        = "users.csv"
+	rosterClasses      = "classes.csv"
+	rosterEnrollments  = "enrollments.csv"
+	rosterDemographics = "demographics.csv"
+)
+
+// readRosterFile reads one of the CSV files in a OneRoster export into rows
+// keyed by column name. Rows marked to be deleted are left out, and a
+// missing file reads as empty unless it's required.
+func readRosterFile(files map[string]*zip.File, name string, required bool) ([]map[string]string, error) {
+	f, ok := files[name]
+	if !ok {
+		if required {
+			return nil, errRegistrationInput(fmt.Sprintf("The roster doesn't have a %s file", name))
+		}
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errRegistrationInput(fmt.Sprintf("Couldn't open %s: %v", name, err))
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errRegistrationInput(fmt.Sprintf("Couldn't read %s: %v", name, err))
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errRegistrationInput(fmt.Sprintf("Couldn't read %s: %v", name, err))
+		}
+		row := make(map[string]string)
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		if strings.EqualFold(row["status"], "tobedeleted") {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// rosterList splits a OneRoster list field, like a user's grades or agents
+func rosterList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// rosterGrade converts a OneRoster grade, like "KG" or "03", to the way run
+// club writes it. It returns "" for grades run club doesn't have.
+func rosterGrade(grade string) string {
+	grade = strings.ToUpper(strings.TrimSpace(grade))
+	if grade == "KG" {
+		return "K"
+	}
+	grade = strings.TrimPrefix(grade, "0")
+	if slices.Contains(csvGrades, grade) {
+		return grade
+	}
+	return ""
+}
+
+// parseOneRoster reads the students in a OneRoster 1.1 CSV export. Their
+// teacher is the teacher of their homeroom class, or of the first class
+// they're enrolled in; their parent is the first guardian listed for them,
+// preferring one with a phone number.
+func parseOneRoster(data []byte) ([]*RosterStudent, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errRegistrationInput("The roster isn't a zip file")
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[strings.ToLower(path.Base(f.Name))] = f
+	}
+
+	users, err := readRosterFile(files, rosterUsers, true)
+	if err != nil {
+		return nil, err
+	}
+	classes, err := readRosterFile(files, rosterClasses, false)
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := readRosterFile(files, rosterEnrollments, false)
+	if err != nil {
+		return nil, err
+	}
+	demographics, err := readRosterFile(files, rosterDemographics, false)
+	if err != nil {
+		return nil, err
+	}
+
+	usersByID := make(map[string]map[string]string)
+	for _, u := range users {
+		usersByID[u["sourcedId"]] = u
+	}
+
+	// Each class's teachers, primary teachers first, and each student's classes
+	teachers := make(map[string][]string)
+	studentClasses := make(map[string][]map[string]string)
+	classesByID := make(map[string]map[string]string)
+	for _, c := range classes {
+		classesByID[c["sourcedId"]] = c
+	}
+	for _, e := range enrollments {
+		class := classesByID[e["classSourcedId"]]
+		switch strings.ToLower(e["role"]) {
+		case "teacher":
+			u := usersByID[e["userSourcedId"]]
+			if u == nil {
+				continue
+			}
+			name := strings.TrimSpace(u["givenName"] + " " + u["familyName"])
+			if strings.EqualFold(e["primary"], "true") {
+				teachers[e["classSourcedId"]] = append([]string{name}, teachers[e["classSourcedId"]]...)
+			} else {
+				teachers[e["classSourcedId"]] = append(teachers[e["classSourcedId"]], name)
+			}
+		case "student":
+			if class != nil {
+				studentClasses[e["userSourcedId"]] = append(studentClasses[e["userSourcedId"]], class)
+			}
+		}
+	}
+
+	genders := make(map[string]string)
+	for _, d := range demographics {
+		switch strings.ToLower(d["sex"]) {
+		case "male":
+			genders[d["sourcedId"]] = "Male"
+		case "female":
+			genders[d["sourcedId"]] = "Female"
+		}
+	}
+
+	var students []*RosterStudent
+	for _, u := range users {
+		if !strings.EqualFold(u["role"], "student") {
+			continue
+		}
+		s := &RosterStudent{
+			SourcedID: u["sourcedId"],
+			FirstName: u["givenName"],
+			LastName:  u["familyName"],
+			Gender:    genders[u["sourcedId"]],
+		}
+		if grades := rosterList(u["grades"]); len(grades) > 0 {
+			s.RosterGrade = grades[0]
+			s.Grade = rosterGrade(grades[0])
+		}
+
+		enrolled := studentClasses[s.SourcedID]
+		sort.SliceStable(enrolled, func(i, j int) bool {
+			iHomeroom := strings.EqualFold(enrolled[i]["classType"], "homeroom")
+			jHomeroom := strings.EqualFold(enrolled[j]["classType"], "homeroom")
+			if iHomeroom != jHomeroom {
+				return iHomeroom
+			}
+			return enrolled[i]["title"] < enrolled[j]["title"]
+		})
+		for _, c := range enrolled {
+			if names := teachers[c["sourcedId"]]; len(names) > 0 {
+				s.Teacher = names[0]
+				break
+			}
+		}
+
+		for _, agentID := range rosterList(u["agentSourcedIds"]) {
+			agent := usersByID[agentID]
+			if agent == nil {
+				continue
+			}
+			phone := agent["phone"]
+			if phone == "" {
+				phone = agent["sms"]
+			}
+			if s.ParentFirstName == "" || (s.ParentPhone == "" && phone != "") {
+				s.ParentFirstName = agent["givenName"]
+				s.ParentLastName = agent["familyName"]
+				s.ParentPhone = phone
+				s.ParentEmail = agent["email"]
+			}
+		}
+		students = append(students, s)
+	}
+
+	gradeOrder := func(grade string) int {
+		if i := slices.Index(csvGrades, grade); i >= 0 {
+			return i
+		}
+		return len(csvGrades)
+	}
+	sort.SliceStable(students, func(i, j int) bool {
+		a, b := students[i], students[j]
+		if gradeOrder(a.Grade) != gradeOrder(b.Grade) {
+			return gradeOrder(a.Grade) < gradeOrder(b.Grade)
+		}
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		return a.FirstName < b.FirstName
+	})
+	return students, nil
+}
+
+// matchRoster works out what importing each student into a season would
+// do. A student already registered, found by their SIS ID or else by name,
+// has their registration linked to the SIS and their grade and teacher
+// corrected; anyone else is registered with the roster's details.
+func matchRoster(students []*RosterStudent, season *Season, existing []*Registration) {
+	now := time.Now()
+	for _, s := range students {
+		s.Registration, s.Changes, s.Problem, s.pending = nil, nil, "", nil
+
+		var byName []*Registration
+		for _, reg := range existing {
+			if reg.ExternalID != "" && reg.ExternalID == s.SourcedID {
+				s.Registration = reg
+				break
+			}
+			if reg.ExternalID == "" && normalizeName(reg.FirstName) == normalizeName(s.FirstName) && normalizeName(reg.LastName) == normalizeName(s.LastName) {
+				byName = append(byName, reg)
+			}
+		}
+		if s.Registration == nil && len(byName) > 1 {
+			s.Problem = fmt.Sprintf("More than one %s %s is registered; correct them from their runner pages", s.FirstName, s.LastName)
+			continue
+		}
+		if s.Registration == nil && len(byName) == 1 {
+			s.Registration = byName[0]
+		}
+
+		if reg := s.Registration; reg != nil {
+			updated := *reg
+			updated.ExternalID = s.SourcedID
+			if s.Grade != "" {
+				updated.Grade = s.Grade
+			}
+			if s.Teacher != "" {
+				updated.Teacher = s.Teacher
+			}
+			s.Changes = csvChanges(reg, &updated)
+			if reg.ExternalID != updated.ExternalID {
+				s.Changes = append(s.Changes, &CSVChange{Label: "SIS ID", Old: reg.ExternalID, New: updated.ExternalID})
+			}
+			s.pending = &updated
+			continue
+		}
+
+		if s.Grade == "" {
+			s.Problem = fmt.Sprintf("Run club doesn't have grade %q", s.RosterGrade)
+			continue
+		}
+		reg := &Registration{
+			ID:                  uuid.New().String(),
+			SeasonID:            &season.ID,
+			ExternalID:          s.SourcedID,
+			FirstName:           s.FirstName,
+			LastName:            s.LastName,
+			Grade:               s.Grade,
+			Teacher:             s.Teacher,
+			Gender:              s.Gender,
+			ParentFirstName:     s.ParentFirstName,
+			ParentLastName:      s.ParentLastName,
+			ParentContactNumber: formatPhoneNumber(s.ParentPhone),
+			ParentEmail:         s.ParentEmail,
+			RegisteredAt:        now,
+			Season:              season,
+		}
+		if err := validateRegistration(reg); err != nil {
+			s.Problem = "The roster doesn't have a usable parent phone number; register them from the registration form instead"
+			continue
+		}
+		s.pending = reg
+	}
+}
+
+// importRoster saves the students picked from a roster, all of them or
+// none: new runners are registered, and registered runners are linked to
+// the SIS and get the roster's grade and teacher. It returns how many were
+// registered and updated.
+func importRoster(students []*RosterStudent, picked []string, season *Season, actor string) (added, updated int, err error) {
+	byID := make(map[string]*RosterStudent)
+	for _, s := range students {
+		byID[s.SourcedID] = s
+	}
+
+	capacity, err := database.GetSeasonCapacity(season)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check registration capacity: %w", err)
+	}
+
+	var regs, updates []*Registration
+	for _, id := range picked {
+		s := byID[id]
+		if s == nil {
+			return 0, 0, errRegistrationInput(fmt.Sprintf("Student %s isn't in the roster", id))
+		}
+		if s.Problem != "" {
+			return 0, 0, errRegistrationInput(fmt.Sprintf("%s %s: %s", s.FirstName, s.LastName, s.Problem))
+		}
+		if !s.CanImport() {
+			continue
+		}
+
+		// Registration closing only stops new runners, not corrections
+		grade := s.pending.Grade
+		if s.Registration == nil && season.IsRegistrationClosed() {
+			return 0, 0, registrationClosedError(season)
+		}
+		if s.Registration == nil || (grade != s.Registration.Grade && s.Registration.WithdrawnAt == nil) {
+			if err := capacity.Check(grade); err != nil {
+				return 0, 0, errRegistrationInput(fmt.Sprintf("%s %s: %v", s.FirstName, s.LastName, err))
+			}
+			capacity.Add(grade)
+		}
+
+		if s.Registration == nil {
+			regs = append(regs, s.pending)
+		} else {
+			updates = append(updates, s.pending)
+		}
+	}
+	if len(regs)+len(updates) == 0 {
+		return 0, 0, errRegistrationInput("Pick the students to import")
+	}
+
+	if err := database.ImportRegistrations(regs, updates, actor); err != nil {
+		return 0, 0, err
+	}
+	return len(regs), len(updates), nil
+}
+
+// rosterUploadData returns the roster zip: a newly uploaded file, or the
+// one carried over from picking students
+func rosterUploadData(r *http.Request) ([]byte, error) {
+	file, _, err := r.FormFile("roster-file")
+	if err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, errRegistrationInput("Error reading uploaded file")
+		}
+		return data, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(r.FormValue("roster-data"))
+	if err != nil || len(data) == 0 {
+		return nil, errRegistrationInput("Error retrieving file from form")
+	}
+	return data, nil
+}
+
+// rosterImportHandler registers runners from a OneRoster export of the
+// school's SIS. The admin uploads the export's zip file, picks the students
+// who joined run club, and imports them with the roster's grade and teacher.
+func rosterImportHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	activeSeason, hasActiveSeason, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+	}
+
+	data := PageData{
+		Title: "Run Club - Import Roster",
+		User:  username,
+		Role:  role,
+	}
+	if hasActiveSeason {
+		data.ActiveSeason = activeSeason
+	}
+
+	if r.Method == http.MethodGet {
+		renderTemplate(w, "roster_import", data)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hasActiveSeason {
+		data.Message = "Cannot register runners without an active season"
+		renderTemplate(w, "roster_import", data)
+		return
+	}
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // Max 10MB
+		data.Message = "Error parsing form"
+		renderTemplate(w, "roster_import", data)
+		return
+	}
+
+	zipData, err := rosterUploadData(r)
+	if err == nil {
+		var students []*RosterStudent
+		students, err = parseOneRoster(zipData)
+		data.Roster = &RosterImport{Data: base64.StdEncoding.EncodeToString(zipData), Students: students}
+	}
+	var existing []*Registration
+	if err == nil {
+		existing, err = database.GetAllRegistrations(activeSeason.ID)
+	}
+	if err == nil {
+		matchRoster(data.Roster.Students, activeSeason, existing)
+		data.Message = fmt.Sprintf("%d students in the roster. Pick the ones who joined run club.", len(data.Roster.Students))
+	}
+
+	if err == nil && r.FormValue("action") == "import" {
+		var added, updated int
+		added, updated, err = importRoster(data.Roster.Students, r.Form["student"], activeSeason, username)
+		if err == nil {
+			data.Success = true
+			data.Message = fmt.Sprintf("Registered %d students and updated %d registrations", added, updated)
+
+			// Show everyone as they are now
+			if existing, err := database.GetAllRegistrations(activeSeason.ID); err != nil {
+				log.Printf("Error getting registrations: %v", err)
+			} else {
+				matchRoster(data.Roster.Students, activeSeason, existing)
+			}
+		}
+	}
+
+	var inputErr errRegistrationInput
+	switch {
+	case errors.As(err, &inputErr):
+		data.Success = false
+		data.Message = err.Error()
+	case err != nil:
+		log.Printf("Error importing roster: %v", err)
+		data.Success = false
+		data.Message = "Failed to import the roster. Nothing was imported."
+	}
+
+	renderTemplate(w, "roster_import", data)
+}
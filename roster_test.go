@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testRosterZip builds a OneRoster export from the contents of its files
+func testRosterZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create("roster/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var testRoster = map[string]string{
+	"users.csv": "\ufeffsourcedId,status,dateLastModified,enabledUser,orgSourcedIds,role,username,userIds,givenName,familyName,middleName,identifier,email,sms,phone,agentSourcedIds,grades,password\n" +
+		"s1,active,,true,sch,student,anna,,Anna,Doe,,1001,,,,\"p1,p2\",03,\n" +
+		"s2,active,,true,sch,student,ben,,Ben,Roe,,1002,,,,p3,KG,\n" +
+		"s3,active,,true,sch,student,cara,,Cara,Lee,,1003,,,,,07,\n" +
+		"s4,tobedeleted,,true,sch,student,gone,,Gone,Away,,1004,,,,,02,\n" +
+		"s5,active,,true,sch,student,dev,,Dev,Poe,,1005,,,,,01,\n" +
+		"t1,active,,true,sch,teacher,smith,,Jane,Smith,,,,,,,,\n" +
+		"t2,active,,true,sch,teacher,jones,,Tom,Jones,,,,,,,,\n" +
+		"p1,active,,true,sch,guardian,,,Sam,Doe,,,sam@example.com,,,,,\n" +
+		"p2,active,,true,sch,guardian,,,Pat,Doe,,,pat@example.com,,(555) 555-1234,,,\n" +
+		"p3,active,,true,sch,parent,,,Lou,Roe,,,lou@example.com,555-555-9876,,,,\n",
+	"classes.csv": "sourcedId,status,dateLastModified,title,grades,courseSourcedId,classCode,classType,location,schoolSourcedId,termSourcedIds,subjects,subjectCodes,periods\n" +
+		"art,active,,Art,03,,,scheduled,,sch,,,,\n" +
+		"hr3,active,,Homeroom 3,03,,,homeroom,,sch,,,,\n" +
+		"hrk,active,,Homeroom K,KG,,,homeroom,,sch,,,,\n",
+	"enrollments.csv": "sourcedId,status,dateLastModified,classSourcedId,schoolSourcedId,userSourcedId,role,primary,beginDate,endDate\n" +
+		"e1,active,,art,sch,s1,student,false,,\n" +
+		"e2,active,,hr3,sch,s1,student,false,,\n" +
+		"e3,active,,art,sch,t2,teacher,true,,\n" +
+		"e4,active,,hr3,sch,t1,teacher,true,,\n" +
+		"e5,active,,hrk,sch,s2,student,false,,\n" +
+		"e6,active,,hrk,sch,t2,teacher,true,,\n",
+	"demographics.csv": "sourcedId,status,dateLastModified,birthDate,sex\n" +
+		"s1,active,,,female\n" +
+		"s2,active,,,male\n",
+}
+
+func TestParseOneRoster(t *testing.T) {
+	students, err := parseOneRoster(testRosterZip(t, testRoster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 4 {
+		t.Fatalf("Expected 4 students, got %d", len(students))
+	}
+
+	// Sorted by grade, with grades run club doesn't have last
+	for i, want := range []string{"Ben", "Dev", "Anna", "Cara"} {
+		if students[i].FirstName != want {
+			t.Errorf("Expected %s at %d, got %s", want, i, students[i].FirstName)
+		}
+	}
+
+	anna := students[2]
+	if anna.SourcedID != "s1" || anna.Grade != "3" || anna.Teacher != "Jane Smith" || anna.Gender != "Female" {
+		t.Errorf("Unexpected student %+v", anna)
+	}
+	if anna.ParentFirstName != "Pat" || anna.ParentPhone != "(555) 555-1234" || anna.ParentEmail != "pat@example.com" {
+		t.Errorf("Expected the guardian with a phone number, got %+v", anna)
+	}
+	if ben := students[0]; ben.Grade != "K" || ben.Teacher != "Tom Jones" || ben.ParentPhone != "555-555-9876" {
+		t.Errorf("Unexpected student %+v", ben)
+	}
+	if cara := students[3]; cara.Grade != "" || cara.RosterGrade != "07" {
+		t.Errorf("Expected Cara's grade not to be one run club has, got %+v", cara)
+	}
+
+	if _, err := parseOneRoster([]byte("not a zip")); err == nil {
+		t.Error("Expected a file that isn't a zip to be rejected")
+	}
+	if _, err := parseOneRoster(testRosterZip(t, map[string]string{"classes.csv": "sourcedId\n"})); err == nil || !strings.Contains(err.Error(), "users.csv") {
+		t.Errorf("Expected a roster without users.csv to be rejected, got %v", err)
+	}
+}
+
+func TestImportRoster(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Anna registered herself, with her teacher's name misspelled
+	registered := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+	registered.Teacher = "Mrs Smth"
+	registered.ParentContactNumber = "555-555-1234"
+	if err := db.SaveRegistration(registered, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	students, err := parseOneRoster(testRosterZip(t, testRoster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchRoster(students, season, existing)
+
+	byName := make(map[string]*RosterStudent)
+	for _, s := range students {
+		byName[s.FirstName] = s
+	}
+	if anna := byName["Anna"]; anna.Registration == nil || anna.Registration.ID != registered.ID || len(anna.Changes) != 2 {
+		t.Errorf("Expected Anna's registration to get her teacher and SIS ID, got %+v", anna)
+	}
+	if !byName["Ben"].CanImport() || byName["Ben"].Registration != nil {
+		t.Errorf("Expected Ben to be a new runner, got %+v", byName["Ben"])
+	}
+	if byName["Cara"].Problem == "" || byName["Dev"].Problem == "" {
+		t.Error("Expected Cara's grade and Dev's missing parent phone to stop them being imported")
+	}
+
+	if _, _, err := importRoster(students, []string{"s1", "s2", "s3"}, season, "admin"); err == nil || !strings.Contains(err.Error(), "Cara Lee") {
+		t.Errorf("Expected Cara to stop the import, got %v", err)
+	}
+	if saved, _, _ := db.GetRegistration(registered.ID); saved.Teacher != "Mrs Smth" {
+		t.Error("Expected nothing to be imported")
+	}
+
+	added, updated, err := importRoster(students, []string{"s1", "s2"}, season, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || updated != 1 {
+		t.Errorf("Expected 1 added and 1 updated, got %d and %d", added, updated)
+	}
+
+	saved, _, err := db.GetRegistration(registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Teacher != "Jane Smith" || saved.ExternalID != "s1" || saved.ParentEmail != "pat@example.com" {
+		t.Errorf("Expected Anna's teacher and SIS ID from the roster, got %+v", saved)
+	}
+	regs, err := db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ben *Registration
+	for _, reg := range regs {
+		if reg.ExternalID == "s2" {
+			ben = reg
+		}
+	}
+	if ben == nil || ben.Grade != "K" || ben.Teacher != "Tom Jones" || ben.ParentContactNumber != "555-555-9876" || ben.Gender != "Male" {
+		t.Fatalf("Expected Ben to be registered from the roster, got %+v", ben)
+	}
+
+	// The next roster finds them by their SIS ID, even after a name change
+	ben.FirstName = "Benjamin"
+	if err := db.UpdateRegistration(ben, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	existing, err = db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchRoster(students, season, existing)
+	for _, name := range []string{"Anna", "Ben"} {
+		if s := byName[name]; s.Registration == nil || s.CanImport() {
+			t.Errorf("Expected %s to be registered with nothing to change, got %+v", name, s)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Runner match resolutions
+const (
+	RunnerMatchMerged    = "merged"
+	RunnerMatchDifferent = "different"
+)
+
+// runnerMatchReasonSameName explains why two runners were flagged for review
+const runnerMatchReasonSameName = "Same name, different parent email"
+
+// RunnerSeasonSummary is a runner's totals for one season
+type RunnerSeasonSummary struct {
+	RegistrationID string
+	SeasonID       string
+	SeasonName     string
+	Grade          string
+	Teacher        string
+	TotalRuns      int
+	TotalDistance  float64
+}
+
+// RunnerLifetime is a runner's history across every season they've registered for
+type RunnerLifetime struct {
+	Seasons       []*RunnerSeasonSummary
+	TotalRuns     int
+	TotalDistance float64
+}
+
+// newRunnerLifetime totals a runner's seasons
+func newRunnerLifetime(seasons []*RunnerSeasonSummary) *RunnerLifetime {
+	lifetime := &RunnerLifetime{Seasons: seasons}
+	for _, s := range seasons {
+		lifetime.TotalRuns += s.TotalRuns
+		lifetime.TotalDistance += s.TotalDistance
+	}
+	return lifetime
+}
+
+// RunnerMatch is a possible match between two runners that an admin needs to review
+type RunnerMatch struct {
+	ID                string
+	RunnerID          string
+	CandidateRunnerID string
+	Reason            string
+	CreatedAt         time.Time
+	Runner            []*Registration // Registrations of each runner, to compare
+	Candidate         []*Registration
+}
+
+// BadgeCode returns the code printed on the registration's badge: the runner
+// ID when the season keeps badges across seasons, otherwise the registration ID
+func (r *Registration) BadgeCode() string {
+	if r.Season != nil && r.Season.KeepBadges && r.RunnerID != "" {
+		return r.RunnerID
+	}
+	return r.ID
+}
+
+// runnerMatchesHandler lists possible runner matches and records the admin's decision
+func runnerMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	// For POST requests, resolve a match then redirect back to the list
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		id := r.FormValue("id")
+		var sameRunner bool
+		switch r.FormValue("decision") {
+		case RunnerMatchMerged:
+			sameRunner = true
+		case RunnerMatchDifferent:
+			sameRunner = false
+		default:
+			http.Error(w, "Invalid decision", http.StatusBadRequest)
+			return
+		}
+
+		err = database.ResolveRunnerMatch(id, sameRunner, username)
+		if errors.Is(err, ErrRunnerMatchNotFound) {
+			http.Error(w, "Match not found or already resolved", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error resolving runner match: %v", err)
+			http.Error(w, "Failed to resolve match", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/runners/matches", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	matches, err := database.GetPendingRunnerMatches()
+	if err != nil {
+		log.Printf("Error getting runner matches: %v", err)
+		http.Error(w, "Failed to retrieve matches", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "runner_matches", PageData{
+		Title:         "Run Club - Review Runner Matches",
+		User:          username,
+		Role:          role,
+		RunnerMatches: matches,
+	})
+}
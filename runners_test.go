@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestRegistration builds a registration for a season with the given child and parent email
+func newTestRegistration(seasonID, firstName, lastName, parentEmail string) *Registration {
+	return &Registration{
+		ID:                  uuid.New().String(),
+		SeasonID:            &seasonID,
+		FirstName:           firstName,
+		LastName:            lastName,
+		Grade:               "3",
+		ParentContactNumber: "555-1234",
+		ParentEmail:         parentEmail,
+		RegisteredAt:        time.Now(),
+	}
+}
+
+func TestRunnerIdentity(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	fall, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spring := &Season{
+		ID:         uuid.New().String(),
+		Name:       "Spring",
+		IsActive:   true,
+		KeepBadges: true,
+		CreatedAt:  time.Now(),
+	}
+	if err := db.SaveSeason(spring, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	fallReg := newTestRegistration(fall.ID, "Jane", "Doe", "parent@example.com")
+	if err := db.SaveRegistration(fallReg, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if fallReg.RunnerID != fallReg.ID {
+		t.Errorf("Expected a new runner to take the registration's ID, got %q", fallReg.RunnerID)
+	}
+
+	t.Run("Same Name And Email Is The Same Runner", func(t *testing.T) {
+		springReg := newTestRegistration(spring.ID, " jane ", "DOE", "Parent@Example.com")
+		if err := db.SaveRegistration(springReg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if springReg.RunnerID != fallReg.RunnerID {
+			t.Errorf("Expected spring registration to link to runner %s, got %s", fallReg.RunnerID, springReg.RunnerID)
+		}
+
+		// Scans in both seasons count toward lifetime stats
+		if _, _, err := db.RecordScan(fallReg.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		history, err := db.GetRunnerHistory(fallReg.RunnerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("Expected 2 seasons of history, got %d", len(history))
+		}
+		lifetime := newRunnerLifetime(history)
+		if lifetime.TotalRuns != 1 {
+			t.Errorf("Expected 1 lifetime run, got %d", lifetime.TotalRuns)
+		}
+	})
+
+	t.Run("Kept Badge Scans Into Active Season", func(t *testing.T) {
+		// Jane's fall badge is scanned during the spring season
+		scan, reg, err := db.RecordScanAt(fallReg.ID, nil, time.Now().Add(time.Hour), ScanOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scan.SeasonID != spring.ID || reg.ID == fallReg.ID {
+			t.Errorf("Expected the fall badge to record a spring scan, got season %s", scan.SeasonID)
+		}
+	})
+
+	t.Run("Same Name Different Email Needs Review", func(t *testing.T) {
+		other := newTestRegistration(spring.ID, "Jane", "Doe", "new-email@example.com")
+		if err := db.SaveRegistration(other, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if other.RunnerID == fallReg.RunnerID {
+			t.Fatal("Expected a different email not to be linked automatically")
+		}
+
+		matches, err := db.GetPendingRunnerMatches()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 {
+			t.Fatalf("Expected 1 match to review, got %d", len(matches))
+		}
+		match := matches[0]
+		if match.RunnerID != fallReg.RunnerID || match.CandidateRunnerID != other.RunnerID {
+			t.Errorf("Unexpected match: %+v", match)
+		}
+		if len(match.Runner) != 2 || len(match.Candidate) != 1 {
+			t.Errorf("Expected 2 and 1 registrations to compare, got %d and %d", len(match.Runner), len(match.Candidate))
+		}
+
+		if err := db.ResolveRunnerMatch(match.ID, true, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		merged, _, err := db.GetRegistration(other.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if merged.RunnerID != fallReg.RunnerID {
+			t.Errorf("Expected merged registration to move to runner %s, got %s", fallReg.RunnerID, merged.RunnerID)
+		}
+		if err := db.ResolveRunnerMatch(match.ID, true, "admin"); err != ErrRunnerMatchNotFound {
+			t.Errorf("Expected ErrRunnerMatchNotFound resolving twice, got %v", err)
+		}
+	})
+}
+
+func TestCopySpringRegistrationsKeepsRunner(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	fall, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := newTestRegistration(fall.ID, "Sam", "Lee", "lee@example.com")
+	reg.RegisterForSpring = true
+	if err := db.SaveRegistration(reg, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	spring := &Season{ID: uuid.New().String(), Name: "Spring", CreatedAt: time.Now()}
+	if err := db.SaveSeason(spring, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CopySpringRegistrations(fall.ID, spring.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := db.GetAllRegistrations(spring.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 1 || copied[0].RunnerID != reg.RunnerID {
+		t.Errorf("Expected copied registration to keep runner %s, got %+v", reg.RunnerID, copied)
+	}
+}
+
+func TestRunnersMigration(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	conn, err := sql.Open("sqlite", tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Build the schema as it was before runners existed
+	mm := NewMigrationManager(conn)
+	if err := mm.LoadMigrationsFromDir("migrations"); err != nil {
+		t.Fatal(err)
+	}
+	all := mm.migrations
+	var runnersMigration int
+	for i, m := range all {
+		if m.Description == "add runners" {
+			runnersMigration = i
+		}
+	}
+	mm.migrations = all[:runnersMigration]
+	if err := mm.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	seasons := []string{"fall", "spring"}
+	for i, id := range seasons {
+		_, err := conn.Exec("INSERT INTO seasons (id, name, created_at) VALUES (?, ?, ?)", id, id, time.Now().Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registrations := []struct {
+		id, season, first, last, email string
+		registeredAt                   time.Time
+	}{
+		{"fall-jane", "fall", "Jane", "Doe", "doe@example.com", time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"spring-jane", "spring", "jane", "Doe ", "DOE@example.com", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"fall-max", "fall", "Max", "Roe", "roe@example.com", time.Date(2024, 8, 2, 0, 0, 0, 0, time.UTC)},
+		{"spring-max", "spring", "Max", "Roe", "max.roe@example.com", time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, r := range registrations {
+		_, err := conn.Exec(
+			`INSERT INTO registrations (id, season_id, first_name, last_name, grade, teacher, parent_contact_number, backup_contact_number, parent_email, registered_at)
+			VALUES (?, ?, ?, ?, '3', '', '555-1234', '', ?, ?)`,
+			r.id, r.season, r.first, r.last, r.email, r.registeredAt,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mm.migrations = all
+	if err := mm.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	runnerOf := func(regID string) string {
+		var runnerID string
+		if err := conn.QueryRow("SELECT runner_id FROM registrations WHERE id = ?", regID).Scan(&runnerID); err != nil {
+			t.Fatal(err)
+		}
+		return runnerID
+	}
+	if runnerOf("fall-jane") != "fall-jane" || runnerOf("spring-jane") != "fall-jane" {
+		t.Errorf("Expected both of Jane's registrations to link to her first one, got %s and %s", runnerOf("fall-jane"), runnerOf("spring-jane"))
+	}
+	if runnerOf("fall-max") == runnerOf("spring-max") {
+		t.Error("Expected Max's registrations with different emails not to be linked automatically")
+	}
+
+	var runnerID, candidateID string
+	err = conn.QueryRow("SELECT runner_id, candidate_runner_id FROM runner_match_reviews").Scan(&runnerID, &candidateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runnerID != "fall-max" || candidateID != "spring-max" {
+		t.Errorf("Expected Max's registrations to be queued for review, got %s and %s", runnerID, candidateID)
+	}
+}
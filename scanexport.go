@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scanExportFilter reads the scans export's filters from its query string.
+// The from and to dates are days in the club's timezone, both included.
+func scanExportFilter(query url.Values) (ScanExportFilter, error) {
+	filter := ScanExportFilter{
+		SeasonID: query.Get("season_id"),
+		Grade:    query.Get("grade"),
+		Teacher:  strings.TrimSpace(query.Get("teacher")),
+		TrackID:  query.Get("track_id"),
+	}
+	if from := query.Get("from"); from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, clubLocation())
+		if err != nil {
+			return filter, fmt.Errorf("Invalid from date %q", from)
+		}
+		filter.From = day
+	}
+	if to := query.Get("to"); to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, clubLocation())
+		if err != nil {
+			return filter, fmt.Errorf("Invalid to date %q", to)
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// scanExportHeader is the scans CSV export's header row
+func scanExportHeader() []string {
+	return []string{
+		"Scan ID", "Scanned At (" + clubLocation().String() + ")", "Registration ID", "First Name", "Last Name",
+		"Grade", "Teacher", "Season", "Track", "Distance (mi)", "Lap Time (min)", "Source", "Recorded By",
+	}
+}
+
+// scanExportRecord is a scan's row in the scans CSV export
+func scanExportRecord(row *ScanExportRow) []string {
+	var distance, lapTime string
+	if row.Track != "" {
+		distance = strconv.FormatFloat(row.DistanceMiles, 'f', -1, 64)
+	}
+	if row.LapTime != nil {
+		lapTime = strconv.FormatFloat(*row.LapTime, 'f', 2, 64)
+	}
+	return []string{
+		row.ID, row.ScannedAt.Format("2006-01-02 15:04:05"), row.RegistrationID, row.FirstName, row.LastName,
+		row.Grade, row.Teacher, row.Season, row.Track, distance, lapTime, row.Source, row.RecordedBy,
+	}
+}
+
+// scansExportHandler downloads every scan matching the season, date range,
+// grade, teacher and track filters as CSV, or as a JSON array with
+// format=json. Rows are written as they're read from the database.
+func scansExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := scanExportFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Once the first row is written the status can't change, so errors
+	// after that can only be logged, leaving the download cut short
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=scans.json")
+
+		encoder := json.NewEncoder(w)
+		separator := "[\n"
+		err = database.EachScanForExport(filter, func(row *ScanExportRow) error {
+			if _, err := fmt.Fprint(w, separator); err != nil {
+				return err
+			}
+			separator = ","
+			return encoder.Encode(row)
+		})
+		if separator == "[\n" {
+			fmt.Fprint(w, separator)
+		}
+		fmt.Fprint(w, "]\n")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=scans.csv")
+
+		csvWriter := csv.NewWriter(w)
+		defer csvWriter.Flush()
+		if err := csvWriter.Write(scanExportHeader()); err != nil {
+			log.Printf("Error writing CSV header: %v", err)
+			return
+		}
+		err = database.EachScanForExport(filter, func(row *ScanExportRow) error {
+			return csvWriter.Write(scanExportRecord(row))
+		})
+	}
+	if err != nil {
+		log.Printf("Error exporting scans: %v", err)
+	}
+}
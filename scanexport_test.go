@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestScansExportHandler(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := &Track{ID: uuid.New().String(), SeasonID: season.ID, Name: "Loop", DistanceMiles: 0.5, CreatedAt: time.Now()}
+	field := &Track{ID: uuid.New().String(), SeasonID: season.ID, Name: "Field", DistanceMiles: 0.25, CreatedAt: time.Now()}
+	for _, track := range []*Track{loop, field} {
+		if err := db.SaveTrack(track, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anna := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+	anna.Teacher = "Smith"
+	ben := newTestRegistration(season.ID, "Ben", "Roe", "lou@example.com")
+	ben.Grade = "K"
+	for _, reg := range []*Registration{anna, ben} {
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Anna runs a lap of the loop then the field on the 15th, and Ben is
+	// scanned the next day; times are recorded in UTC
+	day := time.Date(2026, 9, 15, 20, 0, 0, 0, time.UTC)
+	for _, scan := range []struct {
+		reg   *Registration
+		track *Track
+		at    time.Time
+	}{
+		{anna, loop, day},
+		{anna, field, day.Add(4 * time.Minute)},
+		{ben, loop, day.Add(24 * time.Hour)},
+	} {
+		if _, _, err := db.RecordScanAt(scan.reg.ID, &scan.track.ID, scan.at, ScanOptions{RecordedBy: "coach"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/scans/export?"+query, nil)
+		w := httptest.NewRecorder()
+		scansExportHandler(w, req)
+		return w
+	}
+
+	w := export("season_id=" + season.ID)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected a header and 3 scans, got %v", records)
+	}
+	if records[0][1] != "Scanned At (America/Chicago)" {
+		t.Errorf("Expected the time column to name the timezone, got %q", records[0][1])
+	}
+	want := []string{"2026-09-15 15:04:00", anna.ID, "Anna", "Doe", "3", "Smith", season.Name, "Field", "0.25", "4.00", ScanSourceQR, "coach"}
+	for i, value := range want {
+		if records[2][i+1] != value {
+			t.Errorf("Column %s: expected %q, got %q", records[0][i+1], value, records[2][i+1])
+		}
+	}
+	if records[1][10] != "" || records[3][10] != "" {
+		t.Error("Expected no lap time for each runner's first scan of the day")
+	}
+
+	// The track filter leaves out Anna's first lap, but her lap time still counts it
+	w = export("season_id=" + season.ID + "&track_id=" + field.ID + "&format=json")
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON, got %q", w.Header().Get("Content-Type"))
+	}
+	var rows []*ScanExportRow
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", w.Body.String(), err)
+	}
+	if len(rows) != 1 || rows[0].FirstName != "Anna" || rows[0].LapTime == nil || *rows[0].LapTime != 4 {
+		t.Errorf("Expected Anna's field lap, got %+v", rows)
+	}
+	if _, offset := rows[0].ScannedAt.Zone(); offset != -5*60*60 {
+		t.Errorf("Expected the time in Central time, got %v", rows[0].ScannedAt)
+	}
+
+	for query, want := range map[string]int{
+		"from=2026-09-16":                  1,
+		"from=2026-09-15&to=2026-09-15":    2,
+		"grade=K":                          1,
+		"teacher=smith":                    2,
+		"teacher=Jones":                    0,
+		"season_id=" + uuid.New().String(): 0,
+	} {
+		w := export(query + "&format=json")
+		var rows []*ScanExportRow
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", query, w.Body.String(), err)
+		}
+		if len(rows) != want {
+			t.Errorf("%s: expected %d scans, got %d", query, want, len(rows))
+		}
+	}
+
+	if w := export("from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid date to be rejected, got %d", w.Code)
+	}
+}
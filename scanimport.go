@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScanLogLaps is the most laps one row of a paper scan log can record
+const maxScanLogLaps = 30
+
+// scanLogColumns are the columns a paper scan log can have, and the headers
+// they're found by, normalized
+var scanLogColumns = map[string][]string{
+	"id":        {"id", "registrationid", "runnerid"},
+	"name":      {"name", "runner", "runnername", "student", "studentname"},
+	"firstName": {"firstname", "runnerfirstname", "studentfirstname", "first"},
+	"lastName":  {"lastname", "runnerlastname", "studentlastname", "last"},
+	"dateTime":  {"datetime", "dateandtime", "scannedat", "when"},
+	"date":      {"date", "day"},
+	"time":      {"time", "start", "starttime"},
+	"track":     {"track", "trackname", "course"},
+	"laps":      {"laps", "lapcount", "numberoflaps", "count"},
+}
+
+// scanLogDateLayouts and scanLogTimeLayouts are the ways a paper scan log
+// can write the date and time of a run. AM and PM are upper-cased first.
+var (
+	scanLogDateLayouts = []string{"2006-01-02", "1/2/2006", "1/2/06", "Jan 2, 2006", "Jan 2 2006"}
+	scanLogTimeLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04PM", "3:04:05 PM", "3PM", "3 PM"}
+)
+
+// ScanImport is an uploaded paper scan log: runs recorded by hand on days
+// the scanners failed, to be added as backdated scans
+type ScanImport struct {
+	Data     string // The file, carried from the preview to the import
+	Rows     []*ScanImportRow
+	Imported bool // Whether the scans were saved, rather than previewed
+
+	columns map[string]int // The column each detail is read from
+	records [][]string
+	lines   []int // Each record's line in the file
+}
+
+// ScanImportRow is one runner's laps on a paper scan log
+type ScanImportRow struct {
+	Line         int    // The row's line in the file, counting the header as line 1
+	Runner       string // The runner as the log names them
+	Registration *Registration
+	Track        *Track
+	ScannedAt    time.Time // When the first lap was run, in the club's timezone
+	Laps         int
+	Status       string // csvRowReady or csvRowError
+	Message      string // Why the row can't be imported
+}
+
+// Count returns how many rows have the given status
+func (imp *ScanImport) Count(status string) int {
+	count := 0
+	for _, row := range imp.Rows {
+		if row.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Laps returns how many laps the rows ready to import add up to
+func (imp *ScanImport) Laps() int {
+	laps := 0
+	for _, row := range imp.Rows {
+		if row.Status == csvRowReady {
+			laps += row.Laps
+		}
+	}
+	return laps
+}
+
+// CanImport reports whether every row checked out and there's something to save
+func (imp *ScanImport) CanImport() bool {
+	return imp.Count(csvRowError) == 0 && imp.Count(csvRowReady) > 0
+}
+
+// parseScanImport reads an uploaded paper scan log and finds its columns by
+// their headers
+func parseScanImport(data string) (*ScanImport, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var imp *ScanImport
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errRegistrationInput(fmt.Sprintf("Error reading CSV file: %v", err))
+		}
+		if imp == nil {
+			imp = &ScanImport{Data: data, columns: scanLogColumnsFor(record)}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		imp.records = append(imp.records, record)
+		imp.lines = append(imp.lines, line)
+	}
+	if imp == nil {
+		return nil, errRegistrationInput("The CSV file is empty")
+	}
+
+	var missing []string
+	if !imp.has("id") && !imp.has("name") && !(imp.has("firstName") && imp.has("lastName")) {
+		missing = append(missing, "Registration ID or Name")
+	}
+	if !imp.has("dateTime") && !(imp.has("date") && imp.has("time")) {
+		missing = append(missing, "Date and Time")
+	}
+	if !imp.has("laps") {
+		missing = append(missing, "Laps")
+	}
+	if len(missing) > 0 {
+		return nil, errRegistrationInput("The scan log needs columns for " + strings.Join(missing, ", "))
+	}
+	return imp, nil
+}
+
+// scanLogColumnsFor finds the column for each scan log detail in a header row
+func scanLogColumnsFor(headers []string) map[string]int {
+	columns := make(map[string]int)
+	for i, header := range headers {
+		for name, aliases := range scanLogColumns {
+			if _, ok := columns[name]; !ok && slices.Contains(aliases, normalizeName(header)) {
+				columns[name] = i
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// has reports whether the scan log has a column for a detail
+func (imp *ScanImport) has(name string) bool {
+	_, ok := imp.columns[name]
+	return ok
+}
+
+// cell returns a row's value for a scan log detail, or "" if there's no such column
+func (imp *ScanImport) cell(record []string, name string) string {
+	if i, ok := imp.columns[name]; ok && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// parseScanLogTime reads the date and time of a run in the club's timezone.
+// The date may hold the time as well.
+func parseScanLogTime(date, clock string) (time.Time, error) {
+	value := strings.ToUpper(strings.Join(strings.Fields(date+" "+clock), " "))
+	for _, dateLayout := range scanLogDateLayouts {
+		for _, timeLayout := range scanLogTimeLayouts {
+			if t, err := time.ParseInLocation(dateLayout+" "+timeLayout, value, clubLocation()); err == nil {
+				return t, nil
+			}
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, clubLocation()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid date and time %q", strings.TrimSpace(date+" "+clock))
+}
+
+// scanLogRunner finds the runner a row of a scan log is for: the one with
+// the row's ID, or else the only one in the season with the row's name.
+// A name can be written "First Last" or "Last, First".
+func (imp *ScanImport) scanLogRunner(record []string, existing []*Registration) (string, *Registration, error) {
+	if id := imp.cell(record, "id"); id != "" {
+		for _, reg := range existing {
+			if reg.ID == id {
+				return reg.FirstName + " " + reg.LastName, reg, nil
+			}
+		}
+		return id, nil, fmt.Errorf("No runner with ID %s in this season", id)
+	}
+
+	name := imp.cell(record, "name")
+	key := normalizeName(name)
+	if last, first, ok := strings.Cut(name, ","); ok {
+		key = normalizeName(first + last)
+	}
+	if name == "" {
+		name = strings.TrimSpace(imp.cell(record, "firstName") + " " + imp.cell(record, "lastName"))
+		key = normalizeName(name)
+	}
+	if key == "" {
+		return "", nil, errors.New("Missing the runner")
+	}
+
+	var match *Registration
+	for _, reg := range existing {
+		if normalizeName(reg.FirstName+reg.LastName) != key {
+			continue
+		}
+		if match != nil {
+			return name, nil, fmt.Errorf("More than one %s %s is registered; add an ID column to say which", reg.FirstName, reg.LastName)
+		}
+		match = reg
+	}
+	if match == nil {
+		return name, nil, fmt.Errorf("No runner named %s in this season", name)
+	}
+	return name, match, nil
+}
+
+// scanLogTrack finds a row's track among the season's by name, or the
+// season's default track if the row doesn't name one
+func scanLogTrack(name string, tracks []*Track) (*Track, error) {
+	var names []string
+	for _, track := range tracks {
+		if name == "" && track.IsDefault {
+			return track, nil
+		}
+		if name != "" && normalizeName(track.Name) == normalizeName(name) {
+			return track, nil
+		}
+		names = append(names, track.Name)
+	}
+	if name == "" {
+		return nil, errors.New("Missing the track, and the season has no default track")
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("No track named %s; the season has no tracks", name)
+	}
+	return nil, fmt.Errorf("No track named %s; the season's tracks are %s", name, strings.Join(names, ", "))
+}
+
+// scanLogLapTime is how long a lap of a track takes at the minimum pace a
+// scan is allowed. A row's laps are recorded this far apart, from its time.
+func scanLogLapTime(track *Track) time.Duration {
+	return time.Duration(track.DistanceMiles * 5 * float64(time.Minute))
+}
+
+// scanLogRow checks a row of a scan log against the season's runners and tracks
+func (imp *ScanImport) scanLogRow(record []string, existing []*Registration, tracks []*Track, now time.Time) *ScanImportRow {
+	row := &ScanImportRow{Status: csvRowReady}
+	var problems []string
+
+	runner, reg, err := imp.scanLogRunner(record, existing)
+	row.Runner, row.Registration = runner, reg
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	date := imp.cell(record, "dateTime")
+	if date == "" {
+		date = imp.cell(record, "date")
+	}
+	if date == "" {
+		problems = append(problems, "Missing the date")
+	} else if row.ScannedAt, err = parseScanLogTime(date, imp.cell(record, "time")); err != nil {
+		problems = append(problems, err.Error())
+	} else if row.ScannedAt.After(now) {
+		problems = append(problems, "The time is in the future")
+	}
+
+	if row.Track, err = scanLogTrack(imp.cell(record, "track"), tracks); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if row.Laps, err = strconv.Atoi(imp.cell(record, "laps")); err != nil || row.Laps < 1 || row.Laps > maxScanLogLaps {
+		problems = append(problems, fmt.Sprintf("Laps must be a whole number from 1 to %d", maxScanLogLaps))
+	}
+
+	if len(problems) > 0 {
+		row.Status = csvRowError
+		row.Message = strings.Join(problems, "; ")
+	}
+	return row
+}
+
+// processScanImport checks every row of a paper scan log against the
+// season, and the laps they add against each runner's other scans, then
+// imports them if commit is set and every row checked out. The import is
+// all or nothing, so a log can be fixed and uploaded again.
+func processScanImport(imp *ScanImport, season *Season, actor string, commit bool) error {
+	existing, err := database.GetAllRegistrations(season.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get registrations: %w", err)
+	}
+	tracks, err := database.GetTracksBySeasonID(season.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tracks: %w", err)
+	}
+
+	now := time.Now()
+	imp.Rows = nil
+	var scans []*ScanRecord
+	var scanRows []*ScanImportRow
+	for i, record := range imp.records {
+		row := imp.scanLogRow(record, existing, tracks, now)
+		row.Line = imp.lines[i]
+		imp.Rows = append(imp.Rows, row)
+		if row.Status != csvRowReady {
+			continue
+		}
+		for lap := 0; lap < row.Laps; lap++ {
+			scans = append(scans, &ScanRecord{
+				RegistrationID: row.Registration.ID,
+				TrackID:        &row.Track.ID,
+				ScannedAt:      row.ScannedAt.Add(time.Duration(lap) * scanLogLapTime(row.Track)),
+			})
+			scanRows = append(scanRows, row)
+		}
+	}
+
+	// Check the laps as the scanners would: not faster than the minimum
+	// pace since the runner's other scans, including others in the log
+	rejected, err := database.ImportScans(scans, actor, commit && imp.Count(csvRowError) == 0)
+	if err != nil {
+		return err
+	}
+	for i, err := range rejected {
+		row := scanRows[i]
+		if row.Status == csvRowError {
+			continue
+		}
+		row.Status = csvRowError
+		var tooSoon *ScanTooSoonError
+		if errors.As(err, &tooSoon) {
+			row.Message = fmt.Sprintf("Too soon after another of %s's scans (%.0f minutes apart); was this run already recorded?", row.Runner, tooSoon.MinutesSince)
+		} else {
+			row.Message = fmt.Sprintf("%s can't be scanned: %v", row.Runner, err)
+		}
+	}
+
+	if !commit {
+		return nil
+	}
+	if !imp.CanImport() {
+		return errCSVImportHasErrors
+	}
+	imp.Imported = true
+	return nil
+}
+
+// scanImportData reads the scan log being previewed or imported: a new
+// upload, or the file carried over from its preview
+func scanImportData(r *http.Request) (*ScanImport, error) {
+	file, header, err := r.FormFile("csv-file")
+	if err != nil {
+		data := r.FormValue("csv-data")
+		if data == "" {
+			return nil, errRegistrationInput("Error retrieving file from form")
+		}
+		return parseScanImport(data)
+	}
+	defer file.Close()
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+		return nil, errRegistrationInput("Uploaded file is not a CSV")
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, errRegistrationInput("Error reading uploaded file")
+	}
+	return parseScanImport(string(data))
+}
+
+// scanImportHandler adds runs from a paper scan log kept on days the
+// scanners failed. The log is previewed first, showing the runner, track
+// and laps each row adds or what needs fixing, then imported all at once
+// as backdated scans.
+func scanImportHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	activeSeason, hasActiveSeason, err := database.GetActiveSeason()
+	if err != nil {
+		log.Printf("Error getting active season: %v", err)
+	}
+
+	data := PageData{
+		Title: "Run Club - Import Scan Log",
+		User:  username,
+		Role:  role,
+	}
+	if hasActiveSeason {
+		data.ActiveSeason = activeSeason
+	}
+
+	if r.Method == http.MethodGet {
+		renderTemplate(w, "scan_import", data)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hasActiveSeason {
+		data.Message = "Cannot import scans without an active season"
+		renderTemplate(w, "scan_import", data)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // Max 10MB
+		data.Message = "Error parsing form"
+		renderTemplate(w, "scan_import", data)
+		return
+	}
+
+	imp, err := scanImportData(r)
+	if err != nil {
+		data.Message = err.Error()
+		renderTemplate(w, "scan_import", data)
+		return
+	}
+	data.ScanImport = imp
+
+	err = processScanImport(imp, activeSeason, username, r.FormValue("action") == "import")
+	var inputErr errRegistrationInput
+	switch {
+	case errors.As(err, &inputErr):
+		data.Message = err.Error()
+	case err != nil:
+		log.Printf("Error importing scan log: %v", err)
+		data.Message = "Failed to import the scan log. Nothing was imported."
+	case imp.Imported:
+		data.Success = true
+		data.Message = fmt.Sprintf("Imported %d laps from %d rows", imp.Laps(), imp.Count(csvRowReady))
+	default:
+		data.Success = imp.CanImport()
+		data.Message = fmt.Sprintf("Preview: %d laps from %d rows ready to import, %d rows with errors. Nothing has been saved yet.",
+			imp.Laps(), imp.Count(csvRowReady), imp.Count(csvRowError))
+	}
+
+	renderTemplate(w, "scan_import", data)
+}
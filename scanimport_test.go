@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseScanLogTime(t *testing.T) {
+	want := time.Date(2026, 9, 15, 15, 30, 0, 0, clubLocation())
+	for _, value := range [][2]string{
+		{"9/15/2026", "3:30 PM"},
+		{"9/15/26", "3:30pm"},
+		{"2026-09-15", "15:30"},
+		{"2026-09-15 15:30", ""},
+		{"Sep 15, 2026 3:30 PM", ""},
+	} {
+		got, err := parseScanLogTime(value[0], value[1])
+		if err != nil {
+			t.Errorf("%q %q: %v", value[0], value[1], err)
+		} else if !got.Equal(want) {
+			t.Errorf("%q %q: expected %v, got %v", value[0], value[1], want, got)
+		}
+	}
+	if _, err := parseScanLogTime("9/15/2026", ""); err == nil {
+		t.Error("Expected a date without a time to be rejected")
+	}
+
+	if _, err := parseScanImport("Name,Date,Track\nAnna Doe,9/15/2026,Loop\n"); err == nil || !strings.Contains(err.Error(), "Date and Time, Laps") {
+		t.Errorf("Expected the missing columns to be reported, got %v", err)
+	}
+}
+
+func TestProcessScanImport(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := &Track{ID: uuid.New().String(), SeasonID: season.ID, Name: "Loop", DistanceMiles: 0.5, IsDefault: true, CreatedAt: time.Now()}
+	field := &Track{ID: uuid.New().String(), SeasonID: season.ID, Name: "Field", DistanceMiles: 0.25, CreatedAt: time.Now()}
+	for _, track := range []*Track{loop, field} {
+		if err := db.SaveTrack(track, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anna := newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com")
+	ben := newTestRegistration(season.ID, "Ben", "Roe", "lou@example.com")
+	for _, reg := range []*Registration{anna, ben} {
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ben was scanned before the scanners failed
+	if _, _, err := db.RecordScanAt(ben.ID, &loop.ID, time.Date(2026, 9, 15, 15, 0, 0, 0, clubLocation()), ScanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "Runner,Date,Time,Track,Laps\n" +
+		"Anna Doe,9/15/2026,3:30 PM,field,3\n" +
+		"\"Roe, Ben\",9/15/2026,3:10 PM,,2\n"
+	imp, err := parseScanImport(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := processScanImport(imp, season, "admin", false); err != nil {
+		t.Fatal(err)
+	}
+	if !imp.CanImport() || imp.Laps() != 5 {
+		t.Fatalf("Expected 5 laps ready to import, got %+v", imp.Rows)
+	}
+	if row := imp.Rows[1]; row.Registration.ID != ben.ID || row.Track.ID != loop.ID {
+		t.Errorf("Expected Ben's laps on the default track, got %+v", row)
+	}
+	if scans, _ := db.GetScanHistory(anna.ID); len(scans) != 0 {
+		t.Fatalf("Expected the preview to save nothing, got %d scans", len(scans))
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		bad, err := parseScanImport(csv +
+			"Ben Roe,9/15/2026,3:01 PM,Loop,1\n" +
+			"Cara Lee,9/15/2026,3:30 PM,Loop,1\n" +
+			"Anna Doe,9/15/2026,3:30 PM,Hill,0\n" +
+			"Anna Doe,9/15/2099,3:30 PM,Loop,1\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processScanImport(bad, season, "admin", true); err != errCSVImportHasErrors {
+			t.Fatalf("Expected the import to be refused, got %v", err)
+		}
+		for i, want := range map[int]string{
+			2: "Too soon after another of Ben Roe's scans",
+			3: "No runner named Cara Lee",
+			4: "No track named Hill; the season's tracks are",
+			5: "in the future",
+		} {
+			if !strings.Contains(bad.Rows[i].Message, want) {
+				t.Errorf("Line %d: expected %q in %q", bad.Rows[i].Line, want, bad.Rows[i].Message)
+			}
+		}
+		if !strings.Contains(bad.Rows[4].Message, "Laps must be") {
+			t.Errorf("Expected the laps to be rejected, got %q", bad.Rows[4].Message)
+		}
+		if scans, _ := db.GetScanHistory(anna.ID); len(scans) != 0 {
+			t.Errorf("Expected nothing to be imported, got %d scans", len(scans))
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		if err := processScanImport(imp, season, "admin", true); err != nil {
+			t.Fatal(err)
+		}
+		if !imp.Imported {
+			t.Error("Expected the import to be saved")
+		}
+
+		scans, err := db.GetScanHistory(anna.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scans) != 3 {
+			t.Fatalf("Expected 3 scans for Anna, got %d", len(scans))
+		}
+		first := time.Date(2026, 9, 15, 15, 30, 0, 0, clubLocation())
+		for i, scan := range scans {
+			// Newest first, a quarter mile apart at the minimum pace
+			at := first.Add(time.Duration(2-i) * 75 * time.Second)
+			if !scan.ScannedAt.Equal(at) || scan.Source != ScanSourceImport || scan.RecordedBy != "admin" || scan.TrackID == nil || *scan.TrackID != field.ID {
+				t.Errorf("Unexpected scan %+v, expected at %v", scan, at)
+			}
+		}
+
+		// Importing the log again would record every run twice
+		again, err := parseScanImport(csv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processScanImport(again, season, "admin", true); err != errCSVImportHasErrors {
+			t.Errorf("Expected the second import to be refused, got %v", err)
+		}
+		if again.Count(csvRowError) != 2 {
+			t.Errorf("Expected both rows to be flagged, got %+v", again.Rows)
+		}
+	})
+
+	t.Run("Live scans", func(t *testing.T) {
+		// Ben was scanned at 10:00 UTC, hours before a lap logged on paper
+		// at 9:00 in the club's timezone
+		live := time.Date(2026, 9, 16, 10, 0, 0, 0, time.UTC).In(time.Local)
+		if _, _, err := db.RecordScanAt(ben.ID, &loop.ID, live, ScanOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		imp, err := parseScanImport("Runner,Date,Time,Track,Laps\nBen Roe,9/16/2026,9:00 AM,Loop,1\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := processScanImport(imp, season, "admin", true); err != nil {
+			t.Fatalf("Expected the lap to be imported, got %v: %+v", err, imp.Rows[0])
+		}
+
+		scans, err := db.GetScanHistory(ben.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paper := time.Date(2026, 9, 16, 9, 0, 0, 0, clubLocation())
+		if len(scans) == 0 || !scans[0].ScannedAt.Equal(paper) || scans[0].Source != ScanSourceImport {
+			t.Errorf("Expected the paper lap to be Ben's latest scan, got %+v", scans)
+		}
+	})
+}
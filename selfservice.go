@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change request statuses
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+// ChangeRequest is a parent's request to change their runner's name or
+// grade. Those changes need an admin's approval; parents can change the
+// rest of their registration themselves.
+type ChangeRequest struct {
+	ID             string        `json:"id"`
+	RegistrationID string        `json:"registrationId"`
+	FirstName      string        `json:"firstName"` // The requested values
+	LastName       string        `json:"lastName"`
+	Grade          string        `json:"grade"`
+	Status         string        `json:"status"`
+	RequestedAt    time.Time     `json:"requestedAt"`
+	ResolvedAt     *time.Time    `json:"resolvedAt,omitempty"`
+	ResolvedBy     string        `json:"resolvedBy,omitempty"`
+	Registration   *Registration `json:"registration,omitempty"` // Populated when listing requests
+}
+
+// apply returns a copy of reg with the requested name and grade
+func (req *ChangeRequest) apply(reg *Registration) *Registration {
+	changed := *reg
+	changed.FirstName = req.FirstName
+	changed.LastName = req.LastName
+	changed.Grade = req.Grade
+	return &changed
+}
+
+// requestBaseURL returns the scheme and host the request was made to, for
+// building links to send to parents
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// registrationEditURL returns the private link a parent uses to update their registration
+func registrationEditURL(baseURL string, reg *Registration) string {
+	return baseURL + "/public/edit?token=" + url.QueryEscape(reg.EditToken)
+}
+
+// applyParentEdit copies the details a parent can change themselves onto a
+// registration and validates the result
+func applyParentEdit(reg, edit *Registration) error {
+	reg.ParentContactNumber = edit.ParentContactNumber
+	reg.BackupContactNumber = edit.BackupContactNumber
+	reg.DismissalMethod = edit.DismissalMethod
+	reg.Allergies = edit.Allergies
+	reg.MedicalInfo = edit.MedicalInfo
+	reg.OptOutWebsiteDisplay = edit.OptOutWebsiteDisplay
+	reg.OptOutPhotoSharing = edit.OptOutPhotoSharing
+	return validateRegistration(reg)
+}
+
+// changeRequestFromEdit returns a request for the name and grade changes in a
+// parent's edit, or nil if they didn't change them
+func changeRequestFromEdit(reg, edit *Registration) (*ChangeRequest, error) {
+	if edit.FirstName == reg.FirstName && edit.LastName == reg.LastName && edit.Grade == reg.Grade {
+		return nil, nil
+	}
+	if edit.FirstName == "" || edit.LastName == "" {
+		return nil, errRegistrationInput("Runner first and last name are required")
+	}
+	validGrade := false
+	for _, grade := range schoolGrades {
+		validGrade = validGrade || edit.Grade == grade
+	}
+	if !validGrade {
+		return nil, errRegistrationInput("Please select a valid grade")
+	}
+
+	return &ChangeRequest{
+		ID:             uuid.New().String(),
+		RegistrationID: reg.ID,
+		FirstName:      edit.FirstName,
+		LastName:       edit.LastName,
+		Grade:          edit.Grade,
+		RequestedAt:    time.Now(),
+	}, nil
+}
+
+// notifyRegistrationEditLink sends a parent the link to update their registration
+func notifyRegistrationEditLink(reg *Registration, season *Season, editURL string) error {
+	subject := fmt.Sprintf("%s is registered for Run Club", reg.FirstName)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Thank you for registering %s %s for %s.\n\n"+
+		"If your phone number, dismissal plans, allergies or medical information change, "+
+		"you can update them at any time using this private link:\n\n%s\n\n"+
+		"Please keep this link to yourself, since anyone with it can see and change %s's registration.\n\n"+
+		"Clayton Run Club\n",
+		reg.ParentFirstName, reg.FirstName, reg.LastName, season.Name, editURL, reg.FirstName)
+	return notifier.Notify(reg.ParentEmail, subject, body)
+}
+
+// publicEditHandler lets a parent holding their registration's edit link
+// update their contact and medical details and opt-outs, sign the season's
+// current waiver, and request a name or grade change
+func publicEditHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Missing edit link token", http.StatusBadRequest)
+		return
+	}
+
+	reg, found, err := database.GetRegistrationByEditToken(token)
+	if err != nil {
+		log.Printf("Error getting registration by edit token: %v", err)
+		http.Error(w, "Failed to retrieve registration", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Invalid edit link", http.StatusNotFound)
+		return
+	}
+	if reg.WithdrawnAt != nil {
+		http.Error(w, "This registration has been withdrawn. Please contact Run Club if you have any questions.", http.StatusGone)
+		return
+	}
+
+	// Parents who haven't agreed to the current waiver can sign it here
+	var waiver *SeasonWaiver
+	if reg.SeasonID != nil {
+		waiver, err = currentWaiver(*reg.SeasonID)
+		if err != nil {
+			log.Printf("Error getting waiver: %v", err)
+			http.Error(w, "Failed to retrieve waiver", http.StatusInternalServerError)
+			return
+		}
+		if waiver != nil && waiver.SignedBy(reg) {
+			waiver = nil
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		edit := registrationFromForm(r)
+		req, err := changeRequestFromEdit(reg, edit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := applyParentEdit(reg, edit); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if waiver != nil && (r.FormValue("waiverAgree") != "" || strings.TrimSpace(r.FormValue("waiverSignature")) != "") {
+			if err := consentFromForm(r, reg, waiver); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := database.UpdateRegistration(reg, auditActorParent); err != nil {
+			log.Printf("Error updating registration: %v", err)
+			http.Error(w, "Failed to save registration", http.StatusInternalServerError)
+			return
+		}
+
+		message := "Your changes have been saved"
+		if req != nil {
+			if err := database.RequestRegistrationChange(req, auditActorParent); err != nil {
+				log.Printf("Error saving change request: %v", err)
+				http.Error(w, "Failed to save your name or grade change", http.StatusInternalServerError)
+				return
+			}
+			message += ". Run Club will review the name or grade change before it takes effect"
+		}
+
+		http.Redirect(w, r, "/public/edit?token="+url.QueryEscape(token)+"&message="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, _, err := database.GetPendingChangeRequest(reg.ID)
+	if err != nil {
+		log.Printf("Error getting change request: %v", err)
+	}
+
+	renderTemplate(w, "registration_edit", PageData{
+		Title:         "Run Club - Update Registration",
+		Registration:  reg,
+		ChangeRequest: req,
+		Waiver:        waiver,
+		Message:       r.URL.Query().Get("message"),
+		Success:       r.URL.Query().Get("message") != "",
+		// Parents aren't logged in
+		User: "",
+		Role: "",
+	})
+}
+
+// changeRequestsURL returns the change requests page, with an optional message to show
+func changeRequestsURL(message string) string {
+	u := "/registrations/changes"
+	if message != "" {
+		u += "?message=" + url.QueryEscape(message)
+	}
+	return u
+}
+
+// changeRequestsHandler lists the name and grade changes parents have asked for
+func changeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requests, err := database.GetPendingChangeRequests()
+	if err != nil {
+		log.Printf("Error getting change requests: %v", err)
+		http.Error(w, "Failed to retrieve change requests", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "change_requests", PageData{
+		Title:          "Run Club - Change Requests",
+		User:           username,
+		Role:           role,
+		ChangeRequests: requests,
+		Message:        r.URL.Query().Get("message"),
+		Success:        r.URL.Query().Get("message") != "",
+	})
+}
+
+// changeRequestResolveHandler approves or rejects a parent's change request
+func changeRequestResolveHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Change request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var approve bool
+	switch r.FormValue("action") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	req, err := database.ResolveChangeRequest(id, approve, username)
+	switch {
+	case errors.Is(err, ErrChangeRequestNotFound), errors.Is(err, ErrRegistrationNotFound):
+		http.Error(w, "Change request not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrChangeRequestResolved):
+		http.Error(w, "This change request has already been approved or rejected", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error resolving change request: %v", err)
+		http.Error(w, "Failed to update change request", http.StatusInternalServerError)
+		return
+	}
+
+	message := fmt.Sprintf("Rejected the change to %s %s", req.Registration.FirstName, req.Registration.LastName)
+	if approve {
+		message = fmt.Sprintf("%s %s's registration has been updated", req.Registration.FirstName, req.Registration.LastName)
+	}
+	if r.FormValue("return") == "runner" {
+		http.Redirect(w, r, runnerDetailURL(req.RegistrationID, message), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, changeRequestsURL(message), http.StatusSeeOther)
+}
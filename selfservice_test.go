@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestPublicEditHandler(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := newTestRegistration(season.ID, "Anna", "Doe", "anna@example.com")
+	if err := db.SaveRegistration(reg, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if reg.EditToken == "" {
+		t.Fatal("Expected a new registration to get an edit token")
+	}
+
+	submit := func(token string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/public/edit?token="+url.QueryEscape(token), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		publicEditHandler(rr, req)
+		return rr
+	}
+	form := url.Values{
+		"firstName":           {"Anna"},
+		"lastName":            {"Doe"},
+		"grade":               {"3"},
+		"teacher":             {"Changed by parent"},
+		"parentContactNumber": {"555-555-9999"},
+		"dismissalMethod":     {"Car Pickup"},
+		"allergies":           {"Peanuts"},
+		"optOutPhotoSharing":  {"true"},
+	}
+
+	if rr := submit("not-a-token", form); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown token to be rejected, got %d", rr.Code)
+	}
+
+	t.Run("Details", func(t *testing.T) {
+		if rr := submit(reg.EditToken, form); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected the edit to be saved, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		updated, _, err := db.GetRegistrationByEditToken(reg.EditToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Allergies != "Peanuts" || updated.ParentContactNumber != "555-555-9999" || !updated.OptOutPhotoSharing {
+			t.Errorf("Expected the parent's changes to be saved, got %+v", updated)
+		}
+		if updated.Teacher != reg.Teacher {
+			t.Errorf("Expected the teacher not to be editable by parents, got %q", updated.Teacher)
+		}
+		if _, found, _ := db.GetPendingChangeRequest(reg.ID); found {
+			t.Error("Expected no change request when the name and grade weren't changed")
+		}
+
+		entries, err := db.GetRegistrationAuditLog(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 || entries[0].Actor != auditActorParent {
+			t.Errorf("Expected the edit to be audited as the parent, got %+v", entries)
+		}
+	})
+
+	t.Run("NameAndGrade", func(t *testing.T) {
+		form.Set("firstName", "Annabel")
+		form.Set("grade", "4")
+		if rr := submit(reg.EditToken, form); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected the edit to be saved, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		current, _, err := db.GetRegistration(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.FirstName != "Anna" || current.Grade != "3" {
+			t.Errorf("Expected the name and grade to wait for approval, got %s grade %s", current.FirstName, current.Grade)
+		}
+
+		req, found, err := db.GetPendingChangeRequest(reg.ID)
+		if err != nil || !found {
+			t.Fatalf("Expected a pending change request, found=%v err=%v", found, err)
+		}
+		if req.FirstName != "Annabel" || req.Grade != "4" {
+			t.Errorf("Expected the requested name and grade, got %+v", req)
+		}
+
+		resolved, err := db.ResolveChangeRequest(req.ID, true, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.Registration.FirstName != "Annabel" || resolved.Registration.Grade != "4" {
+			t.Errorf("Expected the approved change to be applied, got %+v", resolved.Registration)
+		}
+		if _, err := db.ResolveChangeRequest(req.ID, false, "admin"); !errors.Is(err, ErrChangeRequestResolved) {
+			t.Errorf("Expected ErrChangeRequestResolved resolving twice, got %v", err)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		form.Set("grade", "9")
+		if rr := submit(reg.EditToken, form); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected an invalid grade to be rejected, got %d", rr.Code)
+		}
+
+		form.Set("lastName", "Smith")
+		form.Set("grade", "4")
+		if rr := submit(reg.EditToken, form); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected the edit to be saved, got %d: %s", rr.Code, rr.Body.String())
+		}
+		requests, err := db.GetPendingChangeRequests()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(requests) != 1 || requests[0].Registration.LastName != "Doe" {
+			t.Fatalf("Expected one pending request for Annabel Doe, got %+v", requests)
+		}
+
+		if _, err := db.ResolveChangeRequest(requests[0].ID, false, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		current, _, err := db.GetRegistration(reg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.LastName != "Doe" {
+			t.Errorf("Expected a rejected change not to be applied, got %s", current.LastName)
+		}
+	})
+
+	t.Run("Withdrawn", func(t *testing.T) {
+		if err := db.WithdrawRegistration(reg.ID, "admin", ""); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/public/edit?token="+url.QueryEscape(reg.EditToken), nil)
+		rr := httptest.NewRecorder()
+		publicEditHandler(rr, req)
+		if rr.Code != http.StatusGone {
+			t.Errorf("Expected a withdrawn registration's link to stop working, got %d", rr.Code)
+		}
+	})
+}
+
+func TestPublicEditURLs(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	publicStore = sessions.NewCookieStore([]byte("test-secret"))
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := url.Values{
+		"firstName":           {"Anna"},
+		"lastName":            {"Doe"},
+		"grade":               {"2"},
+		"parentFirstName":     {"Pat"},
+		"parentLastName":      {"Doe"},
+		"parentContactNumber": {"555-555-1234"},
+		"parentEmail":         {"pat@example.com"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/public/register?token="+season.RegistrationToken, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	publicRegisterHandler(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected Anna to be registered, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, _, err := db.GetRegistration(location.Query().Get("id"))
+	if err != nil || reg == nil {
+		t.Fatalf("Expected the success page to be for Anna, got %s: %v", location, err)
+	}
+	other := newTestRegistration(season.ID, "Ben", "Roe", "lou@example.com")
+	if err := db.SaveRegistration(other, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The parent who registered sees Anna's link once, and never anyone else's
+	success := func(cookies []*http.Cookie) (map[string]string, []*http.Cookie) {
+		req := httptest.NewRequest(http.MethodGet, location.String(), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		return publicEditURLs(rr, req, []*Registration{reg, other}), rr.Result().Cookies()
+	}
+	editURLs, cookies := success(rr.Result().Cookies())
+	if len(editURLs) != 1 || !strings.Contains(editURLs[reg.ID], url.QueryEscape(reg.EditToken)) {
+		t.Errorf("Expected only Anna's edit link, got %v", editURLs)
+	}
+	if editURLs, _ := success(cookies); len(editURLs) != 0 {
+		t.Errorf("Expected the edit link to be shown only once, got %v", editURLs)
+	}
+	if editURLs, _ := success(nil); len(editURLs) != 0 {
+		t.Errorf("Expected no edit links without the parent's session, got %v", editURLs)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	query, _ := url.ParseQuery("token=secret&message=Saved")
+	if got := redactQuery(query); got != "message=Saved&token=redacted" {
+		t.Errorf("Expected the token to be redacted, got %q", got)
+	}
+	for _, path := range []string{"/public/edit", "/public/register", "/login"} {
+		if !hasSensitiveBody(path) {
+			t.Errorf("Expected the body posted to %s to be left out of the log", path)
+		}
+	}
+}
+
+func TestLogNotifierHidesEditLink(t *testing.T) {
+	originalNotifier := notifier
+	defer func() { notifier = originalNotifier }()
+	notifier = logNotifier{}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	reg := newTestRegistration("season-1", "Anna", "Doe", "pat@example.com")
+	reg.EditToken = "edit-secret"
+	season := &Season{ID: "season-1", Name: "Fall 2026"}
+	editURL := registrationEditURL("http://example.com", reg)
+	if err := notifyRegistrationEditLink(reg, season, editURL); err != nil {
+		t.Fatal(err)
+	}
+	if err := notifyWaitlistPromotion(reg, season, editURL); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logged.String(), "pat@example.com") {
+		t.Errorf("Expected the notifications to be logged, got %q", logged.String())
+	}
+	if strings.Contains(logged.String(), "edit-secret") {
+		t.Errorf("Expected the edit link to be left out of the log, got %q", logged.String())
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// Default session lifetimes, overridable with SESSION_IDLE_TIMEOUT and SESSION_ABSOLUTE_TIMEOUT
+const (
+	defaultSessionIdleTimeout     = 12 * time.Hour
+	defaultSessionAbsoluteTimeout = 7 * 24 * time.Hour
+
+	// sessionTouchInterval limits how often last_seen_at is written for an active session
+	sessionTouchInterval = time.Minute
+)
+
+// UserSession represents a logged-in session stored in the database
+type UserSession struct {
+	ID         string    `json:"id"` // SHA-256 of the cookie value, never the cookie value itself
+	UserID     string    `json:"userId"`
+	Username   string    `json:"username"`
+	Data       []byte    `json:"-"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// SQLiteStore is a sessions.Store that keeps session values in the database and
+// only puts an opaque, signed session ID in the cookie so sessions can be revoked
+type SQLiteStore struct {
+	db              *Database
+	Codecs          []securecookie.Codec
+	Options         *sessions.Options
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+}
+
+// NewSQLiteStore creates a database-backed session store
+func NewSQLiteStore(db *Database, idleTimeout, absoluteTimeout time.Duration, keyPairs ...[]byte) *SQLiteStore {
+	return &SQLiteStore{
+		db:     db,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(absoluteTimeout.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		IdleTimeout:     idleTimeout,
+		AbsoluteTimeout: absoluteTimeout,
+	}
+}
+
+// Get returns a session for the given name, reusing it within the same request
+func (s *SQLiteStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request cookie, or returns a new empty session
+// if there is no cookie or the stored session has expired or been revoked
+func (s *SQLiteStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	stored, exists, err := s.db.GetSession(hashSessionToken(token))
+	if err != nil {
+		return session, err
+	}
+	if !exists {
+		return session, nil
+	}
+
+	now := time.Now()
+	if now.After(stored.ExpiresAt) || now.Sub(stored.LastSeenAt) > s.IdleTimeout {
+		if err := s.db.DeleteSession(stored.ID); err != nil {
+			log.Printf("Error deleting expired session: %v", err)
+		}
+		return session, nil
+	}
+
+	if err := (securecookie.GobEncoder{}).Deserialize(stored.Data, &session.Values); err != nil {
+		return session, err
+	}
+	session.ID = token
+	session.IsNew = false
+
+	if now.Sub(stored.LastSeenAt) > sessionTouchInterval {
+		if err := s.db.TouchSession(stored.ID, now); err != nil {
+			log.Printf("Error updating session activity: %v", err)
+		}
+	}
+
+	return session, nil
+}
+
+// Save writes the session values to the database and sets the session ID cookie.
+// A negative MaxAge deletes the session.
+func (s *SQLiteStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.db.DeleteSession(hashSessionToken(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	data, err := (securecookie.GobEncoder{}).Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stored := &UserSession{
+		Data:       data,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  clientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.AbsoluteTimeout),
+	}
+	if userID, ok := session.Values["user_id"].(string); ok {
+		stored.UserID = userID
+	}
+
+	if session.ID == "" {
+		token, err := newSessionToken()
+		if err != nil {
+			return err
+		}
+		session.ID = token
+		stored.ID = hashSessionToken(token)
+		err = s.db.CreateSession(stored)
+		if err != nil {
+			return err
+		}
+	} else {
+		stored.ID = hashSessionToken(session.ID)
+		err = s.db.UpdateSessionData(stored)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// regenerateSession discards the stored session so the next Save issues a new ID
+func regenerateSession(session *sessions.Session) {
+	if session.ID == "" {
+		return
+	}
+	if err := database.DeleteSession(hashSessionToken(session.ID)); err != nil {
+		log.Printf("Error deleting previous session: %v", err)
+	}
+	session.ID = ""
+}
+
+// newSessionToken generates a random opaque session ID
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashSessionToken returns the database key for a session ID so a leaked
+// database does not contain usable cookies
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionStillActive reports whether a login session hasn't been revoked or
+// expired since it was loaded, and its user hasn't been disabled. Streams
+// check it as they go, since they stay open long after authMiddleware ran.
+func sessionStillActive(session *sessions.Session) bool {
+	stored, exists, err := database.GetSession(hashSessionToken(session.ID))
+	if err != nil {
+		// Keep the stream open through a transient database error
+		log.Printf("Error checking session: %v", err)
+		return true
+	}
+	if !exists || time.Now().After(stored.ExpiresAt) {
+		return false
+	}
+
+	user, exists, err := database.GetUser(stored.UserID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		return true
+	}
+	return exists && !user.IsDisabled
+}
+
+// clientIP returns the client address, preferring the header set by the fly.io proxy
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("Fly-Client-IP"); ip != "" {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// loadSessionKey reads a hex-encoded key from the environment, generating a
+// random one if unset. Generated keys invalidate all cookies on restart.
+func loadSessionKey(envVar string, length int) ([]byte, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		log.Printf("WARNING: %s is not set; using a random key, so sessions will not survive a restart", envVar)
+		return securecookie.GenerateRandomKey(length), nil
+	}
+
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be hex-encoded: %w", envVar, err)
+	}
+	if len(key) != length {
+		return nil, fmt.Errorf("%s must be %d bytes (%d hex characters), got %d bytes", envVar, length, length*2, len(key))
+	}
+	return key, nil
+}
+
+// loadDuration reads a duration such as "12h" from the environment
+func loadDuration(envVar string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVar, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", envVar)
+	}
+	return d, nil
+}
+
+// initSessionStores builds the login session store and the cookie store used
+// to prefill the public registration form, using keys from the environment
+func initSessionStores(db *Database) error {
+	hashKey, err := loadSessionKey("SESSION_HASH_KEY", 32)
+	if err != nil {
+		return err
+	}
+	blockKey, err := loadSessionKey("SESSION_BLOCK_KEY", 32)
+	if err != nil {
+		return err
+	}
+	idleTimeout, err := loadDuration("SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout)
+	if err != nil {
+		return err
+	}
+	absoluteTimeout, err := loadDuration("SESSION_ABSOLUTE_TIMEOUT", defaultSessionAbsoluteTimeout)
+	if err != nil {
+		return err
+	}
+
+	store = NewSQLiteStore(db, idleTimeout, absoluteTimeout, hashKey)
+
+	publicStore = sessions.NewCookieStore(hashKey, blockKey)
+	publicStore.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   86400 * 7, // 1 week
+		HttpOnly: true,
+	}
+
+	// Periodically remove expired sessions
+	go func() {
+		for {
+			cutoff := time.Now()
+			if err := db.DeleteExpiredSessions(cutoff, cutoff.Add(-idleTimeout)); err != nil {
+				log.Printf("Error deleting expired sessions: %v", err)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+
+	return nil
+}
+
+// sessionsHandler lists active login sessions per user for admins
+func sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idleTimeout := defaultSessionIdleTimeout
+	if s, ok := store.(*SQLiteStore); ok {
+		idleTimeout = s.IdleTimeout
+	}
+
+	activeSessions, err := database.GetActiveSessions(time.Now(), time.Now().Add(-idleTimeout))
+	if err != nil {
+		log.Printf("Error getting sessions: %v", err)
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	currentSessionID := ""
+	if session.ID != "" {
+		currentSessionID = hashSessionToken(session.ID)
+	}
+
+	renderTemplate(w, "sessions", PageData{
+		Title:            "Run Club - Active Sessions",
+		User:             username,
+		Role:             role,
+		Sessions:         activeSessions,
+		CurrentSessionID: currentSessionID,
+	})
+}
+
+// revokeSessionHandler revokes a single session, or every session for a user
+func revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	if userID := r.FormValue("user_id"); userID != "" {
+		err = database.DeleteUserSessions(userID)
+	} else if sessionID := r.FormValue("id"); sessionID != "" {
+		err = database.DeleteSession(sessionID)
+	} else {
+		http.Error(w, "Session ID or user ID is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreRevocationAndTimeouts(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	originalDB := database
+	defer func() { database = originalDB }()
+	database = db
+
+	user, err := newUser("phone1", "scanner-password", RoleScanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionStore := NewSQLiteStore(db, time.Hour, 24*time.Hour, []byte("0123456789abcdef0123456789abcdef"))
+
+	// login saves a new authenticated session and returns its cookie
+	login := func() *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rr := httptest.NewRecorder()
+		session, err := sessionStore.Get(req, "run-club-session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session.Values["authenticated"] = true
+		session.Values["user_id"] = user.ID
+		if err := session.Save(req, rr); err != nil {
+			t.Fatalf("Failed to save session: %v", err)
+		}
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("Expected 1 cookie, got %d", len(cookies))
+		}
+		return cookies[0]
+	}
+
+	// load reads the session back using the cookie
+	load := func(cookie *http.Cookie) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		session, err := sessionStore.New(req, "run-club-session")
+		if err != nil {
+			t.Fatalf("Failed to load session: %v", err)
+		}
+		auth, _ := session.Values["authenticated"].(bool)
+		return !session.IsNew && auth
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		cookie := login()
+		if !load(cookie) {
+			t.Errorf("Expected session to be loaded from the database")
+		}
+	})
+
+	t.Run("Revoke user sessions", func(t *testing.T) {
+		cookie := login()
+		active, err := db.GetActiveSessions(time.Now(), time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(active) == 0 || active[0].Username != "phone1" {
+			t.Fatalf("Expected active sessions for phone1, got %+v", active)
+		}
+
+		if err := db.DeleteUserSessions(user.ID); err != nil {
+			t.Fatal(err)
+		}
+		if load(cookie) {
+			t.Errorf("Revoked session should not load")
+		}
+	})
+
+	t.Run("Open streams", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(login())
+		session, err := sessionStore.New(req, "run-club-session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sessionStillActive(session) {
+			t.Fatal("Expected a new session to be active")
+		}
+
+		if err := db.SetUserDisabled(user.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if sessionStillActive(session) {
+			t.Error("Expected a disabled user's session to end")
+		}
+		if err := db.SetUserDisabled(user.ID, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.DeleteSession(hashSessionToken(session.ID)); err != nil {
+			t.Fatal(err)
+		}
+		if sessionStillActive(session) {
+			t.Error("Expected a revoked session to end")
+		}
+	})
+
+	t.Run("Idle timeout", func(t *testing.T) {
+		cookie := login()
+		if _, err := db.db.Exec("UPDATE sessions SET last_seen_at = ?", time.Now().Add(-2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if load(cookie) {
+			t.Errorf("Idle session should have expired")
+		}
+	})
+}
@@ -37,6 +37,59 @@ document.addEventListener('DOMContentLoaded', () => {
             });
         });
         
+        // Parents can register siblings on the same form. Each extra child gets
+        // a copy of the first child's fields, named with a prefix like "child2."
+        const addChild = document.getElementById('add-child');
+        if (addChild) {
+            const firstChild = form.querySelector('.child-fields');
+            let nextChild = 2;
+
+            const numberChildren = () => {
+                const headings = form.querySelectorAll('.child-fields .child-heading');
+                headings.forEach((heading, i) => {
+                    heading.textContent = 'Child ' + (i + 1);
+                    heading.hidden = headings.length === 1;
+                });
+            };
+
+            addChild.addEventListener('click', () => {
+                const prefix = 'child' + nextChild + '.';
+                nextChild++;
+
+                const child = firstChild.cloneNode(true);
+                child.querySelectorAll('[name]').forEach(input => {
+                    input.name = prefix + input.name;
+                    if (input.type === 'checkbox' || input.type === 'radio') {
+                        input.checked = false;
+                    } else {
+                        input.value = '';
+                    }
+                });
+                child.querySelectorAll('[id]').forEach(el => {
+                    el.id = prefix + el.id;
+                });
+                child.querySelectorAll('label[for]').forEach(label => {
+                    label.htmlFor = prefix + label.htmlFor;
+                });
+
+                const remove = document.createElement('button');
+                remove.type = 'button';
+                remove.className = 'remove-child-btn';
+                remove.textContent = 'Remove this child';
+                remove.addEventListener('click', () => {
+                    child.remove();
+                    numberChildren();
+                });
+                child.appendChild(remove);
+
+                const children = form.querySelectorAll('.child-fields');
+                const last = children[children.length - 1];
+                last.parentNode.insertBefore(child, last.nextSibling);
+                numberChildren();
+                child.querySelector('input').focus();
+            });
+        }
+
         // Add form validation
         form.addEventListener('submit', (e) => {
             const parentPhone = document.getElementById('parentContactNumber').value;
@@ -8,8 +8,15 @@ document.addEventListener('DOMContentLoaded', () => {
     
     let scanning = false;
     let recentScans = [];
+    // Matches scanUndoWindow on the server
+    const UNDO_WINDOW_MS = 2 * 60 * 1000;
     let selectedTrackId = null;
     
+    // Scans that couldn't reach the server are queued here and replayed later
+    const QUEUE_KEY = 'runclub-offline-scan-queue';
+    const queueStatus = document.getElementById('offline-queue-status');
+    let syncing = false;
+    
     // Track selection handling
     const trackButtons = document.querySelectorAll('.track-btn');
     const selectedTrackName = document.getElementById('selected-track-name');
@@ -149,7 +156,8 @@ document.addEventListener('DOMContentLoaded', () => {
         resultMessage.className = `alert ${alertType}`;
     }
 
-    async function processQRCode(code) {
+    // source is 'manual' when the runner was checked in by name instead of a badge scan
+    async function processQRCode(code, source = 'qr') {
         try {
             // Check if QR code contains a valid UUID format
             const isUUID = /^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/i.test(code);
@@ -162,18 +170,28 @@ document.addEventListener('DOMContentLoaded', () => {
             updateResultMessage('Processing...', 'alert-info');
             
             // Send the code to the server for validation and recording
-            const requestBody = { code };
+            const requestBody = { code, source };
             if (selectedTrackId) {
                 requestBody.trackId = selectedTrackId;
             }
+            const scannedAt = new Date();
             
-            const response = await fetch('/api/scan', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify(requestBody),
-            });
+            let response;
+            try {
+                response = await fetch('/api/scan', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify(requestBody),
+                });
+            } catch (networkError) {
+                // No signal - keep the scan on this device and sync it later
+                console.warn('Scan request failed, queueing offline:', networkError);
+                enqueueOfflineScan(code, selectedTrackId, scannedAt, source);
+                updateResultMessage('No connection - scan saved on this device and will sync automatically', 'alert-warning');
+                return;
+            }
             
             const result = await response.json();
             
@@ -194,7 +212,9 @@ document.addEventListener('DOMContentLoaded', () => {
                     }
                 }
                 
-                updateResultMessage(message, 'alert-success');
+                // Make milestones stand out so the volunteer can congratulate the runner
+                const reachedMilestone = result.milestones && result.milestones.length > 0;
+                updateResultMessage(message, reachedMilestone ? 'alert-success alert-milestone' : 'alert-success');
                 
                 // Add to recent scans list
                 addToRecentScans({
@@ -219,6 +239,194 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    function loadQueue() {
+        try {
+            return JSON.parse(localStorage.getItem(QUEUE_KEY)) || [];
+        } catch (e) {
+            return [];
+        }
+    }
+
+    function saveQueue(queue) {
+        localStorage.setItem(QUEUE_KEY, JSON.stringify(queue));
+        updateQueueStatus(queue);
+    }
+
+    function updateQueueStatus(queue) {
+        if (!queueStatus) return;
+        if (queue.length === 0) {
+            queueStatus.hidden = true;
+            return;
+        }
+        queueStatus.hidden = false;
+        queueStatus.textContent = `${queue.length} scan${queue.length === 1 ? '' : 's'} waiting to sync`;
+    }
+
+    function newIdempotencyKey() {
+        if (window.crypto && crypto.randomUUID) {
+            return crypto.randomUUID();
+        }
+        return `${Date.now()}-${Math.random().toString(16).slice(2)}`;
+    }
+
+    function enqueueOfflineScan(code, trackId, scannedAt, source) {
+        const queue = loadQueue();
+        const item = {
+            idempotencyKey: newIdempotencyKey(),
+            code,
+            scannedAt: scannedAt.toISOString(),
+            source,
+        };
+        if (trackId) {
+            item.trackId = trackId;
+        }
+        queue.push(item);
+        saveQueue(queue);
+    }
+
+    async function syncOfflineQueue() {
+        const queue = loadQueue();
+        if (syncing || queue.length === 0) return;
+        syncing = true;
+
+        try {
+            const response = await fetch('/api/scans/batch', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ scans: queue }),
+            });
+            if (!response.ok) {
+                console.warn('Batch sync failed with status', response.status);
+                return;
+            }
+            const data = await response.json();
+
+            // Every item gets a final answer from the server, so the synced ones can be dropped.
+            // Only keep scans the server couldn't process for an unexpected reason.
+            const retry = new Set();
+            let recorded = 0;
+            let rejected = 0;
+            data.results.forEach(result => {
+                if (result.status === 'recorded') {
+                    recorded++;
+                    addToRecentScans({
+                        id: result.scanRecord.id,
+                        studentName: `${result.registration.firstName} ${result.registration.lastName}`,
+                        grade: result.registration.grade,
+                        teacher: result.registration.teacher,
+                        seasonName: result.scanRecord.season ? result.scanRecord.season.name : 'Unknown Season',
+                        trackName: result.scanRecord.track ? result.scanRecord.track.name : 'No Track',
+                        trackDistance: result.scanRecord.track ? result.scanRecord.track.distanceMiles : null,
+                        scannedAt: new Date(result.scanRecord.scannedAt),
+                        lapTime: result.lapTime,
+                        pace: result.pace,
+                    });
+                } else if (result.status === 'invalid' && result.message === 'Failed to record scan') {
+                    retry.add(result.idempotencyKey);
+                } else if (result.status !== 'duplicate') {
+                    rejected++;
+                }
+            });
+
+            // Scans may have been queued while the request was in flight
+            const sentKeys = new Set(queue.map(item => item.idempotencyKey));
+            const remaining = loadQueue().filter(item => !sentKeys.has(item.idempotencyKey) || retry.has(item.idempotencyKey));
+            saveQueue(remaining);
+
+            if (recorded > 0 || rejected > 0) {
+                let message = `Synced ${recorded} offline scan${recorded === 1 ? '' : 's'}`;
+                if (rejected > 0) {
+                    message += ` (${rejected} rejected)`;
+                }
+                updateResultMessage(message, rejected > 0 ? 'alert-warning' : 'alert-success');
+            }
+        } catch (error) {
+            console.warn('Offline scan sync failed, will retry:', error);
+        } finally {
+            syncing = false;
+        }
+    }
+
+    // Manual check-in for runners who don't have their badge
+    const manualSearch = document.getElementById('manual-search');
+    const manualResults = document.getElementById('manual-results');
+    let manualSearchTimer = null;
+
+    async function searchRunners(query) {
+        if (query.length < 2) {
+            manualResults.innerHTML = '';
+            return;
+        }
+
+        try {
+            const response = await fetch(`/api/runners/search?q=${encodeURIComponent(query)}`);
+            if (!response.ok) {
+                manualResults.textContent = 'Search failed';
+                return;
+            }
+            const runners = await response.json();
+
+            // Ignore responses for a query the scanner has already changed
+            if (manualSearch.value.trim() !== query) return;
+
+            manualResults.innerHTML = '';
+            if (runners.length === 0) {
+                const empty = document.createElement('p');
+                empty.className = 'empty-history';
+                empty.textContent = 'No runners found';
+                manualResults.appendChild(empty);
+                return;
+            }
+
+            runners.forEach(runner => {
+                const item = document.createElement('div');
+                item.className = 'manual-result';
+
+                const info = document.createElement('div');
+                info.className = 'scan-details';
+                const name = document.createElement('div');
+                name.className = 'scan-name';
+                name.textContent = `${runner.firstName} ${runner.lastName}`;
+                const details = document.createElement('div');
+                details.className = 'scan-info';
+                details.textContent = `Grade: ${runner.grade}, Teacher: ${runner.teacher}`;
+                info.appendChild(name);
+                info.appendChild(details);
+
+                const checkInButton = document.createElement('button');
+                checkInButton.className = 'checkin-btn';
+                checkInButton.textContent = 'Check In';
+                checkInButton.addEventListener('click', async () => {
+                    checkInButton.disabled = true;
+                    await processQRCode(runner.id, 'manual');
+                    manualSearch.value = '';
+                    manualResults.innerHTML = '';
+                });
+
+                item.appendChild(info);
+                item.appendChild(checkInButton);
+                manualResults.appendChild(item);
+            });
+        } catch (error) {
+            console.error('Error searching runners:', error);
+            manualResults.textContent = 'Search failed - check your connection';
+        }
+    }
+
+    if (manualSearch) {
+        manualSearch.addEventListener('input', () => {
+            clearTimeout(manualSearchTimer);
+            manualSearchTimer = setTimeout(() => searchRunners(manualSearch.value.trim()), 250);
+        });
+    }
+
+    updateQueueStatus(loadQueue());
+    syncOfflineQueue();
+    window.addEventListener('online', syncOfflineQueue);
+    setInterval(syncOfflineQueue, 30000);
+
     function addToRecentScans(scan) {
         // Add scan to recent scans array (limit to 10)
         recentScans.unshift(scan);
@@ -304,10 +512,54 @@ document.addEventListener('DOMContentLoaded', () => {
             scanDetails.appendChild(scanTime);
             
             scanItem.appendChild(scanDetails);
+
+            if (scan.undone) {
+                scanItem.classList.add('scan-undone');
+                const undoneLabel = document.createElement('div');
+                undoneLabel.className = 'scan-undone-label';
+                undoneLabel.textContent = 'Undone';
+                scanItem.appendChild(undoneLabel);
+            } else if (Date.now() - scan.scannedAt.getTime() < UNDO_WINDOW_MS) {
+                const undoButton = document.createElement('button');
+                undoButton.className = 'undo-btn';
+                undoButton.textContent = 'Undo';
+                undoButton.addEventListener('click', () => undoScan(scan));
+                scanItem.appendChild(undoButton);
+            }
+
             scanHistory.appendChild(scanItem);
         });
     }
 
+    async function undoScan(scan) {
+        if (!confirm(`Undo the scan for ${scan.studentName}?`)) {
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/scans/undo', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ scanId: scan.id }),
+            });
+            const result = await response.json();
+
+            if (response.ok && result.success) {
+                scan.undone = true;
+                updateResultMessage(`Undid scan for ${scan.studentName}`, 'alert-info');
+            } else {
+                updateResultMessage(result.error || 'Could not undo scan', 'alert-warning');
+            }
+        } catch (error) {
+            console.error('Error undoing scan:', error);
+            updateResultMessage('Could not undo scan - check your connection', 'alert-danger');
+        }
+
+        updateScanHistory();
+    }
+
     function formatTime(date) {
         // Format date as "Today at 2:30 PM" or "May 8 at 2:30 PM"
         const now = new Date();
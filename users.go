@@ -0,0 +1,358 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength is the shortest password accepted for a user account
+const minPasswordLength = 8
+
+// User represents a login account for admins, scanner volunteers and viewers
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	IsDisabled   bool      `json:"isDisabled"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// isValidRole checks if a role is one of the known user roles
+func isValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleScanner || role == RoleViewer
+}
+
+// hashPassword hashes a plaintext password with bcrypt
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether the password matches the user's stored hash
+func (u *User) checkPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// newUser validates the input and builds a user with a hashed password
+func newUser(username, password, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errUserInput("Username is required")
+	}
+	if !isValidRole(role) {
+		return nil, errUserInput("Invalid role")
+	}
+	if len(password) < minPasswordLength {
+		return nil, errUserInput("Password must be at least 8 characters")
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
+}
+
+// errUserInput is a validation error whose message is safe to show to the admin
+type errUserInput string
+
+func (e errUserInput) Error() string {
+	return string(e)
+}
+
+// ensureDefaultAdmin creates an initial admin account when no users exist yet.
+// The password comes from ADMIN_PASSWORD, or is generated and logged once.
+func ensureDefaultAdmin(db *Database) error {
+	count, err := db.GetUserCount()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	generated := false
+	if password == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = hex.EncodeToString(buf)
+		generated = true
+	}
+
+	user, err := newUser(username, password, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := db.CreateUser(user); err != nil {
+		return err
+	}
+
+	if generated {
+		log.Printf("Created initial admin account %q with password %q - change it after logging in", username, password)
+	} else {
+		log.Printf("Created initial admin account %q from ADMIN_PASSWORD", username)
+	}
+	return nil
+}
+
+// usersHandler shows the user management page and creates new accounts
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	data := PageData{
+		Title: "Run Club - Manage Users",
+		User:  username,
+		Role:  role,
+	}
+
+	// For POST requests, create the new user then fall through to render the list
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+
+		user, err := newUser(r.FormValue("username"), r.FormValue("password"), r.FormValue("role"))
+		if err == nil {
+			err = database.CreateUser(user)
+		}
+		if err != nil {
+			log.Printf("Error creating user: %v", err)
+			data.Message = userErrorMessage(err)
+		} else {
+			data.Success = true
+			data.Message = "Created user " + user.Username
+		}
+	} else if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, err := database.GetAllUsers()
+	if err != nil {
+		log.Printf("Error getting users: %v", err)
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+	data.Users = users
+
+	renderTemplate(w, "users", data)
+}
+
+// userUpdateHandler handles the disable/enable and password reset forms on the users page
+func userUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := store.Get(r, "run-club-session")
+	currentUserID, _ := session.Values["user_id"].(string)
+
+	update := userUpdate{}
+	switch r.FormValue("action") {
+	case "disable":
+		disabled := true
+		update.Disabled = &disabled
+	case "enable":
+		disabled := false
+		update.Disabled = &disabled
+	case "reset_password":
+		password := r.FormValue("password")
+		update.Password = &password
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	if err := applyUserUpdate(r.FormValue("id"), currentUserID, update); err != nil {
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, userErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// userUpdate describes a change to an existing account; nil fields are left unchanged
+type userUpdate struct {
+	Disabled *bool   `json:"disabled,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
+
+// applyUserUpdate disables/enables and resets the password of a user
+func applyUserUpdate(id, currentUserID string, update userUpdate) error {
+	if id == "" {
+		return errUserInput("User ID is required")
+	}
+	if _, exists, err := database.GetUser(id); err != nil {
+		return err
+	} else if !exists {
+		return errUserInput("User not found")
+	}
+
+	if update.Disabled != nil {
+		// Don't let an admin lock themselves out
+		if *update.Disabled && id == currentUserID {
+			return errUserInput("You cannot disable your own account")
+		}
+		if err := database.SetUserDisabled(id, *update.Disabled); err != nil {
+			return err
+		}
+		// Log out a disabled user everywhere
+		if *update.Disabled {
+			if err := database.DeleteUserSessions(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if update.Password != nil {
+		if len(*update.Password) < minPasswordLength {
+			return errUserInput("Password must be at least 8 characters")
+		}
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			return err
+		}
+		if err := database.UpdateUserPassword(id, hash); err != nil {
+			return err
+		}
+		// A reset password should end any sessions started with the old one,
+		// except the admin's own session when resetting their own password
+		if id != currentUserID {
+			if err := database.DeleteUserSessions(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// userErrorMessage returns a message suitable for display for a user management error
+func userErrorMessage(err error) string {
+	if msg, ok := err.(errUserInput); ok {
+		return string(msg)
+	}
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return "A user with that username already exists"
+	}
+	return "Failed to save user"
+}
+
+// apiUsersHandler lists and creates user accounts
+func apiUsersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users, err := database.GetAllUsers()
+		if err != nil {
+			log.Printf("Error retrieving users: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		sendJSONResponse(w, users, http.StatusOK)
+
+	case http.MethodPost:
+		var request struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			sendJSONResponse(w, map[string]string{"error": "Invalid request format"}, http.StatusBadRequest)
+			return
+		}
+
+		user, err := newUser(request.Username, request.Password, request.Role)
+		if err == nil {
+			err = database.CreateUser(user)
+		}
+		if err != nil {
+			log.Printf("Error creating user: %v", err)
+			sendJSONResponse(w, map[string]string{"error": userErrorMessage(err)}, http.StatusBadRequest)
+			return
+		}
+		sendJSONResponse(w, user, http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apiUserHandler updates a single user account at /api/users/{id}
+func apiUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/users/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var update userUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		sendJSONResponse(w, map[string]string{"error": "Invalid request format"}, http.StatusBadRequest)
+		return
+	}
+
+	session, _ := store.Get(r, "run-club-session")
+	currentUserID, _ := session.Values["user_id"].(string)
+
+	if err := applyUserUpdate(id, currentUserID, update); err != nil {
+		log.Printf("Error updating user: %v", err)
+		sendJSONResponse(w, map[string]string{"error": userErrorMessage(err)}, http.StatusBadRequest)
+		return
+	}
+
+	user, _, err := database.GetUser(id)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	sendJSONResponse(w, user, http.StatusOK)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// VenmoTransaction is a payment received, read from a Venmo statement
+type VenmoTransaction struct {
+	ID          string
+	Date        time.Time
+	From        string // Who paid
+	Note        string
+	AmountCents int
+}
+
+// VenmoMatch is a Venmo payment and the runner it was recorded for
+type VenmoMatch struct {
+	Transaction  *VenmoTransaction
+	Registration *Registration
+}
+
+// PaymentImport is what happened to each payment in an imported Venmo statement
+type PaymentImport struct {
+	Matched   []*VenmoMatch
+	Unmatched []*VenmoTransaction // No runner's parent or name matched; record these by hand
+	Skipped   int                 // Already recorded by an earlier import
+}
+
+// errNotVenmoStatement is returned when an uploaded file has no Venmo transaction columns
+var errNotVenmoStatement = errors.New("this doesn't look like a Venmo statement; download the CSV statement from Venmo's Statements page")
+
+// parseVenmoStatement reads the money received in a Venmo CSV statement.
+// Statements start with a few lines about the account before the header
+// row, and end with totals; payments sent, declined and pending
+// transactions are left out.
+func parseVenmoStatement(r io.Reader) ([]*VenmoTransaction, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var columns map[string]int
+	var transactions []*VenmoTransaction
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the statement: %w", err)
+		}
+
+		if columns == nil {
+			columns = venmoColumns(record)
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if field("ID") == "" || field("Status") != "Complete" {
+			continue
+		}
+		amount, err := parseDollars(field("Amount (total)"))
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %w", field("ID"), err)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		// A charge is a request someone paid, so the payer is who it was sent to
+		payer := field("From")
+		if field("Type") == "Charge" {
+			payer = field("To")
+		}
+		date, err := time.ParseInLocation("2006-01-02T15:04:05", field("Datetime"), clubLocation())
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: invalid date %q", field("ID"), field("Datetime"))
+		}
+
+		transactions = append(transactions, &VenmoTransaction{
+			ID:          field("ID"),
+			Date:        date,
+			From:        payer,
+			Note:        field("Note"),
+			AmountCents: amount,
+		})
+	}
+
+	if columns == nil {
+		return nil, errNotVenmoStatement
+	}
+	return transactions, nil
+}
+
+// venmoColumns returns the column positions in a Venmo statement's header
+// row, or nil if record isn't the header
+func venmoColumns(record []string) map[string]int {
+	columns := make(map[string]int)
+	for i, name := range record {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"ID", "Datetime", "Status", "From", "Amount (total)"} {
+		if _, ok := columns[required]; !ok {
+			return nil
+		}
+	}
+	return columns
+}
+
+// noteWords splits a Venmo note into normalized words
+func noteWords(note string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(note, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '-'
+	}) {
+		words[normalizeName(word)] = true
+	}
+	return words
+}
+
+// matchVenmoTransaction finds the runner a Venmo payment was for. A note
+// naming a runner decides it; otherwise the payment goes to a runner whose
+// parent's name matches the payer, preferring a child named in the note.
+// When a parent has several children, it goes to the first of them who
+// still owes something. It returns nil if no runner matches.
+func matchVenmoTransaction(t *VenmoTransaction, balances []*Balance) *Balance {
+	payer := normalizeName(t.From)
+	note := normalizeName(t.Note)
+	words := noteWords(t.Note)
+
+	var byParent, byNote, byFirstName []*Balance
+	for _, b := range balances {
+		reg := b.Registration
+		parentLastName := reg.ParentLastName
+		if strings.TrimSpace(parentLastName) == "" {
+			parentLastName = reg.LastName
+		}
+		isParent := payer != "" && payer == normalizeName(reg.ParentFirstName+parentLastName)
+		if isParent {
+			byParent = append(byParent, b)
+			if words[normalizeName(reg.FirstName)] {
+				byFirstName = append(byFirstName, b)
+			}
+		}
+		if name := normalizeName(reg.FirstName + reg.LastName); name != "" && strings.Contains(note, name) {
+			byNote = append(byNote, b)
+		}
+	}
+
+	candidates := byParent
+	if len(byNote) > 0 {
+		candidates = byNote
+		var both []*Balance
+		for _, b := range byNote {
+			for _, p := range byParent {
+				if b == p {
+					both = append(both, b)
+				}
+			}
+		}
+		if len(both) > 0 {
+			candidates = both
+		}
+	} else if len(byFirstName) > 0 {
+		candidates = byFirstName
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+	for _, b := range candidates {
+		if b.Outstanding() > 0 {
+			return b
+		}
+	}
+	return candidates[0]
+}
+
+// importVenmoStatement records the payments in an uploaded Venmo statement
+// for a season's runners. Payments already imported are skipped, and the
+// rest are saved together or not at all.
+func importVenmoStatement(r *http.Request, season *Season, actor string) (*PaymentImport, error) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // Max 10MB
+		return nil, errors.New("error parsing form")
+	}
+	file, _, err := r.FormFile("statement")
+	if err != nil {
+		return nil, errors.New("please choose a Venmo statement to import")
+	}
+	defer file.Close()
+
+	transactions, err := parseVenmoStatement(file)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := database.GetPaymentReferences(PaymentMethodVenmo)
+	if err != nil {
+		log.Printf("Error getting payment references: %v", err)
+		return nil, errors.New("failed to check for payments already imported")
+	}
+	balances, err := seasonBalances(season)
+	if err != nil {
+		log.Printf("Error getting balances: %v", err)
+		return nil, errors.New("failed to retrieve balances")
+	}
+
+	result := &PaymentImport{}
+	var payments []*Payment
+	now := time.Now()
+	for _, t := range transactions {
+		if imported[t.ID] {
+			result.Skipped++
+			continue
+		}
+		b := matchVenmoTransaction(t, balances)
+		if b == nil {
+			result.Unmatched = append(result.Unmatched, t)
+			continue
+		}
+
+		// Count it now so a parent's next payment goes to their next child
+		b.Paid += t.AmountCents
+		result.Matched = append(result.Matched, &VenmoMatch{Transaction: t, Registration: b.Registration})
+		payments = append(payments, &Payment{
+			ID:             uuid.New().String(),
+			RegistrationID: b.Registration.ID,
+			AmountCents:    t.AmountCents,
+			Method:         PaymentMethodVenmo,
+			Reference:      t.ID,
+			PaidAt:         t.Date,
+			RecordedBy:     actor,
+			RecordedAt:     now,
+		})
+	}
+
+	if len(payments) > 0 {
+		if err := database.RecordPayments(payments, actor); err != nil {
+			log.Printf("Error recording Venmo payments: %v", err)
+			return nil, errors.New("failed to record payments; none were saved")
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testVenmoStatement = `Account Statement - (@runclub-treasurer) ,,,,,,,,,,,,,,,,,,,,,
+Account Activity,,,,,,,,,,,,,,,,,,,,,
+,ID,Datetime,Type,Status,Note,From,To,Amount (total),Amount (tip),Amount (tax),Amount (fee),Tax Rate,Tax Exempt,Funding Source,Destination,Beginning Balance,Ending Balance,Statement Period Venmo Fees,Terminal Location,Year to Date Venmo Fees,Disclaimer
+,,,,,,,,,,,,,,,,$0.00,,,,,
+,4001,2026-09-02T18:04:11,Payment,Complete,Run club for Ben Doe,Pat Doe,Run Club,+ $30.00,,0,,0,,,Venmo balance,,,,Venmo,,
+,4002,2026-09-03T07:15:00,Payment,Complete,run club,Pat Doe,Run Club,+ $40.00,,0,,0,,,Venmo balance,,,,Venmo,,
+,4003,2026-09-03T08:00:00,Charge,Complete,Run club fee - Cara,Run Club,Sam Lee,+ $40.00,,0,,0,,Venmo balance,,,,,Venmo,,
+,4004,2026-09-04T12:00:00,Payment,Complete,Pizza,Run Club,Dana Fox,- $12.00,,0,,0,,Venmo balance,,,,,Venmo,,
+,4005,2026-09-04T13:00:00,Payment,Issued,Run club,Pat Doe,Run Club,+ $40.00,,0,,0,,,Venmo balance,,,,Venmo,,
+,4006,2026-09-05T09:30:00,Payment,Complete,Fees,Grandma Doe,Run Club,+ $40.00,,0,,0,,,Venmo balance,,,,Venmo,,
+,,,,,,,,,,,,,,,,,$138.00,$0.00,,$0.00,
+`
+
+func TestParseVenmoStatement(t *testing.T) {
+	transactions, err := parseVenmoStatement(strings.NewReader(testVenmoStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The payment sent and the one not yet complete are left out
+	if len(transactions) != 4 {
+		t.Fatalf("Expected 4 payments received, got %d", len(transactions))
+	}
+	first := transactions[0]
+	if first.ID != "4001" || first.From != "Pat Doe" || first.Note != "Run club for Ben Doe" || first.AmountCents != 3000 {
+		t.Errorf("Unexpected transaction %+v", first)
+	}
+	if want := time.Date(2026, 9, 2, 18, 4, 11, 0, clubLocation()); !first.Date.Equal(want) {
+		t.Errorf("Expected the date in the club's time zone, got %v", first.Date)
+	}
+	if transactions[2].From != "Sam Lee" {
+		t.Errorf("Expected a paid charge to be from who it was sent to, got %q", transactions[2].From)
+	}
+
+	if _, err := parseVenmoStatement(strings.NewReader("First Name,Last Name\nAnna,Doe\n")); err != errNotVenmoStatement {
+		t.Errorf("Expected errNotVenmoStatement for another CSV, got %v", err)
+	}
+}
+
+func TestMatchVenmoTransaction(t *testing.T) {
+	balance := func(first, last, parentFirst, parentLast string, fee int) *Balance {
+		return &Balance{
+			Registration: &Registration{ID: first, FirstName: first, LastName: last, ParentFirstName: parentFirst, ParentLastName: parentLast},
+			Fee:          fee,
+		}
+	}
+	anna := balance("Anna", "Doe", "Pat", "Doe", 4000)
+	ben := balance("Ben", "Doe", "Pat", "Doe", 3000)
+	cara := balance("Cara", "Lee", "Sam", "", 4000)
+	balances := []*Balance{anna, ben, cara}
+
+	tests := []struct {
+		name string
+		t    *VenmoTransaction
+		want *Balance
+	}{
+		{"runner named in note", &VenmoTransaction{From: "Someone Else", Note: "For Ben Doe's run club"}, ben},
+		{"parent's child by first name", &VenmoTransaction{From: "Pat Doe", Note: "ben - run club"}, ben},
+		{"parent's first child who owes", &VenmoTransaction{From: "pat doe", Note: "run club"}, anna},
+		{"parent without a last name", &VenmoTransaction{From: "Sam Lee", Note: "fees"}, cara},
+		{"nobody", &VenmoTransaction{From: "Grandma Doe", Note: "fees"}, nil},
+	}
+	for _, tt := range tests {
+		if got := matchVenmoTransaction(tt.t, balances); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	anna.Paid = 4000
+	if got := matchVenmoTransaction(&VenmoTransaction{From: "Pat Doe", Note: "run club"}, balances); got != ben {
+		t.Errorf("Expected the payment to go to the child who still owes, got %v", got)
+	}
+}
+
+func TestImportVenmoStatement(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSeasonFees(season.ID, 4000, 1000, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	for _, reg := range []*Registration{
+		newTestRegistration(season.ID, "Anna", "Doe", "pat@example.com"),
+		newTestRegistration(season.ID, "Ben", "Doe", "pat@example.com"),
+		newTestRegistration(season.ID, "Cara", "Lee", "sam@example.com"),
+	} {
+		reg.ParentFirstName = "Pat"
+		reg.ParentLastName = "Doe"
+		if reg.FirstName == "Cara" {
+			reg.ParentFirstName, reg.ParentLastName, reg.ParentContactNumber = "Sam", "Lee", "555-9876"
+		}
+		if err := db.SaveRegistration(reg, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upload := func() *PaymentImport {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("statement", "venmo.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(testVenmoStatement))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/payments?season_id="+season.ID, &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		result, err := importVenmoStatement(req, season, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := upload()
+	if len(result.Matched) != 3 {
+		t.Fatalf("Expected 3 payments to be recorded, got %d", len(result.Matched))
+	}
+	if len(result.Unmatched) != 1 || result.Unmatched[0].From != "Grandma Doe" {
+		t.Errorf("Expected Grandma Doe's payment not to match, got %+v", result.Unmatched)
+	}
+
+	balances, err := seasonBalances(season)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid := make(map[string]int)
+	for _, b := range balances {
+		paid[b.Registration.FirstName] = b.Paid
+	}
+	if paid["Anna"] != 4000 || paid["Ben"] != 3000 || paid["Cara"] != 4000 {
+		t.Errorf("Unexpected payments recorded: %v", paid)
+	}
+	if summary := summarizeBalances(balances); summary.Outstanding != 0 {
+		t.Errorf("Expected everyone to be paid up, got %+v", summary)
+	}
+
+	// Importing the same statement again doesn't record anything twice
+	result = upload()
+	if len(result.Matched) != 0 || result.Skipped != 3 {
+		t.Errorf("Expected the payments to be skipped, got %d recorded and %d skipped", len(result.Matched), result.Skipped)
+	}
+}
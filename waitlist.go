@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Waitlist entry statuses
+const (
+	WaitlistStatusWaiting  = "waiting"
+	WaitlistStatusPromoted = "promoted"
+	WaitlistStatusRemoved  = "removed"
+)
+
+// WaitlistEntry is a child who signed up after their season filled up
+type WaitlistEntry struct {
+	ID             string        `json:"id"`
+	SeasonID       string        `json:"seasonId"`
+	Position       int           `json:"position"`     // Place in line, starting at 1
+	Registration   *Registration `json:"registration"` // The form as the parent filled it in
+	Status         string        `json:"status"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	ResolvedAt     *time.Time    `json:"resolvedAt,omitempty"` // When the child was promoted or removed
+	ResolvedBy     string        `json:"resolvedBy,omitempty"`
+	RegistrationID string        `json:"registrationId,omitempty"` // The registration created on promotion
+	NotifiedAt     *time.Time    `json:"notifiedAt,omitempty"`     // When the parent was told about the spot
+}
+
+// IsWaiting reports whether the child is still in line
+func (e *WaitlistEntry) IsWaiting() bool {
+	return e.Status == WaitlistStatusWaiting
+}
+
+// waitlistDetails returns the parts of a registration form kept on the
+// waitlist. The ID, season and registration time are set on promotion.
+func waitlistDetails(reg *Registration) *Registration {
+	details := *reg
+	details.ID = ""
+	details.SeasonID = nil
+	details.RunnerID = ""
+	details.RegisteredAt = time.Time{}
+	details.Season = nil
+	return &details
+}
+
+// waitlistURL returns the waitlist page for a season, with an optional message to show
+func waitlistURL(seasonID, message string) string {
+	u := "/waitlist?season_id=" + url.QueryEscape(seasonID)
+	if message != "" {
+		u += "&message=" + url.QueryEscape(message)
+	}
+	return u
+}
+
+// nextOnWaitlistMessage describes who is at the front of a season's
+// waitlist, or returns "" if no one is waiting
+func nextOnWaitlistMessage(seasonID string) string {
+	next, found, err := database.GetNextWaitlistEntry(seasonID)
+	if err != nil {
+		log.Printf("Error getting next waitlist entry: %v", err)
+		return ""
+	}
+	if !found {
+		return ""
+	}
+	return fmt.Sprintf("Next on the waitlist: %s %s (grade %s). Promote them from the Waitlist page.",
+		next.Registration.FirstName, next.Registration.LastName, next.Registration.Grade)
+}
+
+// notifyWaitlistPromotion tells a parent their child has been given a spot,
+// and sends the link for updating the registration
+func notifyWaitlistPromotion(reg *Registration, season *Season, editURL string) error {
+	subject := fmt.Sprintf("%s is registered for Run Club", reg.FirstName)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Good news! A spot opened up in %s and %s %s has been moved off the waitlist. "+
+		"They are now registered for Run Club.\n\n"+
+		"If your plans have changed and %s can no longer take part, please let us know so "+
+		"we can offer the spot to the next family.\n\n"+
+		"You can update your contact details, dismissal plans, allergies or medical information "+
+		"at any time using this private link:\n\n%s\n\n"+
+		"Clayton Run Club\n",
+		reg.ParentFirstName, season.Name, reg.FirstName, reg.LastName, reg.FirstName, editURL)
+	return notifier.Notify(reg.ParentEmail, subject, body)
+}
+
+// waitlistHandler shows a season's waitlist and how many spots are open
+func waitlistHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Waitlist",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+		Message: r.URL.Query().Get("message"),
+		Success: r.URL.Query().Get("message") != "",
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+
+		entries, err := database.GetWaitlist(season.ID)
+		if err != nil {
+			log.Printf("Error getting waitlist: %v", err)
+			http.Error(w, "Failed to retrieve waitlist", http.StatusInternalServerError)
+			return
+		}
+		data.WaitlistEntries = entries
+
+		count, err := database.GetRegistrationCountForSeason(season.ID)
+		if err != nil {
+			log.Printf("Error getting registration count: %v", err)
+			http.Error(w, "Failed to retrieve registration count", http.StatusInternalServerError)
+			return
+		}
+		data.RegistrationCount = count
+		data.RegistrationFull = season.IsRegistrationFull(count)
+	}
+
+	renderTemplate(w, "waitlist", data)
+}
+
+// waitlistUpdateHandler promotes a waitlisted child to a registration and
+// notifies their parent, or takes them off the waitlist
+func waitlistUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "Waitlist entry ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var message string
+	var entry *WaitlistEntry
+	switch r.FormValue("action") {
+	case "promote":
+		var reg *Registration
+		entry, reg, err = database.PromoteWaitlistEntry(id, username)
+		if err == nil {
+			message = fmt.Sprintf("%s %s is registered", reg.FirstName, reg.LastName)
+			message += promotionNotice(entry, reg, requestBaseURL(r))
+		}
+	case "remove":
+		var found bool
+		entry, found, err = database.GetWaitlistEntry(id)
+		if err == nil && !found {
+			err = ErrWaitlistEntryNotFound
+		}
+		if err == nil {
+			err = database.RemoveWaitlistEntry(id, username)
+			message = fmt.Sprintf("%s %s was taken off the waitlist", entry.Registration.FirstName, entry.Registration.LastName)
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrWaitlistEntryNotFound):
+		http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrWaitlistEntryResolved):
+		http.Error(w, "This child is no longer on the waitlist", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error updating waitlist: %v", err)
+		http.Error(w, "Failed to update waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, waitlistURL(entry.SeasonID, message), http.StatusSeeOther)
+}
+
+// promotionNotice notifies the parent of a promoted child and describes the
+// outcome for the admin. The registration stands even if the notification fails.
+func promotionNotice(entry *WaitlistEntry, reg *Registration, baseURL string) string {
+	season, found, err := database.GetSeason(entry.SeasonID)
+	if err != nil || !found {
+		log.Printf("Error getting season %s to notify parent: %v", entry.SeasonID, err)
+		return ", but their parent could not be notified"
+	}
+
+	if err := notifyWaitlistPromotion(reg, season, registrationEditURL(baseURL, reg)); err != nil {
+		log.Printf("Error notifying parent of waitlist promotion %s: %v", entry.ID, err)
+		if errors.Is(err, ErrNoRecipient) {
+			return ", but there is no parent email to notify"
+		}
+		return ", but the notification to their parent failed"
+	}
+
+	if err := database.MarkWaitlistNotified(entry.ID, time.Now()); err != nil {
+		log.Printf("Error marking waitlist entry notified: %v", err)
+	}
+	return " and their parent has been notified"
+}
+
+// publicWaitlistedHandler confirms the places of one or more children on a
+// season's waitlist
+func publicWaitlistedHandler(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	token := r.URL.Query().Get("token")
+	if len(ids) == 0 || token == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	// Verify token is valid
+	season, exists, err := database.GetSeasonByRegistrationToken(token)
+	if err != nil || !exists {
+		http.Error(w, "Invalid registration link", http.StatusNotFound)
+		return
+	}
+
+	entries, ok := publicWaitlistEntries(w, ids, season)
+	if !ok {
+		return
+	}
+
+	renderTemplate(w, "waitlisted", PageData{
+		Title:           "Run Club - Waitlist",
+		ActiveSeason:    season,
+		WaitlistEntries: entries,
+		// For public registration, we don't have a logged-in user
+		User: "",
+		Role: "",
+	})
+}
+
+// publicWaitlistEntries looks up the waitlist entries a parent just created
+// for a season. It writes an error response and returns false if any of
+// them can't be shown.
+func publicWaitlistEntries(w http.ResponseWriter, ids []string, season *Season) ([]*WaitlistEntry, bool) {
+	var entries []*WaitlistEntry
+	for _, id := range ids {
+		entry, found, err := database.GetWaitlistEntry(id)
+		if err != nil {
+			log.Printf("Error getting waitlist entry: %v", err)
+			http.Error(w, "Failed to retrieve waitlist entry", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !found || entry.SeasonID != season.ID {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return nil, false
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
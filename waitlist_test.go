@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+)
+
+// recordingNotifier keeps notifications instead of sending them
+type recordingNotifier struct {
+	sent []string
+}
+
+func (n *recordingNotifier) Notify(to, subject, body string) error {
+	if to == "" {
+		return ErrNoRecipient
+	}
+	n.sent = append(n.sent, to+": "+subject)
+	return nil
+}
+
+func addTestWaitlistEntry(t *testing.T, db *Database, seasonID, firstName string) *WaitlistEntry {
+	t.Helper()
+	entry := &WaitlistEntry{
+		ID:           uuid.New().String(),
+		SeasonID:     seasonID,
+		Registration: newTestRegistration(seasonID, firstName, "Doe", "parent@example.com"),
+		CreatedAt:    time.Now(),
+	}
+	if err := db.AddToWaitlist(entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestWaitlist(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anna := addTestWaitlistEntry(t, db, season.ID, "Anna")
+	ben := addTestWaitlistEntry(t, db, season.ID, "Ben")
+	cara := addTestWaitlistEntry(t, db, season.ID, "Cara")
+	if anna.Position != 1 || ben.Position != 2 || cara.Position != 3 {
+		t.Fatalf("Expected positions 1, 2, 3, got %d, %d, %d", anna.Position, ben.Position, cara.Position)
+	}
+
+	positions := func() map[string]int {
+		entries, err := db.GetWaitlist(season.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int{}
+		for _, e := range entries {
+			if e.IsWaiting() {
+				got[e.Registration.FirstName] = e.Position
+			}
+		}
+		return got
+	}
+
+	t.Run("Promote", func(t *testing.T) {
+		entry, reg, err := db.PromoteWaitlistEntry(anna.ID, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.RegistrationID != reg.ID || entry.Status != WaitlistStatusPromoted {
+			t.Errorf("Expected entry to point at the new registration, got %+v", entry)
+		}
+
+		saved, found, err := db.GetRegistration(reg.ID)
+		if err != nil || !found {
+			t.Fatalf("Expected promoted registration to be saved, found=%v err=%v", found, err)
+		}
+		if saved.FirstName != "Anna" || saved.ParentEmail != "parent@example.com" || *saved.SeasonID != season.ID {
+			t.Errorf("Expected the waitlisted details to be registered, got %+v", saved)
+		}
+
+		if got := positions(); got["Ben"] != 1 || got["Cara"] != 2 || len(got) != 2 {
+			t.Errorf("Expected Ben and Cara to move up, got %v", got)
+		}
+
+		if _, _, err := db.PromoteWaitlistEntry(anna.ID, "admin"); !errors.Is(err, ErrWaitlistEntryResolved) {
+			t.Errorf("Expected ErrWaitlistEntryResolved promoting twice, got %v", err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := db.RemoveWaitlistEntry(ben.ID, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if got := positions(); got["Cara"] != 1 || len(got) != 1 {
+			t.Errorf("Expected only Cara left at the front, got %v", got)
+		}
+
+		next, found, err := db.GetNextWaitlistEntry(season.ID)
+		if err != nil || !found || next.ID != cara.ID {
+			t.Errorf("Expected Cara to be next, got %+v (found=%v err=%v)", next, found, err)
+		}
+
+		if err := db.RemoveWaitlistEntry(uuid.New().String(), "admin"); !errors.Is(err, ErrWaitlistEntryNotFound) {
+			t.Errorf("Expected ErrWaitlistEntryNotFound, got %v", err)
+		}
+	})
+}
+
+func TestPublicRegisterWaitlistsWhenFull(t *testing.T) {
+	originalDB := database
+	originalNotifier := notifier
+	defer func() {
+		database = originalDB
+		notifier = originalNotifier
+	}()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	sent := &recordingNotifier{}
+	notifier = sent
+	store = sessions.NewCookieStore([]byte("test-secret"))
+	publicStore = sessions.NewCookieStore([]byte("test-secret"))
+
+	season := &Season{ID: uuid.New().String(), Name: "Full Season", CreatedAt: time.Now(), MaxRegistrations: 1}
+	if err := db.SaveSeason(season, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	registered := createTestRegistration(t, db, season.ID)
+
+	form := url.Values{
+		"firstName":           {"Wait"},
+		"lastName":            {"Listed"},
+		"grade":               {"2"},
+		"parentFirstName":     {"Pat"},
+		"parentContactNumber": {"555-555-1234"},
+		"parentEmail":         {"pat@example.com"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/public/register?token="+season.RegistrationToken, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	publicRegisterHandler(rr, req)
+
+	if rr.Code != http.StatusSeeOther || !strings.HasPrefix(rr.Header().Get("Location"), "/public/waitlisted?") {
+		t.Fatalf("Expected redirect to the waitlist confirmation, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	count, err := db.GetRegistrationCountForSeason(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected no new registration while full, got %d", count)
+	}
+
+	// Withdrawing the registered runner points the admin at the waitlist
+	withdraw := url.Values{"id": {registered.ID}, "action": {"withdraw"}}
+	req = httptest.NewRequest(http.MethodPost, "/registrations/withdraw", strings.NewReader(withdraw.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session, _ := store.Get(req, "run-club-session")
+	session.Values["username"] = "admin"
+	rr = httptest.NewRecorder()
+	registrationWithdrawHandler(rr, req)
+	if location, _ := url.QueryUnescape(rr.Header().Get("Location")); !strings.Contains(location, "Next on the waitlist: Wait Listed") {
+		t.Errorf("Expected the next waitlisted child in the redirect, got %q", location)
+	}
+
+	next, found, err := db.GetNextWaitlistEntry(season.ID)
+	if err != nil || !found {
+		t.Fatalf("Expected a waitlist entry, found=%v err=%v", found, err)
+	}
+
+	promote := url.Values{"id": {next.ID}, "action": {"promote"}}
+	req = httptest.NewRequest(http.MethodPost, "/waitlist/update", strings.NewReader(promote.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session, _ = store.Get(req, "run-club-session")
+	session.Values["username"] = "admin"
+	rr = httptest.NewRecorder()
+	waitlistUpdateHandler(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected redirect after promoting, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(sent.sent) != 1 || !strings.HasPrefix(sent.sent[0], "pat@example.com: ") {
+		t.Errorf("Expected the parent to be notified, got %v", sent.sent)
+	}
+	promoted, _, err := db.GetWaitlistEntry(next.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoted.NotifiedAt == nil {
+		t.Error("Expected the entry to be marked notified")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SeasonWaiver is one version of the waiver parents agree to when they
+// register for a season. Publishing new text adds a new version.
+type SeasonWaiver struct {
+	ID        string    `json:"id"`
+	SeasonID  string    `json:"seasonId"`
+	Version   int       `json:"version"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// SignedBy reports whether a registration's parent agreed to this version of the waiver
+func (w *SeasonWaiver) SignedBy(reg *Registration) bool {
+	return reg.WaiverVersion == w.Version
+}
+
+// consentFromForm records a parent's agreement to the season's current waiver
+// on a registration. It returns an input error if the parent didn't tick the
+// box and type their name, or signed a version that has since been replaced.
+// Seasons without a waiver need no consent.
+func consentFromForm(r *http.Request, reg *Registration, waiver *SeasonWaiver) error {
+	if waiver == nil {
+		return nil
+	}
+
+	signature := strings.TrimSpace(r.FormValue("waiverSignature"))
+	if r.FormValue("waiverAgree") != "true" || signature == "" {
+		return errRegistrationInput("Please read the waiver, tick the box to agree, and type your full name to sign it")
+	}
+	if version, _ := strconv.Atoi(r.FormValue("waiverVersion")); version != waiver.Version {
+		return errRegistrationInput("The waiver was updated while you had this page open. Please reload the page, read the new waiver, and sign it again")
+	}
+
+	now := time.Now()
+	reg.WaiverVersion = waiver.Version
+	reg.WaiverSignedName = signature
+	reg.WaiverSignedAt = &now
+	reg.WaiverSignedIP = clientIP(r)
+	return nil
+}
+
+// currentWaiver returns a season's current waiver, or nil if it doesn't have one
+func currentWaiver(seasonID string) (*SeasonWaiver, error) {
+	waiver, found, err := database.GetCurrentWaiver(seasonID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return waiver, nil
+}
+
+// unsignedRegistrations lists the registrations whose parent hasn't agreed to
+// the current version of a waiver
+func unsignedRegistrations(regs []*Registration, waiver *SeasonWaiver) []*Registration {
+	var unsigned []*Registration
+	for _, reg := range regs {
+		if !waiver.SignedBy(reg) {
+			unsigned = append(unsigned, reg)
+		}
+	}
+	return unsigned
+}
+
+// seasonWaiverHandler publishes a new version of a season's waiver
+func seasonWaiverHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	season, found, err := database.GetSeason(r.FormValue("season_id"))
+	if err != nil {
+		log.Printf("Error getting season: %v", err)
+		http.Error(w, "Failed to retrieve season", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Season not found", http.StatusNotFound)
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Error(w, "Waiver text is required", http.StatusBadRequest)
+		return
+	}
+
+	waiver := &SeasonWaiver{
+		ID:        uuid.New().String(),
+		SeasonID:  season.ID,
+		Body:      body,
+		CreatedAt: time.Now(),
+		CreatedBy: username,
+	}
+	if err := database.PublishSeasonWaiver(waiver, username); err != nil {
+		log.Printf("Error publishing waiver: %v", err)
+		http.Error(w, "Failed to publish waiver", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, seasonsURL(fmt.Sprintf("Published version %d of the %s waiver", waiver.Version, season.Name)), http.StatusSeeOther)
+}
+
+// waiversHandler lists a season's runners whose parents haven't agreed to
+// the current version of its waiver
+func waiversHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "run-club-session")
+	username := session.Values["username"].(string)
+	role := session.Values["role"].(string)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seasons, err := database.GetAllSeasons()
+	if err != nil {
+		log.Printf("Error getting seasons: %v", err)
+		http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:   "Run Club - Waivers",
+		User:    username,
+		Role:    role,
+		Seasons: seasons,
+		BaseURL: requestBaseURL(r),
+	}
+
+	if season := selectedSeasonFromQuery(r, seasons); season != nil {
+		data.SelectedSeason = season
+		data.SelectedSeasonID = season.ID
+
+		data.Waiver, err = currentWaiver(season.ID)
+		if err != nil {
+			log.Printf("Error getting waiver: %v", err)
+			http.Error(w, "Failed to retrieve waiver", http.StatusInternalServerError)
+			return
+		}
+		if data.Waiver != nil {
+			regs, err := database.GetAllRegistrations(season.ID)
+			if err != nil {
+				log.Printf("Error getting registrations: %v", err)
+				http.Error(w, "Failed to retrieve registrations", http.StatusInternalServerError)
+				return
+			}
+			sort.Slice(regs, func(i, j int) bool {
+				if regs[i].LastName != regs[j].LastName {
+					return regs[i].LastName < regs[j].LastName
+				}
+				return regs[i].FirstName < regs[j].FirstName
+			})
+			data.Registrations = unsignedRegistrations(regs, data.Waiver)
+			data.RegistrationCount = len(regs)
+		}
+	}
+
+	renderTemplate(w, "waivers", data)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+)
+
+func publishTestWaiver(t *testing.T, db *Database, seasonID, body string) *SeasonWaiver {
+	t.Helper()
+	w := &SeasonWaiver{
+		ID:        uuid.New().String(),
+		SeasonID:  seasonID,
+		Body:      body,
+		CreatedAt: time.Now(),
+		CreatedBy: "admin",
+	}
+	if err := db.PublishSeasonWaiver(w, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestConsentFromForm(t *testing.T) {
+	waiver := &SeasonWaiver{Version: 2}
+
+	consent := func(form url.Values, waiver *SeasonWaiver) (*Registration, error) {
+		r := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Fly-Client-IP", "203.0.113.7")
+		reg := &Registration{}
+		return reg, consentFromForm(r, reg, waiver)
+	}
+
+	if _, err := consent(url.Values{}, nil); err != nil {
+		t.Errorf("Expected no consent to be needed without a waiver, got %v", err)
+	}
+
+	reg, err := consent(url.Values{"waiverAgree": {"true"}, "waiverSignature": {" Pat Doe "}, "waiverVersion": {"2"}}, waiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.WaiverVersion != 2 || reg.WaiverSignedName != "Pat Doe" || reg.WaiverSignedIP != "203.0.113.7" || reg.WaiverSignedAt == nil {
+		t.Errorf("Expected the signature to be recorded, got %+v", reg)
+	}
+
+	for name, form := range map[string]url.Values{
+		"not ticked":  {"waiverSignature": {"Pat Doe"}, "waiverVersion": {"2"}},
+		"not signed":  {"waiverAgree": {"true"}, "waiverSignature": {"  "}, "waiverVersion": {"2"}},
+		"old version": {"waiverAgree": {"true"}, "waiverSignature": {"Pat Doe"}, "waiverVersion": {"1"}},
+	} {
+		if _, err := consent(form, waiver); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if _, ok := err.(errRegistrationInput); !ok {
+			t.Errorf("%s: expected an input error, got %v", name, err)
+		}
+	}
+}
+
+func TestSeasonWaivers(t *testing.T) {
+	originalDB := database
+	defer func() { database = originalDB }()
+
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	database = db
+	publicStore = sessions.NewCookieStore([]byte("test-secret"))
+
+	season, _, err := db.GetActiveSeason()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := publishTestWaiver(t, db, season.ID, "Runners may get muddy.")
+	second := publishTestWaiver(t, db, season.ID, "Runners will get muddy.")
+	if first.Version != 1 || second.Version != 2 {
+		t.Fatalf("Expected versions 1 and 2, got %d and %d", first.Version, second.Version)
+	}
+	waivers, err := db.GetCurrentWaivers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current := waivers[season.ID]; current == nil || current.ID != second.ID {
+		t.Fatalf("Expected the latest version to be current, got %+v", current)
+	}
+
+	register := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/public/register?token="+season.RegistrationToken, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		publicRegisterHandler(rr, req)
+		return rr
+	}
+	form := url.Values{
+		"firstName":           {"Anna"},
+		"lastName":            {"Doe"},
+		"grade":               {"2"},
+		"parentFirstName":     {"Pat"},
+		"parentContactNumber": {"555-555-1234"},
+		"parentEmail":         {"pat@example.com"},
+	}
+	if rr := register(form); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected registering without signing the waiver to fail, got %d", rr.Code)
+	}
+
+	form.Set("waiverAgree", "true")
+	form.Set("waiverSignature", "Pat Doe")
+	form.Set("waiverVersion", "2")
+	if rr := register(form); rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the signed registration to be saved, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	regs, err := db.GetAllRegistrations(season.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regs) != 1 || regs[0].WaiverVersion != 2 || regs[0].WaiverSignedName != "Pat Doe" {
+		t.Fatalf("Expected the signature to be saved, got %+v", regs)
+	}
+	if unsigned := unsignedRegistrations(regs, second); len(unsigned) != 0 {
+		t.Errorf("Expected everyone to have signed version 2, got %d unsigned", len(unsigned))
+	}
+
+	third := publishTestWaiver(t, db, season.ID, "Runners will definitely get muddy.")
+	if unsigned := unsignedRegistrations(regs, third); len(unsigned) != 1 {
+		t.Errorf("Expected Anna to need to sign version 3, got %d unsigned", len(unsigned))
+	}
+
+	t.Run("SignWithEditLink", func(t *testing.T) {
+		edit := url.Values{
+			"firstName":           {"Anna"},
+			"lastName":            {"Doe"},
+			"grade":               {"2"},
+			"parentContactNumber": {"555-555-1234"},
+			"waiverAgree":         {"true"},
+			"waiverSignature":     {"Pat Doe"},
+			"waiverVersion":       {"3"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/public/edit?token="+url.QueryEscape(regs[0].EditToken), strings.NewReader(edit.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		publicEditHandler(rr, req)
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected the edit to be saved, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		reg, _, err := db.GetRegistration(regs[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !third.SignedBy(reg) {
+			t.Errorf("Expected the parent to have signed version 3, got version %d", reg.WaiverVersion)
+		}
+	})
+}